@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"simple-go-auth/internal/platform"
+)
+
+// ErrUnknownKey is returned when no key in the set has the token's "kid".
+var ErrUnknownKey = errors.New("signing key not found in JWKS")
+
+// JWKS fetches and caches the public keys published at a JWKS URL (for
+// Cognito, "<issuer>/.well-known/jwks.json"). Keys are refetched every
+// RefreshInterval, and early when a token names a kid we don't have yet, so
+// key rotation needs no restart.
+type JWKS struct {
+	URL    string
+	Client *http.Client
+
+	RefreshInterval    time.Duration // default 1h
+	MinRefreshInterval time.Duration // unknown kids refetch at most this often; default 1m
+
+	mu      sync.RWMutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+// NewJWKS returns a key set for url with default intervals.
+func NewJWKS(url string) *JWKS {
+	return &JWKS{
+		URL:                url,
+		Client:             &http.Client{Timeout: 10 * time.Second},
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Minute,
+	}
+}
+
+// Key returns the public key with the given kid: *rsa.PublicKey or *ecdsa.PublicKey.
+func (k *JWKS) Key(ctx context.Context, kid string) (any, error) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	age := time.Since(k.fetched)
+	k.mu.RUnlock()
+
+	if ok && age < k.RefreshInterval {
+		return key, nil
+	}
+	if ok || age >= k.MinRefreshInterval {
+		if err := k.Refresh(ctx); err != nil {
+			// Keep serving known keys while the endpoint is unreachable
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Refresh refetches the key set.
+func (k *JWKS) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	keys, err := platform.ParseJWKS(body)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys, k.fetched = keys, time.Now()
+	k.mu.Unlock()
+	return nil
+}
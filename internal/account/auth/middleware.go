@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NewMiddleware requires a valid bearer token on every route except the
+// public paths, and makes the caller available through PrincipalFromContext.
+func NewMiddleware(v *Validator, public ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, path := range public {
+				if c.Request().URL.Path == path {
+					return next(c)
+				}
+			}
+
+			header := c.Request().Header.Get("Authorization")
+			raw, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || raw == "" {
+				c.Response().Header().Set("WWW-Authenticate", `Bearer`)
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+			}
+			p, err := v.Validate(c.Request().Context(), raw)
+			if err != nil {
+				c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token").SetInternal(err)
+			}
+			c.Set(principalKey, p)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const principalKey = "principal"
+
+// Principal is the caller of a request, taken from a validated token.
+type Principal struct {
+	Subject  string   `json:"sub"`
+	Username string   `json:"username"`
+	Issuer   string   `json:"iss"`
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// Service is set for internal HS256 service tokens.
+	Service bool `json:"service"`
+	// Client is set for OAuth client_credentials tokens, which act for a
+	// client rather than a user.
+	Client bool          `json:"client,omitempty"`
+	Claims jwt.MapClaims `json:"-"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// PrincipalFromContext returns the caller set by NewMiddleware, or nil on public routes.
+func PrincipalFromContext(c echo.Context) *Principal {
+	p, _ := c.Get(principalKey).(*Principal)
+	return p
+}
+
+// newPrincipal maps the claims of Cognito ID/access tokens, the users
+// service's native tokens and internal service tokens onto a Principal.
+func newPrincipal(claims jwt.MapClaims, service bool) *Principal {
+	str := func(name string) string {
+		s, _ := claims[name].(string)
+		return s
+	}
+	p := &Principal{
+		Subject:  str("sub"),
+		Username: str("cognito:username"),
+		Issuer:   str("iss"),
+		ClientID: str("client_id"),
+		Service:  service,
+		Client:   str("token_use") == "client",
+		Claims:   claims,
+	}
+	if p.Username == "" {
+		p.Username = str("username")
+	}
+	if p.ClientID == "" {
+		p.ClientID = str("azp")
+	}
+	if scope := str("scope"); scope != "" {
+		p.Scopes = strings.Fields(scope)
+	}
+	if groups, ok := claims["cognito:groups"].([]any); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				p.Groups = append(p.Groups, s)
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Validation errors.
+var (
+	ErrIssuerNotAllowed   = errors.New("token issuer not allowed")
+	ErrAudienceNotAllowed = errors.New("token audience not allowed")
+)
+
+// Validator checks bearer tokens. User tokens (RS256/ES256) are verified
+// against Keys and must come from one of Issuers. A token naming an audience,
+// in "aud" (ID tokens) or "client_id" (Cognito access tokens), must name one
+// of Audiences; with none configured such tokens are refused. Only the users
+// service's native access tokens name none. Internal service tokens are HS256
+// with ServiceSecret; leave it empty to refuse them.
+type Validator struct {
+	Keys          *JWKS
+	Issuers       []string
+	Audiences     []string
+	ServiceSecret []byte
+	Leeway        time.Duration
+}
+
+// Validate verifies raw and returns its principal.
+func (v *Validator) Validate(ctx context.Context, raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	tok, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		switch t.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			if len(v.ServiceSecret) == 0 {
+				return nil, errors.New("service tokens not accepted")
+			}
+			return v.ServiceSecret, nil
+		default:
+			if v.Keys == nil {
+				return nil, errors.New("no JWKS configured")
+			}
+			kid, _ := t.Header["kid"].(string)
+			return v.Keys.Key(ctx, kid)
+		}
+	},
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodES256.Alg(),
+			jwt.SigningMethodHS256.Alg(),
+		}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.Leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	service := tok.Method.Alg() == jwt.SigningMethodHS256.Alg()
+	if !service {
+		if err := v.checkIssuerAndAudience(claims); err != nil {
+			return nil, err
+		}
+	}
+	return newPrincipal(claims, service), nil
+}
+
+func (v *Validator) checkIssuerAndAudience(claims jwt.MapClaims) error {
+	iss, _ := claims.GetIssuer()
+	if !slices.Contains(v.Issuers, iss) {
+		return fmt.Errorf("%w: %q", ErrIssuerNotAllowed, iss)
+	}
+	aud, _ := claims.GetAudience()
+	if clientID, ok := claims["client_id"].(string); ok {
+		aud = append(aud, clientID)
+	}
+	// A token a user delegated to a client is for that client, never for us
+	// by default, so it is held to the allowlist even without an audience
+	if len(aud) == 0 && claims["token_use"] != "delegated" {
+		return nil
+	}
+	for _, a := range aud {
+		if slices.Contains(v.Audiences, a) {
+			return nil
+		}
+	}
+	return ErrAudienceNotAllowed
+}
@@ -1,43 +1,103 @@
 package main
 
 import (
+	"log"
 	"net/http"
+	"time"
+
+	"simple-go-auth/internal/account/auth"
 	"simple-go-auth/internal/account/config"
-	"simple-go-auth/internal/account/logger"
+	"simple-go-auth/internal/account/profile"
+	"simple-go-auth/internal/platform"
 
-	"github.com/brpaz/echozap"
-	jwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
-func NewServer() *echo.Echo {
-	// Load config & Zap
-	config.Init()
-	logger.Init() // sets logger.Logger (*zap.Logger)
-
-	e := echo.New()
-
-	// 1) Recovery middleware so panics become proper 5xx + a log line
-	e.Use(middleware.Recover())
-
-	// 2) Zap request‐logging middleware
-	e.Use(echozap.ZapLogger(logger.Logger))
+// NewServer builds the account service on the platform Echo instance.
+func NewServer(cfg *config.Config, logger *zap.Logger) (*echo.Echo, error) {
+	// 1) Recovery, tracing, request IDs and request logging
+	e := platform.NewEcho(cfg.ServiceName, logger)
 
-	e.Use(jwt.WithConfig(jwt.Config{
-		SigningKey: []byte(config.Cfg.JWTSecret),
-	}))
+	// 2) Bearer tokens: user tokens through the JWKS, HS256 for internal services
+	validator := &auth.Validator{
+		Issuers:   cfg.JWTIssuers,
+		Audiences: cfg.JWTAudiences,
+		Leeway:    30 * time.Second,
+	}
+	if cfg.JWTSecret != "" {
+		validator.ServiceSecret = []byte(cfg.JWTSecret)
+	}
+	if cfg.JWKSURL != "" {
+		validator.Keys = auth.NewJWKS(cfg.JWKSURL)
+		validator.Keys.RefreshInterval = cfg.JWKSRefreshInterval
+	}
+	e.Use(auth.NewMiddleware(validator, "/healthz"))
 
-	// 4) Health‐check
+	// 3) Health‐check
 	e.GET("/healthz", func(c echo.Context) error {
-		logger.Logger.Info("Health check invoked")
 		return c.JSON(http.StatusOK, map[string]string{"status": "OK"})
 	})
 
-	return e
+	// 4) Profiles: Postgres, read through Redis
+	gormDB, err := gorm.Open(postgres.Open(cfg.DBDSN), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := profile.Migrate(gormDB); err != nil {
+		return nil, err
+	}
+	redisOpts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	profiles := &profile.Handler{
+		Service: &profile.Service{
+			DB:     gormDB,
+			Cache:  &profile.Cache{Client: redis.NewClient(redisOpts), TTL: cfg.ProfileCacheTTL},
+			Logger: logger.Named("profile"),
+		},
+		Subject: func(c echo.Context) string {
+			// Profiles belong to users; OAuth clients have none.
+			if p := auth.PrincipalFromContext(c); !p.Client {
+				return p.Subject
+			}
+			return ""
+		},
+	}
+	profiles.RegisterRoutes(e)
+
+	return e, nil
 }
 
 func main() {
-	e := NewServer()
-	e.Logger.Fatal(e.Start(":" + config.Cfg.Port))
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	logger, err := platform.NewLogger(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	ctx, stop := platform.SignalContext()
+	defer stop()
+
+	shutdown, err := platform.InitTelemetry(ctx, cfg.Telemetry)
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer shutdown()
+
+	e, err := NewServer(cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to build server", zap.Error(err))
+	}
+	if err := platform.Serve(ctx, e, cfg.Server, logger); err != nil {
+		logger.Fatal("Server failed", zap.Error(err))
+	}
 }
@@ -1,56 +1,88 @@
 package config
 
 import (
-	"context"
-	"encoding/json"
-	"log"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/spf13/viper"
+	"errors"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/platform"
 )
 
 type Config struct {
-	Port      string
-	DBDSN     string
-	JWTSecret string
-	RedisURL  string
+	platform.Base
+	DBDSN           string
+	JWTSecret       string
+	RedisURL        string
+	ProfileCacheTTL time.Duration
+
+	// User tokens: Cognito (or users-service native) RS256/ES256 JWTs
+	JWTIssuers          []string      // allowed "iss" values, space-separated
+	JWTAudiences        []string      // allowed "aud"/"client_id" values; required for Cognito tokens
+	JWKSURL             string        // default "<first issuer>/.well-known/jwks.json"
+	JWKSRefreshInterval time.Duration // default 1h
+
+	SecretsProvider string // aws or env; default "aws"
+	SecretID        string // default "account-service-secrets"
+	AWSRegion       string // default from the AWS SDK environment
 }
 
-var Cfg Config
+// Load reads ACCOUNT_* environment variables, then db_dsn and jwt_secret
+// from the service's JSON secret.
+func Load() (*Config, error) {
+	v := platform.NewEnv("ACCOUNT")
+	cfg := &Config{
+		Base:                platform.LoadBase(v, "account-service", "8080"),
+		RedisURL:            v.GetString("REDIS_URL"),
+		ProfileCacheTTL:     v.GetDuration("PROFILE_CACHE_TTL"),
+		JWTIssuers:          strings.Fields(v.GetString("JWT_ISSUERS")),
+		JWTAudiences:        strings.Fields(v.GetString("JWT_AUDIENCES")),
+		JWKSURL:             v.GetString("JWKS_URL"),
+		JWKSRefreshInterval: v.GetDuration("JWKS_REFRESH_INTERVAL"),
+		SecretsProvider:     v.GetString("SECRETS_PROVIDER"),
+		SecretID:            v.GetString("SECRET_ID"),
+		AWSRegion:           v.GetString("AWS_REGION"),
+	}
 
-func Init() {
-	viper.SetEnvPrefix("ACCOUNT")
-	viper.AutomaticEnv()
-	Cfg.Port = viper.GetString("PORT")
-	if Cfg.Port == "" {
-		Cfg.Port = "8080"
+	if cfg.RedisURL == "" {
+		return nil, errors.New("ACCOUNT_REDIS_URL must be set")
+	}
+	if cfg.ProfileCacheTTL == 0 {
+		cfg.ProfileCacheTTL = 5 * time.Minute
 	}
-	Cfg.RedisURL = viper.GetString("REDIS_URL")
-	if Cfg.RedisURL == "" {
-		log.Fatal("REDIS_URL must be set")
+	if cfg.JWKSURL == "" && len(cfg.JWTIssuers) > 0 {
+		cfg.JWKSURL = strings.TrimSuffix(cfg.JWTIssuers[0], "/") + "/.well-known/jwks.json"
+	}
+	if cfg.JWKSRefreshInterval == 0 {
+		cfg.JWKSRefreshInterval = time.Hour
+	}
+	if cfg.SecretsProvider == "" {
+		cfg.SecretsProvider = "aws"
+	}
+	if cfg.SecretID == "" {
+		cfg.SecretID = "account-service-secrets"
 	}
 
-	// Load AWS Secrets Manager
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	// Secrets: one JSON document in Secrets Manager, or ACCOUNT_DB_DSN and
+	// ACCOUNT_JWT_SECRET with the env provider
+	secrets, err := platform.NewSecrets(cfg.SecretsProvider, cfg.AWSRegion, v)
 	if err != nil {
-		log.Fatalf("AWS config load error: %v", err)
+		return nil, err
 	}
-	sm := secretsmanager.NewFromConfig(awsCfg)
-	secOut, err := sm.GetSecretValue(context.TODO(), &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String("account-service-secrets"),
-	})
-	if err != nil {
-		log.Fatalf("unable to fetch secrets: %v", err)
+	if cfg.SecretsProvider == "env" {
+		if cfg.DBDSN, err = secrets.GetSecret("DB_DSN"); err != nil {
+			return nil, err
+		}
+		cfg.JWTSecret, _ = secrets.GetSecret("JWT_SECRET") // optional: no internal service tokens
+		return cfg, nil
 	}
 	var s struct {
 		DBDSN     string `json:"db_dsn"`
 		JWTSecret string `json:"jwt_secret"`
 	}
-	if err := json.Unmarshal([]byte(*secOut.SecretString), &s); err != nil {
-		log.Fatalf("invalid secret JSON: %v", err)
+	if err := platform.JSONSecret(secrets, cfg.SecretID, &s); err != nil {
+		return nil, err
 	}
-	Cfg.DBDSN = s.DBDSN
-	Cfg.JWTSecret = s.JWTSecret
+	cfg.DBDSN = s.DBDSN
+	cfg.JWTSecret = s.JWTSecret
+	return cfg, nil
 }
@@ -0,0 +1,48 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache keeps serialized profiles in Redis under "profile:<sub>".
+type Cache struct {
+	Client *redis.Client
+	TTL    time.Duration
+}
+
+func cacheKey(sub string) string { return "profile:" + sub }
+
+// Get returns the cached profile, or (nil, nil) on a miss.
+func (c *Cache) Get(ctx context.Context, sub string) (*Profile, error) {
+	b, err := c.Client.Get(ctx, cacheKey(sub)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Profile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Set caches p for TTL.
+func (c *Cache) Set(ctx context.Context, p *Profile) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return c.Client.Set(ctx, cacheKey(p.Subject), b, c.TTL).Err()
+}
+
+// Delete evicts sub.
+func (c *Cache) Delete(ctx context.Context, sub string) error {
+	return c.Client.Del(ctx, cacheKey(sub)).Err()
+}
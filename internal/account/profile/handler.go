@@ -0,0 +1,129 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler serves /me for the caller identified by Subject.
+type Handler struct {
+	Service *Service
+	// Subject returns the authenticated caller's subject, "" if none.
+	Subject func(echo.Context) string
+}
+
+// RegisterRoutes mounts GET, PATCH and DELETE /me.
+func (h *Handler) RegisterRoutes(e *echo.Echo) {
+	e.GET("/me", h.Get)
+	e.PATCH("/me", h.Patch)
+	e.DELETE("/me", h.Delete)
+}
+
+// Get returns the caller's profile with its ETag; If-None-Match yields 304.
+func (h *Handler) Get(c echo.Context) error {
+	sub, err := h.subject(c)
+	if err != nil {
+		return err
+	}
+	p, err := h.Service.Get(c.Request().Context(), sub)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("ETag", p.ETag())
+	if match := c.Request().Header.Get("If-None-Match"); match != "" && etagListContains(match, p.ETag()) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// Patch applies a JSON merge patch. If-Match is required.
+func (h *Handler) Patch(c echo.Context) error {
+	sub, err := h.subject(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	var patch Patch
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "body must be a JSON object")
+	}
+	p, err := h.Service.Update(c.Request().Context(), sub, version, patch)
+	if err != nil {
+		return mapError(err)
+	}
+	c.Response().Header().Set("ETag", p.ETag())
+	return c.JSON(http.StatusOK, p)
+}
+
+// Delete removes the caller's profile. If-Match is required.
+func (h *Handler) Delete(c echo.Context) error {
+	sub, err := h.subject(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	if err := h.Service.Delete(c.Request().Context(), sub, version); err != nil {
+		return mapError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) subject(c echo.Context) (string, error) {
+	sub := h.Subject(c)
+	if sub == "" {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "token has no subject")
+	}
+	return sub, nil
+}
+
+// ifMatch parses If-Match into a version; "*" means any (0). Weak tags never
+// match, as If-Match uses strong comparison.
+func ifMatch(c echo.Context) (int64, error) {
+	v := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	switch {
+	case v == "":
+		return 0, echo.NewHTTPError(http.StatusPreconditionRequired, "If-Match is required")
+	case v == "*":
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64)
+	if err != nil || !strings.HasPrefix(v, `"`) || n < 1 {
+		return 0, echo.NewHTTPError(http.StatusPreconditionFailed, "If-Match does not match the current version")
+	}
+	return n, nil
+}
+
+// etagListContains reports whether an If-None-Match list matches etag
+// (weak comparison).
+func etagListContains(list, etag string) bool {
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func mapError(err error) error {
+	switch {
+	case errors.Is(err, ErrVersionMismatch):
+		return echo.NewHTTPError(http.StatusPreconditionFailed, "If-Match does not match the current version")
+	case errors.Is(err, ErrInvalidDisplayName), errors.Is(err, ErrInvalidAvatarURL),
+		errors.Is(err, ErrInvalidLocale), errors.Is(err, ErrInvalidTimezone),
+		errors.Is(err, ErrInvalidPreferences):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+	_ "time/tzdata" // timezone validation without system zoneinfo
+
+	"golang.org/x/text/language"
+)
+
+// Validation errors from Patch.Apply.
+var (
+	ErrInvalidDisplayName = errors.New("display_name must be at most 100 characters")
+	ErrInvalidAvatarURL   = errors.New("avatar_url must be an absolute https URL")
+	ErrInvalidLocale      = errors.New("locale must be a BCP 47 language tag")
+	ErrInvalidTimezone    = errors.New("timezone must be an IANA time zone name")
+	ErrInvalidPreferences = errors.New("preferences must be an object")
+)
+
+// maxDisplayName bounds DisplayName in runes.
+const maxDisplayName = 100
+
+// Patch is a JSON merge patch (RFC 7396) of a Profile: absent fields are
+// kept, null clears a field, and preferences are merged key by key.
+type Patch map[string]json.RawMessage
+
+// Apply validates the patch and applies it to p.
+func (patch Patch) Apply(p *Profile) error {
+	for field, raw := range patch {
+		null := string(raw) == "null"
+		switch field {
+		case "display_name", "avatar_url", "locale", "timezone":
+			var v string
+			if !null {
+				if err := json.Unmarshal(raw, &v); err != nil {
+					return fieldError(field)
+				}
+			}
+			if err := setString(p, field, v); err != nil {
+				return err
+			}
+		case "preferences":
+			if null {
+				p.Preferences = nil
+				continue
+			}
+			var prefs map[string]any
+			if err := json.Unmarshal(raw, &prefs); err != nil || prefs == nil {
+				return ErrInvalidPreferences
+			}
+			if p.Preferences == nil {
+				p.Preferences = map[string]any{}
+			}
+			for k, v := range prefs {
+				if v == nil {
+					delete(p.Preferences, k)
+				} else {
+					p.Preferences[k] = v
+				}
+			}
+		}
+		// Unknown and read-only fields (sub, version, ...) are ignored
+	}
+	return nil
+}
+
+func setString(p *Profile, field, v string) error {
+	switch field {
+	case "display_name":
+		if len([]rune(v)) > maxDisplayName {
+			return ErrInvalidDisplayName
+		}
+		p.DisplayName = v
+	case "avatar_url":
+		if v != "" {
+			u, err := url.Parse(v)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				return ErrInvalidAvatarURL
+			}
+		}
+		p.AvatarURL = v
+	case "locale":
+		if v != "" {
+			tag, err := language.Parse(v)
+			if err != nil {
+				return ErrInvalidLocale
+			}
+			v = tag.String()
+		}
+		p.Locale = v
+	case "timezone":
+		if v != "" {
+			if _, err := time.LoadLocation(v); err != nil || v == "Local" {
+				return ErrInvalidTimezone
+			}
+		}
+		p.Timezone = v
+	}
+	return nil
+}
+
+func fieldError(field string) error {
+	switch field {
+	case "display_name":
+		return ErrInvalidDisplayName
+	case "avatar_url":
+		return ErrInvalidAvatarURL
+	case "locale":
+		return ErrInvalidLocale
+	default:
+		return ErrInvalidTimezone
+	}
+}
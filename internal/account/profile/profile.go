@@ -0,0 +1,37 @@
+// Package profile stores user profiles for the account service, keyed by the
+// JWT subject, with a Redis read-through cache.
+package profile
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Profile is a user's editable profile. Version increases on every change
+// and is exposed as the ETag.
+type Profile struct {
+	Subject     string         `gorm:"primaryKey;size:255" json:"sub"`
+	DisplayName string         `gorm:"size:100;default:''" json:"display_name"`
+	AvatarURL   string         `gorm:"size:2048;default:''" json:"avatar_url"`
+	Locale      string         `gorm:"size:35;default:''" json:"locale"`
+	Timezone    string         `gorm:"size:64;default:''" json:"timezone"`
+	Preferences map[string]any `gorm:"type:text;serializer:json" json:"preferences"`
+	Version     int64          `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// TableName keeps the table name stable.
+func (Profile) TableName() string { return "profiles" }
+
+// ETag is the strong entity tag for this version.
+func (p *Profile) ETag() string {
+	return `"` + strconv.FormatInt(p.Version, 10) + `"`
+}
+
+// Migrate creates the profiles table.
+func Migrate(gormDB *gorm.DB) error {
+	return gormDB.AutoMigrate(&Profile{})
+}
@@ -0,0 +1,126 @@
+package profile
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVersionMismatch is returned when If-Match names an outdated version.
+var ErrVersionMismatch = errors.New("profile was modified")
+
+// Service reads profiles through the cache and writes them to the database.
+type Service struct {
+	DB     *gorm.DB
+	Cache  *Cache // nil disables caching
+	Logger *zap.Logger
+}
+
+// Get returns the profile of sub, creating an empty one on first access.
+func (s *Service) Get(ctx context.Context, sub string) (*Profile, error) {
+	// 1) Cache
+	if s.Cache != nil {
+		p, err := s.Cache.Get(ctx, sub)
+		if err != nil {
+			s.Logger.Warn("profile cache read failed", zap.Error(err))
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+
+	// 2) Database, creating the row the first time
+	p := &Profile{Subject: sub, Version: 1}
+	if err := s.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(p).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.WithContext(ctx).Where("subject = ?", sub).First(p).Error; err != nil {
+		return nil, err
+	}
+
+	// 3) Fill the cache
+	s.cacheSet(ctx, p)
+	return p, nil
+}
+
+// Update applies patch to the profile of sub if it is still at version;
+// version 0 skips the check.
+func (s *Service) Update(ctx context.Context, sub string, version int64, patch Patch) (*Profile, error) {
+	p, err := s.fresh(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && p.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	if err := patch.Apply(p); err != nil {
+		return nil, err
+	}
+
+	// Compare-and-swap on the version read above
+	next := *p
+	next.Version++
+	res := s.DB.WithContext(ctx).Model(&Profile{}).
+		Where("subject = ? AND version = ?", sub, p.Version).
+		Select("display_name", "avatar_url", "locale", "timezone", "preferences", "version").
+		Updates(&next)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrVersionMismatch
+	}
+	s.cacheDelete(ctx, sub)
+	return s.fresh(ctx, sub)
+}
+
+// Delete removes the profile of sub if it is still at version; version 0
+// skips the check.
+func (s *Service) Delete(ctx context.Context, sub string, version int64) error {
+	q := s.DB.WithContext(ctx).Where("subject = ?", sub)
+	if version != 0 {
+		q = q.Where("version = ?", version)
+	}
+	res := q.Delete(&Profile{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 && version != 0 {
+		return ErrVersionMismatch
+	}
+	s.cacheDelete(ctx, sub)
+	return nil
+}
+
+// fresh reads sub from the database, bypassing the cache.
+func (s *Service) fresh(ctx context.Context, sub string) (*Profile, error) {
+	var p Profile
+	err := s.DB.WithContext(ctx).Where("subject = ?", sub).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Get(ctx, sub)
+	}
+	return &p, err
+}
+
+func (s *Service) cacheSet(ctx context.Context, p *Profile) {
+	if s.Cache == nil {
+		return
+	}
+	if err := s.Cache.Set(ctx, p); err != nil {
+		s.Logger.Warn("profile cache write failed", zap.Error(err))
+	}
+}
+
+func (s *Service) cacheDelete(ctx context.Context, sub string) {
+	if s.Cache == nil {
+		return
+	}
+	if err := s.Cache.Delete(ctx, sub); err != nil {
+		s.Logger.Warn("profile cache eviction failed", zap.Error(err))
+	}
+}
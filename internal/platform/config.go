@@ -0,0 +1,105 @@
+// Package platform is the bootstrap code shared by the users and account
+// services: configuration, logging, secrets, telemetry and the HTTP server.
+package platform
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// NewEnv returns a viper that reads PREFIX_NAME environment variables (plain
+// NAME with an empty prefix) and, when present, the .env file in the working
+// directory. Each service uses its own instance so prefixes don't clash.
+func NewEnv(prefix string) *viper.Viper {
+	v := viper.New()
+	if prefix != "" {
+		v.SetEnvPrefix(prefix)
+	}
+	v.AutomaticEnv()
+	v.SetConfigFile(".env")
+	v.SetConfigType("env")
+	_ = v.ReadInConfig()
+	return v
+}
+
+// Base is the configuration every service has.
+type Base struct {
+	ServiceName    string // default the service's own name
+	ServiceVersion string // default "dev"
+	Env            string // default "production"
+	Log            LogConfig
+	Telemetry      TelemetryConfig
+	Server         ServerConfig
+}
+
+// LoadBase reads the shared settings from v:
+//
+//	OTEL_SERVICE_NAME, SERVICE_VERSION, ENV, PORT, LOG_LEVEL, LOG_SAMPLING,
+//	OTEL_EXPORTER, OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_INSECURE,
+//	OTEL_TRACES_SAMPLER_RATIO, ECHO_READ_TIMEOUT, ECHO_WRITE_TIMEOUT,
+//	SHUTDOWN_TIMEOUT, TLS_CERT_FILE, TLS_KEY_FILE
+func LoadBase(v *viper.Viper, serviceName, defaultPort string) Base {
+	b := Base{
+		ServiceName:    v.GetString("OTEL_SERVICE_NAME"),
+		ServiceVersion: v.GetString("SERVICE_VERSION"),
+		Env:            v.GetString("ENV"),
+		Log: LogConfig{
+			Level:    v.GetString("LOG_LEVEL"),
+			Sampling: true,
+		},
+		Telemetry: TelemetryConfig{
+			Exporter:    v.GetString("OTEL_EXPORTER"),
+			Endpoint:    v.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			Insecure:    v.GetBool("OTEL_EXPORTER_OTLP_INSECURE"),
+			SampleRatio: 1.0,
+		},
+		Server: ServerConfig{
+			Port:            v.GetString("PORT"),
+			ReadTimeout:     v.GetDuration("ECHO_READ_TIMEOUT"),
+			WriteTimeout:    v.GetDuration("ECHO_WRITE_TIMEOUT"),
+			ShutdownTimeout: v.GetDuration("SHUTDOWN_TIMEOUT"),
+			TLSCertFile:     v.GetString("TLS_CERT_FILE"),
+			TLSKeyFile:      v.GetString("TLS_KEY_FILE"),
+		},
+	}
+	if v.IsSet("LOG_SAMPLING") {
+		b.Log.Sampling = v.GetBool("LOG_SAMPLING")
+	}
+	if v.IsSet("OTEL_TRACES_SAMPLER_RATIO") {
+		b.Telemetry.SampleRatio = v.GetFloat64("OTEL_TRACES_SAMPLER_RATIO")
+	}
+
+	if b.ServiceName == "" {
+		b.ServiceName = serviceName
+	}
+	if b.ServiceVersion == "" {
+		b.ServiceVersion = "dev"
+	}
+	if b.Env == "" {
+		b.Env = "production"
+	}
+	if b.Log.Level == "" {
+		b.Log.Level = "info"
+	}
+	if b.Telemetry.Exporter == "" {
+		b.Telemetry.Exporter = "stdout"
+	}
+	if b.Server.Port == "" {
+		b.Server.Port = defaultPort
+	}
+	if b.Server.ReadTimeout == 0 {
+		b.Server.ReadTimeout = 5 * time.Second
+	}
+	if b.Server.WriteTimeout == 0 {
+		b.Server.WriteTimeout = 10 * time.Second
+	}
+	if b.Server.ShutdownTimeout == 0 {
+		b.Server.ShutdownTimeout = 15 * time.Second
+	}
+	b.Log.Development = b.Env == "development"
+	b.Telemetry.ServiceName = b.ServiceName
+	b.Telemetry.ServiceVersion = b.ServiceVersion
+	b.Telemetry.Env = b.Env
+	return b
+}
@@ -0,0 +1,37 @@
+package platform
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogConfig configures NewLogger.
+type LogConfig struct {
+	Level       string // debug, info, warn or error; default "info"
+	Sampling    bool   // drop repeats of the same message beyond 100/s
+	Development bool   // console output with stack traces on warnings
+}
+
+// NewLogger builds a JSON (or, in development, console) zap logger.
+func NewLogger(cfg LogConfig) (*zap.Logger, error) {
+	zc := zap.NewProductionConfig()
+	if cfg.Development {
+		zc = zap.NewDevelopmentConfig()
+	}
+
+	level := zapcore.InfoLevel
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL %q: %w", cfg.Level, err)
+		}
+	}
+	zc.Level = zap.NewAtomicLevelAt(level)
+
+	zc.Sampling = nil
+	if cfg.Sampling {
+		zc.Sampling = &zap.SamplingConfig{Initial: 100, Thereafter: 100}
+	}
+	return zc.Build()
+}
@@ -0,0 +1,101 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sdkconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/spf13/viper"
+)
+
+// Secrets is how services fetch secrets.
+type Secrets interface {
+	GetSecret(name string) (string, error)
+}
+
+// NewSecrets returns the provider named by kind: "aws" (Secrets Manager in
+// region) or "env" (environment variables and .env through v).
+func NewSecrets(kind, region string, v *viper.Viper) (Secrets, error) {
+	switch kind {
+	case "aws":
+		return NewAWSSecrets(region), nil
+	case "env":
+		return &EnvSecrets{Env: v}, nil
+	}
+	return nil, fmt.Errorf("unknown SECRETS_PROVIDER %q", kind)
+}
+
+// JSONSecret fetches a secret holding a JSON object and decodes it into out.
+func JSONSecret(s Secrets, name string, out any) error {
+	raw, err := s.GetSecret(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(raw), out); err != nil {
+		return fmt.Errorf("secret %s: invalid JSON: %w", name, err)
+	}
+	return nil
+}
+
+// AWSSecrets reads secrets from AWS Secrets Manager.
+type AWSSecrets struct {
+	Region string
+}
+
+// NewAWSSecrets returns Secrets backed by Secrets Manager in region.
+func NewAWSSecrets(region string) *AWSSecrets {
+	return &AWSSecrets{Region: region}
+}
+
+// GetSecret retrieves a secret string.
+func (a *AWSSecrets) GetSecret(name string) (string, error) {
+	opts := []func(*sdkconfig.LoadOptions) error{}
+	if a.Region != "" {
+		opts = append(opts, sdkconfig.WithRegion(a.Region))
+	}
+	cfg, err := sdkconfig.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return "", errors.New("unable to load AWS SDK config: " + err.Error())
+	}
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(context.TODO(), &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", errors.New("unable to retrieve secret: " + err.Error())
+	}
+	if out.SecretString == nil {
+		return "", errors.New("secret value is empty")
+	}
+	return *out.SecretString, nil
+}
+
+// EnvSecrets reads secrets from configuration; for development and tests.
+// A nil Env reads the process environment only.
+type EnvSecrets struct {
+	Env *viper.Viper
+}
+
+// GetSecret retrieves a secret value.
+func (e *EnvSecrets) GetSecret(name string) (string, error) {
+	var val string
+	if e.Env != nil {
+		val = e.Env.GetString(name)
+	} else {
+		val = os.Getenv(name)
+	}
+	if val == "" {
+		return "", fmt.Errorf("secret %s not set", name)
+	}
+	return val, nil
+}
+
+// Compile-time checks that both providers implement Secrets.
+var (
+	_ Secrets = (*AWSSecrets)(nil)
+	_ Secrets = (*EnvSecrets)(nil)
+)
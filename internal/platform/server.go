@@ -0,0 +1,114 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.uber.org/zap"
+)
+
+// ServerConfig configures Serve.
+type ServerConfig struct {
+	Port            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration // how long in-flight requests get on shutdown
+	TLSCertFile     string        // with TLSKeyFile, serve HTTPS with HTTP/2
+	TLSKeyFile      string
+}
+
+// NewEcho returns an Echo instance with the standard middleware, in order:
+// panic recovery, a server span per request, X-Request-ID and request logging.
+func NewEcho(serviceName string, logger *zap.Logger) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+
+	e.Use(middleware.Recover())
+	e.Use(otelecho.Middleware(serviceName))
+	e.Use(RequestID())
+	e.Use(RequestLogger(logger))
+	return e
+}
+
+// RequestID adds a unique ID to each request and response header.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := uuid.New().String()
+			c.Response().Header().Set("X-Request-ID", id)
+			c.Set("request_id", id)
+			return next(c)
+		}
+	}
+}
+
+// RequestLogger logs one line per request, including the request ID.
+func RequestLogger(logger *zap.Logger) echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogURI:    true,
+		LogStatus: true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			id, _ := c.Get("request_id").(string)
+			logger.Info("request",
+				zap.String("request_id", id),
+				zap.String("method", v.Method),
+				zap.String("uri", v.URI),
+				zap.Int("status", v.Status),
+			)
+			return nil
+		},
+	})
+}
+
+// SignalContext is cancelled on SIGINT or SIGTERM.
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// Serve runs e until ctx is cancelled, then stops accepting connections and
+// waits up to ShutdownTimeout for in-flight requests. It returns nil after a
+// clean shutdown.
+func Serve(ctx context.Context, e *echo.Echo, cfg ServerConfig, logger *zap.Logger) error {
+	for _, s := range []*http.Server{e.Server, e.TLSServer} {
+		s.ReadTimeout = cfg.ReadTimeout
+		s.WriteTimeout = cfg.WriteTimeout
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		addr := ":" + cfg.Port
+		logger.Info("server listening", zap.String("addr", addr), zap.Bool("tls", cfg.TLSCertFile != ""))
+		if cfg.TLSCertFile != "" {
+			errc <- e.StartTLS(addr, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			errc <- e.Start(addr)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down", zap.Duration("timeout", cfg.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
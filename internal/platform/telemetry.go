@@ -0,0 +1,112 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// TelemetryConfig configures InitTelemetry.
+type TelemetryConfig struct {
+	ServiceName    string
+	ServiceVersion string
+	Env            string
+	Exporter       string  // otlp-grpc, otlp-http, stdout or none
+	Endpoint       string  // collector host:port or URL for the OTLP exporters
+	Insecure       bool    // plaintext to the collector
+	SampleRatio    float64 // share of new root traces sampled
+}
+
+// InitTelemetry sets up the configured span exporter, a parent-based ratio
+// sampler and W3C trace-context/baggage propagation. The returned func
+// flushes pending spans and must be called on shutdown.
+func InitTelemetry(ctx context.Context, cfg TelemetryConfig) (func(), error) {
+	// Always propagate, even with exporting disabled, so traces stay
+	// connected across services.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exp, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(cfg.ServiceVersion),
+			semconv.DeploymentEnvironment(cfg.Env),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+
+	return func() { _ = tp.Shutdown(context.Background()) }, nil
+}
+
+// newExporter returns the exporter selected by cfg.Exporter; nil for "none".
+func newExporter(ctx context.Context, cfg TelemetryConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp-grpc":
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			if strings.Contains(cfg.Endpoint, "://") {
+				opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+			} else {
+				opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+			}
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "otlp-http":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			if u, err := url.Parse(cfg.Endpoint); err == nil && strings.Contains(cfg.Endpoint, "://") {
+				// Like OTEL_EXPORTER_OTLP_ENDPOINT, a bare base URL gets the traces path.
+				if u.Path == "" || u.Path == "/" {
+					u.Path = "/v1/traces"
+				}
+				opts = append(opts, otlptracehttp.WithEndpointURL(u.String()))
+			} else {
+				opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+			}
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTEL_EXPORTER %q", cfg.Exporter)
+	}
+}
@@ -0,0 +1,89 @@
+// Package audit records security-relevant events.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is one entry of the audit trail.
+type Event struct {
+	Time    time.Time      `json:"time"`
+	Action  string         `json:"action"` // dotted, e.g. "mfa.recovery_code.used"
+	Actor   string         `json:"actor"`  // who did it; the username for self-service actions
+	Subject string         `json:"subject"`
+	IP      string         `json:"ip,omitempty"`
+	Outcome string         `json:"outcome"`
+	Details map[string]any `json:"details,omitempty"`
+	Tenant  string         `json:"tenant,omitempty"` // owner of the event; only its webhooks receive it
+}
+
+// Recorder stores audit events.
+type Recorder interface {
+	Record(ctx context.Context, e Event) error
+}
+
+// LogRecorder writes events to a zap logger.
+type LogRecorder struct {
+	Logger *zap.Logger
+}
+
+var _ Recorder = (*LogRecorder)(nil)
+
+// NewLogRecorder returns a Recorder that logs under the "audit" name.
+func NewLogRecorder(logger *zap.Logger) *LogRecorder {
+	return &LogRecorder{Logger: logger.Named("audit")}
+}
+
+// Record logs e at info level.
+func (r *LogRecorder) Record(_ context.Context, e Event) error {
+	r.Logger.Info(e.Action,
+		zap.Time("time", e.Time),
+		zap.String("actor", e.Actor),
+		zap.String("subject", e.Subject),
+		zap.String("ip", e.IP),
+		zap.String("outcome", e.Outcome),
+		zap.Any("details", e.Details),
+	)
+	return nil
+}
+
+// Multi records every event with each Recorder in turn and returns the
+// first error.
+type Multi []Recorder
+
+var _ Recorder = Multi(nil)
+
+// Record implements Recorder.
+func (m Multi) Record(ctx context.Context, e Event) error {
+	var first error
+	for _, r := range m {
+		if err := r.Record(ctx, e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// FindStore returns the Store behind r, looking inside Multi.
+func FindStore(r Recorder) (*Store, bool) {
+	switch r := r.(type) {
+	case *Store:
+		return r, true
+	case Multi:
+		for _, inner := range r {
+			if s, ok := FindStore(inner); ok {
+				return s, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Export formats.
+const (
+	FormatJSONL = "jsonl"
+	FormatCEF   = "cef"
+)
+
+// CEF header fields identifying this product.
+const (
+	cefVendor  = "simple-go-auth"
+	cefProduct = "users"
+	cefVersion = "1.0"
+)
+
+// Export writes every entry matching f (ignoring Before and Limit) to w in
+// ascending order, as JSON lines or ArcSight CEF.
+func (s *Store) Export(ctx context.Context, w io.Writer, format string, f Filter) error {
+	var write func(*bufio.Writer, *Entry) error
+	switch format {
+	case FormatJSONL, "":
+		write = writeJSONL
+	case FormatCEF:
+		write = writeCEF
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	bw := bufio.NewWriter(w)
+	f.Before, f.Limit = 0, 0
+	var after uint64
+	for {
+		var batch []Entry
+		if err := f.apply(s.db.WithContext(ctx)).
+			Where("seq > ?", after).
+			Order("seq ASC").
+			Limit(verifyBatch).
+			Find(&batch).Error; err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := write(bw, &batch[i]); err != nil {
+				return err
+			}
+			after = batch[i].Seq
+		}
+		if len(batch) < verifyBatch {
+			return bw.Flush()
+		}
+	}
+}
+
+func writeJSONL(w *bufio.Writer, e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.Write(b)
+	return w.WriteByte('\n')
+}
+
+// writeCEF writes one line of
+// CEF:0|Vendor|Product|Version|SignatureID|Name|Severity|Extension.
+func writeCEF(w *bufio.Writer, e *Entry) error {
+	severity := 3
+	if e.Outcome == OutcomeFailure {
+		severity = 5
+	}
+	header := []string{
+		"CEF:0", cefHeader(cefVendor), cefHeader(cefProduct), cefHeader(cefVersion),
+		cefHeader(e.Action), cefHeader(e.Action), fmt.Sprint(severity),
+	}
+	ext := []string{
+		"rt=" + fmt.Sprint(e.Time.UnixMilli()),
+		"cn1=" + fmt.Sprint(e.Seq),
+		"cn1Label=seq",
+		"outcome=" + cefExt(e.Outcome),
+	}
+	if e.Actor != "" {
+		ext = append(ext, "suser="+cefExt(e.Actor))
+	}
+	if e.Subject != "" {
+		ext = append(ext, "duser="+cefExt(e.Subject))
+	}
+	if e.IP != "" {
+		ext = append(ext, "src="+cefExt(e.IP))
+	}
+	if e.Details != "" {
+		ext = append(ext, "cs1="+cefExt(e.Details), "cs1Label=details")
+	}
+	ext = append(ext, "cs2="+e.Hash, "cs2Label=hash")
+	_, err := w.WriteString(strings.Join(header, "|") + "|" + strings.Join(ext, " ") + "\n")
+	return err
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+func cefExt(s string) string    { return cefExtEscaper.Replace(s) }
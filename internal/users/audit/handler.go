@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// Handler serves the audit log to administrators.
+type Handler struct {
+	Store *Store
+	// Actor names the caller; exports are audited under it.
+	Actor func(echo.Context) string
+}
+
+// RegisterRoutes mounts GET /audit, /audit/export and /audit/verify on g,
+// which should already require an administrator.
+func (h *Handler) RegisterRoutes(g *echo.Group) {
+	g.GET("/audit", h.Query)
+	g.GET("/audit/export", h.Export)
+	g.GET("/audit/verify", h.Verify)
+}
+
+// Query returns one page of entries, newest first. Filters: actor, subject,
+// action, outcome, ip, from, to (RFC 3339), before and limit.
+func (h *Handler) Query(c echo.Context) error {
+	f, err := filterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	page, err := h.Store.Query(c.Request().Context(), f)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// Export streams matching entries as ?format=jsonl (default) or cef.
+func (h *Handler) Export(c echo.Context) error {
+	f, err := filterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = FormatJSONL
+	}
+	if format != FormatJSONL && format != FormatCEF {
+		return problem.ErrBadRequest.WithDetail("format must be jsonl or cef")
+	}
+
+	ctx := c.Request().Context()
+	actor := ""
+	if h.Actor != nil {
+		actor = h.Actor(c)
+	}
+	_ = h.Store.Record(ctx, Event{
+		Action:  "admin.audit.exported",
+		Actor:   actor,
+		IP:      c.RealIP(),
+		Outcome: OutcomeSuccess,
+		Details: map[string]any{"format": format},
+	})
+
+	contentType := "application/x-ndjson"
+	if format == FormatCEF {
+		contentType = echo.MIMETextPlainCharsetUTF8
+	}
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit.`+format+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+	return h.Store.Export(ctx, c.Response(), format, f)
+}
+
+// Verify checks the hash chain.
+func (h *Handler) Verify(c echo.Context) error {
+	res, err := h.Store.Verify(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func filterFromQuery(c echo.Context) (Filter, error) {
+	f := Filter{
+		Actor:   c.QueryParam("actor"),
+		Subject: c.QueryParam("subject"),
+		Action:  c.QueryParam("action"),
+		Outcome: c.QueryParam("outcome"),
+		IP:      c.QueryParam("ip"),
+	}
+	var err error
+	if v := c.QueryParam("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, problem.ErrBadRequest.WithDetail("from must be an RFC 3339 time")
+		}
+	}
+	if v := c.QueryParam("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, problem.ErrBadRequest.WithDetail("to must be an RFC 3339 time")
+		}
+	}
+	if v := c.QueryParam("before"); v != "" {
+		if f.Before, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return f, problem.ErrBadRequest.WithDetail("before must be a sequence number")
+		}
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			return f, problem.ErrBadRequest.WithDetail("limit must be a positive integer")
+		}
+	}
+	return f, nil
+}
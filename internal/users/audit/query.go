@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Page size bounds for Query.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Filter narrows Query and Export. Zero fields match everything.
+type Filter struct {
+	Actor   string
+	Subject string
+	Action  string // exact match, or a prefix ending in "." such as "signin."
+	Outcome string
+	IP      string
+	From    time.Time // inclusive
+	To      time.Time // exclusive
+	Before  uint64    // keyset cursor: only entries with a lower Seq
+	Limit   int       // default DefaultLimit, at most MaxLimit
+}
+
+// Page is one page of Query results, newest first.
+type Page struct {
+	Entries    []Entry `json:"entries"`
+	NextBefore uint64  `json:"next_before,omitempty"` // pass as Filter.Before for the next page; 0 when done
+}
+
+// Query returns entries matching f, newest first.
+func (s *Store) Query(ctx context.Context, f Filter) (Page, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	var entries []Entry
+	// Fetch one extra row to learn whether another page exists
+	if err := f.apply(s.db.WithContext(ctx)).
+		Order("seq DESC").
+		Limit(limit + 1).
+		Find(&entries).Error; err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextBefore = page.Entries[limit-1].Seq
+	}
+	return page, nil
+}
+
+func (f Filter) apply(q *gorm.DB) *gorm.DB {
+	if f.Actor != "" {
+		q = q.Where("actor = ?", f.Actor)
+	}
+	if f.Subject != "" {
+		q = q.Where("subject = ?", f.Subject)
+	}
+	if f.Action != "" {
+		if f.Action[len(f.Action)-1] == '.' {
+			q = q.Where("action LIKE ?", f.Action+"%")
+		} else {
+			q = q.Where("action = ?", f.Action)
+		}
+	}
+	if f.Outcome != "" {
+		q = q.Where("outcome = ?", f.Outcome)
+	}
+	if f.IP != "" {
+		q = q.Where("ip = ?", f.IP)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("time >= ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		q = q.Where("time < ?", f.To.UTC())
+	}
+	if f.Before > 0 {
+		q = q.Where("seq < ?", f.Before)
+	}
+	return q
+}
@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Entry is a stored, hash-chained Event. Hash is a MAC over every other
+// field and the previous entry's hash, so editing, deleting or reordering
+// entries breaks the chain, and without the key it cannot be re-sealed.
+type Entry struct {
+	Seq      uint64    `gorm:"primaryKey;autoIncrement:false" json:"seq"`
+	Time     time.Time `gorm:"index;not null" json:"time"`
+	Action   string    `gorm:"index;not null" json:"action"`
+	Actor    string    `gorm:"index;default:''" json:"actor"`
+	Subject  string    `gorm:"index;default:''" json:"subject"`
+	IP       string    `gorm:"default:''" json:"ip,omitempty"`
+	Outcome  string    `gorm:"index;not null" json:"outcome"`
+	Details  string    `gorm:"type:text;default:''" json:"details,omitempty"` // JSON object
+	PrevHash string    `gorm:"size:64;not null" json:"prev_hash"`
+	Hash     string    `gorm:"size:64;uniqueIndex;not null" json:"hash"`
+}
+
+// TableName keeps the table name explicit; the append-only trigger refers to it.
+func (Entry) TableName() string { return "audit_entries" }
+
+// genesisHash is the PrevHash of the first entry.
+var genesisHash = strings.Repeat("0", 64)
+
+// chainLock is the Postgres advisory lock key serializing appends.
+const chainLock = 0x617564697400 // "audit"
+
+// Migrate creates the audit table and triggers rejecting UPDATE, DELETE and
+// TRUNCATE, making the table append-only. Dialects without such triggers
+// are refused rather than left writable.
+func Migrate(gormDB *gorm.DB) error {
+	if err := gormDB.AutoMigrate(&Entry{}); err != nil {
+		return err
+	}
+	switch name := gormDB.Dialector.Name(); name {
+	case "postgres":
+		return gormDB.Exec(postgresAppendOnly).Error
+	case "sqlite":
+		// SQLite has no TRUNCATE; an unqualified DELETE fires the row trigger
+		return gormDB.Exec(sqliteAppendOnly).Error
+	default:
+		return fmt.Errorf("audit: cannot make audit_entries append-only on %s", name)
+	}
+}
+
+const postgresAppendOnly = `
+CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_entries_no_change ON audit_entries;
+CREATE TRIGGER audit_entries_no_change BEFORE UPDATE OR DELETE ON audit_entries
+	FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only();
+DROP TRIGGER IF EXISTS audit_entries_no_truncate ON audit_entries;
+CREATE TRIGGER audit_entries_no_truncate BEFORE TRUNCATE ON audit_entries
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_entries_append_only();
+`
+
+const sqliteAppendOnly = `
+CREATE TRIGGER IF NOT EXISTS audit_entries_no_update BEFORE UPDATE ON audit_entries
+BEGIN
+	SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
+CREATE TRIGGER IF NOT EXISTS audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+	SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
+`
+
+// ErrNoHashKey is returned by NewStore without a key to seal the chain.
+var ErrNoHashKey = errors.New("audit: hash key is required")
+
+// Store is the database-backed Recorder.
+type Store struct {
+	db  *gorm.DB
+	key []byte
+}
+
+var _ Recorder = (*Store)(nil)
+
+// NewStore returns a Store on gormDB sealing entries with key; run Migrate
+// first. The key must stay the same for the life of the chain.
+func NewStore(gormDB *gorm.DB, key string) (*Store, error) {
+	if key == "" {
+		return nil, ErrNoHashKey
+	}
+	return &Store{db: gormDB, key: []byte(key)}, nil
+}
+
+// Record appends e to the chain.
+func (s *Store) Record(ctx context.Context, e Event) error {
+	details := ""
+	if len(e.Details) > 0 {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		details = string(b)
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	entry := &Entry{
+		// Postgres keeps microseconds; hash what will be read back.
+		Time:    e.Time.UTC().Truncate(time.Microsecond),
+		Action:  e.Action,
+		Actor:   e.Actor,
+		Subject: e.Subject,
+		IP:      e.IP,
+		Outcome: e.Outcome,
+		Details: details,
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1) One writer at a time, or two entries would share a predecessor
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLock).Error; err != nil {
+				return err
+			}
+		}
+
+		// 2) Link to the last entry
+		var last Entry
+		err := tx.Order("seq DESC").First(&last).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			entry.Seq, entry.PrevHash = 1, genesisHash
+		case err != nil:
+			return err
+		default:
+			entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+		}
+
+		// 3) Seal and append
+		entry.Hash = entry.computeHash(s.key)
+		return tx.Create(entry).Error
+	})
+}
+
+// computeHash is hex(hmac-sha256(key, prev_hash || canonical JSON of the entry)).
+func (e *Entry) computeHash(key []byte) string {
+	canonical, _ := json.Marshal(struct {
+		Seq     uint64 `json:"seq"`
+		Time    string `json:"time"`
+		Action  string `json:"action"`
+		Actor   string `json:"actor"`
+		Subject string `json:"subject"`
+		IP      string `json:"ip"`
+		Outcome string `json:"outcome"`
+		Details string `json:"details"`
+	}{e.Seq, e.Time.UTC().Format(time.RFC3339Nano), e.Action, e.Actor, e.Subject, e.IP, e.Outcome, e.Details})
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(e.PrevHash))
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyResult reports the outcome of Verify.
+type VerifyResult struct {
+	Checked  uint64 `json:"checked"`
+	OK       bool   `json:"ok"`
+	BrokenAt uint64 `json:"broken_at,omitempty"` // first bad sequence number
+	Reason   string `json:"reason,omitempty"`
+}
+
+// verifyBatch is how many entries Verify loads at a time.
+const verifyBatch = 1000
+
+// Verify walks the whole chain and reports the first entry that was altered,
+// removed or inserted out of order.
+func (s *Store) Verify(ctx context.Context) (VerifyResult, error) {
+	res := VerifyResult{OK: true}
+	prevSeq, prevHash := uint64(0), genesisHash
+	for {
+		var batch []Entry
+		if err := s.db.WithContext(ctx).
+			Where("seq > ?", prevSeq).
+			Order("seq ASC").
+			Limit(verifyBatch).
+			Find(&batch).Error; err != nil {
+			return res, err
+		}
+		for i := range batch {
+			e := &batch[i]
+			switch {
+			case e.Seq != prevSeq+1:
+				return broken(res, prevSeq+1, fmt.Sprintf("entry %d missing", prevSeq+1)), nil
+			case e.PrevHash != prevHash:
+				return broken(res, e.Seq, "previous hash does not match"), nil
+			case e.Hash != e.computeHash(s.key):
+				return broken(res, e.Seq, "entry hash does not match its contents"), nil
+			}
+			res.Checked++
+			prevSeq, prevHash = e.Seq, e.Hash
+		}
+		if len(batch) < verifyBatch {
+			return res, nil
+		}
+	}
+}
+
+func broken(res VerifyResult, seq uint64, reason string) VerifyResult {
+	res.OK, res.BrokenAt, res.Reason = false, seq, reason
+	return res
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/db"
+)
+
+// User statuses for the admin API.
+const (
+	UserActive        = "active"
+	UserDisabled      = "disabled"
+	UserUnconfirmed   = "unconfirmed"
+	UserResetRequired = "reset_required"
+)
+
+// Page sizes for ListUsers.
+const (
+	DefaultUserPageSize = 50
+	MaxUserPageSize     = 200
+)
+
+// UserFilter selects users for ListUsers. Zero fields don't filter.
+type UserFilter struct {
+	Query         string    // substring of username or email
+	Status        string    // one of the User* statuses
+	EmailDomain   string    // "example.com" matches *@example.com
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+	Before        uint      // keyset cursor: only IDs below this
+	Limit         int
+}
+
+// AdminUser is a user as administrators see it: no password hash, plus the
+// identity provider's view when users live in Cognito.
+type AdminUser struct {
+	ID                    uint       `json:"id"`
+	Username              string     `json:"username"`
+	Email                 string     `json:"email"`
+	Phone                 string     `json:"phone,omitempty"`
+	MFAMethod             string     `json:"mfa_method,omitempty"`
+	Status                string     `json:"status"`
+	ConfirmedAt           *time.Time `json:"confirmed_at,omitempty"`
+	DisabledAt            *time.Time `json:"disabled_at,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	ProviderStatus        string     `json:"provider_status,omitempty"` // Cognito UserStatus
+	ProviderEnabled       *bool      `json:"provider_enabled,omitempty"`
+}
+
+// UserPage is one page of ListUsers, newest first. Pass NextBefore as
+// UserFilter.Before for the next page; it is zero on the last page.
+type UserPage struct {
+	Users      []AdminUser `json:"users"`
+	NextBefore uint        `json:"next_before,omitempty"`
+}
+
+func userStatus(u *db.User) string {
+	switch {
+	case u.DisabledAt != nil:
+		return UserDisabled
+	case u.PasswordResetRequired:
+		return UserResetRequired
+	case u.ConfirmedAt == nil:
+		return UserUnconfirmed
+	}
+	return UserActive
+}
+
+func adminUserView(u *db.User) AdminUser {
+	return AdminUser{
+		ID:                    u.ID,
+		Username:              u.Username,
+		Email:                 u.Email,
+		Phone:                 u.Phone,
+		MFAMethod:             u.MFAMethod,
+		Status:                userStatus(u),
+		ConfirmedAt:           u.ConfirmedAt,
+		DisabledAt:            u.DisabledAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+		CreatedAt:             u.CreatedAt,
+		UpdatedAt:             u.UpdatedAt,
+	}
+}
+
+// ListUsers searches users in the database, which mirrors the identity provider.
+func (s *AuthServiceImpl) ListUsers(ctx context.Context, f UserFilter) (_ *UserPage, err error) {
+	ctx, span := startSpan(ctx, "ListUsers")
+	defer func() { endSpan(span, err) }()
+
+	if f.Limit <= 0 {
+		f.Limit = DefaultUserPageSize
+	}
+	if f.Limit > MaxUserPageSize {
+		f.Limit = MaxUserPageSize
+	}
+
+	q := s.DB.WithContext(ctx).Model(&db.User{})
+	if f.Query != "" {
+		like := "%" + escapeLike(strings.ToLower(f.Query)) + "%"
+		q = q.Where(`(LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\')`, like, like)
+	}
+	if f.EmailDomain != "" {
+		q = q.Where(`LOWER(email) LIKE ? ESCAPE '\'`, "%@"+escapeLike(strings.ToLower(f.EmailDomain)))
+	}
+	switch f.Status {
+	case "":
+	case UserDisabled:
+		q = q.Where("disabled_at IS NOT NULL")
+	case UserResetRequired:
+		q = q.Where("disabled_at IS NULL AND password_reset_required = ?", true)
+	case UserUnconfirmed:
+		q = q.Where("disabled_at IS NULL AND password_reset_required = ? AND confirmed_at IS NULL", false)
+	case UserActive:
+		q = q.Where("disabled_at IS NULL AND password_reset_required = ? AND confirmed_at IS NOT NULL", false)
+	default:
+		return nil, ErrInvalidUserFilter.WithDetail("unknown status " + f.Status)
+	}
+	if !f.CreatedAfter.IsZero() {
+		q = q.Where("created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		q = q.Where("created_at < ?", f.CreatedBefore)
+	}
+	if f.Before > 0 {
+		q = q.Where("id < ?", f.Before)
+	}
+
+	var users []db.User
+	if err := q.Order("id DESC").Limit(f.Limit + 1).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	page := &UserPage{Users: make([]AdminUser, 0, len(users))}
+	if len(users) > f.Limit {
+		users = users[:f.Limit]
+		page.NextBefore = users[len(users)-1].ID
+	}
+	for i := range users {
+		page.Users = append(page.Users, adminUserView(&users[i]))
+	}
+	return page, nil
+}
+
+// escapeLike escapes LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetUser returns one user, with Cognito's status when users live there.
+func (s *AuthServiceImpl) GetUser(ctx context.Context, username string) (_ *AdminUser, err error) {
+	ctx, span := startSpan(ctx, "GetUser")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	out := adminUserView(user)
+	if !s.local() {
+		cu, err := s.CognitoClient.AdminGetUser(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		out.ProviderStatus = string(cu.UserStatus)
+		out.ProviderEnabled = sdkaws.Bool(cu.Enabled)
+	}
+	return &out, nil
+}
+
+// SetUserDisabled disables or re-enables username. Disabling also ends all
+// of the user's sessions.
+func (s *AuthServiceImpl) SetUserDisabled(ctx context.Context, actor, username string, disabled bool) (err error) {
+	ctx, span := startSpan(ctx, "SetUserDisabled")
+	defer func() { endSpan(span, err) }()
+	action := "admin.user.enabled"
+	if disabled {
+		action = "admin.user.disabled"
+	}
+	defer func() { s.auditAdminAction(ctx, actor, action, username, err) }()
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// 1) Identity provider first, so a failure leaves both sides unchanged
+	if !s.local() {
+		if disabled {
+			err = s.CognitoClient.AdminDisableUser(ctx, username)
+		} else {
+			err = s.CognitoClient.AdminEnableUser(ctx, username)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2) Mirror it, and revoke our refresh tokens
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+	if err := s.updateUser(ctx, user, map[string]any{"disabled_at": disabledAt}); err != nil {
+		return err
+	}
+	if disabled {
+		return s.revokeRefreshTokens(ctx, user.ID)
+	}
+	return nil
+}
+
+// ForcePasswordReset invalidates username's password. Cognito emails a
+// reset code; local users can no longer sign in with their password. All
+// sessions end.
+func (s *AuthServiceImpl) ForcePasswordReset(ctx context.Context, actor, username string) (err error) {
+	ctx, span := startSpan(ctx, "ForcePasswordReset")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAdminAction(ctx, actor, "admin.user.password_reset", username, err) }()
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !s.local() {
+		if err := s.CognitoClient.AdminResetUserPassword(ctx, username); err != nil {
+			return err
+		}
+	}
+	if err := s.updateUser(ctx, user, map[string]any{"password_reset_required": true}); err != nil {
+		return err
+	}
+	return s.revokeRefreshTokens(ctx, user.ID)
+}
+
+// GlobalSignOut ends every session of username. Access tokens already
+// issued stay valid until they expire.
+func (s *AuthServiceImpl) GlobalSignOut(ctx context.Context, actor, username string) (err error) {
+	ctx, span := startSpan(ctx, "GlobalSignOut")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAdminAction(ctx, actor, "admin.user.signed_out", username, err) }()
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !s.local() {
+		if err := s.CognitoClient.AdminUserGlobalSignOut(ctx, username); err != nil {
+			return err
+		}
+	}
+	return s.revokeRefreshTokens(ctx, user.ID)
+}
+
+// findUser loads username, mapping a miss to ErrUserNotFound.
+func (s *AuthServiceImpl) findUser(ctx context.Context, username string) (*db.User, error) {
+	var user db.User
+	err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// revokeRefreshTokens revokes every stored refresh token of a user.
+func (s *AuthServiceImpl) revokeRefreshTokens(ctx context.Context, userID uint) error {
+	return s.DB.WithContext(ctx).
+		Model(&db.RefreshToken{}).
+		Where("user_id = ? AND revoked = false", userID).
+		Update("revoked", true).
+		Error
+}
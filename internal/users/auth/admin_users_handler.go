@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/rbac"
+)
+
+// ListAdminUsers searches users: ?q=&status=&email_domain=&created_after=
+// &created_before=&before=&limit=.
+func (h *AuthHandler) ListAdminUsers(c echo.Context) error {
+	f, err := userFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	page, err := h.Service.ListUsers(c.Request().Context(), f)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetAdminUser returns one user's details.
+func (h *AuthHandler) GetAdminUser(c echo.Context) error {
+	u, err := h.Service.GetUser(c.Request().Context(), c.Param("username"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, u)
+}
+
+// DisableUser disables an account and ends its sessions.
+func (h *AuthHandler) DisableUser(c echo.Context) error {
+	actor := PrincipalFromContext(c).Username
+	if actor == c.Param("username") {
+		return ErrSelfAdminAction
+	}
+	if err := h.Service.SetUserDisabled(RequestContext(c), actor, c.Param("username"), true); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// EnableUser re-enables a disabled account.
+func (h *AuthHandler) EnableUser(c echo.Context) error {
+	if err := h.Service.SetUserDisabled(RequestContext(c), PrincipalFromContext(c).Username, c.Param("username"), false); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResetUserPassword forces a password reset.
+func (h *AuthHandler) ResetUserPassword(c echo.Context) error {
+	if err := h.Service.ForcePasswordReset(RequestContext(c), PrincipalFromContext(c).Username, c.Param("username")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SignOutUser ends every session of a user.
+func (h *AuthHandler) SignOutUser(c echo.Context) error {
+	if err := h.Service.GlobalSignOut(RequestContext(c), PrincipalFromContext(c).Username, c.Param("username")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteAdminUser deletes a user everywhere.
+func (h *AuthHandler) DeleteAdminUser(c echo.Context) error {
+	actor := PrincipalFromContext(c).Username
+	if actor == c.Param("username") {
+		return ErrSelfAdminAction
+	}
+	if err := h.Service.DeleteUser(RequestContext(c), actor, c.Param("username")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListRoles lists the roles with their permissions.
+func (h *AuthHandler) ListRoles(c echo.Context) error {
+	roles, err := h.Service.RBAC.Roles(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, roles)
+}
+
+// GetUserRoles lists the roles held by a user.
+func (h *AuthHandler) GetUserRoles(c echo.Context) error {
+	roles, err := h.Service.UserRoleNames(c.Request().Context(), c.Param("username"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"roles": roles})
+}
+
+// AssignUserRole grants a role to a user.
+func (h *AuthHandler) AssignUserRole(c echo.Context) error {
+	if err := h.Service.AssignRole(RequestContext(c), PrincipalFromContext(c).Username, c.Param("username"), c.Param("role")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeUserRole takes a role away from a user. Administrators cannot
+// revoke their own roles, so the last one cannot lock everyone out by mistake.
+func (h *AuthHandler) RevokeUserRole(c echo.Context) error {
+	actor := PrincipalFromContext(c).Username
+	if actor == c.Param("username") {
+		return ErrSelfAdminAction
+	}
+	if err := h.Service.RevokeRole(RequestContext(c), actor, c.Param("username"), c.Param("role")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AuthHandler) registerUserRoutes(g *echo.Group) {
+	read, write := RequirePermission(rbac.PermUsersRead), RequirePermission(rbac.PermUsersWrite)
+	g.GET("/users", h.ListAdminUsers, read)
+	g.GET("/users/:username", h.GetAdminUser, read)
+	g.POST("/users/:username/disable", h.DisableUser, write)
+	g.POST("/users/:username/enable", h.EnableUser, write)
+	g.POST("/users/:username/reset-password", h.ResetUserPassword, write)
+	g.POST("/users/:username/sign-out", h.SignOutUser, write)
+	g.DELETE("/users/:username", h.DeleteAdminUser, write)
+}
+
+func (h *AuthHandler) registerRoleRoutes(g *echo.Group) {
+	roles := RequirePermission(rbac.PermRolesWrite)
+	g.GET("/roles", h.ListRoles, RequirePermission(rbac.PermUsersRead))
+	g.GET("/users/:username/roles", h.GetUserRoles, RequirePermission(rbac.PermUsersRead))
+	g.PUT("/users/:username/roles/:role", h.AssignUserRole, roles)
+	g.DELETE("/users/:username/roles/:role", h.RevokeUserRole, roles)
+}
+
+func userFilterFromQuery(c echo.Context) (UserFilter, error) {
+	f := UserFilter{
+		Query:       c.QueryParam("q"),
+		Status:      c.QueryParam("status"),
+		EmailDomain: c.QueryParam("email_domain"),
+	}
+	var err error
+	if v := c.QueryParam("created_after"); v != "" {
+		if f.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, problem.ErrBadRequest.WithDetail("created_after must be an RFC 3339 time")
+		}
+	}
+	if v := c.QueryParam("created_before"); v != "" {
+		if f.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, problem.ErrBadRequest.WithDetail("created_before must be an RFC 3339 time")
+		}
+	}
+	if v := c.QueryParam("before"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, problem.ErrBadRequest.WithDetail("before must be a user ID")
+		}
+		f.Before = uint(before)
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			return f, problem.ErrBadRequest.WithDetail("limit must be a positive integer")
+		}
+	}
+	return f, nil
+}
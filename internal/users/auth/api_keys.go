@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/token"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable in code and
+// by secret scanners: "sga_<16 hex key ID>_<secret>".
+const APIKeyPrefix = "sga_"
+
+const apiKeyIDLen = 16
+
+// Lifetimes of API keys when APIKeyDefaultTTL and APIKeyMaxTTL are unset.
+const (
+	defaultAPIKeyTTL = 90 * 24 * time.Hour
+	defaultAPIKeyMax = 365 * 24 * time.Hour
+)
+
+func (s *AuthServiceImpl) apiKeyTTL() (ttl, maxTTL time.Duration) {
+	ttl, maxTTL = defaultAPIKeyTTL, defaultAPIKeyMax
+	if s.APIKeyDefaultTTL > 0 {
+		ttl = s.APIKeyDefaultTTL
+	}
+	if s.APIKeyMaxTTL > 0 {
+		maxTTL = s.APIKeyMaxTTL
+	}
+	return ttl, maxTTL
+}
+
+// IsAPIKey reports whether credential looks like an API key rather than an
+// access token.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// APIKeyRequest describes a key to create.
+type APIKeyRequest struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`     // permissions the key may use; a subset of the owner's
+	ExpiresAt time.Time `json:"expires_at"` // zero for APIKeyDefaultTTL
+}
+
+// APIKey is an API key as listed to its owner; the secret is only returned
+// by CreateAPIKey.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first part of the key, to recognise it
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Key        string     `json:"key,omitempty"` // only on creation
+}
+
+func apiKeyView(k *db.APIKey) APIKey {
+	return APIKey{
+		ID:         k.KeyID,
+		Name:       k.Name,
+		Prefix:     APIKeyPrefix + k.KeyID,
+		Scopes:     strings.Fields(k.Scopes),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		LastUsedIP: k.LastUsedIP,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// CreateAPIKey creates a key for the caller. Scopes must be permissions the
+// caller holds, and API keys cannot create further keys.
+func (s *AuthServiceImpl) CreateAPIKey(ctx context.Context, p *Principal, req APIKeyRequest) (_ *APIKey, err error) {
+	ctx, span := startSpan(ctx, "CreateAPIKey")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAction(ctx, "apikey.created", p.Username, err) }()
+
+	// 1) Validate the request against the caller
+	if p.APIKey != "" || p.ClientID != "" {
+		return nil, ErrAPIKeyNotAllowed
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, ErrInvalidAPIKeyRequest.WithDetail("name is required")
+	}
+	for _, scope := range req.Scopes {
+		if !p.HasPermission(scope) {
+			return nil, ErrInvalidAPIKeyRequest.WithDetail("scope " + scope + " is not one of your permissions")
+		}
+	}
+	now := time.Now()
+	ttl, maxTTL := s.apiKeyTTL()
+	if req.ExpiresAt.IsZero() {
+		req.ExpiresAt = now.Add(ttl)
+	}
+	if !req.ExpiresAt.After(now) || req.ExpiresAt.After(now.Add(maxTTL)) {
+		return nil, ErrInvalidAPIKeyRequest.WithDetail("expires_at must be in the future and within " + maxTTL.String())
+	}
+	user, err := s.findUser(ctx, p.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2) Generate; only the hash is stored
+	id := make([]byte, apiKeyIDLen/2)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	keyID := hex.EncodeToString(id)
+	key := APIKeyPrefix + keyID + "_" + token.RandomString(32)
+	row := &db.APIKey{
+		KeyID:     keyID,
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(req.Name),
+		Scopes:    strings.Join(req.Scopes, " "),
+		Hash:      token.Hash(key),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.DB.WithContext(ctx).Create(row).Error; err != nil {
+		return nil, err
+	}
+	out := apiKeyView(row)
+	out.Key = key
+	return &out, nil
+}
+
+// ListAPIKeys lists username's keys, newest first, including revoked and
+// expired ones.
+func (s *AuthServiceImpl) ListAPIKeys(ctx context.Context, username string) ([]APIKey, error) {
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	var rows []db.APIKey
+	if err := s.DB.WithContext(ctx).Where("user_id = ?", user.ID).Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]APIKey, 0, len(rows))
+	for i := range rows {
+		out = append(out, apiKeyView(&rows[i]))
+	}
+	return out, nil
+}
+
+// RevokeAPIKey revokes username's key id; actor is username itself or an
+// administrator.
+func (s *AuthServiceImpl) RevokeAPIKey(ctx context.Context, actor, username, id string) (err error) {
+	ctx, span := startSpan(ctx, "RevokeAPIKey")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAdminAction(ctx, actor, "apikey.revoked", username, err) }()
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	res := s.DB.WithContext(ctx).Model(&db.APIKey{}).
+		Where("key_id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// authenticateAPIKey returns the principal of the owner of key, limited to
+// the key's scopes.
+func (s *AuthServiceImpl) authenticateAPIKey(ctx context.Context, key string) (*Principal, error) {
+	// 1) Find the live key and its active owner
+	k, user, err := s.findAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	// 2) Record use, at most once a minute per key
+	if err := s.DB.WithContext(ctx).Model(&db.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", k.ID, now.Add(-time.Minute)).
+		Updates(map[string]any{"last_used_at": now, "last_used_ip": ClientInfoFrom(ctx).IP}).Error; err != nil {
+		return nil, err
+	}
+
+	// 3) Current role permissions, narrowed to the key's scopes
+	p := &Principal{
+		Subject:  strconv.FormatUint(uint64(user.ID), 10),
+		Username: user.Username,
+		APIKey:   k.KeyID,
+		Scopes:   strings.Fields(k.Scopes),
+	}
+	roles, err := s.rolesOf(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Roles = roles
+	if err := s.resolvePermissions(ctx, p); err != nil {
+		return nil, err
+	}
+	p.Permissions = slices.DeleteFunc(p.Permissions, func(perm string) bool {
+		return !slices.Contains(p.Scopes, perm)
+	})
+	return p, nil
+}
+
+// findAPIKey returns key, which must be live, and its owner, who must be
+// enabled.
+func (s *AuthServiceImpl) findAPIKey(ctx context.Context, key string) (*db.APIKey, *db.User, error) {
+	// 1) Find the key by its public ID and compare the hash
+	rest := strings.TrimPrefix(key, APIKeyPrefix)
+	if len(rest) <= apiKeyIDLen || rest[apiKeyIDLen] != '_' {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	var k db.APIKey
+	if err := s.DB.WithContext(ctx).Where("key_id = ?", rest[:apiKeyIDLen]).First(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(token.Hash(key))) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if k.RevokedAt != nil || time.Now().After(k.ExpiresAt) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	// 2) The owner must still be active
+	var user db.User
+	if err := s.DB.WithContext(ctx).First(&user, k.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if user.DisabledAt != nil {
+		return nil, nil, ErrUserDisabled
+	}
+	return &k, &user, nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/rbac"
+)
+
+// CreateAPIKey creates a key for the caller and returns it once.
+func (h *AuthHandler) CreateAPIKey(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	var req APIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	key, err := h.Service.CreateAPIKey(RequestContext(c), p, req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys lists the caller's keys.
+func (h *AuthHandler) ListAPIKeys(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	keys, err := h.Service.ListAPIKeys(c.Request().Context(), p.Username)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"api_keys": keys})
+}
+
+// RevokeAPIKey revokes one of the caller's keys.
+func (h *AuthHandler) RevokeAPIKey(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	if err := h.Service.RevokeAPIKey(RequestContext(c), p.Username, p.Username, c.Param("id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListUserAPIKeys lists a user's keys for an administrator.
+func (h *AuthHandler) ListUserAPIKeys(c echo.Context) error {
+	keys, err := h.Service.ListAPIKeys(c.Request().Context(), c.Param("username"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"api_keys": keys})
+}
+
+// RevokeUserAPIKey revokes a user's key, e.g. one that leaked.
+func (h *AuthHandler) RevokeUserAPIKey(c echo.Context) error {
+	if err := h.Service.RevokeAPIKey(RequestContext(c), PrincipalFromContext(c).Username, c.Param("username"), c.Param("id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RegisterAPIKeyRoutes mounts the self-service API key routes on e behind
+// authMw.
+func (h *AuthHandler) RegisterAPIKeyRoutes(e *echo.Echo, authMw echo.MiddlewareFunc) {
+	e.POST("/api-keys", h.CreateAPIKey, authMw)
+	e.GET("/api-keys", h.ListAPIKeys, authMw)
+	e.DELETE("/api-keys/:id", h.RevokeAPIKey, authMw)
+}
+
+func (h *AuthHandler) registerAPIKeyAdminRoutes(g *echo.Group) {
+	g.GET("/users/:username/api-keys", h.ListUserAPIKeys, RequirePermission(rbac.PermUsersRead))
+	g.DELETE("/users/:username/api-keys/:id", h.RevokeUserAPIKey, RequirePermission(rbac.PermUsersWrite))
+}
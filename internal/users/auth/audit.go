@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/problem"
+)
+
+// recordAudit stores e when an audit recorder is configured. Failures are
+// not returned: the audited action already happened.
+func (s *AuthServiceImpl) recordAudit(ctx context.Context, e audit.Event) {
+	if s.Audit == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.IP == "" {
+		e.IP = ClientInfoFrom(ctx).IP
+	}
+	_ = s.Audit.Record(ctx, e)
+}
+
+// auditSignIn records the outcome of a sign-in attempt by method
+// ("password", "magic_link", ...). A challenge is not a failure: the
+// password step passed.
+func (s *AuthServiceImpl) auditSignIn(ctx context.Context, username, method string, err error) {
+	e := audit.Event{
+		Action:  "signin.succeeded",
+		Actor:   username,
+		Subject: username,
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]any{"method": method},
+	}
+	var ch *AuthChallenge
+	switch {
+	case errors.As(err, &ch):
+		e.Action = "signin.challenged"
+		e.Details["challenge"] = ch.Name
+	case err != nil:
+		e.Action = "signin.failed"
+		e.Outcome = audit.OutcomeFailure
+		e.Details["reason"] = failureCode(err)
+	}
+	s.recordAudit(ctx, e)
+}
+
+// auditAction records a self-service action by username.
+func (s *AuthServiceImpl) auditAction(ctx context.Context, action, username string, err error) {
+	e := audit.Event{
+		Action:  action,
+		Actor:   username,
+		Subject: username,
+		Outcome: audit.OutcomeSuccess,
+	}
+	if err != nil {
+		e.Outcome = audit.OutcomeFailure
+		e.Details = map[string]any{"reason": failureCode(err)}
+	}
+	s.recordAudit(ctx, e)
+}
+
+// failureCode is the problem code of err, or the Cognito failure reason.
+func failureCode(err error) string {
+	if p, ok := problem.From(err); ok {
+		return p.Code
+	}
+	return signInFailureReason(err)
+}
+
+// auditAdmin records an administrator action on subject.
+func (s *AuthServiceImpl) auditAdmin(ctx context.Context, actor, action, subject string, details map[string]any) {
+	s.recordAudit(ctx, audit.Event{
+		Action:  action,
+		Actor:   actor,
+		Subject: subject,
+		Outcome: audit.OutcomeSuccess,
+		Details: details,
+	})
+}
+
+// auditAdminAction records the outcome of an administrator action on subject.
+func (s *AuthServiceImpl) auditAdminAction(ctx context.Context, actor, action, subject string, err error) {
+	e := audit.Event{Action: action, Actor: actor, Subject: subject, Outcome: audit.OutcomeSuccess}
+	if err != nil {
+		e.Outcome = audit.OutcomeFailure
+		e.Details = map[string]any{"reason": failureCode(err)}
+	}
+	s.recordAudit(ctx, e)
+}
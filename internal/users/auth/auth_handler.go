@@ -2,9 +2,13 @@
 package auth
 
 import (
+	"errors"
+	"net/http"
 	"regexp"
+	"strings"
 
 	"simple-go-auth/internal/users/config"
+	"simple-go-auth/internal/users/problem"
 
 	"github.com/labstack/echo/v4"
 )
@@ -15,28 +19,13 @@ type AuthHandler struct {
 	RecaptchaSecret string
 }
 
-// NewHandler registers all auth routes on the given Echo and returns the handler.
+// NewHandler returns the handler for svc; http.RegisterRoutes mounts its routes.
 // You must pass in your app-wide config so we can read RecaptchaSecretKey.
-func NewHandler(e *echo.Echo, svc *AuthServiceImpl, cfg *config.Config) *AuthHandler {
-	h := &AuthHandler{
+func NewHandler(svc *AuthServiceImpl, cfg *config.Config) *AuthHandler {
+	return &AuthHandler{
 		Service:         svc,
 		RecaptchaSecret: cfg.RecaptchaSecretKey,
 	}
-
-	// Public routes
-	e.GET("/ping", h.Ping)
-	e.POST("/signup", h.SignUp)
-	e.POST("/signin", h.SignIn)
-	e.POST("/confirm", h.ConfirmSignUp)
-	e.POST("/refresh", h.Refresh)
-
-	// Protected
-	e.POST("/logout", h.SignOut, NewMiddleware(svc))
-	e.POST("/mfa/setup", h.SetupMFA)
-	e.POST("/mfa/verify", h.VerifyMFA)
-	e.POST("/social/:provider/callback", h.SocialCallback)
-
-	return h
 }
 
 // Ping returns pong.
@@ -53,23 +42,23 @@ func (h *AuthHandler) SignUp(c echo.Context) error {
 		CaptchaToken string `json:"captcha_token"`
 	}
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(400, map[string]string{"error": "invalid request body"})
+		return problem.ErrInvalidBody
 	}
 
 	// 1) Verify reCAPTCHA
 	if !verifyCaptcha(req.CaptchaToken, h.RecaptchaSecret) {
-		return c.JSON(400, map[string]string{"error": "captcha failed"})
+		return ErrCaptchaFailed
 	}
 
 	// 2) Enforce password policy
 	pwdPolicy := regexp.MustCompile(`^(?=.*[0-9])(?=.*[A-Z]).{8,}$`)
 	if !pwdPolicy.MatchString(req.Password) {
-		return c.JSON(400, map[string]string{"error": "password must be ≥8 chars, include uppercase & digit"})
+		return ErrWeakPassword
 	}
 
-	// 3) Call service.SignUp
-	if err := h.Service.SignUp(c.Request().Context(), req.Username, req.Password, req.Email); err != nil {
-		return c.JSON(500, map[string]string{"error": "failed to create user"})
+	// 3) Call service.SignUp (Cognito errors such as UsernameExists map to problems centrally)
+	if err := h.Service.SignUp(RequestContext(c), req.Username, req.Password, req.Email); err != nil {
+		return err
 	}
 
 	// 4) Return 202—Cognito has sent the confirmation code
@@ -89,10 +78,14 @@ func (h *AuthHandler) ConfirmSignUp(c echo.Context) error {
 		Code     string `json:"code"`
 	}
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(400, map[string]string{"error": "invalid request body"})
+		return problem.ErrInvalidBody
 	}
-	if err := h.Service.ConfirmSignUp(c.Request().Context(), req.Username, req.Code); err != nil {
-		return c.JSON(400, map[string]string{"error": err.Error()})
+	if err := h.Service.ConfirmSignUp(RequestContext(c), req.Username, req.Code); err != nil {
+		// Don't reveal whether the username exists.
+		if mapped := problem.FromCognito(err); errors.Is(mapped, problem.ErrUserNotFound) {
+			return problem.ErrInvalidCode.Wrap(err)
+		}
+		return err
 	}
 	return c.JSON(200, map[string]string{"message": "user confirmed"})
 }
@@ -104,54 +97,65 @@ func (h *AuthHandler) SignIn(c echo.Context) error {
 		Password string `json:"password"`
 	}
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(400, map[string]string{"error": "invalid request body"})
+		return problem.ErrInvalidBody
 	}
-	tokens, err := h.Service.SignIn(c.Request().Context(), req.Username, req.Password)
+	tokens, err := h.Service.SignIn(RequestContext(c), req.Username, req.Password)
 	if err != nil {
-		return c.JSON(401, map[string]string{"error": "invalid credentials"})
+		var ch *AuthChallenge
+		if errors.As(err, &ch) {
+			return challengeOrError(c, err)
+		}
+		// Don't reveal whether the username exists.
+		mapped := problem.FromCognito(err)
+		if errors.Is(mapped, problem.ErrNotAuthorized) || errors.Is(mapped, problem.ErrUserNotFound) {
+			return ErrInvalidCredentials.Wrap(err)
+		}
+		return mapped
 	}
 	return c.JSON(200, tokens)
 }
 
+// challengeOrError answers 200 with the challenge when err is an
+// AuthChallenge, so the client can continue with /signin/challenge.
+func challengeOrError(c echo.Context, err error) error {
+	var ch *AuthChallenge
+	if errors.As(err, &ch) {
+		return c.JSON(http.StatusOK, ch)
+	}
+	return err
+}
+
 // Refresh rotates tokens.
 func (h *AuthHandler) Refresh(c echo.Context) error {
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
 	}
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(400, map[string]string{"error": "invalid request body"})
+		return problem.ErrInvalidBody
 	}
-	tokens, err := h.Service.RefreshTokens(c.Request().Context(), req.RefreshToken)
+	tokens, err := h.Service.RefreshTokens(RequestContext(c), req.RefreshToken)
 	if err != nil {
-		return c.JSON(401, map[string]string{"error": err.Error()})
+		if errors.Is(problem.FromCognito(err), problem.ErrNotAuthorized) {
+			return ErrInvalidRefreshToken.Wrap(err)
+		}
+		return err
 	}
 	return c.JSON(200, tokens)
 }
 
 // SignOut revokes the user’s session.
 func (h *AuthHandler) SignOut(c echo.Context) error {
-	token := c.Request().Header.Get("Authorization")
-	if token == "" {
-		return c.JSON(400, map[string]string{"error": "authorization token required"})
+	header := c.Request().Header.Get("Authorization")
+	if header == "" {
+		return ErrTokenRequired
 	}
-	if err := h.Service.SignOut(c.Request().Context(), token); err != nil {
-		return c.JSON(500, map[string]string{"error": "failed to sign out"})
+	token := strings.TrimPrefix(header, "Bearer ")
+	if err := h.Service.SignOut(RequestContext(c), token); err != nil {
+		return err
 	}
 	return c.NoContent(204)
 }
 
-// SetupMFA sets up multi-factor authentication for the user.
-func (h *AuthHandler) SetupMFA(c echo.Context) error {
-	// Implementation for setting up MFA
-	return c.JSON(200, map[string]string{"message": "MFA setup successful"})
-}
-
-// VerifyMFA verifies the multi-factor authentication code.
-func (h *AuthHandler) VerifyMFA(c echo.Context) error {
-	// Implementation for verifying MFA
-	return c.JSON(200, map[string]string{"message": "MFA verification successful"})
-}
-
 // SocialCallback handles social login callbacks.
 func (h *AuthHandler) SocialCallback(c echo.Context) error {
 	// Implementation for social login callback
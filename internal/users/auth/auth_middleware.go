@@ -1,24 +1,73 @@
 package auth
 
 import (
-	"net/http"
+	"errors"
 	"strings"
 
 	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
 )
 
-// NewMiddleware creates an Echo middleware for token validation.
+// HeaderAPIKey carries an API key for clients that can't set Authorization.
+const HeaderAPIKey = "X-API-Key"
+
+// NewMiddleware creates an Echo middleware for token validation. It takes
+// a bearer access token or an API key, in Authorization or X-API-Key, and
+// on success the caller is available through PrincipalFromContext.
 func NewMiddleware(svc *AuthServiceImpl) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			header := c.Request().Header.Get("Authorization")
-			if header == "" {
-				return c.NoContent(http.StatusUnauthorized)
-			}
 			// Strip "Bearer " if present
-			token := strings.TrimPrefix(header, "Bearer ")
-			if err := svc.ValidateToken(c.Request().Context(), token); err != nil {
-				return c.NoContent(http.StatusUnauthorized)
+			token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				token = c.Request().Header.Get(HeaderAPIKey)
+			}
+			if token == "" {
+				return ErrTokenRequired
+			}
+			p, err := svc.Authenticate(RequestContext(c), token)
+			if err != nil {
+				if errors.Is(problem.FromCognito(err), problem.ErrNotAuthorized) {
+					return ErrInvalidToken.Wrap(err)
+				}
+				return err
+			}
+			SetPrincipal(c, p)
+			return next(c)
+		}
+	}
+}
+
+// RequireSession only lets users signed in with an access token through:
+// API keys and OAuth clients can't act on the user's session, MFA or
+// devices. It must run after NewMiddleware.
+func RequireSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := PrincipalFromContext(c)
+			if p == nil {
+				return problem.ErrUnauthorized
+			}
+			if p.APIKey != "" || p.ClientID != "" {
+				return ErrSessionRequired
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequirePermission only lets callers whose roles grant perm through. It
+// must run after NewMiddleware.
+func RequirePermission(perm string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := PrincipalFromContext(c)
+			if p == nil {
+				return problem.ErrUnauthorized
+			}
+			if !p.HasPermission(perm) {
+				return problem.ErrForbidden.WithDetail("missing permission " + perm)
 			}
 			return next(c)
 		}
@@ -3,12 +3,35 @@ package auth
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"simple-go-auth/internal/users/audit"
 	"simple-go-auth/internal/users/aws"
 	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/http/metrics"
+	"simple-go-auth/internal/users/notify"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/outbox"
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/rebac"
+	"simple-go-auth/internal/users/risk"
+	"simple-go-auth/internal/users/token"
+	"simple-go-auth/internal/users/webhook"
 
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -25,6 +48,64 @@ type AuthTokens struct {
 type AuthServiceImpl struct {
 	CognitoClient *aws.CognitoClient
 	DB            *gorm.DB
+
+	// CustomAuthSecret signs the answers IssueTokens gives to the CUSTOM_AUTH
+	// challenge; the pool's VerifyAuthChallengeResponse Lambda holds the same key.
+	CustomAuthSecret string
+
+	// SessionSecret seals the Cognito challenge sessions SignIn returns, see
+	// sealSession. Without it Cognito challenges fail.
+	SessionSecret string
+
+	// Notifier delivers transactional email/SMS rendered from Templates.
+	Notifier  notify.Notifier
+	Templates *notify.Templates
+
+	// Provider is ProviderCognito (default) or ProviderLocal. Native tokens
+	// from Tokens are accepted with either provider.
+	Provider       string
+	Tokens         *token.Signer
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long refresh tokens last. Cognito sets its own
+	// per app client; this must match it, as Cognito doesn't report it.
+	RefreshTokenTTL time.Duration
+
+	// Audit receives security events such as recovery-code use.
+	Audit audit.Recorder
+
+	// DeviceSecret signs trusted-device cookies, which skip MFA for DeviceTrustTTL.
+	DeviceSecret   string
+	DeviceTrustTTL time.Duration
+
+	// Risk scores password sign-ins; nil disables adaptive authentication.
+	Risk *risk.Engine
+
+	// OTP controls email/SMS one-time codes for login and MFA.
+	OTP otp.Policy
+
+	// Magic-link sign-in, see RequestMagicLink.
+	MagicLinkSecret string
+	MagicLinkURL    string
+	MagicLinkTTL    time.Duration
+
+	// Webhooks delivers auth events to tenant endpoints; nil disables the
+	// webhook admin API.
+	Webhooks *webhook.Dispatcher
+
+	// RBAC resolves roles to permissions; nil grants no permissions.
+	RBAC *rbac.Store
+
+	// Relations answers relationship checks for product services; nil
+	// disables the /authz API. Deleted users lose their tuples.
+	Relations *rebac.Store
+
+	// API key lifetimes, see CreateAPIKey; 90 and 365 days when unset.
+	APIKeyDefaultTTL time.Duration
+	APIKeyMaxTTL     time.Duration
+
+	// Logger records failures that must not reach the caller, such as
+	// undeliverable magic links.
+	Logger *zap.Logger
 }
 
 // NewAuthServiceImpl creates a new instance of AuthServiceImpl.
@@ -32,57 +113,368 @@ func NewAuthServiceImpl(client *aws.CognitoClient, db *gorm.DB) *AuthServiceImpl
 	return &AuthServiceImpl{
 		CognitoClient: client,
 		DB:            db,
+		Logger:        zap.NewNop(),
 	}
 }
 
 // SignUp registers a new user in Cognito and persists a User record.
-func (s *AuthServiceImpl) SignUp(ctx context.Context, username, password, email string) error {
-	// 1) Create in Cognito
-	if err := s.CognitoClient.SignUp(ctx, username, password, email); err != nil {
+func (s *AuthServiceImpl) SignUp(ctx context.Context, username, password, email string) (err error) {
+	ctx, span := startSpan(ctx, "SignUp")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAction(ctx, "signup", username, err) }()
+
+	// 1) Create in Cognito, or hash the password for the local provider
+	var hash []byte
+	if s.local() {
+		if hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			return err
+		}
+	} else if err := s.CognitoClient.SignUp(ctx, username, password, email); err != nil {
 		return err
 	}
-	// 2) Persist in local DB
-	user := &db.User{Username: username, Email: email}
-	if err := s.DB.Create(user).Error; err != nil {
+	// 2) Persist in local DB, with the user.created event
+	user := &db.User{Username: username, Email: email, Password: string(hash)}
+	if s.local() {
+		now := time.Now()
+		user.ConfirmedAt = &now
+	}
+	if err := s.saveUser(ctx, outbox.UserCreated, user, nil, func(tx *gorm.DB) error {
+		return tx.Create(user).Error
+	}); err != nil {
 		return err
 	}
+	metrics.RecordSignUp()
 	return nil
 }
 
 // SignIn authenticates a user via Cognito, persists the refresh token, and returns tokens.
-func (s *AuthServiceImpl) SignIn(ctx context.Context, username, password string) (*AuthTokens, error) {
+func (s *AuthServiceImpl) SignIn(ctx context.Context, username, password string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "SignIn")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.finishSignIn(ctx, username, "password", err) }()
+
+	// 0) Risk: blocked attempts don't get to try the password
+	assessment, err := s.assessSignIn(ctx, username)
+	if err != nil {
+		metrics.RecordSignIn("internal_error")
+		return nil, err
+	}
+	stepUp := false
+	if assessment != nil {
+		span.SetAttributes(
+			attribute.Int("auth.risk.score", assessment.Score),
+			attribute.String("auth.risk.decision", string(assessment.Decision)),
+		)
+		if assessment.Decision == risk.Block {
+			s.recordAttempt(ctx, username, false)
+			metrics.RecordSignIn("blocked")
+			return nil, ErrSignInBlocked
+		}
+		stepUp = assessment.Decision == risk.StepUp
+	}
+
+	if s.local() {
+		tokens, err := s.localSignIn(ctx, username, password, stepUp)
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.recordAttempt(ctx, username, false)
+		}
+		switch {
+		case errors.Is(err, ErrChallengeRequired):
+			metrics.RecordSignIn("challenge_required")
+		case errors.Is(err, ErrInvalidCredentials):
+			metrics.RecordSignIn("invalid_credentials")
+		case err != nil:
+			metrics.RecordSignIn("internal_error")
+		default:
+			metrics.RecordSignIn("")
+		}
+		return tokens, err
+	}
+
 	// 1) Cognito auth
 	out, err := s.CognitoClient.SignIn(ctx, username, password)
 	if err != nil {
+		reason := signInFailureReason(err)
+		if reason == "invalid_credentials" {
+			s.recordAttempt(ctx, username, false)
+		}
+		metrics.RecordSignIn(reason)
 		return nil, err
 	}
+	if out.ChallengeName != "" {
+		span.SetAttributes(attribute.String("auth.challenge", string(out.ChallengeName)))
+		metrics.RecordMFAChallenge(string(out.ChallengeName))
+	}
 	ar := out.AuthenticationResult
 	if ar == nil {
-		return nil, errors.New("authentication failed: no result returned")
+		if out.ChallengeName == "" {
+			metrics.RecordSignIn("challenge_required")
+			return nil, ErrChallengeRequired
+		}
+		// 2) Trusted devices skip the second factor, unless the attempt looks risky
+		if !stepUp && mfaChallenges[out.ChallengeName] && s.cognitoDeviceTrusted(ctx, username) {
+			span.SetAttributes(attribute.Bool("auth.device_trusted", true))
+			tokens, err := s.IssueTokens(ctx, username)
+			if err != nil {
+				metrics.RecordSignIn("internal_error")
+				return nil, err
+			}
+			metrics.RecordSignIn("")
+			return tokens, nil
+		}
+		ch, err := s.cognitoChallenge(username, out.ChallengeName, out.Session, out.ChallengeParameters)
+		if err != nil {
+			metrics.RecordSignIn("internal_error")
+			return nil, err
+		}
+		metrics.RecordSignIn("challenge_required")
+		return nil, ch
+	}
+
+	// 3) Risky attempts without MFA get a one-time code first. The tokens
+	// Cognito just issued are dropped; IssueTokens replaces them once the
+	// code is verified.
+	if stepUp {
+		var user db.User
+		if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+			metrics.RecordSignIn("internal_error")
+			return nil, err
+		}
+		ch, err := s.codeChallenge(ctx, &user, true)
+		if err != nil {
+			metrics.RecordSignIn("internal_error")
+			return nil, err
+		}
+		metrics.RecordSignIn("challenge_required")
+		return nil, ch
+	}
+
+	// 4) Build tokens and persist the refresh token
+	tokens, err := s.persistTokens(ctx, username, ar)
+	if err != nil {
+		metrics.RecordSignIn("internal_error")
+		return nil, err
+	}
+	metrics.RecordSignIn("")
+	return tokens, nil
+}
+
+// RespondToChallenge answers an AuthChallenge from SignIn with a code. It can
+// return another AuthChallenge if Cognito asks for more.
+func (s *AuthServiceImpl) RespondToChallenge(ctx context.Context, username, challenge, session, code string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "RespondToChallenge", attribute.String("auth.challenge", challenge))
+	defer func() { endSpan(span, err) }()
+	defer func() { s.finishSignIn(ctx, username, "challenge", err) }()
+
+	if s.local() || challenge == ChallengeStepUp {
+		user, err := s.verifyChallengeCode(ctx, username, session, code)
+		if err != nil {
+			return nil, err
+		}
+		if s.local() {
+			return s.issueNativeTokens(ctx, user, "")
+		}
+		return s.IssueTokens(ctx, username)
+	}
+
+	raw, err := s.openSession(ctx, username, session, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.CognitoClient.RespondToAuthChallenge(ctx, types.ChallengeNameType(challenge), username, raw, code)
+	if err != nil {
+		return nil, err
+	}
+	if out.AuthenticationResult == nil {
+		if out.ChallengeName == "" {
+			return nil, problem.ErrUpstream.WithDetail("no authentication result returned")
+		}
+		ch, err := s.cognitoChallenge(username, out.ChallengeName, out.Session, out.ChallengeParameters)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ch
+	}
+	return s.persistTokens(ctx, username, out.AuthenticationResult)
+}
+
+// mfaChallenges are the challenges a trusted device may skip.
+var mfaChallenges = map[types.ChallengeNameType]bool{
+	types.ChallengeNameTypeSmsMfa:           true,
+	types.ChallengeNameTypeEmailOtp:         true,
+	types.ChallengeNameTypeSoftwareTokenMfa: true,
+}
+
+// cognitoDeviceTrusted looks up username locally and checks its device cookie.
+func (s *AuthServiceImpl) cognitoDeviceTrusted(ctx context.Context, username string) bool {
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return false
+	}
+	return s.deviceTrusted(ctx, &user)
+}
+
+// signInFailureReason maps a Cognito sign-in error to a low-cardinality metric label.
+func signInFailureReason(err error) string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return "internal_error"
+	}
+	switch apiErr.ErrorCode() {
+	case "NotAuthorizedException", "UserNotFoundException":
+		return "invalid_credentials"
+	case "UserNotConfirmedException":
+		return "not_confirmed"
+	case "PasswordResetRequiredException":
+		return "password_reset_required"
+	case "TooManyRequestsException", "LimitExceededException":
+		return "throttled"
+	default:
+		return "cognito_error"
 	}
+}
+
+// IssueTokens signs a user in without a password, for flows where the caller
+// has already proven who the user is (e.g. an approved QR login). With Cognito
+// it runs the CUSTOM_AUTH flow with a server-signed answer; the local provider
+// signs native tokens.
+func (s *AuthServiceImpl) IssueTokens(ctx context.Context, username string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "IssueTokens")
+	defer func() { endSpan(span, err) }()
 
-	// 2) Build AuthTokens
+	if s.local() {
+		var user db.User
+		if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+			return nil, err
+		}
+		return s.issueNativeTokens(ctx, &user, "")
+	}
+
+	if s.CustomAuthSecret == "" {
+		return nil, errors.New("custom auth secret not configured")
+	}
+
+	// 1) Start CUSTOM_AUTH; the CreateAuthChallenge Lambda issues CUSTOM_CHALLENGE
+	start, err := s.CognitoClient.InitiateCustomAuth(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if start.ChallengeName != types.ChallengeNameTypeCustomChallenge {
+		return nil, fmt.Errorf("unexpected challenge %q", start.ChallengeName)
+	}
+
+	// 2) Answer with a short-lived HMAC the VerifyAuthChallengeResponse Lambda can check
+	out, err := s.CognitoClient.RespondToCustomChallenge(ctx, username,
+		sdkaws.ToString(start.Session), s.customAuthAnswer(username, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	if out.AuthenticationResult == nil {
+		return nil, problem.ErrUpstream.WithDetail("no authentication result returned")
+	}
+
+	// 3) Same persistence as a password sign-in
+	return s.persistTokens(ctx, username, out.AuthenticationResult)
+}
+
+// completeSignIn finishes a sign-in whose first factor was proven without a
+// password, such as a magic link. Like SignIn it refuses blocked attempts and
+// returns a code challenge when the attempt is risky or the user has MFA on
+// an untrusted device.
+func (s *AuthServiceImpl) completeSignIn(ctx context.Context, user *db.User) (*AuthTokens, error) {
+	// 1) Risk
+	assessment, err := s.assessSignIn(ctx, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	if assessment != nil && assessment.Decision == risk.Block {
+		s.recordAttempt(ctx, user.Username, false)
+		return nil, ErrSignInBlocked
+	}
+	stepUp := assessment != nil && assessment.Decision == risk.StepUp
+
+	// 2) Second factor. Cognito can't check our codes, so its users get a
+	// step-up challenge, which RespondToChallenge answers locally.
+	mfa, err := s.mfaEnabled(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if stepUp || (mfa && !s.deviceTrusted(ctx, user)) {
+		ch, err := s.codeChallenge(ctx, user, stepUp || !s.local())
+		if err != nil {
+			return nil, err
+		}
+		ch.Username = user.Username
+		return nil, ch
+	}
+	if s.local() {
+		return s.issueNativeTokens(ctx, user, "")
+	}
+	return s.IssueTokens(ctx, user.Username)
+}
+
+// mfaEnabled reports whether user has a second factor: a local MFA method,
+// or an MFA setting in Cognito.
+func (s *AuthServiceImpl) mfaEnabled(ctx context.Context, user *db.User) (bool, error) {
+	if s.local() {
+		return user.MFAMethod != "", nil
+	}
+	out, err := s.CognitoClient.AdminGetUser(ctx, user.Username)
+	if err != nil {
+		return false, err
+	}
+	return len(out.UserMFASettingList) > 0, nil
+}
+
+// customAuthAnswer builds "<unix>.<hex hmac(username:unix)>".
+func (s *AuthServiceImpl) customAuthAnswer(username string, now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(s.CustomAuthSecret))
+	mac.Write([]byte(username + ":" + ts))
+	return ts + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// persistTokens converts a Cognito result to AuthTokens and records the refresh token.
+func (s *AuthServiceImpl) persistTokens(ctx context.Context, username string, ar *types.AuthenticationResultType) (*AuthTokens, error) {
 	tokens := &AuthTokens{
-		AccessToken:  *ar.AccessToken,
-		IdToken:      *ar.IdToken,
-		RefreshToken: *ar.RefreshToken,
+		AccessToken:  sdkaws.ToString(ar.AccessToken),
+		IdToken:      sdkaws.ToString(ar.IdToken),
+		RefreshToken: sdkaws.ToString(ar.RefreshToken),
 		ExpiresIn:    int64(ar.ExpiresIn),
-		TokenType:    *ar.TokenType,
+		TokenType:    sdkaws.ToString(ar.TokenType),
 	}
 
-	// 3) Persist refresh token
 	var user db.User
-	if err := s.DB.
+	if err := s.DB.WithContext(ctx).
 		Where("username = ?", username).
 		First(&user).Error; err != nil {
 		return nil, err
 	}
+	// Confirm devices Cognito started tracking, then record the device. A
+	// failed confirmation doesn't fail the sign-in; the device just isn't
+	// remembered in Cognito.
+	var deviceKey string
+	if md := ar.NewDeviceMetadata; md != nil && md.DeviceKey != nil {
+		deviceKey = *md.DeviceKey
+		if err := s.CognitoClient.ConfirmDevice(ctx, tokens.AccessToken, deviceKey, ClientInfoFrom(ctx).UserAgent); err != nil {
+			trace.SpanFromContext(ctx).AddEvent("device.confirm_failed",
+				trace.WithAttributes(attribute.String("error.type", errorKind(err))))
+			deviceKey = ""
+		}
+	}
+	if _, err := s.trackDevice(ctx, &user, deviceKey); err != nil {
+		return nil, err
+	}
+	if err := s.syncRolesFromCognito(ctx, &user); err != nil {
+		return nil, err
+	}
+
 	rt := &db.RefreshToken{
 		UserID:    user.ID,
 		Token:     tokens.RefreshToken,
-		ExpiresAt: time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second),
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL()),
 	}
-	if err := s.DB.Create(rt).Error; err != nil {
+	if err := s.DB.WithContext(ctx).Create(rt).Error; err != nil {
 		return nil, err
 	}
 
@@ -90,7 +482,25 @@ func (s *AuthServiceImpl) SignIn(ctx context.Context, username, password string)
 }
 
 // SignOut revokes the session in Cognito and marks the refresh token revoked in DB.
-func (s *AuthServiceImpl) SignOut(ctx context.Context, accessToken string) error {
+func (s *AuthServiceImpl) SignOut(ctx context.Context, accessToken string) (err error) {
+	ctx, span := startSpan(ctx, "SignOut")
+	defer func() { endSpan(span, err) }()
+
+	var username string
+	defer func() { s.auditAction(ctx, "signout", username, err) }()
+
+	if p, ok := s.authenticateNative(accessToken); ok {
+		username = p.Username
+		return s.revokeUserTokens(ctx, p)
+	}
+
+	// 0) Resolve the caller for the audit trail while the token still works
+	if s.Audit != nil {
+		if out, err := s.CognitoClient.GetUser(ctx, accessToken); err == nil {
+			username = sdkaws.ToString(out.Username)
+		}
+	}
+
 	// 1) Cognito global sign-out
 	if err := s.CognitoClient.SignOut(ctx, accessToken); err != nil {
 		return err
@@ -105,60 +515,196 @@ func (s *AuthServiceImpl) SignOut(ctx context.Context, accessToken string) error
 
 // ValidateToken checks the access token via Cognito GetUser API.
 func (s *AuthServiceImpl) ValidateToken(ctx context.Context, token string) error {
-	_, err := s.CognitoClient.GetUser(ctx, token)
+	_, err := s.Authenticate(ctx, token)
 	return err
 }
 
+// Authenticate validates the access token and resolves the caller behind it.
+func (s *AuthServiceImpl) Authenticate(ctx context.Context, token string) (_ *Principal, err error) {
+	ctx, span := startSpan(ctx, "Authenticate")
+	defer func() { endSpan(span, err) }()
+
+	if IsAPIKey(token) {
+		return s.authenticateAPIKey(ctx, token)
+	}
+	if p, ok := s.authenticateNative(token); ok {
+		// Native tokens outlive a disable; their user must still be active
+		if p.ClientID == "" {
+			var user db.User
+			if err := s.DB.WithContext(ctx).Where("username = ?", p.Username).First(&user).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, ErrInvalidToken
+				}
+				return nil, err
+			}
+			if user.DisabledAt != nil {
+				return nil, ErrUserDisabled
+			}
+		}
+		return p, s.resolvePermissions(ctx, p)
+	}
+	if s.local() {
+		return nil, ErrInvalidToken
+	}
+
+	out, err := s.CognitoClient.GetUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	p := &Principal{
+		Username:    sdkaws.ToString(out.Username),
+		AccessToken: token,
+	}
+	for _, attr := range out.UserAttributes {
+		if sdkaws.ToString(attr.Name) == "sub" {
+			p.Subject = sdkaws.ToString(attr.Value)
+		}
+	}
+	// GetUser doesn't return groups; they are mirrored as user roles
+	if p.Roles, err = s.userRoles(ctx, p.Username); err != nil {
+		return nil, err
+	}
+	return p, s.resolvePermissions(ctx, p)
+}
+
 // ConfirmSignUp confirms a user's sign-up using a verification code.
-func (s *AuthServiceImpl) ConfirmSignUp(ctx context.Context, username, code string) error {
-	return s.CognitoClient.ConfirmSignUp(ctx, username, code)
+func (s *AuthServiceImpl) ConfirmSignUp(ctx context.Context, username, code string) (err error) {
+	ctx, span := startSpan(ctx, "ConfirmSignUp")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAction(ctx, "signup.confirmed", username, err) }()
+
+	// Local accounts need no confirmation.
+	if s.local() {
+		return nil
+	}
+	if err := s.CognitoClient.ConfirmSignUp(ctx, username, code); err != nil {
+		return err
+	}
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.saveUser(ctx, outbox.UserConfirmed, &user, nil, func(tx *gorm.DB) error {
+		user.ConfirmedAt = &now
+		return tx.Model(&user).Update("confirmed_at", now).Error
+	}); err != nil {
+		return err
+	}
+	metrics.RecordConfirmation()
+	return nil
 }
 
-// RefreshTokens handles refresh‐token rotation and revocation.
+// RefreshTokens handles refresh‐token rotation and revocation for the
+// service's own sign-ins; tokens issued to OAuth clients are refused.
 func (s *AuthServiceImpl) RefreshTokens(ctx context.Context, refreshToken string) (*AuthTokens, error) {
+	tokens, _, err := s.refreshTokens(ctx, refreshToken, "")
+	return tokens, err
+}
+
+// refreshTokens rotates refreshToken if it was issued to clientID, empty for
+// first-party tokens, and returns the new tokens and the token's scope.
+func (s *AuthServiceImpl) refreshTokens(ctx context.Context, refreshToken, clientID string) (_ *AuthTokens, _ string, err error) {
+	ctx, span := startSpan(ctx, "RefreshTokens")
+	defer func() { endSpan(span, err) }()
+
+	var username string
+	defer func() { s.auditAction(ctx, "token.refreshed", username, err) }()
+
 	// 1) Lookup existing, non‐revoked token
 	var rt db.RefreshToken
 	if err := s.DB.
 		Where("token = ? AND revoked = false", refreshToken).
 		First(&rt).Error; err != nil {
-		return nil, err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", s.detectRefreshReuse(ctx, refreshToken)
+		}
+		return nil, "", err
+	}
+	// Tokens only work for the client they were issued to
+	if rt.ClientID != clientID {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	var owner db.User
+	if err := s.DB.WithContext(ctx).Select("username").First(&owner, rt.UserID).Error; err == nil {
+		username = owner.Username
+	}
+
+	if s.local() {
+		tokens, err := s.localRefresh(ctx, &rt)
+		if err != nil {
+			return nil, "", err
+		}
+		if rt.ClientID != "" {
+			err = s.bindRefreshToken(ctx, tokens.RefreshToken, rt.ClientID, rt.Scope)
+		}
+		return tokens, rt.Scope, err
 	}
 
 	// 2) Rotate via Cognito
 	out, err := s.CognitoClient.RefreshAuth(ctx, refreshToken)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	ar := out.AuthenticationResult
 	if ar == nil {
-		return nil, errors.New("authentication failed: no result returned")
+		return nil, "", problem.ErrUpstream.WithDetail("no authentication result returned")
+	}
+	tokens := &AuthTokens{
+		AccessToken:  sdkaws.ToString(ar.AccessToken),
+		IdToken:      sdkaws.ToString(ar.IdToken),
+		RefreshToken: sdkaws.ToString(ar.RefreshToken),
+		ExpiresIn:    int64(ar.ExpiresIn),
+		TokenType:    sdkaws.ToString(ar.TokenType),
 	}
 
-	// 3) Revoke old and record previous token
-	if err := s.DB.Model(&rt).Updates(db.RefreshToken{
-		Revoked:       true,
-		PreviousToken: rt.Token,
-	}).Error; err != nil {
-		return nil, err
+	// Without refresh-token rotation enabled on the app client, Cognito
+	// returns no new refresh token and the current one stays valid.
+	span.SetAttributes(attribute.Bool("auth.refresh.rotated", tokens.RefreshToken != ""))
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = refreshToken
+		return tokens, rt.Scope, nil
+	}
+
+	// 3) Revoke old
+	if err := s.DB.Model(&rt).Update("revoked", true).Error; err != nil {
+		return nil, "", err
 	}
 
 	// 4) Insert new refresh token record
 	newRT := &db.RefreshToken{
 		UserID:        rt.UserID,
-		Token:         *ar.RefreshToken,
-		ExpiresAt:     time.Now().Add(time.Duration(ar.ExpiresIn) * time.Second),
+		Token:         tokens.RefreshToken,
+		ExpiresAt:     time.Now().Add(s.refreshTokenTTL()),
 		PreviousToken: rt.Token,
+		ClientID:      rt.ClientID,
+		Scope:         rt.Scope,
 	}
 	if err := s.DB.Create(newRT).Error; err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	metrics.RecordRefreshRotation()
 
 	// 5) Return the refreshed tokens
-	return &AuthTokens{
-		AccessToken:  *ar.AccessToken,
-		IdToken:      *ar.IdToken,
-		RefreshToken: *ar.RefreshToken,
-		ExpiresIn:    int64(ar.ExpiresIn),
-		TokenType:    *ar.TokenType,
-	}, nil
+	return tokens, rt.Scope, nil
+}
+
+// detectRefreshReuse is called for tokens that are unknown or revoked. A revoked
+// token coming back means it leaked, so all of the user's refresh tokens are
+// revoked and ErrRefreshTokenReused is returned.
+func (s *AuthServiceImpl) detectRefreshReuse(ctx context.Context, refreshToken string) error {
+	var rt db.RefreshToken
+	if err := s.DB.WithContext(ctx).
+		Where("token = ? AND revoked = true", refreshToken).
+		First(&rt).Error; err != nil {
+		return ErrInvalidRefreshToken
+	}
+	metrics.RecordRefreshReuse()
+	if err := s.DB.WithContext(ctx).
+		Model(&db.RefreshToken{}).
+		Where("user_id = ? AND revoked = false", rt.UserID).
+		Update("revoked", true).Error; err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
 }
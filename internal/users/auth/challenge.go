@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/users/db"
+
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"gorm.io/gorm/clause"
+)
+
+// AuthChallenge is returned by SignIn when the user must also answer a
+// second factor through RespondToChallenge. errors.Is(err, ErrChallengeRequired)
+// holds for it.
+type AuthChallenge struct {
+	Name        string `json:"challenge_name"`        // SMS_MFA, EMAIL_OTP, SOFTWARE_TOKEN_MFA, ...
+	Session     string `json:"session"`               // pass back to RespondToChallenge
+	Destination string `json:"destination,omitempty"` // masked email or phone the code went to
+	Username    string `json:"username,omitempty"`    // set for sign-ins that didn't start with a username
+}
+
+func (c *AuthChallenge) Error() string { return "auth challenge required: " + c.Name }
+
+func (c *AuthChallenge) Unwrap() error { return ErrChallengeRequired }
+
+// Challenge names, matching Cognito's.
+const (
+	ChallengeSMSMFA   = "SMS_MFA"
+	ChallengeEmailOTP = "EMAIL_OTP"
+	ChallengeStepUp   = "STEP_UP_OTP" // risk-based; answered locally even with Cognito
+)
+
+// challengeSessionTTL matches the lifetime of a Cognito challenge session.
+const challengeSessionTTL = 5 * time.Minute
+
+// sealSession appends "~<unix expiry>~<hmac>" to a Cognito challenge session,
+// so a recovery code answering it can prove the password step passed. It
+// fails without SessionSecret rather than hand out an unsealed session.
+func (s *AuthServiceImpl) sealSession(username, session string, now time.Time) (string, error) {
+	if s.SessionSecret == "" {
+		return "", errors.New("challenge session secret not configured")
+	}
+	payload := session + "~" + strconv.FormatInt(now.Add(challengeSessionTTL).Unix(), 10)
+	return payload + "~" + hex.EncodeToString(s.sessionMAC(username, payload)), nil
+}
+
+// cognitoChallenge wraps a challenge Cognito returned, sealing its session.
+func (s *AuthServiceImpl) cognitoChallenge(username string, name types.ChallengeNameType, session *string, params map[string]string) (*AuthChallenge, error) {
+	sealed, err := s.sealSession(username, sdkaws.ToString(session), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &AuthChallenge{
+		Name:        string(name),
+		Session:     sealed,
+		Destination: params["CODE_DELIVERY_DESTINATION"],
+	}, nil
+}
+
+// openSession returns the Cognito session inside a sealed one. Each sealed
+// session opens once; ErrInvalidChallengeSession is returned for forged,
+// expired and replayed ones.
+func (s *AuthServiceImpl) openSession(ctx context.Context, username, sealed string, now time.Time) (string, error) {
+	i := strings.LastIndex(sealed, "~")
+	if i < 0 || s.SessionSecret == "" {
+		return "", ErrInvalidChallengeSession
+	}
+	payload, sig := sealed[:i], sealed[i+1:]
+	mac, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.sessionMAC(username, payload)) {
+		return "", ErrInvalidChallengeSession
+	}
+	j := strings.LastIndex(payload, "~")
+	exp, err := strconv.ParseInt(payload[j+1:], 10, 64)
+	if j < 0 || err != nil || now.Unix() >= exp {
+		return "", ErrInvalidChallengeSession
+	}
+
+	// Remember the session until it expires; a second use is a replay
+	tx := s.DB.WithContext(ctx)
+	if err := tx.Where("expires_at < ?", now).Delete(&db.UsedChallengeSession{}).Error; err != nil {
+		return "", err
+	}
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&db.UsedChallengeSession{ID: hex.EncodeToString(mac), ExpiresAt: time.Unix(exp, 0)})
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", ErrInvalidChallengeSession
+	}
+	return payload[:j], nil
+}
+
+func (s *AuthServiceImpl) sessionMAC(username, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.SessionSecret))
+	mac.Write([]byte(username + ":" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ClientInfo describes where a request comes from. Handlers attach it to the
+// context so the service can track devices and score risk.
+type ClientInfo struct {
+	IP          string
+	UserAgent   string
+	Fingerprint string // optional, from the X-Device-Fingerprint header
+	DeviceToken string // signed trusted-device cookie, if any
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo returns ctx carrying ci.
+func WithClientInfo(ctx context.Context, ci ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, ci)
+}
+
+// ClientInfoFrom returns the ClientInfo attached to ctx, or the zero value.
+func ClientInfoFrom(ctx context.Context) ClientInfo {
+	ci, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return ci
+}
+
+// RequestContext returns the request context with the caller's ClientInfo.
+func RequestContext(c echo.Context) context.Context {
+	ci := ClientInfo{
+		IP:          c.RealIP(),
+		UserAgent:   c.Request().UserAgent(),
+		Fingerprint: c.Request().Header.Get("X-Device-Fingerprint"),
+	}
+	if cookie, err := c.Cookie(deviceCookie); err == nil {
+		ci.DeviceToken = cookie.Value
+	}
+	return WithClientInfo(c.Request().Context(), ci)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+
+	"simple-go-auth/internal/users/db"
+)
+
+// IssueClientTokens signs username in for an OAuth client, like
+// IssueTokens, and binds the refresh token to clientID and the granted
+// scope: only that client can use it, through RefreshClientTokens.
+func (s *AuthServiceImpl) IssueClientTokens(ctx context.Context, username, clientID, scope string) (*AuthTokens, error) {
+	tokens, err := s.IssueTokens(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.bindRefreshToken(ctx, tokens.RefreshToken, clientID, scope); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RefreshClientTokens rotates a refresh token issued to clientID and
+// returns the new tokens with the scope of the original grant. Tokens of
+// other clients and first-party tokens are ErrInvalidRefreshToken.
+func (s *AuthServiceImpl) RefreshClientTokens(ctx context.Context, refreshToken, clientID string) (*AuthTokens, string, error) {
+	return s.refreshTokens(ctx, refreshToken, clientID)
+}
+
+// bindRefreshToken ties a stored refresh token to an OAuth client.
+func (s *AuthServiceImpl) bindRefreshToken(ctx context.Context, refreshToken, clientID, scope string) error {
+	return s.DB.WithContext(ctx).Model(&db.RefreshToken{}).
+		Where("token = ?", refreshToken).
+		Updates(map[string]any{"client_id": clientID, "scope": scope}).Error
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// ListDevices returns the devices the caller has signed in from.
+func (h *AuthHandler) ListDevices(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	devices, err := h.Service.ListDevices(c.Request().Context(), p)
+	if err != nil {
+		return err
+	}
+	out := make([]map[string]any, 0, len(devices))
+	for _, d := range devices {
+		out = append(out, map[string]any{
+			"id":            d.ID,
+			"user_agent":    d.UserAgent,
+			"ip":            d.IP,
+			"trusted":       d.Trusted,
+			"trusted_until": d.TrustedUntil,
+			"first_seen_at": d.FirstSeenAt,
+			"last_seen_at":  d.LastSeenAt,
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"devices": out})
+}
+
+// ForgetDevice removes one of the caller's devices. A trusted device has to
+// pass MFA again.
+func (h *AuthHandler) ForgetDevice(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrDeviceNotFound
+	}
+	if err := h.Service.ForgetDevice(RequestContext(c), p, uint(id)); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/token"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deviceCookie holds the signed token of a trusted device.
+const deviceCookie = "device_token"
+
+// trackDevice records the device from ctx's ClientInfo for user and returns
+// it; nil when ctx carries no ClientInfo. deviceKey is Cognito's key for the
+// device, if it reported one.
+func (s *AuthServiceImpl) trackDevice(ctx context.Context, user *db.User, deviceKey string) (*db.Device, error) {
+	ci := ClientInfoFrom(ctx)
+	if ci.UserAgent == "" && ci.Fingerprint == "" {
+		return nil, nil
+	}
+	now := time.Now()
+	d := &db.Device{
+		UserID:      user.ID,
+		Fingerprint: deviceFingerprint(ci),
+		DeviceKey:   deviceKey,
+		UserAgent:   ci.UserAgent,
+		IP:          ci.IP,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+	update := []string{"user_agent", "ip", "last_seen_at"}
+	if deviceKey != "" {
+		update = append(update, "device_key")
+	}
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "fingerprint"}},
+		DoUpdates: clause.AssignmentColumns(update),
+	}).Create(d).Error
+	if err != nil {
+		return nil, err
+	}
+	// Re-read: on conflict d still holds the new values, not the stored row.
+	if err := s.DB.WithContext(ctx).
+		Where("user_id = ? AND fingerprint = ?", user.ID, d.Fingerprint).
+		First(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// deviceFingerprint prefers the client's own fingerprint over the user agent.
+func deviceFingerprint(ci ClientInfo) string {
+	if ci.Fingerprint != "" {
+		return token.Hash("fp:" + ci.Fingerprint)
+	}
+	return token.Hash("ua:" + ci.UserAgent)
+}
+
+// RememberDevice trusts the caller's device for DeviceTrustTTL and returns
+// the value of the device cookie that lets it skip MFA.
+func (s *AuthServiceImpl) RememberDevice(ctx context.Context, username string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "RememberDevice")
+	defer func() { endSpan(span, err) }()
+
+	if s.DeviceSecret == "" {
+		return "", errors.New("device cookie secret not configured")
+	}
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return "", err
+	}
+	d, err := s.trackDevice(ctx, &user, "")
+	if err != nil {
+		return "", err
+	}
+	if d == nil {
+		return "", ErrDeviceUnknown
+	}
+	until := time.Now().Add(s.DeviceTrustTTL)
+	if err := s.DB.WithContext(ctx).Model(d).Updates(map[string]any{
+		"trusted":       true,
+		"trusted_until": until,
+	}).Error; err != nil {
+		return "", err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Action:  "device.trusted",
+		Actor:   username,
+		Subject: username,
+		IP:      d.IP,
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]any{"device_id": d.ID},
+	})
+	return s.signDeviceToken(username, d.ID, until), nil
+}
+
+// deviceTrusted reports whether ctx's device cookie belongs to a device user
+// still trusts, on the same device it was issued to.
+func (s *AuthServiceImpl) deviceTrusted(ctx context.Context, user *db.User) bool {
+	ci := ClientInfoFrom(ctx)
+	id, ok := s.parseDeviceToken(user.Username, ci.DeviceToken, time.Now())
+	if !ok {
+		return false
+	}
+	var d db.Device
+	err := s.DB.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND fingerprint = ? AND trusted = ? AND trusted_until > ?",
+			id, user.ID, deviceFingerprint(ci), true, time.Now()).
+		First(&d).Error
+	return err == nil
+}
+
+// ListDevices returns the caller's devices, most recently used first.
+func (s *AuthServiceImpl) ListDevices(ctx context.Context, p *Principal) (_ []db.Device, err error) {
+	ctx, span := startSpan(ctx, "ListDevices")
+	defer func() { endSpan(span, err) }()
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", p.Username).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	// Devices forgotten in Cognito directly lose their key here too. The
+	// admin API is used as the caller may hold a native token or API key.
+	if !s.local() && s.CognitoClient != nil {
+		tracked, err := s.CognitoClient.AdminListDevices(ctx, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(tracked))
+		for _, d := range tracked {
+			if d.DeviceKey != nil {
+				keys = append(keys, *d.DeviceKey)
+			}
+		}
+		q := s.DB.WithContext(ctx).Model(&db.Device{}).Where("user_id = ? AND device_key <> ''", user.ID)
+		if len(keys) > 0 {
+			q = q.Where("device_key NOT IN ?", keys)
+		}
+		if err := q.Update("device_key", "").Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var devices []db.Device
+	err = s.DB.WithContext(ctx).
+		Where("user_id = ?", user.ID).
+		Order("last_seen_at DESC").
+		Find(&devices).Error
+	return devices, err
+}
+
+// ForgetDevice deletes one of the caller's devices; its cookie stops working.
+func (s *AuthServiceImpl) ForgetDevice(ctx context.Context, p *Principal, id uint) (err error) {
+	ctx, span := startSpan(ctx, "ForgetDevice")
+	defer func() { endSpan(span, err) }()
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", p.Username).First(&user).Error; err != nil {
+		return err
+	}
+	var d db.Device
+	if err := s.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, user.ID).First(&d).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+	if d.DeviceKey != "" && !s.local() {
+		if err := s.CognitoClient.AdminForgetDevice(ctx, user.Username, d.DeviceKey); err != nil {
+			return err
+		}
+	}
+	if err := s.DB.WithContext(ctx).Delete(&d).Error; err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Action:  "device.forgotten",
+		Actor:   p.Username,
+		Subject: p.Username,
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]any{"device_id": d.ID},
+	})
+	return nil
+}
+
+// signDeviceToken builds "<device id>.<unix expiry>.<base64url hmac>", bound to username.
+func (s *AuthServiceImpl) signDeviceToken(username string, id uint, until time.Time) string {
+	payload := strconv.FormatUint(uint64(id), 10) + "." + strconv.FormatInt(until.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.deviceMAC(username, payload))
+}
+
+func (s *AuthServiceImpl) parseDeviceToken(username, tok string, now time.Time) (uint, bool) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 || s.DeviceSecret == "" {
+		return 0, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, s.deviceMAC(username, parts[0]+"."+parts[1])) {
+		return 0, false
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (s *AuthServiceImpl) deviceMAC(username, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.DeviceSecret))
+	mac.Write([]byte(username + ":" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"net/http"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// Domain errors returned by AuthServiceImpl and AuthHandler. They render as
+// application/problem+json through problem.HTTPErrorHandler.
+var (
+	ErrCaptchaFailed           = problem.New(http.StatusBadRequest, "captcha_failed", "Captcha verification failed")
+	ErrWeakPassword            = problem.New(http.StatusBadRequest, "weak_password", "Password must be at least 8 characters and include an uppercase letter and a digit")
+	ErrInvalidCredentials      = problem.New(http.StatusUnauthorized, "invalid_credentials", "Invalid username or password")
+	ErrChallengeRequired       = problem.New(http.StatusUnauthorized, "challenge_required", "Additional authentication is required")
+	ErrTokenRequired           = problem.New(http.StatusUnauthorized, "token_required", "An access token is required")
+	ErrInvalidToken            = problem.New(http.StatusUnauthorized, "invalid_token", "The access token is invalid or expired")
+	ErrInvalidRefreshToken     = problem.New(http.StatusUnauthorized, "invalid_refresh_token", "The refresh token is invalid, expired or revoked")
+	ErrRefreshTokenReused      = problem.New(http.StatusUnauthorized, "refresh_token_reused", "Refresh token reuse detected; all sessions were revoked")
+	ErrChallengeNotFound       = problem.New(http.StatusNotFound, "login_challenge_not_found", "Login challenge not found or expired")
+	ErrChallengeNotApproved    = problem.New(http.StatusConflict, "login_challenge_not_approved", "Login challenge not approved")
+	ErrInvalidMagicLink        = problem.New(http.StatusUnauthorized, "invalid_magic_link", "The sign-in link is invalid, expired or already used")
+	ErrInvalidOTP              = problem.New(http.StatusUnauthorized, "invalid_otp", "The code is invalid or expired")
+	ErrOTPAttemptsExceeded     = problem.New(http.StatusTooManyRequests, "otp_attempts_exceeded", "Too many wrong codes; request a new one")
+	ErrInvalidChannel          = problem.New(http.StatusBadRequest, "invalid_channel", "Channel must be email or sms")
+	ErrInvalidChallengeSession = problem.New(http.StatusUnauthorized, "invalid_challenge_session", "The challenge session is invalid or expired")
+	ErrInvalidRecoveryCode     = problem.New(http.StatusUnauthorized, "invalid_recovery_code", "The recovery code is invalid or already used")
+	ErrMFANotEnabled           = problem.New(http.StatusConflict, "mfa_not_enabled", "MFA is not enabled for this account")
+	ErrMFASetupFailed          = problem.New(http.StatusBadRequest, "mfa_setup_failed", "The authenticator code did not match")
+	ErrDeviceUnknown           = problem.New(http.StatusBadRequest, "device_unknown", "The device could not be identified")
+	ErrDeviceNotFound          = problem.New(http.StatusNotFound, "device_not_found", "Device not found")
+	ErrSignInBlocked           = problem.New(http.StatusForbidden, "signin_blocked", "This sign-in attempt was blocked")
+	ErrUserNotFound            = problem.New(http.StatusNotFound, "user_not_found", "User not found")
+	ErrUserDisabled            = problem.New(http.StatusForbidden, "user_disabled", "This account has been disabled")
+	ErrPasswordResetRequired   = problem.New(http.StatusForbidden, "password_reset_required", "The password must be reset before signing in")
+	ErrInvalidUserFilter       = problem.New(http.StatusBadRequest, "invalid_user_filter", "The user filter is invalid")
+	ErrAPIKeyNotFound          = problem.New(http.StatusNotFound, "api_key_not_found", "API key not found")
+	ErrInvalidAPIKey           = problem.New(http.StatusUnauthorized, "invalid_api_key", "The API key is invalid, expired or revoked")
+	ErrInvalidAPIKeyRequest    = problem.New(http.StatusBadRequest, "invalid_api_key_request", "Invalid API key request")
+	ErrAPIKeyNotAllowed        = problem.New(http.StatusForbidden, "api_key_not_allowed", "Only users can manage API keys")
+	ErrSessionRequired         = problem.New(http.StatusForbidden, "session_required", "This action requires a signed-in user, not an API key or client")
+	ErrRoleNotFound            = problem.New(http.StatusNotFound, "role_not_found", "Role not found")
+	ErrSelfAdminAction         = problem.New(http.StatusConflict, "self_admin_action", "Administrators can't disable or delete their own account")
+)
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/token"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Identity providers selectable through AUTH_PROVIDER.
+const (
+	ProviderCognito = "cognito"
+	ProviderLocal   = "local"
+)
+
+// Token lifetimes when AccessTokenTTL and RefreshTokenTTL are unset. 30
+// days is also Cognito's default refresh token expiration.
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// dummyHash keeps unknown-user sign-ins as slow as wrong-password ones.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("simple-go-auth"), bcrypt.DefaultCost)
+
+// local reports whether users live in our own database instead of Cognito.
+func (s *AuthServiceImpl) local() bool {
+	return s.Provider == ProviderLocal
+}
+
+func (s *AuthServiceImpl) accessTokenTTL() time.Duration {
+	if s.AccessTokenTTL > 0 {
+		return s.AccessTokenTTL
+	}
+	return defaultAccessTokenTTL
+}
+
+func (s *AuthServiceImpl) refreshTokenTTL() time.Duration {
+	if s.RefreshTokenTTL > 0 {
+		return s.RefreshTokenTTL
+	}
+	return defaultRefreshTokenTTL
+}
+
+// localSignIn checks the bcrypt password hash stored on db.User. stepUp
+// demands a one-time code even without MFA or on a trusted device.
+func (s *AuthServiceImpl) localSignIn(ctx context.Context, username, password string, stepUp bool) (*AuthTokens, error) {
+	var user db.User
+	err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+	if stepUp || (user.MFAMethod != "" && !s.deviceTrusted(ctx, &user)) {
+		ch, err := s.codeChallenge(ctx, &user, stepUp)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ch
+	}
+	return s.issueNativeTokens(ctx, &user, "")
+}
+
+// issueNativeTokens signs access and ID tokens for user and stores a new
+// opaque refresh token; previous links it to the token it replaces.
+func (s *AuthServiceImpl) issueNativeTokens(ctx context.Context, user *db.User, previous string) (*AuthTokens, error) {
+	if s.Tokens == nil {
+		return nil, errors.New("token signer not configured")
+	}
+	// Every sign-in method and refresh ends here, so this check covers them all
+	if user.DisabledAt != nil {
+		return nil, ErrUserDisabled
+	}
+	ttl := s.accessTokenTTL()
+	sub := strconv.FormatUint(uint64(user.ID), 10)
+	roles, err := s.rolesOf(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.Tokens.Sign(jwt.MapClaims{
+		"sub":       sub,
+		"username":  user.Username,
+		"roles":     roles,
+		"token_use": "access",
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.Tokens.Sign(jwt.MapClaims{
+		"sub":       sub,
+		"aud":       s.Tokens.Issuer(),
+		"email":     user.Email,
+		"username":  user.Username,
+		"roles":     roles,
+		"token_use": "id",
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.trackDevice(ctx, user, ""); err != nil {
+		return nil, err
+	}
+
+	rt := &db.RefreshToken{
+		UserID:        user.ID,
+		Token:         token.RandomString(32),
+		ExpiresAt:     time.Now().Add(s.refreshTokenTTL()),
+		PreviousToken: previous,
+	}
+	if err := s.DB.WithContext(ctx).Create(rt).Error; err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  access,
+		IdToken:      id,
+		RefreshToken: rt.Token,
+		ExpiresIn:    int64(ttl.Seconds()),
+		TokenType:    "Bearer",
+	}, nil
+}
+
+// authenticateNative resolves a native access token. ok is false when the
+// token was not issued by s.Tokens.
+func (s *AuthServiceImpl) authenticateNative(tokenString string) (p *Principal, ok bool) {
+	if s.Tokens == nil {
+		return nil, false
+	}
+	claims, err := s.Tokens.Verify(tokenString)
+	if err != nil {
+		return nil, false
+	}
+	if claims["token_use"] == "client" {
+		return s.clientPrincipal(tokenString, claims)
+	}
+	if claims["token_use"] != "access" {
+		return nil, false
+	}
+	sub, _ := claims["sub"].(string)
+	username, _ := claims["username"].(string)
+	p = &Principal{Subject: sub, Username: username, AccessToken: tokenString}
+	if roles, ok := claims["roles"].([]any); ok {
+		for _, r := range roles {
+			if name, ok := r.(string); ok {
+				p.Roles = append(p.Roles, name)
+			}
+		}
+	}
+	return p, true
+}
+
+// clientPrincipal is the caller of an OAuth client_credentials token meant
+// for this service: its scopes are its permissions.
+func (s *AuthServiceImpl) clientPrincipal(tokenString string, claims jwt.MapClaims) (*Principal, bool) {
+	aud, _ := claims.GetAudience()
+	if !slices.Contains(aud, s.Tokens.Issuer()) {
+		return nil, false
+	}
+	clientID, _ := claims["client_id"].(string)
+	scopes, _ := claims["scope"].(string)
+	return &Principal{
+		Subject:     clientID,
+		ClientID:    clientID,
+		Scopes:      strings.Fields(scopes),
+		Permissions: strings.Fields(scopes),
+		AccessToken: tokenString,
+	}, true
+}
+
+// localRefresh rotates a stored native refresh token.
+func (s *AuthServiceImpl) localRefresh(ctx context.Context, rt *db.RefreshToken) (*AuthTokens, error) {
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	// Revoke first and only if still live, so concurrent refreshes can't both rotate.
+	res := s.DB.WithContext(ctx).
+		Model(&db.RefreshToken{}).
+		Where("id = ? AND revoked = false", rt.ID).
+		Update("revoked", true)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, s.detectRefreshReuse(ctx, rt.Token)
+	}
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).First(&user, rt.UserID).Error; err != nil {
+		return nil, err
+	}
+	return s.issueNativeTokens(ctx, &user, rt.Token)
+}
+
+// revokeUserTokens revokes every refresh token of the user behind a native access token.
+func (s *AuthServiceImpl) revokeUserTokens(ctx context.Context, p *Principal) error {
+	return s.DB.WithContext(ctx).
+		Model(&db.RefreshToken{}).
+		Where("user_id = ? AND revoked = false", p.Subject).
+		Update("revoked", true).
+		Error
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/token"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RequestMagicLink emails a single-use sign-in link to the account owning
+// email. The link only works in the browser holding nonce. It returns nil
+// whether or not the account exists so callers can't probe for addresses.
+func (s *AuthServiceImpl) RequestMagicLink(ctx context.Context, email, nonce, locale string) (err error) {
+	ctx, span := startSpan(ctx, "RequestMagicLink")
+	defer func() { endSpan(span, err) }()
+
+	// Fail on configuration before looking at the account, so the outcome
+	// doesn't depend on whether it exists.
+	if s.MagicLinkSecret == "" || s.Notifier == nil || s.Templates == nil {
+		return errors.New("magic link sign-in not configured")
+	}
+
+	// 1) Lookup; unknown addresses end here silently
+	var user db.User
+	err = s.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// 2) Store and send the link. Failures past this point, such as a full
+	// notification queue, would only happen for real accounts, so they are
+	// logged and the caller sees the same result as for an unknown address.
+	if err := s.sendMagicLink(ctx, &user, nonce, locale); err != nil {
+		s.Logger.Warn("magic link not sent", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+	return nil
+}
+
+// sendMagicLink stores a link for user, bound to the browser nonce, and
+// emails it.
+func (s *AuthServiceImpl) sendMagicLink(ctx context.Context, user *db.User, nonce, locale string) error {
+	link := &db.MagicLink{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		NonceHash: token.Hash(nonce),
+		ExpiresAt: time.Now().Add(s.MagicLinkTTL),
+	}
+	if err := s.DB.WithContext(ctx).Create(link).Error; err != nil {
+		return err
+	}
+
+	u, err := url.Parse(s.MagicLinkURL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("token", s.signMagicLink(link.ID, link.ExpiresAt))
+	u.RawQuery = q.Encode()
+
+	msg, err := s.Templates.Email("magic_link", locale, user.Email, map[string]any{
+		"Username":  user.Username,
+		"Link":      u.String(),
+		"ExpiresIn": s.MagicLinkTTL.String(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Notifier.Send(ctx, msg)
+}
+
+// VerifyMagicLink consumes a link requested from the browser holding nonce
+// and signs its owner in, or returns an AuthChallenge when MFA or the risk
+// engine asks for a second factor.
+func (s *AuthServiceImpl) VerifyMagicLink(ctx context.Context, link, nonce string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "VerifyMagicLink")
+	defer func() { endSpan(span, err) }()
+
+	var username string
+	defer func() { s.finishSignIn(ctx, username, "magic_link", err) }()
+
+	// 1) Signature and expiry, before touching the database
+	id, ok := s.parseMagicLink(link, time.Now())
+	if !ok {
+		return nil, ErrInvalidMagicLink
+	}
+
+	var ml db.MagicLink
+	if err := s.DB.WithContext(ctx).Where("id = ?", id).First(&ml).Error; err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	// 2) Same browser that asked for it
+	if subtle.ConstantTimeCompare([]byte(ml.NonceHash), []byte(token.Hash(nonce))) != 1 {
+		return nil, ErrInvalidMagicLink
+	}
+
+	// 3) Mark used atomically so a replayed link can't sign in twice
+	now := time.Now()
+	res := s.DB.WithContext(ctx).
+		Model(&db.MagicLink{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidMagicLink
+	}
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).First(&user, ml.UserID).Error; err != nil {
+		return nil, err
+	}
+	username = user.Username
+	return s.completeSignIn(ctx, &user)
+}
+
+// signMagicLink builds "<id>.<unix expiry>.<base64url hmac(id.expiry)>".
+func (s *AuthServiceImpl) signMagicLink(id string, expires time.Time) string {
+	payload := id + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.magicLinkMAC(payload))
+}
+
+// parseMagicLink returns the link ID if the signature holds and the link hasn't expired.
+func (s *AuthServiceImpl) parseMagicLink(link string, now time.Time) (string, bool) {
+	parts := strings.Split(link, ".")
+	if len(parts) != 3 || s.MagicLinkSecret == "" {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, s.magicLinkMAC(parts[0]+"."+parts[1])) {
+		return "", false
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func (s *AuthServiceImpl) magicLinkMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.MagicLinkSecret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/token"
+)
+
+// magicLinkCookie holds the nonce binding a magic link to the browser that requested it.
+const magicLinkCookie = "magic_link_nonce"
+
+// RequestMagicLink emails a sign-in link. The response is the same whether or
+// not the address belongs to an account.
+func (h *AuthHandler) RequestMagicLink(c echo.Context) error {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	if !emailRe.MatchString(req.Email) {
+		return problem.ErrBadRequest.WithDetail("invalid email format")
+	}
+
+	// 1) Bind the link to this browser
+	nonce := token.RandomString(32)
+	c.SetCookie(&http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    nonce,
+		Path:     "/signin/magic",
+		MaxAge:   int(h.Service.MagicLinkTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// 2) Send it
+	if err := h.Service.RequestMagicLink(RequestContext(c), req.Email, nonce, acceptLanguage(c)); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "if the address belongs to an account, a sign-in link has been sent",
+	})
+}
+
+// VerifyMagicLink exchanges a magic link token for AuthTokens. The token comes
+// from the "token" query parameter (the emailed link) or the JSON body.
+func (h *AuthHandler) VerifyMagicLink(c echo.Context) error {
+	link := c.QueryParam("token")
+	if link == "" {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return problem.ErrInvalidBody
+		}
+		link = req.Token
+	}
+	cookie, err := c.Cookie(magicLinkCookie)
+	if err != nil || link == "" {
+		return ErrInvalidMagicLink
+	}
+
+	tokens, err := h.Service.VerifyMagicLink(RequestContext(c), link, cookie.Value)
+	if err != nil && !errors.Is(err, ErrChallengeRequired) {
+		return err
+	}
+	c.SetCookie(&http.Cookie{Name: magicLinkCookie, Path: "/signin/magic", MaxAge: -1, HttpOnly: true, Secure: true})
+	if err != nil {
+		return challengeOrError(c, err)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// acceptLanguage returns the first language tag of the Accept-Language header.
+func acceptLanguage(c echo.Context) string {
+	lang, _, _ := strings.Cut(c.Request().Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
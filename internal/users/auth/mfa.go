@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/notify"
+
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// CodeMFASetup is the purpose of codes confirming a new local second factor.
+const CodeMFASetup = "mfa_setup"
+
+// MFASetup is the first step of enabling MFA.
+type MFASetup struct {
+	SecretCode  string `json:"secret_code,omitempty"` // TOTP secret for the authenticator app (Cognito)
+	Session     string `json:"session,omitempty"`     // pass back to CompleteMFASetup
+	Destination string `json:"destination,omitempty"` // masked, where the confirmation code went
+}
+
+// BeginMFASetup starts enabling MFA. With Cognito it associates a TOTP
+// authenticator; the local provider sends a code over method (email or sms,
+// the latter to phone). A new phone is only saved by CompleteMFASetup.
+func (s *AuthServiceImpl) BeginMFASetup(ctx context.Context, p *Principal, method, phone string) (_ *MFASetup, err error) {
+	ctx, span := startSpan(ctx, "BeginMFASetup")
+	defer func() { endSpan(span, err) }()
+
+	if !s.local() {
+		out, err := s.CognitoClient.AssociateSoftwareToken(ctx, p.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		return &MFASetup{SecretCode: sdkaws.ToString(out.SecretCode), Session: sdkaws.ToString(out.Session)}, nil
+	}
+
+	if err := s.checkCodeDelivery(method); err != nil {
+		return nil, err
+	}
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", p.Username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	dest := user.Email
+	if method == notify.ChannelSMS {
+		if phone == "" {
+			return nil, ErrInvalidChannel.WithDetail("a phone number is required for sms")
+		}
+		user.Phone = phone // pending until the code comes back
+		dest = phone
+	}
+	id, err := s.sendCode(ctx, &user, CodeMFASetup, method, "")
+	if err != nil {
+		return nil, err
+	}
+	return &MFASetup{Session: id, Destination: maskDestination(method, dest)}, nil
+}
+
+// CompleteMFASetup confirms the code from BeginMFASetup, enables MFA and
+// returns a fresh set of recovery codes.
+func (s *AuthServiceImpl) CompleteMFASetup(ctx context.Context, p *Principal, session, code string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "CompleteMFASetup")
+	defer func() { endSpan(span, err) }()
+
+	if s.local() {
+		var user db.User
+		if err := s.DB.WithContext(ctx).Where("username = ?", p.Username).First(&user).Error; err != nil {
+			return nil, err
+		}
+		var c db.OneTimeCode
+		if err := s.DB.WithContext(ctx).
+			Where("id = ? AND user_id = ? AND purpose = ?", session, user.ID, CodeMFASetup).
+			First(&c).Error; err != nil {
+			return nil, ErrInvalidOTP
+		}
+		if err := s.consumeCode(ctx, &c, code); err != nil {
+			return nil, err
+		}
+		// The code proves the phone it went to, so that is the one saved
+		updates := map[string]any{"mfa_method": c.Channel}
+		if c.Channel == notify.ChannelSMS {
+			updates["phone"] = c.Destination
+		}
+		if err := s.updateUser(ctx, &user, updates); err != nil {
+			return nil, err
+		}
+	} else {
+		out, err := s.CognitoClient.VerifySoftwareToken(ctx, p.AccessToken, code)
+		if err != nil {
+			return nil, err
+		}
+		if out.Status != types.VerifySoftwareTokenResponseTypeSuccess {
+			return nil, ErrMFASetupFailed
+		}
+		if err := s.CognitoClient.EnableMFA(ctx, p.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Action:  "mfa.enabled",
+		Actor:   p.Username,
+		Subject: p.Username,
+		Outcome: audit.OutcomeSuccess,
+	})
+	return s.GenerateRecoveryCodes(ctx, p.Username)
+}
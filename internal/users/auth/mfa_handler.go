@@ -4,14 +4,68 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
 )
 
-func (h *AuthHandler) InitiateMFASetup(c echo.Context) error {
-	// Call CognitoClient.AssociateSoftwareToken
-	return c.JSON(http.StatusOK, map[string]string{"message": "MFA setup initiated"})
+// SetupMFA starts enabling MFA for the caller: a TOTP secret with Cognito, a
+// code sent by email or SMS with the local provider.
+func (h *AuthHandler) SetupMFA(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	var req struct {
+		Method string `json:"method"` // local provider: email or sms
+		Phone  string `json:"phone"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	setup, err := h.Service.BeginMFASetup(c.Request().Context(), p, req.Method, req.Phone)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, setup)
+}
+
+// VerifyMFA confirms the first code, enables MFA and returns the recovery
+// codes. This is the only time they are shown.
+func (h *AuthHandler) VerifyMFA(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	var req struct {
+		Session string `json:"session"`
+		Code    string `json:"code"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	codes, err := h.Service.CompleteMFASetup(RequestContext(c), p, req.Session, req.Code)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"message": "MFA enabled", "recovery_codes": codes})
 }
 
-func (h *AuthHandler) VerifyMFAToken(c echo.Context) error {
-	// Call CognitoClient.VerifySoftwareToken
-	return c.JSON(http.StatusOK, map[string]string{"message": "MFA verified"})
+// RegenerateRecoveryCodes replaces the caller's recovery codes. The caller
+// confirms their password again.
+func (h *AuthHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	codes, err := h.Service.RegenerateRecoveryCodes(RequestContext(c), p, req.Password)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"recovery_codes": codes})
 }
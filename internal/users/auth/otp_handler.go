@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// RequestLoginCode sends a one-time sign-in code by email or SMS. The
+// response is the same whether or not the destination belongs to an account.
+func (h *AuthHandler) RequestLoginCode(c echo.Context) error {
+	var req struct {
+		Channel     string `json:"channel"` // email or sms
+		Destination string `json:"destination"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	if err := h.Service.RequestLoginCode(c.Request().Context(), req.Channel, req.Destination, acceptLanguage(c)); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "if the destination belongs to an account, a code has been sent",
+	})
+}
+
+// VerifyLoginCode exchanges a one-time sign-in code for tokens.
+func (h *AuthHandler) VerifyLoginCode(c echo.Context) error {
+	var req struct {
+		Channel     string `json:"channel"`
+		Destination string `json:"destination"`
+		Code        string `json:"code"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	tokens, err := h.Service.VerifyLoginCode(RequestContext(c), req.Channel, req.Destination, req.Code)
+	if err != nil {
+		return challengeOrError(c, err)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// RespondToChallenge answers the second-factor challenge returned by SignIn,
+// with its code or with a recovery code.
+func (h *AuthHandler) RespondToChallenge(c echo.Context) error {
+	var req struct {
+		Username      string `json:"username"`
+		ChallengeName string `json:"challenge_name"`
+		Session       string `json:"session"`
+		Code          string `json:"code"`
+		RecoveryCode  string `json:"recovery_code"` // instead of code, when the second factor is lost
+		Remember      bool   `json:"remember_device"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	ctx := RequestContext(c)
+
+	var tokens *AuthTokens
+	var err error
+	if req.RecoveryCode != "" {
+		tokens, err = h.Service.SignInWithRecoveryCode(ctx, req.Username, req.ChallengeName, req.Session, req.RecoveryCode)
+	} else {
+		tokens, err = h.Service.RespondToChallenge(ctx, req.Username, req.ChallengeName, req.Session, req.Code)
+	}
+	if err != nil {
+		return challengeOrError(c, err)
+	}
+
+	// Skip MFA on this device from now on
+	if req.Remember {
+		value, err := h.Service.RememberDevice(ctx, req.Username)
+		if err != nil {
+			return err
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     deviceCookie,
+			Value:    value,
+			Path:     "/",
+			MaxAge:   int(h.Service.DeviceTrustTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/http/metrics"
+	"simple-go-auth/internal/users/notify"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// One-time code purposes.
+const (
+	CodeLogin = "login"
+	CodeMFA   = "mfa"
+)
+
+// RequestLoginCode sends a sign-in code to an email address or phone number.
+// Like RequestMagicLink it returns nil for unknown destinations, and within
+// the resend cooldown it silently sends nothing.
+func (s *AuthServiceImpl) RequestLoginCode(ctx context.Context, channel, destination, locale string) (err error) {
+	ctx, span := startSpan(ctx, "RequestLoginCode")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.checkCodeDelivery(channel); err != nil {
+		return err
+	}
+	user, err := s.userByDestination(ctx, channel, destination)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.sendCode(ctx, user, CodeLogin, channel, locale)
+	return err
+}
+
+// VerifyLoginCode checks a code from RequestLoginCode and signs the user in,
+// or returns an AuthChallenge when MFA or the risk engine asks for a second
+// factor.
+func (s *AuthServiceImpl) VerifyLoginCode(ctx context.Context, channel, destination, code string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "VerifyLoginCode")
+	defer func() { endSpan(span, err) }()
+
+	var username string
+	defer func() { s.finishSignIn(ctx, username, "login_code", err) }()
+
+	user, err := s.userByDestination(ctx, channel, destination)
+	if err != nil {
+		return nil, ErrInvalidOTP
+	}
+	username = user.Username
+	var c db.OneTimeCode
+	if err := s.DB.WithContext(ctx).
+		Where("user_id = ? AND purpose = ? AND channel = ? AND consumed_at IS NULL", user.ID, CodeLogin, channel).
+		Order("created_at DESC").
+		First(&c).Error; err != nil {
+		return nil, ErrInvalidOTP
+	}
+	if err := s.consumeCode(ctx, &c, code); err != nil {
+		return nil, err
+	}
+	return s.completeSignIn(ctx, user)
+}
+
+// codeChallenge sends a second-factor code over the user's MFA channel
+// (email by default) and returns the challenge to answer. The session is the
+// code's ID. Step-up challenges are named ChallengeStepUp so they are told
+// apart from Cognito's own.
+func (s *AuthServiceImpl) codeChallenge(ctx context.Context, user *db.User, stepUp bool) (*AuthChallenge, error) {
+	channel, name, dest := notify.ChannelEmail, ChallengeEmailOTP, user.Email
+	if user.MFAMethod == notify.ChannelSMS {
+		channel, name, dest = notify.ChannelSMS, ChallengeSMSMFA, user.Phone
+	}
+	if stepUp {
+		name = ChallengeStepUp
+	}
+	if err := s.checkCodeDelivery(channel); err != nil {
+		return nil, err
+	}
+	id, err := s.sendCode(ctx, user, CodeMFA, channel, "")
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordMFAChallenge(name)
+	return &AuthChallenge{Name: name, Session: id, Destination: maskDestination(channel, dest)}, nil
+}
+
+// verifyChallengeCode checks the code for a challenge from codeChallenge.
+func (s *AuthServiceImpl) verifyChallengeCode(ctx context.Context, username, session, code string) (*db.User, error) {
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, ErrInvalidOTP
+	}
+	var c db.OneTimeCode
+	if err := s.DB.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND purpose = ?", session, user.ID, CodeMFA).
+		First(&c).Error; err != nil {
+		return nil, ErrInvalidOTP
+	}
+	if err := s.consumeCode(ctx, &c, code); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// sendCode stores and delivers a new code to user's email or phone and
+// returns its ID. Within the resend cooldown the pending code for the same
+// destination is reused and nothing is sent.
+func (s *AuthServiceImpl) sendCode(ctx context.Context, user *db.User, purpose, channel, locale string) (string, error) {
+	policy := s.OTP.WithDefaults()
+	now := time.Now()
+	dest := user.Email
+	if channel == notify.ChannelSMS {
+		dest = user.Phone
+	}
+
+	// 1) Cooldown
+	var recent db.OneTimeCode
+	err := s.DB.WithContext(ctx).
+		Where("user_id = ? AND purpose = ? AND channel = ? AND destination = ? AND consumed_at IS NULL AND created_at > ?",
+			user.ID, purpose, channel, dest, now.Add(-policy.ResendCooldown)).
+		Order("created_at DESC").
+		First(&recent).Error
+	if err == nil {
+		return recent.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	// 2) Store the new code; older ones of the same purpose stop working
+	code, err := policy.Generate()
+	if err != nil {
+		return "", err
+	}
+	c := &db.OneTimeCode{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		Purpose:     purpose,
+		Channel:     channel,
+		Destination: dest,
+		CodeHash:    policy.Hash(code),
+		ExpiresAt:   now.Add(policy.TTL),
+	}
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.OneTimeCode{}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", user.ID, purpose).
+			Update("consumed_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(c).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 3) Deliver
+	data := map[string]any{
+		"Username":  user.Username,
+		"Code":      code,
+		"ExpiresIn": policy.TTL.String(),
+	}
+	var msg notify.Message
+	if channel == notify.ChannelSMS {
+		msg, err = s.Templates.SMS("login_code", locale, user.Phone, data)
+	} else {
+		msg, err = s.Templates.Email("login_code", locale, user.Email, data)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := s.Notifier.Send(ctx, msg); err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+// consumeCode checks code against c, counting the attempt, and marks c used.
+func (s *AuthServiceImpl) consumeCode(ctx context.Context, c *db.OneTimeCode, code string) error {
+	policy := s.OTP.WithDefaults()
+	if c.ConsumedAt != nil || time.Now().After(c.ExpiresAt) {
+		return ErrInvalidOTP
+	}
+
+	// 1) Count the attempt before comparing, so parallel guesses can't exceed the limit
+	res := s.DB.WithContext(ctx).
+		Model(&db.OneTimeCode{}).
+		Where("id = ? AND consumed_at IS NULL AND attempts < ?", c.ID, policy.MaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrOTPAttemptsExceeded
+	}
+
+	// 2) Compare
+	if !policy.Equal(c.CodeHash, code) {
+		return ErrInvalidOTP
+	}
+
+	// 3) Mark used exactly once
+	res = s.DB.WithContext(ctx).
+		Model(&db.OneTimeCode{}).
+		Where("id = ? AND consumed_at IS NULL", c.ID).
+		Update("consumed_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidOTP
+	}
+	return nil
+}
+
+// checkCodeDelivery fails when codes can't be sent on channel.
+func (s *AuthServiceImpl) checkCodeDelivery(channel string) error {
+	if channel != notify.ChannelEmail && channel != notify.ChannelSMS {
+		return ErrInvalidChannel
+	}
+	if s.OTP.Secret == "" || s.Notifier == nil || s.Templates == nil {
+		return errors.New("one-time codes not configured")
+	}
+	return nil
+}
+
+func (s *AuthServiceImpl) userByDestination(ctx context.Context, channel, destination string) (*db.User, error) {
+	column := "email"
+	if channel == notify.ChannelSMS {
+		column = "phone"
+	}
+	var user db.User
+	if destination == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := s.DB.WithContext(ctx).Where(column+" = ?", destination).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// maskDestination hides most of an address: "a***@example.com", "*******4567".
+func maskDestination(channel, dest string) string {
+	if channel == notify.ChannelEmail {
+		local, domain, ok := strings.Cut(dest, "@")
+		if !ok || local == "" {
+			return "***"
+		}
+		return local[:1] + "***@" + domain
+	}
+	if len(dest) <= 4 {
+		return strings.Repeat("*", len(dest))
+	}
+	return strings.Repeat("*", len(dest)-4) + dest[len(dest)-4:]
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// principalKey is the echo.Context key the middleware stores the caller under.
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a protected route.
+type Principal struct {
+	Subject     string   `json:"sub"`
+	Username    string   `json:"username"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"` // granted by Roles
+	APIKey      string   `json:"api_key,omitempty"`     // ID of the API key used, if any
+	ClientID    string   `json:"client_id,omitempty"`   // OAuth client calling for itself; Username is empty
+	Scopes      []string `json:"scopes,omitempty"`      // API keys and clients: the permissions granted
+	AccessToken string   `json:"-"`
+}
+
+// HasPermission reports whether one of the caller's roles grants perm.
+func (p *Principal) HasPermission(perm string) bool {
+	return slices.Contains(p.Permissions, perm)
+}
+
+// PrincipalFromContext returns the caller set by NewMiddleware, or nil on public routes.
+func PrincipalFromContext(c echo.Context) *Principal {
+	p, _ := c.Get(principalKey).(*Principal)
+	return p
+}
+
+// SetPrincipal makes p the caller of the request, for middleware that
+// authenticates by other means.
+func SetPrincipal(c echo.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
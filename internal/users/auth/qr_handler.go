@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// GetQRLogin shows the scanning (authenticated) client where a login request
+// comes from, so the user can check it before approving.
+func (h *AuthHandler) GetQRLogin(c echo.Context) error {
+	ch, err := h.Service.GetLoginChallenge(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	if ch.Status != ChallengePending {
+		return ErrChallengeNotFound
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"challenge_id": ch.ID,
+		"ip":           ch.RequestIP,
+		"user_agent":   ch.UserAgent,
+		"expires_at":   ch.ExpiresAt,
+	})
+}
+
+// ApproveQRLogin approves a pending QR login challenge for the calling user.
+// The waiting WebSocket then receives fresh tokens.
+func (h *AuthHandler) ApproveQRLogin(c echo.Context) error {
+	p := PrincipalFromContext(c)
+	if p == nil {
+		return problem.ErrUnauthorized
+	}
+	if err := h.Service.ApproveLoginChallenge(c.Request().Context(), c.Param("id"), p.Username); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "login approved"})
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/risk"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Login challenge states.
+const (
+	ChallengePending  = "pending"
+	ChallengeApproved = "approved"
+	ChallengeConsumed = "consumed"
+)
+
+// CreateLoginChallenge opens a pending cross-device login challenge that lives for ttl.
+func (s *AuthServiceImpl) CreateLoginChallenge(ctx context.Context, ttl time.Duration, ip, userAgent string) (_ *db.LoginChallenge, err error) {
+	ctx, span := startSpan(ctx, "CreateLoginChallenge")
+	defer func() { endSpan(span, err) }()
+
+	ch := &db.LoginChallenge{
+		ID:        uuid.New().String(),
+		Status:    ChallengePending,
+		RequestIP: ip,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.DB.WithContext(ctx).Create(ch).Error; err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// GetLoginChallenge returns a challenge that has not expired yet.
+func (s *AuthServiceImpl) GetLoginChallenge(ctx context.Context, id string) (_ *db.LoginChallenge, err error) {
+	ctx, span := startSpan(ctx, "GetLoginChallenge")
+	defer func() { endSpan(span, err) }()
+
+	var ch db.LoginChallenge
+	err = s.DB.WithContext(ctx).
+		Where("id = ? AND expires_at > ?", id, time.Now()).
+		First(&ch).Error
+	if err != nil {
+		return nil, ErrChallengeNotFound
+	}
+	return &ch, nil
+}
+
+// ApproveLoginChallenge binds a pending challenge to the approving user.
+func (s *AuthServiceImpl) ApproveLoginChallenge(ctx context.Context, id, username string) (err error) {
+	ctx, span := startSpan(ctx, "ApproveLoginChallenge")
+	defer func() { endSpan(span, err) }()
+
+	now := time.Now()
+	res := s.DB.WithContext(ctx).
+		Model(&db.LoginChallenge{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, ChallengePending, now).
+		Updates(map[string]any{
+			"status":      ChallengeApproved,
+			"username":    username,
+			"approved_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrChallengeNotFound
+	}
+	return nil
+}
+
+// CompleteLoginChallenge consumes an approved challenge exactly once and
+// issues tokens for the user who approved it. The waiting client in ctx is
+// scored like any sign-in: it may be blocked, or get a step-up AuthChallenge
+// instead of tokens.
+func (s *AuthServiceImpl) CompleteLoginChallenge(ctx context.Context, id string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "CompleteLoginChallenge")
+	defer func() { endSpan(span, err) }()
+
+	var username string
+	defer func() { s.finishSignIn(ctx, username, "qr_login", err) }()
+
+	ch, err := s.GetLoginChallenge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ch.Status != ChallengeApproved {
+		return nil, ErrChallengeNotApproved
+	}
+	username = ch.Username
+
+	// 1) Risk of the waiting client; the approving phone is already signed in
+	assessment, err := s.assessSignIn(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if assessment != nil && assessment.Decision == risk.Block {
+		s.recordAttempt(ctx, username, false)
+		return nil, ErrSignInBlocked
+	}
+	stepUp := assessment != nil && assessment.Decision == risk.StepUp
+
+	// 2) Flip approved -> consumed atomically so two sockets can't both win,
+	// and only keep the flip if tokens or a step-up code were issued so a
+	// failure can be retried.
+	var tokens *AuthTokens
+	var stepUpChallenge *AuthChallenge
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&db.LoginChallenge{}).
+			Where("id = ? AND status = ?", id, ChallengeApproved).
+			Update("status", ChallengeConsumed)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrChallengeNotFound
+		}
+		if stepUp {
+			var user db.User
+			if err := tx.Where("username = ?", username).First(&user).Error; err != nil {
+				return err
+			}
+			if stepUpChallenge, err = s.withDB(tx).codeChallenge(ctx, &user, true); err != nil {
+				return err
+			}
+			stepUpChallenge.Username = username
+			return nil
+		}
+		tokens, err = s.withDB(tx).IssueTokens(ctx, username)
+		return err
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case stepUpChallenge != nil:
+		return nil, stepUpChallenge
+	}
+	return tokens, nil
+}
+
+// withDB returns a copy of s that uses tx, so a method's writes commit or
+// roll back with the caller's transaction.
+func (s *AuthServiceImpl) withDB(tx *gorm.DB) *AuthServiceImpl {
+	c := *s
+	c.DB = tx
+	return &c
+}
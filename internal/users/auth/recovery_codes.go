@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/problem"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is the size of a recovery code set.
+const recoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes replaces the user's recovery codes with a new set and
+// returns it. Only hashes are kept, so the codes can't be shown again.
+func (s *AuthServiceImpl) GenerateRecoveryCodes(ctx context.Context, username string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "GenerateRecoveryCodes")
+	defer func() { endSpan(span, err) }()
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]db.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+		rows[i] = db.RecoveryCode{UserID: user.ID, CodeHash: s.OTP.Hash(normalizeRecoveryCode(codes[i]))}
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&db.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Action:  "mfa.recovery_codes.generated",
+		Actor:   username,
+		Subject: username,
+		Outcome: audit.OutcomeSuccess,
+	})
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes once they
+// have confirmed their password again. Without MFA there is nothing to
+// recover, so it returns ErrMFANotEnabled.
+func (s *AuthServiceImpl) RegenerateRecoveryCodes(ctx context.Context, p *Principal, password string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "RegenerateRecoveryCodes")
+	defer func() { endSpan(span, err) }()
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", p.Username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	// 1) Only with MFA on
+	mfa, err := s.mfaEnabled(ctx, &user)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa {
+		return nil, ErrMFANotEnabled
+	}
+	// 2) Step up: a stolen access token alone can't mint new codes
+	if err := s.checkPassword(ctx, &user, password); err != nil {
+		return nil, err
+	}
+	return s.GenerateRecoveryCodes(ctx, user.Username)
+}
+
+// checkPassword verifies the password of a signed-in user again. A wrong
+// one counts as a failed sign-in.
+func (s *AuthServiceImpl) checkPassword(ctx context.Context, user *db.User, password string) error {
+	var ok bool
+	if s.local() {
+		ok = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+	} else {
+		// A challenge still means the password was right
+		_, err := s.CognitoClient.SignIn(ctx, user.Username, password)
+		if err != nil && !errors.Is(problem.FromCognito(err), problem.ErrNotAuthorized) {
+			return err
+		}
+		ok = err == nil
+	}
+	if !ok {
+		s.recordAttempt(ctx, user.Username, false)
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// SignInWithRecoveryCode answers an MFA or step-up challenge from SignIn with
+// a recovery code instead of the second factor.
+func (s *AuthServiceImpl) SignInWithRecoveryCode(ctx context.Context, username, challenge, session, code string) (_ *AuthTokens, err error) {
+	ctx, span := startSpan(ctx, "SignInWithRecoveryCode")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.finishSignIn(ctx, username, "recovery_code", err) }()
+
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, ErrInvalidRecoveryCode
+	}
+
+	// 1) The session proves the password step passed. Local and step-up
+	// challenges are one-time codes; Cognito's are sealed sessions.
+	if s.local() || challenge == ChallengeStepUp {
+		res := s.DB.WithContext(ctx).
+			Model(&db.OneTimeCode{}).
+			Where("id = ? AND user_id = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?",
+				session, user.ID, CodeMFA, time.Now()).
+			Update("consumed_at", time.Now())
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil, ErrInvalidRecoveryCode
+		}
+	} else if _, err := s.openSession(ctx, username, session, time.Now()); errors.Is(err, ErrInvalidChallengeSession) {
+		return nil, ErrInvalidRecoveryCode
+	} else if err != nil {
+		return nil, err
+	}
+
+	// 2) Burn the code
+	if err := s.useRecoveryCode(ctx, &user, code); err != nil {
+		return nil, err
+	}
+
+	// 3) Skip the second factor
+	if s.local() {
+		return s.issueNativeTokens(ctx, &user, "")
+	}
+	return s.IssueTokens(ctx, username)
+}
+
+// useRecoveryCode marks one of the user's codes used, then audits and
+// notifies the user either way.
+func (s *AuthServiceImpl) useRecoveryCode(ctx context.Context, user *db.User, code string) error {
+	now := time.Now()
+	res := s.DB.WithContext(ctx).
+		Model(&db.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, s.OTP.Hash(normalizeRecoveryCode(code))).
+		Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	ev := audit.Event{
+		Action:  "mfa.recovery_code.used",
+		Actor:   user.Username,
+		Subject: user.Username,
+		Outcome: audit.OutcomeSuccess,
+	}
+	if res.RowsAffected == 0 {
+		ev.Outcome = audit.OutcomeFailure
+		s.recordAudit(ctx, ev)
+		return ErrInvalidRecoveryCode
+	}
+
+	var remaining int64
+	s.DB.WithContext(ctx).Model(&db.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+	ev.Details = map[string]any{"remaining": remaining}
+	s.recordAudit(ctx, ev)
+	s.sendSecurityAlert(ctx, user, "A recovery code was used to sign in", now)
+	return nil
+}
+
+// normalizeRecoveryCode accepts codes typed with any case, spaces or dashes.
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
+
+// sendSecurityAlert emails the user about event, best effort.
+func (s *AuthServiceImpl) sendSecurityAlert(ctx context.Context, user *db.User, event string, at time.Time) {
+	if s.Notifier == nil || s.Templates == nil {
+		return
+	}
+	msg, err := s.Templates.Email("security_alert", "", user.Email, map[string]any{
+		"Username": user.Username,
+		"Event":    event,
+		"Time":     at.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		return
+	}
+	_ = s.Notifier.Send(ctx, msg)
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/risk"
+)
+
+// Bounds on the login attempts read per assessment, whatever the rules file
+// says: failures are only counted up to the threshold, within at most a day,
+// and only the most recent successful sign-ins are loaded.
+const (
+	maxVelocityWindow = 24 * time.Hour
+	maxHistory        = 100
+)
+
+// assessSignIn scores a sign-in attempt by username from ctx's ClientInfo
+// and audits the result. It returns nil when no risk engine is configured.
+func (s *AuthServiceImpl) assessSignIn(ctx context.Context, username string) (*risk.Assessment, error) {
+	if s.Risk == nil {
+		return nil, nil
+	}
+	signals, err := s.collectSignals(ctx, username, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	a := s.Risk.Rules.Evaluate(signals)
+
+	outcome := audit.OutcomeSuccess
+	if a.Decision == risk.Block {
+		outcome = audit.OutcomeFailure
+	}
+	s.recordAudit(ctx, audit.Event{
+		Action:  "signin.risk_assessed",
+		Actor:   username,
+		Subject: username,
+		IP:      ClientInfoFrom(ctx).IP,
+		Outcome: outcome,
+		Details: map[string]any{"score": a.Score, "decision": a.Decision, "reasons": a.Reasons},
+	})
+	return &a, nil
+}
+
+// collectSignals gathers risk.Signals from stored devices and login attempts.
+func (s *AuthServiceImpl) collectSignals(ctx context.Context, username string, now time.Time) (risk.Signals, error) {
+	ci := ClientInfoFrom(ctx)
+	rules := s.Risk.Rules
+	var sig risk.Signals
+
+	// 1) IP reputation
+	sig.ListedIn = s.Risk.Reputation.Match(ci.IP)
+
+	// 2) Failed-attempt velocity, by username or IP
+	if window := min(time.Duration(rules.VelocityWindow), maxVelocityWindow); window > 0 && rules.VelocityThreshold > 0 {
+		q := s.DB.WithContext(ctx).Model(&db.LoginAttempt{}).
+			Select("id").
+			Where("success = ? AND created_at > ?", false, now.Add(-window))
+		if ci.IP != "" {
+			q = q.Where("username = ? OR ip = ?", username, ci.IP)
+		} else {
+			q = q.Where("username = ?", username)
+		}
+		var failures int64
+		if err := s.DB.WithContext(ctx).Table("(?) AS failures", q.Limit(rules.VelocityThreshold)).
+			Count(&failures).Error; err != nil {
+			return sig, err
+		}
+		sig.RecentFailures = int(failures)
+	}
+
+	// 3) New device, for known users on an identifiable client
+	var user db.User
+	if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err == nil &&
+		(ci.UserAgent != "" || ci.Fingerprint != "") {
+		var seen int64
+		if err := s.DB.WithContext(ctx).Model(&db.Device{}).
+			Where("user_id = ? AND fingerprint = ?", user.ID, deviceFingerprint(ci)).
+			Count(&seen).Error; err != nil {
+			return sig, err
+		}
+		sig.NewDevice = seen == 0
+	}
+
+	// 4) History of successful sign-ins: travel speed and usual hours
+	var past []db.LoginAttempt
+	if err := s.DB.WithContext(ctx).
+		Where("username = ? AND success = ? AND created_at > ?", username, true, now.Add(-time.Duration(rules.HistoryWindow))).
+		Order("created_at DESC").
+		Limit(maxHistory).
+		Find(&past).Error; err != nil {
+		return sig, err
+	}
+	if here, ok := s.Risk.Locate(ci.IP); ok {
+		for _, p := range past {
+			if !p.HasLocation {
+				continue
+			}
+			hours := now.Sub(p.CreatedAt).Hours()
+			if hours < 1.0/60 {
+				hours = 1.0 / 60
+			}
+			prev := risk.Location{Latitude: p.Latitude, Longitude: p.Longitude}
+			sig.TravelSpeedKmh = risk.DistanceKm(prev, here) / hours
+			break
+		}
+	}
+	hours := make([]int, len(past))
+	for i, p := range past {
+		hours[i] = p.CreatedAt.UTC().Hour()
+	}
+	sig.UnusualHour = rules.UnusualHour(now.UTC().Hour(), hours)
+
+	return sig, nil
+}
+
+// finishSignIn records the outcome of a sign-in once its method returns.
+// Only a sign-in that issued tokens is a success in the risk history; one
+// that ended in a challenge isn't finished yet, and failures were recorded
+// where they happened.
+func (s *AuthServiceImpl) finishSignIn(ctx context.Context, username, method string, err error) {
+	if err == nil {
+		s.recordAttempt(ctx, username, true)
+	}
+	s.auditSignIn(ctx, username, method, err)
+}
+
+// recordAttempt stores the outcome of a password check for later scoring.
+func (s *AuthServiceImpl) recordAttempt(ctx context.Context, username string, success bool) {
+	if s.Risk == nil {
+		return
+	}
+	ci := ClientInfoFrom(ctx)
+	a := &db.LoginAttempt{Username: username, IP: ci.IP, Success: success}
+	if loc, ok := s.Risk.Locate(ci.IP); ok {
+		a.HasLocation = true
+		a.Country = loc.Country
+		a.Latitude = loc.Latitude
+		a.Longitude = loc.Longitude
+	}
+	_ = s.DB.WithContext(ctx).Create(a).Error
+}
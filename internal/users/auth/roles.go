@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/rbac"
+)
+
+// rolesOf returns the roles held by a user; none without RBAC.
+func (s *AuthServiceImpl) rolesOf(ctx context.Context, userID uint) ([]string, error) {
+	if s.RBAC == nil {
+		return []string{}, nil
+	}
+	roles, err := s.RBAC.UserRoles(ctx, userID)
+	if roles == nil {
+		roles = []string{}
+	}
+	return roles, err
+}
+
+// userRoles returns the roles of username; none for users we don't mirror.
+func (s *AuthServiceImpl) userRoles(ctx context.Context, username string) ([]string, error) {
+	if s.RBAC == nil {
+		return nil, nil
+	}
+	user, err := s.findUser(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.rolesOf(ctx, user.ID)
+}
+
+// resolvePermissions fills p.Permissions from p.Roles.
+func (s *AuthServiceImpl) resolvePermissions(ctx context.Context, p *Principal) error {
+	if s.RBAC == nil || len(p.Roles) == 0 {
+		return nil
+	}
+	perms, err := s.RBAC.Permissions(ctx, p.Roles)
+	if err != nil {
+		return err
+	}
+	p.Permissions = perms
+	return nil
+}
+
+// UserRoleNames lists the roles held by username.
+func (s *AuthServiceImpl) UserRoleNames(ctx context.Context, username string) ([]string, error) {
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.rolesOf(ctx, user.ID)
+}
+
+// AssignRole grants role to username, in the Cognito group of the same name
+// first when users live in Cognito. Tokens issued before carry the old roles
+// until they expire.
+func (s *AuthServiceImpl) AssignRole(ctx context.Context, actor, username, role string) (err error) {
+	ctx, span := startSpan(ctx, "AssignRole")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditRole(ctx, actor, "admin.role.assigned", username, role, err) }()
+
+	user, err := s.roleTarget(ctx, username, role)
+	if err != nil {
+		return err
+	}
+	if !s.local() {
+		if err := s.CognitoClient.AdminAddUserToGroup(ctx, username, role); err != nil {
+			return err
+		}
+	}
+	return s.RBAC.Assign(ctx, user.ID, role)
+}
+
+// RevokeRole takes role away from username, in Cognito first.
+func (s *AuthServiceImpl) RevokeRole(ctx context.Context, actor, username, role string) (err error) {
+	ctx, span := startSpan(ctx, "RevokeRole")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditRole(ctx, actor, "admin.role.revoked", username, role, err) }()
+
+	user, err := s.roleTarget(ctx, username, role)
+	if err != nil {
+		return err
+	}
+	if !s.local() {
+		if err := s.CognitoClient.AdminRemoveUserFromGroup(ctx, username, role); err != nil {
+			return err
+		}
+	}
+	return s.RBAC.Unassign(ctx, user.ID, role)
+}
+
+// roleTarget loads username and checks role exists.
+func (s *AuthServiceImpl) roleTarget(ctx context.Context, username, role string) (*db.User, error) {
+	if s.RBAC == nil {
+		return nil, errors.New("rbac not configured")
+	}
+	ok, err := s.RBAC.Exists(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoleNotFound
+	}
+	return s.findUser(ctx, username)
+}
+
+func (s *AuthServiceImpl) auditRole(ctx context.Context, actor, action, username, role string, err error) {
+	if err != nil {
+		s.auditAdminAction(ctx, actor, action, username, err)
+		return
+	}
+	s.auditAdmin(ctx, actor, action, username, map[string]any{"role": role})
+}
+
+// syncRolesFromCognito mirrors the user's Cognito groups as roles, so
+// groups changed in the console take effect at the next sign-in.
+func (s *AuthServiceImpl) syncRolesFromCognito(ctx context.Context, user *db.User) error {
+	if s.RBAC == nil || s.local() {
+		return nil
+	}
+	groups, err := s.CognitoClient.AdminListGroupsForUser(ctx, user.Username)
+	if err != nil {
+		return err
+	}
+	return s.RBAC.SetUserRoles(ctx, user.ID, groups)
+}
+
+// SetupRoles seeds specs, creates a Cognito group per role and grants the
+// admin role to the admins that exist. Unknown admin usernames are logged
+// and skipped.
+func (s *AuthServiceImpl) SetupRoles(ctx context.Context, specs []rbac.RoleSpec, admins []string, logger *zap.Logger) error {
+	if err := s.RBAC.Seed(ctx, specs); err != nil {
+		return err
+	}
+	if !s.local() {
+		for _, spec := range specs {
+			if err := s.CognitoClient.CreateGroup(ctx, spec.Name, spec.Description); err != nil {
+				return err
+			}
+		}
+	}
+	for _, username := range admins {
+		err := s.AssignRole(ctx, "system", username, rbac.RoleAdmin)
+		if errors.Is(err, ErrUserNotFound) {
+			logger.Warn("admin user not found; skipped", zap.String("username", username))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/oauth"
+)
+
+// IntrospectToken describes tok for the OAuth introspection endpoint: a
+// native or Cognito access token, a refresh token or an API key. hint, if
+// set, is tried first. Unknown, expired and revoked tokens, and tokens of
+// disabled users, are inactive.
+func (s *AuthServiceImpl) IntrospectToken(ctx context.Context, tok, hint string) (_ *oauth.Introspection, err error) {
+	ctx, span := startSpan(ctx, "IntrospectToken")
+	defer func() { endSpan(span, err) }()
+
+	if IsAPIKey(tok) {
+		return s.introspectAPIKey(ctx, tok)
+	}
+	lookups := []func(context.Context, string) (*oauth.Introspection, error){
+		s.introspectAccessToken, s.introspectRefreshToken,
+	}
+	if hint == oauth.HintRefreshToken {
+		slices.Reverse(lookups)
+	}
+	for _, lookup := range lookups {
+		info, err := lookup(ctx, tok)
+		if err != nil || info.Active {
+			return info, err
+		}
+	}
+	return &oauth.Introspection{}, nil
+}
+
+// RevokeToken revokes a refresh token for the OAuth revocation endpoint and
+// the OIDC provider. Access tokens are self-contained and can't be revoked
+// on their own; unknown tokens are ignored. API keys belong to no client, so
+// the endpoint never passes them here: their owners revoke them through the
+// API key routes.
+func (s *AuthServiceImpl) RevokeToken(ctx context.Context, tok, hint string) (err error) {
+	ctx, span := startSpan(ctx, "RevokeToken")
+	defer func() { endSpan(span, err) }()
+
+	// 1) Refresh tokens, in Cognito too so the access tokens issued from
+	//    them stop working
+	var rt db.RefreshToken
+	err = s.DB.WithContext(ctx).Where("token = ? AND revoked = false", tok).First(&rt).Error
+	switch {
+	case err == nil:
+		var username string
+		var owner db.User
+		if err := s.DB.WithContext(ctx).Select("username").First(&owner, rt.UserID).Error; err == nil {
+			username = owner.Username
+		}
+		err = s.revokeRefreshToken(ctx, &rt)
+		s.auditAction(ctx, "token.revoked", username, err)
+		return err
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	// 2) Access tokens
+	if hint != oauth.HintRefreshToken && (accessTokenClaims(tok) != nil || s.delegatedClaims(tok) != nil) {
+		return oauth.ErrUnsupportedTokenType.WithDescription("access tokens expire on their own; revoke the refresh token instead")
+	}
+	return nil
+}
+
+func (s *AuthServiceImpl) revokeRefreshToken(ctx context.Context, rt *db.RefreshToken) error {
+	if !s.local() {
+		if err := s.CognitoClient.RevokeToken(ctx, rt.Token); err != nil && !cognitoTokenRejected(err) {
+			return err
+		}
+	}
+	return s.DB.WithContext(ctx).Model(rt).Update("revoked", true).Error
+}
+
+// introspectAccessToken describes a live native or Cognito access token, or
+// one a user granted an OAuth client.
+func (s *AuthServiceImpl) introspectAccessToken(ctx context.Context, tok string) (*oauth.Introspection, error) {
+	// 1) Native tokens are verified locally; their user must still be enabled
+	native := func(claims jwt.MapClaims, username string) *oauth.Introspection {
+		var user db.User
+		if err := s.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil || user.DisabledAt != nil {
+			return &oauth.Introspection{}
+		}
+		return accessTokenIntrospection(claims, username)
+	}
+	if p, ok := s.authenticateNative(tok); ok && p.ClientID == "" {
+		return native(accessTokenClaims(tok), p.Username), nil
+	}
+	if claims := s.delegatedClaims(tok); claims != nil {
+		username, _ := claims["username"].(string)
+		return native(claims, username), nil
+	}
+	claims := accessTokenClaims(tok)
+	if s.local() || claims == nil {
+		return &oauth.Introspection{}, nil
+	}
+
+	// 2) Cognito tokens are live while GetUser accepts them
+	out, err := s.CognitoClient.GetUser(ctx, tok)
+	if err != nil {
+		if cognitoTokenRejected(err) {
+			return &oauth.Introspection{}, nil
+		}
+		return nil, err
+	}
+	return accessTokenIntrospection(claims, sdkaws.ToString(out.Username)), nil
+}
+
+// introspectRefreshToken describes a live refresh token.
+func (s *AuthServiceImpl) introspectRefreshToken(ctx context.Context, tok string) (*oauth.Introspection, error) {
+	var rt db.RefreshToken
+	if err := s.DB.WithContext(ctx).Where("token = ? AND revoked = false", tok).First(&rt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &oauth.Introspection{}, nil
+		}
+		return nil, err
+	}
+	var user db.User
+	if err := s.DB.WithContext(ctx).First(&user, rt.UserID).Error; err != nil || user.DisabledAt != nil || time.Now().After(rt.ExpiresAt) {
+		return &oauth.Introspection{}, nil
+	}
+	info := &oauth.Introspection{
+		Active:   true,
+		Scope:    rt.Scope,
+		ClientID: rt.ClientID,
+		Username: user.Username,
+		Exp:      rt.ExpiresAt.Unix(),
+		Iat:      rt.CreatedAt.Unix(),
+	}
+	// Native tokens use the local user ID as subject; Cognito's sub isn't stored
+	if s.local() {
+		info.Sub = strconv.FormatUint(uint64(user.ID), 10)
+	}
+	if s.Tokens != nil {
+		info.Iss = s.Tokens.Issuer()
+	}
+	return info, nil
+}
+
+// introspectAPIKey describes a live API key of an enabled user.
+func (s *AuthServiceImpl) introspectAPIKey(ctx context.Context, key string) (*oauth.Introspection, error) {
+	k, user, err := s.findAPIKey(ctx, key)
+	if errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrUserDisabled) {
+		return &oauth.Introspection{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := &oauth.Introspection{
+		Active:    true,
+		Scope:     k.Scopes,
+		Username:  user.Username,
+		TokenType: "Bearer",
+		Exp:       k.ExpiresAt.Unix(),
+		Iat:       k.CreatedAt.Unix(),
+		Sub:       strconv.FormatUint(uint64(user.ID), 10),
+	}
+	if s.Tokens != nil {
+		info.Iss = s.Tokens.Issuer()
+	}
+	return info, nil
+}
+
+// delegatedClaims returns the verified claims of an access token a user
+// granted an OAuth client, or nil if tok isn't one.
+func (s *AuthServiceImpl) delegatedClaims(tok string) jwt.MapClaims {
+	if s.Tokens == nil {
+		return nil
+	}
+	claims, err := s.Tokens.Verify(tok)
+	if err != nil || claims["token_use"] != oauth.TokenUseDelegated {
+		return nil
+	}
+	return claims
+}
+
+// accessTokenClaims returns the unverified claims of an access token, or
+// nil if tok isn't one. Callers verify the token by other means.
+func accessTokenClaims(tok string) jwt.MapClaims {
+	if strings.Count(tok, ".") != 2 {
+		return nil
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tok, claims); err != nil || claims["token_use"] != "access" {
+		return nil
+	}
+	return claims
+}
+
+func accessTokenIntrospection(claims jwt.MapClaims, username string) *oauth.Introspection {
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return v
+	}
+	info := &oauth.Introspection{
+		Active:    true,
+		Scope:     str("scope"),
+		ClientID:  str("client_id"),
+		Username:  username,
+		TokenType: "Bearer",
+		Sub:       str("sub"),
+		Iss:       str("iss"),
+	}
+	info.Aud, _ = claims.GetAudience()
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		info.Exp = exp.Unix()
+	}
+	if iat, _ := claims.GetIssuedAt(); iat != nil {
+		info.Iat = iat.Unix()
+	}
+	return info
+}
+
+// cognitoTokenRejected reports whether Cognito refused a token as invalid,
+// expired or revoked, rather than failing.
+func cognitoTokenRejected(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "NotAuthorizedException", "UserNotFoundException", "UnsupportedTokenTypeException":
+		return true
+	}
+	return false
+}
+
+var _ oauth.UserTokens = (*AuthServiceImpl)(nil)
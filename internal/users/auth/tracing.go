@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+var tracer = otel.Tracer("simple-go-auth/internal/users/auth")
+
+// startSpan opens a span for an AuthServiceImpl method. Attributes must never
+// carry usernames, emails, passwords, codes or tokens.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "AuthService."+name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan records err and ends the span. Only the error type is recorded:
+// driver and Cognito messages can echo user input (e.g. duplicate keys).
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		kind := errorKind(err)
+		span.RecordError(errors.New(kind), trace.WithAttributes(attribute.String("error.type", kind)))
+		span.SetStatus(codes.Error, kind)
+	}
+	span.End()
+}
+
+// errorKind returns a PII-free description of err: the Cognito error code,
+// the code of a domain problem, or the Go type.
+func errorKind(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	if p, ok := problem.From(err); ok {
+		return p.Code
+	}
+	return fmt.Sprintf("%T", err)
+}
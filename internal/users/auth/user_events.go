@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/outbox"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/rebac"
+)
+
+// UserEvent is the data of the user lifecycle events in the outbox.
+type UserEvent struct {
+	ID          uint       `json:"id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email,omitempty"`
+	Phone       string     `json:"phone,omitempty"`
+	MFAMethod   string     `json:"mfa_method,omitempty"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	Changed     []string   `json:"changed,omitempty"` // columns changed by a user.changed event
+}
+
+// saveUser runs fn and writes a lifecycle event for user in one transaction,
+// so the event is published if and only if the change is committed. For
+// outbox.UserCreated fn must assign user.ID.
+func (s *AuthServiceImpl) saveUser(ctx context.Context, eventType string, user *db.User, changed []string, fn func(tx *gorm.DB) error) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		data := UserEvent{ID: user.ID, Username: user.Username, Changed: changed}
+		if eventType != outbox.UserDeleted {
+			data.Email, data.Phone, data.MFAMethod, data.ConfirmedAt = user.Email, user.Phone, user.MFAMethod, user.ConfirmedAt
+		}
+		return outbox.Enqueue(tx, strconv.FormatUint(uint64(user.ID), 10), eventType, data)
+	})
+}
+
+// updateUser sets columns on user and emits outbox.UserChanged.
+func (s *AuthServiceImpl) updateUser(ctx context.Context, user *db.User, columns map[string]any) error {
+	changed := make([]string, 0, len(columns))
+	for c := range columns {
+		changed = append(changed, c)
+	}
+	return s.saveUser(ctx, outbox.UserChanged, user, changed, func(tx *gorm.DB) error {
+		return tx.Model(user).Updates(columns).Error
+	})
+}
+
+// DeleteUser removes username from the identity provider and the database,
+// together with its tokens, API keys, codes, devices, roles and relation
+// tuples.
+func (s *AuthServiceImpl) DeleteUser(ctx context.Context, actor, username string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteUser")
+	defer func() { endSpan(span, err) }()
+	defer func() { s.auditAdminAction(ctx, actor, "user.deleted", username, err) }()
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// 1) Identity provider first: a user left only in Cognito could still sign in
+	if !s.local() {
+		if err := s.CognitoClient.AdminDeleteUser(ctx, username); err != nil {
+			return err
+		}
+	}
+
+	// 2) Local rows and the event, atomically
+	return s.saveUser(ctx, outbox.UserDeleted, user, nil, func(tx *gorm.DB) error {
+		for _, model := range []any{
+			&db.RefreshToken{}, &db.MagicLink{}, &db.OneTimeCode{}, &db.RecoveryCode{}, &db.Device{}, &db.APIKey{}, &rbac.UserRole{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if s.Relations != nil {
+			if err := s.Relations.DeleteSubject(tx, rebac.Object{Namespace: rebac.UserNamespace, ID: user.Username}); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+}
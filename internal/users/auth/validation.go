@@ -5,12 +5,11 @@ import (
 	"regexp"
 )
 
-func ValidateSignUpInput(email, password string) error {
-	// Stronger email validation
-	emailRegex := `^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`
-	re := regexp.MustCompile(emailRegex)
+// emailRe is the stronger email validation shared by sign-up and magic links.
+var emailRe = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
 
-	if !re.MatchString(email) {
+func ValidateSignUpInput(email, password string) error {
+	if !emailRe.MatchString(email) {
 		return errors.New("invalid email format")
 	}
 
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/webhook"
+)
+
+// endpointView is an Endpoint without its secrets.
+func endpointView(ep *webhook.Endpoint) map[string]any {
+	return map[string]any{
+		"id":                   ep.ID,
+		"tenant":               ep.Tenant,
+		"url":                  ep.URL,
+		"events":               ep.EventTypes(),
+		"active":               ep.Active,
+		"consecutive_failures": ep.ConsecutiveFailures,
+		"created_at":           ep.CreatedAt,
+	}
+}
+
+// RegisterWebhook adds a tenant webhook endpoint. The signing secret is
+// returned only here and by RotateWebhookSecret.
+func (h *AuthHandler) RegisterWebhook(c echo.Context) error {
+	var req struct {
+		Tenant string   `json:"tenant"`
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	if req.Tenant == "" {
+		return problem.ErrBadRequest.WithDetail("tenant is required")
+	}
+	ctx := RequestContext(c)
+	ep, err := h.Service.Webhooks.Register(ctx, req.Tenant, req.URL, req.Events)
+	if err != nil {
+		return err
+	}
+	h.Service.auditAdmin(ctx, PrincipalFromContext(c).Username, "admin.webhook.registered", ep.ID,
+		map[string]any{"tenant": ep.Tenant, "url": ep.URL})
+	out := endpointView(ep)
+	out["secret"] = ep.Secret
+	return c.JSON(http.StatusCreated, out)
+}
+
+// ListWebhooks lists endpoints, optionally for one ?tenant=.
+func (h *AuthHandler) ListWebhooks(c echo.Context) error {
+	eps, err := h.Service.Webhooks.List(c.Request().Context(), c.QueryParam("tenant"))
+	if err != nil {
+		return err
+	}
+	out := make([]map[string]any, 0, len(eps))
+	for i := range eps {
+		out = append(out, endpointView(&eps[i]))
+	}
+	return c.JSON(http.StatusOK, map[string]any{"endpoints": out})
+}
+
+// UpdateWebhook pauses or resumes an endpoint: {"active": false}.
+func (h *AuthHandler) UpdateWebhook(c echo.Context) error {
+	var req struct {
+		Active *bool `json:"active"`
+	}
+	if err := c.Bind(&req); err != nil || req.Active == nil {
+		return problem.ErrInvalidBody
+	}
+	ctx := RequestContext(c)
+	if err := h.Service.Webhooks.SetActive(ctx, c.Param("id"), *req.Active); err != nil {
+		return err
+	}
+	h.Service.auditAdmin(ctx, PrincipalFromContext(c).Username, "admin.webhook.updated", c.Param("id"),
+		map[string]any{"active": *req.Active})
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteWebhook removes an endpoint and its deliveries.
+func (h *AuthHandler) DeleteWebhook(c echo.Context) error {
+	ctx := RequestContext(c)
+	if err := h.Service.Webhooks.Delete(ctx, c.Param("id")); err != nil {
+		return err
+	}
+	h.Service.auditAdmin(ctx, PrincipalFromContext(c).Username, "admin.webhook.deleted", c.Param("id"), nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RotateWebhookSecret issues a new signing secret; the old one keeps
+// signing alongside it for the grace period.
+func (h *AuthHandler) RotateWebhookSecret(c echo.Context) error {
+	ctx := RequestContext(c)
+	secret, err := h.Service.Webhooks.RotateSecret(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	h.Service.auditAdmin(ctx, PrincipalFromContext(c).Username, "admin.webhook.secret_rotated", c.Param("id"), nil)
+	return c.JSON(http.StatusOK, map[string]any{"secret": secret})
+}
+
+// TestWebhook sends a webhook.test event now and returns the outcome.
+func (h *AuthHandler) TestWebhook(c echo.Context) error {
+	res, err := h.Service.Webhooks.TestFire(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// WebhookHealth returns delivery health for an endpoint.
+func (h *AuthHandler) WebhookHealth(c echo.Context) error {
+	health, err := h.Service.Webhooks.Health(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, health)
+}
+
+// ListWebhookDeliveries lists an endpoint's deliveries; ?status=dead shows
+// its dead-letter queue.
+func (h *AuthHandler) ListWebhookDeliveries(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	deliveries, err := h.Service.Webhooks.Deliveries(c.Request().Context(), c.Param("id"), c.QueryParam("status"), limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"deliveries": deliveries})
+}
+
+// ReplayWebhookDelivery requeues one delivery.
+func (h *AuthHandler) ReplayWebhookDelivery(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return webhook.ErrDeliveryNotFound
+	}
+	ctx := RequestContext(c)
+	if err := h.Service.Webhooks.Replay(ctx, id); err != nil {
+		return err
+	}
+	h.Service.auditAdmin(ctx, PrincipalFromContext(c).Username, "admin.webhook.replayed", c.Param("id"), nil)
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ReplayWebhookDeadLetters requeues an endpoint's whole dead-letter queue.
+func (h *AuthHandler) ReplayWebhookDeadLetters(c echo.Context) error {
+	ctx := RequestContext(c)
+	n, err := h.Service.Webhooks.ReplayDead(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	h.Service.auditAdmin(ctx, PrincipalFromContext(c).Username, "admin.webhook.replayed", c.Param("id"),
+		map[string]any{"dead_letters": n})
+	return c.JSON(http.StatusAccepted, map[string]any{"requeued": n})
+}
+
+// registerWebhookRoutes mounts the webhook admin API.
+func (h *AuthHandler) registerWebhookRoutes(g *echo.Group) {
+	read, write := RequirePermission(rbac.PermWebhooksRead), RequirePermission(rbac.PermWebhooksWrite)
+	g.POST("/webhooks", h.RegisterWebhook, write)
+	g.GET("/webhooks", h.ListWebhooks, read)
+	g.PATCH("/webhooks/:id", h.UpdateWebhook, write)
+	g.DELETE("/webhooks/:id", h.DeleteWebhook, write)
+	g.POST("/webhooks/:id/rotate-secret", h.RotateWebhookSecret, write)
+	g.POST("/webhooks/:id/test", h.TestWebhook, write)
+	g.GET("/webhooks/:id/health", h.WebhookHealth, read)
+	g.GET("/webhooks/:id/deliveries", h.ListWebhookDeliveries, read)
+	g.POST("/webhooks/:id/replay", h.ReplayWebhookDeadLetters, write)
+	g.POST("/webhooks/deliveries/:id/replay", h.ReplayWebhookDelivery, write)
+}
+
+// RegisterAdminRoutes mounts the admin API handled by AuthHandler on g,
+// which must already authenticate the caller; each route checks its own
+// permission.
+func (h *AuthHandler) RegisterAdminRoutes(g *echo.Group) {
+	h.registerUserRoutes(g)
+	h.registerAPIKeyAdminRoutes(g)
+	if h.Service.RBAC != nil {
+		h.registerRoleRoutes(g)
+	}
+	if h.Service.Webhooks != nil {
+		h.registerWebhookRoutes(g)
+	}
+}
@@ -2,12 +2,19 @@ package aws
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	sdkconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/smithy-go/middleware"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+
+	"simple-go-auth/internal/users/http/metrics"
 )
 
 // CognitoClient wraps AWS Cognito SDK operations.
@@ -26,6 +33,8 @@ func NewCognitoClient(region, userPoolID, appClientID string) (*CognitoClient, e
 
 	// Add OpenTelemetry instrumentation to the AWS SDK client.
 	otelaws.AppendMiddlewares(&cfg.APIOptions)
+	// Record per-operation latency and errors.
+	cfg.APIOptions = append(cfg.APIOptions, addMetricsMiddleware)
 
 	return &CognitoClient{
 		client:      cognitoidentityprovider.NewFromConfig(cfg),
@@ -34,6 +43,17 @@ func NewCognitoClient(region, userPoolID, appClientID string) (*CognitoClient, e
 	}, nil
 }
 
+// addMetricsMiddleware times every Cognito call, retries included.
+func addMetricsMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CognitoMetrics",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			start := time.Now()
+			out, md, err := next.HandleInitialize(ctx, in)
+			metrics.ObserveCognito(ctx, awsmiddleware.GetOperationName(ctx), time.Since(start), err)
+			return out, md, err
+		}), middleware.After)
+}
+
 // SignUp registers a new user in Cognito.
 func (c *CognitoClient) SignUp(ctx context.Context, username, password, email string) error {
 	_, err := c.client.SignUp(ctx, &cognitoidentityprovider.SignUpInput{
@@ -95,22 +115,174 @@ func (c *CognitoClient) RefreshAuth(ctx context.Context, refreshToken string) (*
 	})
 }
 
-// AssociateSoftwareToken associates a software token for MFA.
+// RevokeToken revokes a refresh token and the access tokens issued from it.
+func (c *CognitoClient) RevokeToken(ctx context.Context, refreshToken string) error {
+	_, err := c.client.RevokeToken(ctx, &cognitoidentityprovider.RevokeTokenInput{
+		ClientId: aws.String(c.appClientID),
+		Token:    aws.String(refreshToken),
+	})
+	return err
+}
+
+// InitiateCustomAuth starts the CUSTOM_AUTH flow for a user on the server side.
+// The pool's Define/Create/Verify auth challenge Lambdas decide what answer is accepted.
+func (c *CognitoClient) InitiateCustomAuth(ctx context.Context, username string) (*cognitoidentityprovider.AdminInitiateAuthOutput, error) {
+	return c.client.AdminInitiateAuth(ctx, &cognitoidentityprovider.AdminInitiateAuthInput{
+		AuthFlow:   types.AuthFlowTypeCustomAuth,
+		ClientId:   aws.String(c.appClientID),
+		UserPoolId: aws.String(c.userPoolID),
+		AuthParameters: map[string]string{
+			"USERNAME": username,
+		},
+	})
+}
+
+// RespondToCustomChallenge answers a CUSTOM_CHALLENGE issued by InitiateCustomAuth.
+func (c *CognitoClient) RespondToCustomChallenge(ctx context.Context, username, session, answer string) (*cognitoidentityprovider.AdminRespondToAuthChallengeOutput, error) {
+	return c.client.AdminRespondToAuthChallenge(ctx, &cognitoidentityprovider.AdminRespondToAuthChallengeInput{
+		ChallengeName: types.ChallengeNameTypeCustomChallenge,
+		ClientId:      aws.String(c.appClientID),
+		UserPoolId:    aws.String(c.userPoolID),
+		Session:       aws.String(session),
+		ChallengeResponses: map[string]string{
+			"USERNAME": username,
+			"ANSWER":   answer,
+		},
+	})
+}
+
+// AssociateSoftwareToken starts TOTP setup and returns the shared secret.
 func (c *CognitoClient) AssociateSoftwareToken(ctx context.Context, accessToken string) (*cognitoidentityprovider.AssociateSoftwareTokenOutput, error) {
-	// Stub: Call AWS SDK's AssociateSoftwareToken API
-	return nil, nil
+	return c.client.AssociateSoftwareToken(ctx, &cognitoidentityprovider.AssociateSoftwareTokenInput{
+		AccessToken: aws.String(accessToken),
+	})
 }
 
-// VerifySoftwareToken verifies a software token for MFA.
-func (c *CognitoClient) VerifySoftwareToken(ctx context.Context, session, code string) (*cognitoidentityprovider.VerifySoftwareTokenOutput, error) {
-	// Stub: Call AWS SDK's VerifySoftwareToken API
-	return nil, nil
+// VerifySoftwareToken checks the first TOTP code of a new authenticator.
+func (c *CognitoClient) VerifySoftwareToken(ctx context.Context, accessToken, code string) (*cognitoidentityprovider.VerifySoftwareTokenOutput, error) {
+	return c.client.VerifySoftwareToken(ctx, &cognitoidentityprovider.VerifySoftwareTokenInput{
+		AccessToken: aws.String(accessToken),
+		UserCode:    aws.String(code),
+	})
 }
 
-// EnableMFA enables MFA for a user.
+// EnableMFA makes TOTP the user's preferred, required second factor.
 func (c *CognitoClient) EnableMFA(ctx context.Context, username string) error {
-	// Stub: Call AWS SDK to enable MFA for a user
-	return nil
+	_, err := c.client.AdminSetUserMFAPreference(ctx, &cognitoidentityprovider.AdminSetUserMFAPreferenceInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+		SoftwareTokenMfaSettings: &types.SoftwareTokenMfaSettingsType{
+			Enabled:      true,
+			PreferredMfa: true,
+		},
+	})
+	return err
+}
+
+// AdminDeleteUser deletes a user from the pool.
+func (c *CognitoClient) AdminDeleteUser(ctx context.Context, username string) error {
+	_, err := c.client.AdminDeleteUser(ctx, &cognitoidentityprovider.AdminDeleteUserInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// AdminGetUser returns a user's status, enabled flag and attributes.
+func (c *CognitoClient) AdminGetUser(ctx context.Context, username string) (*cognitoidentityprovider.AdminGetUserOutput, error) {
+	return c.client.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+}
+
+// AdminDisableUser stops a user from signing in or refreshing tokens.
+func (c *CognitoClient) AdminDisableUser(ctx context.Context, username string) error {
+	_, err := c.client.AdminDisableUser(ctx, &cognitoidentityprovider.AdminDisableUserInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// AdminEnableUser re-enables a disabled user.
+func (c *CognitoClient) AdminEnableUser(ctx context.Context, username string) error {
+	_, err := c.client.AdminEnableUser(ctx, &cognitoidentityprovider.AdminEnableUserInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// AdminResetUserPassword invalidates the password and sends the user a reset code.
+func (c *CognitoClient) AdminResetUserPassword(ctx context.Context, username string) error {
+	_, err := c.client.AdminResetUserPassword(ctx, &cognitoidentityprovider.AdminResetUserPasswordInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// AdminUserGlobalSignOut revokes all of a user's refresh tokens.
+func (c *CognitoClient) AdminUserGlobalSignOut(ctx context.Context, username string) error {
+	_, err := c.client.AdminUserGlobalSignOut(ctx, &cognitoidentityprovider.AdminUserGlobalSignOutInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// CreateGroup creates a user pool group; an existing group is not an error.
+func (c *CognitoClient) CreateGroup(ctx context.Context, name, description string) error {
+	_, err := c.client.CreateGroup(ctx, &cognitoidentityprovider.CreateGroupInput{
+		GroupName:   aws.String(name),
+		Description: aws.String(description),
+		UserPoolId:  aws.String(c.userPoolID),
+	})
+	var exists *types.GroupExistsException
+	if errors.As(err, &exists) {
+		return nil
+	}
+	return err
+}
+
+// AdminAddUserToGroup adds a user to a group.
+func (c *CognitoClient) AdminAddUserToGroup(ctx context.Context, username, group string) error {
+	_, err := c.client.AdminAddUserToGroup(ctx, &cognitoidentityprovider.AdminAddUserToGroupInput{
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// AdminRemoveUserFromGroup removes a user from a group.
+func (c *CognitoClient) AdminRemoveUserFromGroup(ctx context.Context, username, group string) error {
+	_, err := c.client.AdminRemoveUserFromGroup(ctx, &cognitoidentityprovider.AdminRemoveUserFromGroupInput{
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	return err
+}
+
+// AdminListGroupsForUser returns the names of the groups a user is in.
+func (c *CognitoClient) AdminListGroupsForUser(ctx context.Context, username string) ([]string, error) {
+	var names []string
+	p := cognitoidentityprovider.NewAdminListGroupsForUserPaginator(c.client, &cognitoidentityprovider.AdminListGroupsForUserInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range out.Groups {
+			names = append(names, aws.ToString(g.GroupName))
+		}
+	}
+	return names, nil
 }
 
 // InitiateSocialAuth initiates social login authentication.
@@ -118,3 +290,81 @@ func (c *CognitoClient) InitiateSocialAuth(ctx context.Context, provider, idToke
 	// Stub: Call AWS SDK's InitiateAuth API for social login
 	return nil, nil
 }
+
+// challengeCodeKeys maps a code challenge to the ChallengeResponses key carrying the code.
+var challengeCodeKeys = map[types.ChallengeNameType]string{
+	types.ChallengeNameTypeSmsMfa:           "SMS_MFA_CODE",
+	types.ChallengeNameTypeEmailOtp:         "EMAIL_OTP_CODE",
+	types.ChallengeNameTypeSoftwareTokenMfa: "SOFTWARE_TOKEN_MFA_CODE",
+}
+
+// RespondToAuthChallenge answers a code challenge (SMS_MFA, EMAIL_OTP or
+// SOFTWARE_TOKEN_MFA) returned by SignIn.
+func (c *CognitoClient) RespondToAuthChallenge(ctx context.Context, challenge types.ChallengeNameType, username, session, code string) (*cognitoidentityprovider.RespondToAuthChallengeOutput, error) {
+	key, ok := challengeCodeKeys[challenge]
+	if !ok {
+		return nil, fmt.Errorf("unsupported challenge %q", challenge)
+	}
+	return c.client.RespondToAuthChallenge(ctx, &cognitoidentityprovider.RespondToAuthChallengeInput{
+		ChallengeName: challenge,
+		ClientId:      aws.String(c.appClientID),
+		Session:       aws.String(session),
+		ChallengeResponses: map[string]string{
+			"USERNAME": username,
+			key:        code,
+		},
+	})
+}
+
+// ConfirmDevice confirms a device Cognito reported as NewDeviceMetadata and
+// marks it remembered.
+func (c *CognitoClient) ConfirmDevice(ctx context.Context, accessToken, deviceKey, deviceName string) error {
+	in := &cognitoidentityprovider.ConfirmDeviceInput{
+		AccessToken: aws.String(accessToken),
+		DeviceKey:   aws.String(deviceKey),
+	}
+	if deviceName != "" {
+		in.DeviceName = aws.String(deviceName)
+	}
+	_, err := c.client.ConfirmDevice(ctx, in)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.UpdateDeviceStatus(ctx, &cognitoidentityprovider.UpdateDeviceStatusInput{
+		AccessToken:            aws.String(accessToken),
+		DeviceKey:              aws.String(deviceKey),
+		DeviceRememberedStatus: types.DeviceRememberedStatusTypeRemembered,
+	})
+	return err
+}
+
+// AdminListDevices returns the devices Cognito tracks for a user.
+func (c *CognitoClient) AdminListDevices(ctx context.Context, username string) ([]types.DeviceType, error) {
+	var devices []types.DeviceType
+	var token *string
+	for {
+		out, err := c.client.AdminListDevices(ctx, &cognitoidentityprovider.AdminListDevicesInput{
+			Username:        aws.String(username),
+			UserPoolId:      aws.String(c.userPoolID),
+			PaginationToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, out.Devices...)
+		if out.PaginationToken == nil {
+			return devices, nil
+		}
+		token = out.PaginationToken
+	}
+}
+
+// AdminForgetDevice stops Cognito tracking one of a user's devices.
+func (c *CognitoClient) AdminForgetDevice(ctx context.Context, username, deviceKey string) error {
+	_, err := c.client.AdminForgetDevice(ctx, &cognitoidentityprovider.AdminForgetDeviceInput{
+		Username:   aws.String(username),
+		UserPoolId: aws.String(c.userPoolID),
+		DeviceKey:  aws.String(deviceKey),
+	})
+	return err
+}
@@ -1,29 +1,18 @@
 package aws
 
-import (
-	"fmt"
+import "simple-go-auth/internal/platform"
 
-	"github.com/spf13/viper"
-)
-
-// LocalSecretsManager reads secrets from Viper (env/.env).
-type LocalSecretsManager struct{}
+// LocalSecretsManager reads secrets from the environment and .env.
+type LocalSecretsManager struct {
+	platform.EnvSecrets
+}
 
 // NewLocalSecretsManager creates a new LocalSecretsManager.
 func NewLocalSecretsManager() *LocalSecretsManager {
-	return &LocalSecretsManager{}
-}
-
-// GetSecret retrieves a secret value from Viper.
-func (l *LocalSecretsManager) GetSecret(name string) (string, error) {
-	val := viper.GetString(name)
-	if val == "" {
-		return "", fmt.Errorf("secret %s not set", name)
-	}
-	return val, nil
+	return &LocalSecretsManager{platform.EnvSecrets{Env: platform.NewEnv("")}}
 }
 
-// GetJWTSecret retrieves the JWT secret key from Viper.
+// GetJWTSecret retrieves the JWT secret key.
 func (l *LocalSecretsManager) GetJWTSecret() (string, error) {
 	return l.GetSecret("JWT_SECRET")
 }
@@ -1,7 +1,27 @@
 package aws
 
+import (
+	"fmt"
+
+	"simple-go-auth/internal/platform"
+)
+
 // SecretsManager defines how we fetch secrets.
 type SecretsManager interface {
-	GetSecret(name string) (string, error)
+	platform.Secrets
 	GetJWTSecret() (string, error)
 }
+
+// NewSecretsManager returns the provider named by SECRETS_PROVIDER: "aws" or
+// "env". An empty provider returns nil.
+func NewSecretsManager(provider, region string) (SecretsManager, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case "aws":
+		return NewAWSSecretsManager(region), nil
+	case "env":
+		return NewLocalSecretsManager(), nil
+	}
+	return nil, fmt.Errorf("unknown SECRETS_PROVIDER %q", provider)
+}
@@ -0,0 +1,87 @@
+// auditctl verifies and exports the audit log.
+//
+//	auditctl verify
+//	auditctl export [-format jsonl|cef] [-actor name] [-action signin.] [-from RFC3339] [-to RFC3339]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/config"
+	"simple-go-auth/internal/users/db"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	// 1) Load config and connect
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	dbInstance, err := db.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	store, err := audit.NewStore(dbInstance, cfg.AuditHashKey)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	ctx := context.Background()
+
+	// 2) Run the subcommand
+	switch os.Args[1] {
+	case "verify":
+		res, err := store.Verify(ctx)
+		if err != nil {
+			log.Fatalf("Failed to verify audit log: %v", err)
+		}
+		_ = json.NewEncoder(os.Stdout).Encode(res)
+		if !res.OK {
+			os.Exit(1)
+		}
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		format := fs.String("format", audit.FormatJSONL, "jsonl or cef")
+		var f audit.Filter
+		fs.StringVar(&f.Actor, "actor", "", "only entries by this actor")
+		fs.StringVar(&f.Subject, "subject", "", "only entries about this subject")
+		fs.StringVar(&f.Action, "action", "", `action, or a prefix ending in "."`)
+		fs.StringVar(&f.Outcome, "outcome", "", "success or failure")
+		from := fs.String("from", "", "RFC 3339 start time (inclusive)")
+		to := fs.String("to", "", "RFC 3339 end time (exclusive)")
+		_ = fs.Parse(os.Args[2:])
+		if f.From, err = parseTime(*from); err != nil {
+			log.Fatalf("Invalid -from: %v", err)
+		}
+		if f.To, err = parseTime(*to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+		if err := store.Export(ctx, os.Stdout, *format, f); err != nil {
+			log.Fatalf("Failed to export audit log: %v", err)
+		}
+	default:
+		usage()
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: auditctl verify | export [-format jsonl|cef] [filters]")
+	os.Exit(2)
+}
@@ -3,37 +3,66 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/labstack/echo/v4"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.uber.org/zap"
 
+	"simple-go-auth/internal/platform"
+	"simple-go-auth/internal/users/audit"
 	"simple-go-auth/internal/users/auth"
 	"simple-go-auth/internal/users/aws"
 	"simple-go-auth/internal/users/config"
 	"simple-go-auth/internal/users/db"
 	"simple-go-auth/internal/users/http"
+	"simple-go-auth/internal/users/notify"
+	"simple-go-auth/internal/users/oauth"
+	"simple-go-auth/internal/users/oidc"
 	"simple-go-auth/internal/users/otel"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/outbox"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/rebac"
+	"simple-go-auth/internal/users/risk"
+	"simple-go-auth/internal/users/token"
+	"simple-go-auth/internal/users/webhook"
 )
 
 // 3bis. Initialize your SecretsManager for router health checks
 
 func main() {
-	// 0. Initialize OpenTelemetry
-	shutdown := otel.InitTracer()
-	defer shutdown()
-
 	// 1) Load config
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Create shared Zap logger
+	logger, err := platform.NewLogger(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to initialize Zap logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// Cancelled on SIGINT/SIGTERM; the server then drains and background workers stop
+	ctx, stop := platform.SignalContext()
+	defer stop()
+
+	// 1b) Initialize OpenTelemetry (exporter and sampling come from config)
+	shutdown, err := otel.InitTracer(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdown()
+
 	// 2) Init DB
 	dbInstance, err := db.InitDB(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if err := db.Migrate(dbInstance); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
 
 	// 3) Init Cognito client
 	cognitoClient, err := aws.NewCognitoClient(
@@ -47,37 +76,147 @@ func main() {
 
 	// 4) Build AuthService
 	authService := auth.NewAuthServiceImpl(cognitoClient, dbInstance)
+	authService.Logger = logger
+	authService.CustomAuthSecret = cfg.CustomAuthSecret
+	authService.SessionSecret = cfg.ChallengeSessionSecret
+	authService.Provider = cfg.AuthProvider
+	authService.AccessTokenTTL = time.Duration(cfg.AccessTokenExpiry) * time.Second
+	authService.RefreshTokenTTL = cfg.RefreshTokenTTL
+	authService.MagicLinkSecret = cfg.MagicLinkSecret
+	authService.MagicLinkURL = cfg.MagicLinkURL
+	authService.MagicLinkTTL = cfg.MagicLinkTTL
+	authService.DeviceSecret = cfg.DeviceCookieSecret
+	authService.DeviceTrustTTL = cfg.DeviceTrustTTL
+	authService.APIKeyDefaultTTL = cfg.APIKeyDefaultTTL
+	authService.APIKeyMaxTTL = cfg.APIKeyMaxTTL
+	if cfg.RiskEnabled {
+		engine, err := risk.NewEngine(cfg.RiskRulesFile, cfg.GeoIPDBPath, cfg.IPReputationFiles)
+		if err != nil {
+			log.Fatalf("Failed to initialize risk engine: %v", err)
+		}
+		authService.Risk = engine
+	}
+	authService.OTP = otp.Policy{
+		Digits:         cfg.OTPDigits,
+		TTL:            cfg.OTPTTL,
+		MaxAttempts:    cfg.OTPMaxAttempts,
+		ResendCooldown: cfg.OTPResendCooldown,
+		Secret:         cfg.OTPSecret,
+	}
+	signer, err := token.NewSigner(cfg.TokenSigningKey, cfg.TokenIssuer)
+	if err != nil {
+		log.Fatalf("Failed to load token signing key: %v", err)
+	}
+	authService.Tokens = signer
 
 	// 5) Build your handler (this also registers its own routes on a new echo.Group internally)
 	//    Note: it DOES NOT create the base echo - just records handler methods.
-	authHandler := auth.NewHandler(nil, authService, cfg) // we’ll pass 'nil' because SetupRouter will mount routes directly
+	authHandler := auth.NewHandler(authService, cfg) // SetupRouter mounts its routes
 
-	// Create shared Zap logger
-	logger, err := zap.NewProduction()
+	// Transactional email/SMS, delivered asynchronously with retries
+	notifier, err := notify.NewFromConfig(ctx, cfg, logger)
 	if err != nil {
-		log.Fatalf("Failed to initialize Zap logger: %v", err)
+		log.Fatalf("Failed to initialize notifier: %v", err)
 	}
-	defer logger.Sync()
+	defer notifier.Close()
+	templates, err := notify.NewTemplates()
+	if err != nil {
+		log.Fatalf("Failed to parse notification templates: %v", err)
+	}
+	authService.Notifier = notifier
+	authService.Templates = templates
+	auditStore, err := audit.NewStore(dbInstance, cfg.AuditHashKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize audit log: %v", err)
+	}
+	authService.Audit = auditStore
 
-	// 3bis. Wrap Echo with OTel middleware
-	e := echo.New()
-	e.Use(otelecho.Middleware("my-go-auth-service"))
+	// Relay user lifecycle events from the outbox to the configured sink
+	sink, err := outbox.NewSinkFromConfig(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize outbox sink: %v", err)
+	}
 
-	// 6) Create the Echo router with global middleware + config/ping + auth routes
-	router := http.SetupRouter(authHandler, auth.NewMiddleware(authService), logger, cfg)
+	// Tenant webhooks receive audit events and user lifecycle events
+	if cfg.WebhooksEnabled {
+		dispatcher := webhook.NewDispatcher(dbInstance, logger.Named("webhook"))
+		dispatcher.MaxAttempts = cfg.WebhookMaxAttempts
+		dispatcher.AllowInsecureURLs = cfg.Env != "production"
+		dispatcher.AllowPrivateNetworks = cfg.Env != "production"
+		dispatcher.Tenant = cfg.WebhookTenant
+		authService.Webhooks = dispatcher
+		authService.Audit = audit.Multi{auditStore, dispatcher}
+		if sink == nil {
+			sink = dispatcher
+		} else {
+			sink = outbox.MultiSink{sink, dispatcher}
+		}
+		go dispatcher.Run(ctx)
+	}
+
+	// Roles and permissions; ADMIN_USERNAMES are granted the admin role
+	roles, err := rbac.LoadRoles(cfg.RBACRolesFile)
+	if err != nil {
+		log.Fatalf("Failed to load roles: %v", err)
+	}
+	authService.RBAC = rbac.NewStore(dbInstance)
+	if err := authService.SetupRoles(ctx, roles, cfg.AdminUsernames, logger); err != nil {
+		log.Fatalf("Failed to set up roles: %v", err)
+	}
+
+	// Relationship-based authorization for product services
+	if cfg.RelationsConfigFile != "" {
+		namespaces, err := rebac.LoadConfig(cfg.RelationsConfigFile)
+		if err != nil {
+			log.Fatalf("Failed to load relation namespaces: %v", err)
+		}
+		relations := rebac.NewStore(dbInstance, namespaces)
+		relations.Staleness = cfg.RelationsStaleness
+		relations.CacheSize = cfg.RelationsCacheSize
+		authService.Relations = relations
+	}
 
-	// Set Echo server read and write timeouts
-	router.Server.ReadTimeout = cfg.EchoReadTimeout
-	router.Server.WriteTimeout = cfg.EchoWriteTimeout
+	if sink != nil {
+		relay := outbox.NewRelay(dbInstance, sink, logger.Named("outbox"))
+		relay.Interval = cfg.OutboxPollInterval
+		go relay.Run(ctx)
+	}
+
+	// 6) Create the Echo router with global middleware + config/ping + auth routes
+	authMw := auth.NewMiddleware(authService)
+	router := http.SetupRouter(authHandler, authMw, logger, cfg)
+
+	// OAuth 2.0: client_credentials tokens for services, introspection and
+	// revocation of every token we issue, and the client registry, which
+	// holders of OAUTH_REGISTRATION_TOKEN may also register with
+	oauthServer := oauth.NewServer(dbInstance, signer)
+	oauthServer.AccessTokenTTL = cfg.OAuthAccessTokenTTL
+	oauthServer.IntrospectionCacheTTL = cfg.OAuthIntrospectionCacheTTL
+	oauthServer.Audit = authService.Audit
+	oauthServer.Users = authService
+	oauthServer.RegistrationToken = cfg.OAuthRegistrationToken
+	oauthServer.RegistrationScopes = cfg.OAuthRegistrationScopes
+	oauthServer.AllowInsecureRedirects = cfg.Env != "production"
+	oauthServer.RegisterRoutes(router)
+	oauthServer.RegisterAdminRoutes(router.Group("/admin/oauth", authMw),
+		auth.RequirePermission(rbac.PermClientsRead), auth.RequirePermission(rbac.PermClientsWrite),
+		func(c echo.Context) string { return auth.PrincipalFromContext(c).Username })
+
+	// OpenID Connect for internal apps, signing users in through authService,
+	// and the device flow for CLIs; it adds the authorization_code,
+	// refresh_token and device_code grants to /oauth/token
+	provider := oidc.NewProvider(oauthServer, authService)
+	provider.SessionTTL = cfg.OIDCSessionTTL
+	provider.DeviceCodeTTL = cfg.OAuthDeviceCodeTTL
+	provider.RegisterRoutes(router, authMw)
 
 	// 7) Root welcome (optional – you can also add this in SetupRouter)
 	router.GET("/", func(c echo.Context) error {
 		return c.String(200, "🚀 Welcome to BitPolaris! Please POST to /signin or /signup")
 	})
 
-	// 8) Start
-	log.Printf("Server running on port %s...\n", cfg.Port)
-
-	// Listen with TLS (HTTP/2)
-	log.Fatal(router.StartTLS(":"+cfg.Port, "certs/server.crt", "certs/server.key"))
+	// 8) Start with TLS (HTTP/2) and shut down gracefully on SIGTERM
+	if err := platform.Serve(ctx, router, cfg.Server, logger); err != nil {
+		logger.Fatal("Server failed", zap.Error(err))
+	}
 }
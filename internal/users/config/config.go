@@ -4,90 +4,289 @@ package config
 import (
 	"time"
 
-	"github.com/spf13/viper"
+	"simple-go-auth/internal/platform"
 )
 
+// Config is the users service configuration. Secrets are tagged json:"-"
+// so GET /config never serves them.
 type Config struct {
-	Port               string // default "80"
-	AWSRegion          string // default "ap-southeast-2"
-	Env                string // add this
-	DBHost             string
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	AccessTokenExpiry  int           // default 3600
-	DBMaxOpenConns     int           // max open DB connections
-	DBMaxIdleConns     int           // max idle DB connections
-	DBConnMaxLifetime  time.Duration // max connection lifetime
-	JWTSecret          string        // populated at startup from AWS
-	CognitoUserPoolID  string        // from COGNITO_USER_POOL_ID
-	CognitoAppClientID string        // from COGNITO_APP_CLIENT_ID
-	RecaptchaSecretKey string        // from RECAPTCHA_SECRET_KEY
-	EchoReadTimeout    time.Duration // default '5s'
-	EchoWriteTimeout   time.Duration // default '10s'
-	MFAEnabled         bool          // default "false"
-	SocialProviders    []string      // default ""
+	platform.Base
+	AWSRegion                  string // default "ap-southeast-2"
+	DBHost                     string
+	DBUser                     string
+	DBPassword                 string `json:"-"`
+	DBName                     string
+	AccessTokenExpiry          int           // default 3600
+	RefreshTokenTTL            time.Duration // default '720h'; with Cognito, the app client's refresh token expiration
+	DBMaxOpenConns             int           // max open DB connections
+	DBMaxIdleConns             int           // max idle DB connections
+	DBConnMaxLifetime          time.Duration // max connection lifetime
+	JWTSecret                  string        `json:"-"` // populated at startup from AWS
+	CognitoUserPoolID          string        // from COGNITO_USER_POOL_ID
+	CognitoAppClientID         string        // from COGNITO_APP_CLIENT_ID
+	RecaptchaSecretKey         string        `json:"-"` // from RECAPTCHA_SECRET_KEY
+	MFAEnabled                 bool          // default "false"
+	SocialProviders            []string      // default ""
+	CustomAuthSecret           string        `json:"-"` // from CUSTOM_AUTH_SECRET, shared with the CUSTOM_AUTH Lambda triggers
+	ChallengeSessionSecret     string        `json:"-"` // from CHALLENGE_SESSION_SECRET, seals Cognito MFA challenge sessions
+	QRLoginTTL                 time.Duration // default '2m'
+	QRLoginURL                 string        // default "bitpolaris://qr-login"
+	WebSocketOrigins           []string      // space-separated browser origins besides our own allowed to open WebSockets
+	NotifyEmailProvider        string        // smtp, ses or none; default "none"
+	SMTPHost                   string
+	SMTPPort                   int // default 587
+	SMTPUsername               string
+	SMTPPassword               string `json:"-"`
+	MailFrom                   string // default "no-reply@localhost"
+	SESEndpoint                string // optional SES-compatible endpoint
+	SMSGatewayURL              string // empty disables SMS delivery
+	SMSGatewayAPIKey           string `json:"-"`
+	SMSSender                  string
+	NotifyWorkers              int           // default 4
+	NotifyMaxAttempts          int           // default 5
+	AuthProvider               string        // cognito or local; default "cognito"
+	TokenIssuer                string        // "iss" of native tokens; default "simple-go-auth"
+	TokenSigningKey            string        `json:"-"` // PEM RSA key for native tokens; empty generates an ephemeral key
+	MagicLinkURL               string        // default "https://localhost/signin/magic/verify"
+	MagicLinkTTL               time.Duration // default '15m'
+	MagicLinkSecret            string        `json:"-"` // HMAC key for magic-link signatures
+	OTPDigits                  int           // default 6
+	OTPTTL                     time.Duration // default '5m'
+	OTPMaxAttempts             int           // default 5
+	OTPResendCooldown          time.Duration // default '30s'
+	OTPSecret                  string        `json:"-"` // HMAC key for stored one-time code hashes
+	DeviceTrustTTL             time.Duration // default '720h'; how long a remembered device skips MFA
+	DeviceCookieSecret         string        `json:"-"` // HMAC key for trusted-device cookies
+	RiskEnabled                bool          // default "false"; score sign-ins and step up or block
+	RiskRulesFile              string        // JSON overrides of risk.DefaultRules
+	GeoIPDBPath                string        // MaxMind-format city database; empty disables impossible travel
+	IPReputationFiles          []string      // space-separated IP/CIDR list files, one list per file
+	AdminUsernames             []string      // space-separated usernames granted the admin role at startup
+	RBACRolesFile              string        // JSON roles and permissions; empty uses rbac.DefaultRoles
+	AuditHashKey               string        `json:"-"` // HMAC key sealing the audit log chain; required
+	OutboxSink                 string        // sns, sqs, webhook, file or none; default "none"
+	OutboxSNSTopicARN          string
+	OutboxSQSQueueURL          string
+	OutboxWebhookURL           string
+	OutboxWebhookSecret        string        `json:"-"` // HMAC key for X-Webhook-Signature
+	OutboxFile                 string        // JSON-lines file for the file sink
+	OutboxPollInterval         time.Duration // default '1s'
+	WebhooksEnabled            bool          // default "false"; tenant webhook endpoints for auth events
+	WebhookMaxAttempts         int           // default 8; then the delivery is dead-lettered
+	WebhookTenant              string        // tenant whose webhooks receive this deployment's events
+	APIKeyDefaultTTL           time.Duration // default '2160h' (90 days)
+	APIKeyMaxTTL               time.Duration // default '8760h' (365 days); longest expiry a key may ask for
+	OAuthAccessTokenTTL        time.Duration // default '1h'; client_credentials tokens of clients that set none
+	OAuthIntrospectionCacheTTL time.Duration // default '10s'; how long /oauth/introspect reuses a result
+	OAuthRegistrationToken     string        `json:"-"` // initial access token of POST /oauth/register; empty disables it
+	OAuthRegistrationScopes    []string      // space-separated scopes registered clients may ask for; default the OpenID Connect ones
+	OIDCSessionTTL             time.Duration // default '12h'; how long a browser stays signed in at /authorize
+	OAuthDeviceCodeTTL         time.Duration // default '10m'; how long a device code waits for approval at /device
+	RelationsConfigFile        string        // namespace configuration; empty disables the /authz API
+	RelationsStaleness         time.Duration // default '1s'; snapshot age allowed without a zookie
+	RelationsCacheSize         int           // default 10000; cached check results, negative disables
+	SecretsProvider            string        // aws or env; default "aws"
 }
 
 // LoadConfig reads .env and environment variables into Config.
 func LoadConfig() (*Config, error) {
-	viper.SetConfigFile(".env")
-	viper.AutomaticEnv()
-	_ = viper.ReadInConfig()
+	v := platform.NewEnv("")
+	base := platform.LoadBase(v, "simple-go-auth", "80")
 
 	cfg := &Config{
-		Port:               viper.GetString("PORT"),
-		AWSRegion:          viper.GetString("AWS_REGION"),
-		Env:                viper.GetString("ENV"), // add this
-		DBHost:             viper.GetString("DB_HOST"),
-		DBUser:             viper.GetString("DB_USER"),
-		DBPassword:         viper.GetString("DB_PASSWORD"),
-		DBName:             viper.GetString("DB_NAME"),
-		AccessTokenExpiry:  viper.GetInt("ACCESS_TOKEN_EXPIRY"),
-		DBMaxOpenConns:     viper.GetInt("DB_MAX_OPEN_CONNS"),
-		DBMaxIdleConns:     viper.GetInt("DB_MAX_IDLE_CONNS"),
-		DBConnMaxLifetime:  viper.GetDuration("DB_CONN_MAX_LIFETIME"),
-		JWTSecret:          "", // will be fetched from AWS
-		CognitoUserPoolID:  viper.GetString("COGNITO_USER_POOL_ID"),
-		CognitoAppClientID: viper.GetString("COGNITO_APP_CLIENT_ID"),
-		RecaptchaSecretKey: viper.GetString("RECAPTCHA_SECRET_KEY"),
-		EchoReadTimeout:    viper.GetDuration("ECHO_READ_TIMEOUT"),
-		EchoWriteTimeout:   viper.GetDuration("ECHO_WRITE_TIMEOUT"),
-		MFAEnabled:         viper.GetBool("MFA_ENABLED"),
-		SocialProviders:    viper.GetStringSlice("SOCIAL_PROVIDERS"),
+		Base:                       base,
+		AWSRegion:                  v.GetString("AWS_REGION"),
+		DBHost:                     v.GetString("DB_HOST"),
+		DBUser:                     v.GetString("DB_USER"),
+		DBPassword:                 v.GetString("DB_PASSWORD"),
+		DBName:                     v.GetString("DB_NAME"),
+		AccessTokenExpiry:          v.GetInt("ACCESS_TOKEN_EXPIRY"),
+		RefreshTokenTTL:            v.GetDuration("REFRESH_TOKEN_TTL"),
+		DBMaxOpenConns:             v.GetInt("DB_MAX_OPEN_CONNS"),
+		DBMaxIdleConns:             v.GetInt("DB_MAX_IDLE_CONNS"),
+		DBConnMaxLifetime:          v.GetDuration("DB_CONN_MAX_LIFETIME"),
+		JWTSecret:                  "", // will be fetched from AWS
+		CognitoUserPoolID:          v.GetString("COGNITO_USER_POOL_ID"),
+		CognitoAppClientID:         v.GetString("COGNITO_APP_CLIENT_ID"),
+		RecaptchaSecretKey:         v.GetString("RECAPTCHA_SECRET_KEY"),
+		MFAEnabled:                 v.GetBool("MFA_ENABLED"),
+		SocialProviders:            v.GetStringSlice("SOCIAL_PROVIDERS"),
+		CustomAuthSecret:           v.GetString("CUSTOM_AUTH_SECRET"),
+		ChallengeSessionSecret:     v.GetString("CHALLENGE_SESSION_SECRET"),
+		QRLoginTTL:                 v.GetDuration("QR_LOGIN_TTL"),
+		QRLoginURL:                 v.GetString("QR_LOGIN_URL"),
+		WebSocketOrigins:           v.GetStringSlice("WEBSOCKET_ORIGINS"),
+		NotifyEmailProvider:        v.GetString("NOTIFY_EMAIL_PROVIDER"),
+		SMTPHost:                   v.GetString("SMTP_HOST"),
+		SMTPPort:                   v.GetInt("SMTP_PORT"),
+		SMTPUsername:               v.GetString("SMTP_USERNAME"),
+		SMTPPassword:               v.GetString("SMTP_PASSWORD"),
+		MailFrom:                   v.GetString("MAIL_FROM"),
+		SESEndpoint:                v.GetString("SES_ENDPOINT"),
+		SMSGatewayURL:              v.GetString("SMS_GATEWAY_URL"),
+		SMSGatewayAPIKey:           v.GetString("SMS_GATEWAY_API_KEY"),
+		SMSSender:                  v.GetString("SMS_SENDER"),
+		NotifyWorkers:              v.GetInt("NOTIFY_WORKERS"),
+		NotifyMaxAttempts:          v.GetInt("NOTIFY_MAX_ATTEMPTS"),
+		AuthProvider:               v.GetString("AUTH_PROVIDER"),
+		TokenIssuer:                v.GetString("TOKEN_ISSUER"),
+		TokenSigningKey:            v.GetString("TOKEN_SIGNING_KEY"),
+		MagicLinkURL:               v.GetString("MAGIC_LINK_URL"),
+		MagicLinkTTL:               v.GetDuration("MAGIC_LINK_TTL"),
+		MagicLinkSecret:            v.GetString("MAGIC_LINK_SECRET"),
+		OTPDigits:                  v.GetInt("OTP_DIGITS"),
+		OTPTTL:                     v.GetDuration("OTP_TTL"),
+		OTPMaxAttempts:             v.GetInt("OTP_MAX_ATTEMPTS"),
+		OTPResendCooldown:          v.GetDuration("OTP_RESEND_COOLDOWN"),
+		OTPSecret:                  v.GetString("OTP_SECRET"),
+		DeviceTrustTTL:             v.GetDuration("DEVICE_TRUST_TTL"),
+		DeviceCookieSecret:         v.GetString("DEVICE_COOKIE_SECRET"),
+		RiskEnabled:                v.GetBool("RISK_ENABLED"),
+		RiskRulesFile:              v.GetString("RISK_RULES_FILE"),
+		GeoIPDBPath:                v.GetString("GEOIP_DB_PATH"),
+		IPReputationFiles:          v.GetStringSlice("IP_REPUTATION_FILES"),
+		AdminUsernames:             v.GetStringSlice("ADMIN_USERNAMES"),
+		RBACRolesFile:              v.GetString("RBAC_ROLES_FILE"),
+		AuditHashKey:               v.GetString("AUDIT_HASH_KEY"),
+		OutboxSink:                 v.GetString("OUTBOX_SINK"),
+		OutboxSNSTopicARN:          v.GetString("OUTBOX_SNS_TOPIC_ARN"),
+		OutboxSQSQueueURL:          v.GetString("OUTBOX_SQS_QUEUE_URL"),
+		OutboxWebhookURL:           v.GetString("OUTBOX_WEBHOOK_URL"),
+		OutboxWebhookSecret:        v.GetString("OUTBOX_WEBHOOK_SECRET"),
+		OutboxFile:                 v.GetString("OUTBOX_FILE"),
+		OutboxPollInterval:         v.GetDuration("OUTBOX_POLL_INTERVAL"),
+		WebhooksEnabled:            v.GetBool("WEBHOOKS_ENABLED"),
+		WebhookMaxAttempts:         v.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		WebhookTenant:              v.GetString("WEBHOOK_TENANT"),
+		APIKeyDefaultTTL:           v.GetDuration("API_KEY_DEFAULT_TTL"),
+		APIKeyMaxTTL:               v.GetDuration("API_KEY_MAX_TTL"),
+		OAuthAccessTokenTTL:        v.GetDuration("OAUTH_ACCESS_TOKEN_TTL"),
+		OAuthIntrospectionCacheTTL: v.GetDuration("OAUTH_INTROSPECTION_CACHE_TTL"),
+		OAuthRegistrationToken:     v.GetString("OAUTH_REGISTRATION_TOKEN"),
+		OAuthRegistrationScopes:    v.GetStringSlice("OAUTH_REGISTRATION_SCOPES"),
+		OIDCSessionTTL:             v.GetDuration("OIDC_SESSION_TTL"),
+		OAuthDeviceCodeTTL:         v.GetDuration("OAUTH_DEVICE_CODE_TTL"),
+		RelationsConfigFile:        v.GetString("RELATIONS_CONFIG_FILE"),
+		RelationsStaleness:         v.GetDuration("RELATIONS_STALENESS"),
+		RelationsCacheSize:         v.GetInt("RELATIONS_CACHE_SIZE"),
+		SecretsProvider:            v.GetString("SECRETS_PROVIDER"),
 	}
 
 	// Fallback defaults
-	if cfg.Port == "" {
-		cfg.Port = "80"
-	}
 	if cfg.AWSRegion == "" {
 		cfg.AWSRegion = "ap-southeast-2"
 	}
-	if cfg.Env == "" {
-		cfg.Env = "production" // set default to "production"
-	}
 	if cfg.AccessTokenExpiry == 0 {
 		cfg.AccessTokenExpiry = 3600
 	}
+	if cfg.RefreshTokenTTL == 0 {
+		cfg.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
 	if cfg.CognitoUserPoolID == "" {
 		cfg.CognitoUserPoolID = ""
 	}
 	if cfg.CognitoAppClientID == "" {
 		cfg.CognitoAppClientID = ""
 	}
-	if cfg.EchoReadTimeout == 0 {
-		cfg.EchoReadTimeout = 5 * time.Second
-	}
-	if cfg.EchoWriteTimeout == 0 {
-		cfg.EchoWriteTimeout = 10 * time.Second
-	}
 	if !cfg.MFAEnabled {
 		cfg.MFAEnabled = false
 	}
 	if len(cfg.SocialProviders) == 0 {
 		cfg.SocialProviders = []string{}
 	}
+	if cfg.QRLoginTTL == 0 {
+		cfg.QRLoginTTL = 2 * time.Minute
+	}
+	if cfg.QRLoginURL == "" {
+		cfg.QRLoginURL = "bitpolaris://qr-login"
+	}
+	if cfg.NotifyEmailProvider == "" {
+		cfg.NotifyEmailProvider = "none"
+	}
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 587
+	}
+	if cfg.MailFrom == "" {
+		cfg.MailFrom = "no-reply@localhost"
+	}
+	if cfg.NotifyWorkers == 0 {
+		cfg.NotifyWorkers = 4
+	}
+	if cfg.NotifyMaxAttempts == 0 {
+		cfg.NotifyMaxAttempts = 5
+	}
+	if cfg.AuthProvider == "" {
+		cfg.AuthProvider = "cognito"
+	}
+	if cfg.TokenIssuer == "" {
+		cfg.TokenIssuer = "simple-go-auth"
+	}
+	if cfg.MagicLinkURL == "" {
+		cfg.MagicLinkURL = "https://localhost/signin/magic/verify"
+	}
+	if cfg.MagicLinkTTL == 0 {
+		cfg.MagicLinkTTL = 15 * time.Minute
+	}
+	if cfg.OTPDigits == 0 {
+		cfg.OTPDigits = 6
+	}
+	if cfg.OTPTTL == 0 {
+		cfg.OTPTTL = 5 * time.Minute
+	}
+	if cfg.OTPMaxAttempts == 0 {
+		cfg.OTPMaxAttempts = 5
+	}
+	if cfg.OTPResendCooldown == 0 {
+		cfg.OTPResendCooldown = 30 * time.Second
+	}
+	if cfg.DeviceTrustTTL == 0 {
+		cfg.DeviceTrustTTL = 30 * 24 * time.Hour
+	}
+	if cfg.OutboxSink == "" {
+		cfg.OutboxSink = "none"
+	}
+	if cfg.OutboxPollInterval == 0 {
+		cfg.OutboxPollInterval = time.Second
+	}
+	if cfg.WebhookMaxAttempts == 0 {
+		cfg.WebhookMaxAttempts = 8
+	}
+	if cfg.APIKeyDefaultTTL == 0 {
+		cfg.APIKeyDefaultTTL = 90 * 24 * time.Hour
+	}
+	if cfg.APIKeyMaxTTL == 0 {
+		cfg.APIKeyMaxTTL = 365 * 24 * time.Hour
+	}
+	if cfg.OAuthAccessTokenTTL == 0 {
+		cfg.OAuthAccessTokenTTL = time.Hour
+	}
+	if cfg.OAuthIntrospectionCacheTTL == 0 {
+		cfg.OAuthIntrospectionCacheTTL = 10 * time.Second
+	}
+	if len(cfg.OAuthRegistrationScopes) == 0 {
+		cfg.OAuthRegistrationScopes = []string{"openid", "profile", "email", "phone", "offline_access"}
+	}
+	if cfg.OIDCSessionTTL == 0 {
+		cfg.OIDCSessionTTL = 12 * time.Hour
+	}
+	if cfg.OAuthDeviceCodeTTL == 0 {
+		cfg.OAuthDeviceCodeTTL = 10 * time.Minute
+	}
+	if cfg.RelationsStaleness == 0 {
+		cfg.RelationsStaleness = time.Second
+	}
+	if cfg.RelationsCacheSize == 0 {
+		cfg.RelationsCacheSize = 10000
+	}
+	if cfg.Server.TLSCertFile == "" {
+		cfg.Server.TLSCertFile = "certs/server.crt"
+	}
+	if cfg.Server.TLSKeyFile == "" {
+		cfg.Server.TLSKeyFile = "certs/server.key"
+	}
+	if cfg.SecretsProvider == "" {
+		cfg.SecretsProvider = "aws"
+	}
 
 	return cfg, nil
 }
@@ -4,7 +4,13 @@ import (
 	"fmt"
 	"time"
 
+	"simple-go-auth/internal/users/audit"
 	"simple-go-auth/internal/users/config"
+	"simple-go-auth/internal/users/oauth"
+	"simple-go-auth/internal/users/outbox"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/rebac"
+	"simple-go-auth/internal/users/webhook"
 
 	"github.com/uptrace/opentelemetry-go-extra/otelsql"
 	"gorm.io/driver/postgres"
@@ -48,3 +54,37 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 
 	return gormDB, nil
 }
+
+// Migrate creates or updates the tables for all models.
+func Migrate(gormDB *gorm.DB) error {
+	if err := gormDB.AutoMigrate(
+		&User{},
+		&RefreshToken{},
+		&LoginChallenge{},
+		&UsedChallengeSession{},
+		&MagicLink{},
+		&OneTimeCode{},
+		&RecoveryCode{},
+		&Device{},
+		&LoginAttempt{},
+		&APIKey{},
+	); err != nil {
+		return err
+	}
+	if err := audit.Migrate(gormDB); err != nil {
+		return err
+	}
+	if err := outbox.Migrate(gormDB); err != nil {
+		return err
+	}
+	if err := webhook.Migrate(gormDB); err != nil {
+		return err
+	}
+	if err := rbac.Migrate(gormDB); err != nil {
+		return err
+	}
+	if err := rebac.Migrate(gormDB); err != nil {
+		return err
+	}
+	return oauth.Migrate(gormDB)
+}
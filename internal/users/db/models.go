@@ -4,21 +4,132 @@ import "time"
 
 // User represents a registered user.
 type User struct {
-	ID        uint   `gorm:"primaryKey"`
-	Username  string `gorm:"unique;not null"`
-	Email     string `gorm:"unique;not null"`
-	Password  string `gorm:"not null"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID                    uint       `gorm:"primaryKey"`
+	Username              string     `gorm:"unique;not null"`
+	Email                 string     `gorm:"unique;not null"`
+	Password              string     `gorm:"not null"`
+	Phone                 string     `gorm:"index;default:''"` // E.164, for SMS codes
+	MFAMethod             string     `gorm:"default:''"`       // local provider second factor: sms, email or empty
+	ConfirmedAt           *time.Time // set when the sign-up is confirmed; local accounts start confirmed
+	DisabledAt            *time.Time `gorm:"index"`         // set while an administrator has disabled the account
+	PasswordResetRequired bool       `gorm:"default:false"` // password sign-in is refused until the password is reset
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
 }
 
-// RefreshToken tracks a user's refresh tokens.
+// RefreshToken tracks a user's refresh tokens. Rotation revokes a token and
+// stores its successor with PreviousToken set to it; tokens from a sign-in
+// have none, so PreviousToken is indexed but not unique. Tokens issued to
+// an OAuth client carry its ClientID and the granted Scope, which rotation
+// keeps; first-party tokens have neither.
 type RefreshToken struct {
 	ID            uint      `gorm:"primaryKey"`
 	UserID        uint      `gorm:"index;not null"`
 	Token         string    `gorm:"unique;not null"`
 	ExpiresAt     time.Time `gorm:"not null"`
 	Revoked       bool      `gorm:"default:false"`
-	PreviousToken string    `gorm:"unique;default:''"`
+	PreviousToken string    `gorm:"index;default:''"`
+	ClientID      string    `gorm:"size:64;index;default:''"`
+	Scope         string    `gorm:"type:text;default:''"` // space-separated
 	CreatedAt     time.Time
 }
+
+// APIKey is a long-lived credential for CLIs and pipelines. Only the hash
+// of the key is stored; KeyID is the public part used to find it.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey"`
+	KeyID      string     `gorm:"size:32;uniqueIndex;not null"`
+	UserID     uint       `gorm:"index;not null"`
+	Name       string     `gorm:"not null"`
+	Scopes     string     `gorm:"type:text;default:''"` // space-separated permissions the key may use
+	Hash       string     `gorm:"not null"`             // SHA-256 of the whole key, hex
+	ExpiresAt  time.Time  `gorm:"not null"`
+	LastUsedAt *time.Time // updated at most once a minute
+	LastUsedIP string     `gorm:"default:''"`
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// UsedChallengeSession remembers a sealed challenge session that was answered
+// until it expires, so it can't be replayed.
+type UsedChallengeSession struct {
+	ID        string    `gorm:"primaryKey;size:64"` // hex HMAC of the sealed session
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// LoginChallenge is a short-lived cross-device login request. A logged-out
+// client creates it and waits; an authenticated client approves it.
+type LoginChallenge struct {
+	ID         string    `gorm:"primaryKey;size:36"`
+	Status     string    `gorm:"index;not null;default:'pending'"` // pending, approved, consumed
+	Username   string    `gorm:"default:''"`                       // set on approval
+	RequestIP  string    `gorm:"default:''"`
+	UserAgent  string    `gorm:"default:''"`
+	ExpiresAt  time.Time `gorm:"index;not null"`
+	ApprovedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// MagicLink is a single-use passwordless sign-in link. Only the hash of the
+// browser nonce it is bound to is stored.
+type MagicLink struct {
+	ID        string    `gorm:"primaryKey;size:36"`
+	UserID    uint      `gorm:"index;not null"`
+	NonceHash string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// OneTimeCode is a numeric code sent by email or SMS. Only a keyed hash of
+// the code is stored.
+type OneTimeCode struct {
+	ID          string    `gorm:"primaryKey;size:36"`
+	UserID      uint      `gorm:"index;not null"`
+	Purpose     string    `gorm:"index;not null"` // login or mfa
+	Channel     string    `gorm:"not null"`       // email or sms
+	Destination string    `gorm:"default:''"`     // address or phone the code went to
+	CodeHash    string    `gorm:"not null"`
+	Attempts    int       `gorm:"not null;default:0"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	ConsumedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+// RecoveryCode is a single-use MFA backup code. Only a keyed hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	User      User   `gorm:"constraint:OnDelete:CASCADE"`
+	CodeHash  string `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Device is a browser or app a user has signed in from. Trusted devices skip
+// MFA until TrustedUntil.
+type Device struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"uniqueIndex:idx_device_user_fp;not null"`
+	Fingerprint  string `gorm:"uniqueIndex:idx_device_user_fp;not null"` // hash of client-supplied fingerprint or user agent
+	DeviceKey    string `gorm:"default:''"`                              // Cognito device key, when tracked there
+	UserAgent    string `gorm:"default:''"`
+	IP           string `gorm:"default:''"`
+	Trusted      bool   `gorm:"default:false"`
+	TrustedUntil *time.Time
+	FirstSeenAt  time.Time
+	LastSeenAt   time.Time
+}
+
+// LoginAttempt is one password sign-in attempt, kept for risk scoring.
+type LoginAttempt struct {
+	ID          uint   `gorm:"primaryKey"`
+	Username    string `gorm:"index;not null"`
+	IP          string `gorm:"index;default:''"`
+	Success     bool   `gorm:"not null"`
+	HasLocation bool   `gorm:"default:false"`
+	Country     string `gorm:"default:''"`
+	Latitude    float64
+	Longitude   float64
+	CreatedAt   time.Time `gorm:"index"`
+}
@@ -28,9 +28,11 @@ func HealthCheck(cfg *config.Config, sm aws.SecretsManager) echo.HandlerFunc {
 			return c.JSON(http.StatusServiceUnavailable, map[string]string{"db": err.Error()})
 		}
 
-		// 2) Cognito: dummy GetUser or ListUsers call
-		if _, err := sm.GetJWTSecret(); err != nil {
-			return c.JSON(http.StatusServiceUnavailable, map[string]string{"secrets": err.Error()})
+		// 2) Secrets reachable, when a provider is configured
+		if sm != nil {
+			if _, err := sm.GetJWTSecret(); err != nil {
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{"secrets": err.Error()})
+			}
 		}
 
 		return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
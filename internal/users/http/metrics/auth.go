@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Authentication domain metrics
+var (
+	signIns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_signin_total",
+			Help: "Sign-in attempts by result and failure reason",
+		},
+		[]string{"result", "reason"},
+	)
+	signUps = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_signup_total",
+			Help: "Successful sign-ups",
+		},
+	)
+	confirmations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_confirmation_total",
+			Help: "Successful sign-up confirmations",
+		},
+	)
+	refreshRotations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_refresh_rotation_total",
+			Help: "Refresh tokens rotated",
+		},
+	)
+	refreshReuse = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_refresh_reuse_detected_total",
+			Help: "Revoked refresh tokens presented again",
+		},
+	)
+	mfaChallenges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_mfa_challenge_total",
+			Help: "MFA challenges issued by challenge type",
+		},
+		[]string{"challenge"},
+	)
+	cognitoDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cognito_request_duration_seconds",
+			Help:    "Cognito API latency by operation",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	cognitoErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cognito_request_errors_total",
+			Help: "Cognito API errors by operation and error code",
+		},
+		[]string{"operation", "code"},
+	)
+	wsConnections = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_active_connections",
+			Help: "Currently open WebSocket connections",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		signIns, signUps, confirmations,
+		refreshRotations, refreshReuse, mfaChallenges,
+		cognitoDuration, cognitoErrors, wsConnections,
+	)
+}
+
+// RecordSignIn counts a sign-in; an empty reason means success.
+func RecordSignIn(reason string) {
+	if reason == "" {
+		signIns.WithLabelValues("success", "").Inc()
+		return
+	}
+	signIns.WithLabelValues("failure", reason).Inc()
+}
+
+// RecordSignUp counts a successful sign-up.
+func RecordSignUp() { signUps.Inc() }
+
+// RecordConfirmation counts a confirmed sign-up.
+func RecordConfirmation() { confirmations.Inc() }
+
+// RecordRefreshRotation counts a rotated refresh token.
+func RecordRefreshRotation() { refreshRotations.Inc() }
+
+// RecordRefreshReuse counts a detected refresh-token reuse.
+func RecordRefreshReuse() { refreshReuse.Inc() }
+
+// RecordMFAChallenge counts an MFA challenge of the given type (e.g. SOFTWARE_TOKEN_MFA).
+func RecordMFAChallenge(challenge string) { mfaChallenges.WithLabelValues(challenge).Inc() }
+
+// ObserveCognito records latency and, if err is set, the error code of a Cognito call.
+func ObserveCognito(ctx context.Context, operation string, d time.Duration, err error) {
+	observe(ctx, cognitoDuration.WithLabelValues(operation), d.Seconds())
+	if err == nil {
+		return
+	}
+	code := "unknown"
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+	cognitoErrors.WithLabelValues(operation, code).Inc()
+}
+
+// WebSocketOpened and WebSocketClosed track open WebSocket connections.
+func WebSocketOpened() { wsConnections.Inc() }
+func WebSocketClosed() { wsConnections.Dec() }
+
+// observe records v and, when ctx carries a sampled span, links it as a trace-ID exemplar.
+func observe(ctx context.Context, o prometheus.Observer, v float64) {
+	sc := trace.SpanContextFromContext(ctx)
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && sc.IsSampled() {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+	o.Observe(v)
+}
@@ -41,13 +41,17 @@ func MetricsMiddleware() echo.MiddlewareFunc {
 			status := c.Response().Status
 			path := c.Path()
 			httpRequests.WithLabelValues(c.Request().Method, path, fmt.Sprint(status)).Inc()
-			httpDuration.WithLabelValues(c.Request().Method, path).Observe(time.Since(start).Seconds())
+			observe(c.Request().Context(), httpDuration.WithLabelValues(c.Request().Method, path), time.Since(start).Seconds())
 			return err
 		}
 	}
 }
 
-// MetricsHandler exposes /metrics
+// MetricsHandler exposes /metrics, in OpenMetrics format when the scraper asks
+// for it (required for exemplars).
 func MetricsHandler(e *echo.Echo) {
-	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	e.GET("/metrics", echo.WrapHandler(promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)))
 }
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outbound webhook metrics, per endpoint ID
+var (
+	webhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Webhook delivery attempts by endpoint and result",
+		},
+		[]string{"endpoint", "result"},
+	)
+	webhookDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "webhook_delivery_duration_seconds",
+			Help:    "Webhook delivery latency by endpoint",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"endpoint"},
+	)
+	webhookDeadLetters = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_dead_letters_total",
+			Help: "Webhook deliveries moved to the dead-letter queue",
+		},
+		[]string{"endpoint"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(webhookDeliveries, webhookDuration, webhookDeadLetters)
+}
+
+// ObserveWebhook records one delivery attempt to endpoint.
+func ObserveWebhook(ctx context.Context, endpoint string, d time.Duration, err error) {
+	observe(ctx, webhookDuration.WithLabelValues(endpoint), d.Seconds())
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	webhookDeliveries.WithLabelValues(endpoint, result).Inc()
+}
+
+// RecordWebhookDeadLetter counts a delivery that ran out of attempts.
+func RecordWebhookDeadLetter(endpoint string) { webhookDeadLetters.WithLabelValues(endpoint).Inc() }
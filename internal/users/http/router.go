@@ -5,79 +5,108 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
 
+	"simple-go-auth/internal/platform"
+	"simple-go-auth/internal/users/audit"
 	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/aws"
 	"simple-go-auth/internal/users/config"
 	"simple-go-auth/internal/users/db"
 	"simple-go-auth/internal/users/http/health"
 	"simple-go-auth/internal/users/http/metrics"
 	"simple-go-auth/internal/users/http/ws"
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/rebac"
 )
 
 // SetupRouter returns a fully-configured Echo instance.
 func SetupRouter(h *auth.AuthHandler, authMw echo.MiddlewareFunc, logger *zap.Logger, dbConfig *config.Config) *echo.Echo {
-	e := echo.New()
+	// Recovery, server spans (W3C trace context is extracted here),
+	// X-Request-ID and structured request logging
+	e := platform.NewEcho(dbConfig.ServiceName, logger)
 
 	// Initialize the database using InitDB
 	if _, err := db.InitDB(dbConfig); err != nil {
 		logger.Fatal("Failed to initialize database", zap.Error(err))
 	}
 
-	// Serve HTTP/2 on TLS
-	e.Server.TLSConfig.NextProtos = []string{"h2", "http/1.1"}
+	// Render every error as application/problem+json
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(logger)
 
-	// 3. Global middleware, in this order:
+	// 3. Global middleware after the platform defaults, in this order:
 
-	// 3a. Recover from panics
-	e.Use(middleware.Recover())
-
-	// 3b. Attach X-Request-ID
-	e.Use(RequestID())
-
-	// 3c. Structured Zap logging
-	e.Use(ZapLogger(logger))
-
-	// 3d. CORS support (allow all origins by default)
+	// 3a. CORS support (allow all origins by default)
 	e.Use(middleware.CORS())
 
-	// 3e. Limit body size to 2MB
+	// 3b. Limit body size to 2MB
 	e.Use(middleware.BodyLimit("2M"))
 
-	// 3f. Rate-limit to 10 requests/minute per IP
+	// 3c. Rate-limit to 10 requests/minute per IP
 	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(10)))
 
 	// Prometheus metrics middleware
 	e.Use(metrics.MetricsMiddleware())
 
-	// 4. Debug /config endpoint
+	// 4. Debug /config endpoint, for callers allowed to read it
 	e.GET("/config", func(c echo.Context) error {
 		cfg, err := config.LoadConfig()
 		if err != nil {
-			return c.JSON(500, map[string]string{"error": err.Error()})
+			return err
 		}
 		return c.JSON(200, cfg)
-	})
+	}, authMw, auth.RequirePermission(rbac.PermConfigRead))
+
+	// Wire health endpoint
+	secrets, err := aws.NewSecretsManager(dbConfig.SecretsProvider, dbConfig.AWSRegion)
+	if err != nil {
+		logger.Fatal("Failed to initialize secrets", zap.Error(err))
+	}
+	e.GET("/health", health.HealthCheck(dbConfig, secrets))
+
+	// Auth, admin and relationship routes
+	RegisterRoutes(e, h, authMw, dbConfig)
+
+	// Expose /metrics endpoint
+	metrics.MetricsHandler(e)
+
+	return e
+}
 
+// RegisterRoutes mounts the routes served by h on e, guarding protected ones
+// with authMw. SetupRouter calls it after the global middleware.
+func RegisterRoutes(e *echo.Echo, h *auth.AuthHandler, authMw echo.MiddlewareFunc, cfg *config.Config) {
 	e.GET("/ping", h.Ping)
 
-	// Wire health endpoint
-	e.GET("/health", health.HealthCheck(dbConfig, nil))
+	// Public keys of native tokens, for other services to verify them
+	if h != nil && h.Service != nil && h.Service.Tokens != nil {
+		jwks := h.Service.Tokens.JWKS()
+		e.GET("/.well-known/jwks.json", func(c echo.Context) error {
+			return c.JSON(200, jwks)
+		})
+	}
 
 	// granular rate‐limiter
 	rateLimiterStore := middleware.NewRateLimiterMemoryStore(10)
 	e.POST("/signup", h.SignUp, middleware.RateLimiter(rateLimiterStore))
 	e.POST("/signin", h.SignIn, middleware.RateLimiter(rateLimiterStore))
+	e.POST("/confirm", h.ConfirmSignUp, middleware.RateLimiter(rateLimiterStore))
 	e.POST("/refresh", h.Refresh, middleware.RateLimiter(rateLimiterStore))
-	e.POST("/logout", h.SignOut, authMw)
+	e.POST("/signin/challenge", h.RespondToChallenge, middleware.RateLimiter(rateLimiterStore))
+	e.POST("/signin/otp", h.RequestLoginCode, middleware.RateLimiter(rateLimiterStore))
+	e.POST("/signin/otp/verify", h.VerifyLoginCode, middleware.RateLimiter(rateLimiterStore))
+	e.POST("/signin/magic", h.RequestMagicLink, middleware.RateLimiter(rateLimiterStore))
+	e.GET("/signin/magic/verify", h.VerifyMagicLink, middleware.RateLimiter(rateLimiterStore))
+	e.POST("/signin/magic/verify", h.VerifyMagicLink, middleware.RateLimiter(rateLimiterStore))
+	e.POST("/logout", h.SignOut, authMw, auth.RequireSession())
+	e.GET("/devices", h.ListDevices, authMw)
+	e.DELETE("/devices/:id", h.ForgetDevice, authMw, auth.RequireSession())
+	h.RegisterAPIKeyRoutes(e, authMw)
 
 	// Mount routes conditionally based on configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		logger.Fatal("Failed to load configuration", zap.Error(err))
-	}
-
 	if cfg.MFAEnabled {
-		e.POST("/mfa/setup", h.SetupMFA)
-		e.POST("/mfa/verify", h.VerifyMFA)
+		e.POST("/mfa/setup", h.SetupMFA, authMw, auth.RequireSession())
+		e.POST("/mfa/verify", h.VerifyMFA, authMw, auth.RequireSession())
+		e.POST("/mfa/recovery-codes", h.RegenerateRecoveryCodes, authMw, auth.RequireSession())
 	}
 
 	for _, p := range cfg.SocialProviders {
@@ -85,10 +114,32 @@ func SetupRouter(h *auth.AuthHandler, authMw echo.MiddlewareFunc, logger *zap.Lo
 	}
 
 	// Register WebSocket endpoint
-	ws.RegisterWebsocket(e)
+	ws.RegisterWebsocket(e, cfg.WebSocketOrigins)
 
-	// Expose /metrics endpoint
-	metrics.MetricsHandler(e)
+	// QR-code cross-device login: desktop waits on the socket, mobile approves
+	if h != nil && h.Service != nil {
+		ws.RegisterQRLogin(e, h.Service, cfg.QRLoginTTL, cfg.QRLoginURL, cfg.WebSocketOrigins)
+		e.GET("/qr-login/:id", h.GetQRLogin, authMw)
+		e.POST("/qr-login/:id/approve", h.ApproveQRLogin, authMw, auth.RequireSession())
+	}
+
+	// Admin API: users, webhooks, and the hash-chained audit log when it is stored in the database
+	if h != nil && h.Service != nil {
+		admin := e.Group("/admin", authMw)
+		h.RegisterAdminRoutes(admin)
+		if store, ok := audit.FindStore(h.Service.Audit); ok {
+			ah := &audit.Handler{Store: store, Actor: func(c echo.Context) string {
+				return auth.PrincipalFromContext(c).Username
+			}}
+			ah.RegisterRoutes(admin.Group("", auth.RequirePermission(rbac.PermAuditRead)))
+		}
+	}
+
+	// Relationship checks for product services, authenticated like every caller
+	if h != nil && h.Service != nil && h.Service.Relations != nil {
+		rh := &rebac.Handler{Store: h.Service.Relations}
+		rh.RegisterRoutes(e.Group("/authz", authMw),
+			auth.RequirePermission(rbac.PermRelationsRead), auth.RequirePermission(rbac.PermRelationsWrite))
+	}
 
-	return e
 }
@@ -0,0 +1,111 @@
+package ws
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	qrcode "github.com/skip2/go-qrcode"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/http/metrics"
+)
+
+// qrPollInterval is how often a waiting socket checks its challenge.
+// Polling the DB (rather than in-process signalling) lets the approval
+// land on any replica.
+const qrPollInterval = time.Second
+
+// qrMessage is the JSON frame sent to the waiting client.
+type qrMessage struct {
+	Type        string              `json:"type"` // challenge, authenticated, challenge_required, expired, error
+	ChallengeID string              `json:"challenge_id,omitempty"`
+	QRCode      string              `json:"qr_code,omitempty"` // PNG data URL
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	Tokens      *auth.AuthTokens    `json:"tokens,omitempty"`
+	Challenge   *auth.AuthChallenge `json:"challenge,omitempty"` // step-up code to answer at /signin/challenge
+	Error       string              `json:"error,omitempty"`
+}
+
+// RegisterQRLogin sets up the "scan to log in" WebSocket endpoint.
+// baseURL is encoded in the QR code with the challenge ID as ?c=...
+// Browsers may connect from the origins CheckOrigin allows.
+func RegisterQRLogin(e *echo.Echo, svc *auth.AuthServiceImpl, ttl time.Duration, baseURL string, origins []string) {
+	upgrader := websocket.Upgrader{CheckOrigin: CheckOrigin(origins)}
+
+	e.GET("/ws/qr-login", func(c echo.Context) error {
+		conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		metrics.WebSocketOpened()
+		defer metrics.WebSocketClosed()
+
+		ctx, cancel := context.WithTimeout(auth.RequestContext(c), ttl)
+		defer cancel()
+
+		// 1) Create the challenge and send it as a QR code
+		ch, err := svc.CreateLoginChallenge(ctx, ttl, c.RealIP(), c.Request().UserAgent())
+		if err != nil {
+			conn.WriteJSON(qrMessage{Type: "error", Error: "could not create login challenge"})
+			return nil
+		}
+		png, err := qrcode.Encode(qrContent(baseURL, ch.ID), qrcode.Medium, 256)
+		if err != nil {
+			conn.WriteJSON(qrMessage{Type: "error", Error: "could not render QR code"})
+			return nil
+		}
+		conn.WriteJSON(qrMessage{
+			Type:        "challenge",
+			ChallengeID: ch.ID,
+			QRCode:      "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+			ExpiresAt:   &ch.ExpiresAt,
+		})
+
+		// 2) Stop waiting as soon as the client goes away
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		// 3) Wait for approval, then hand over tokens
+		ticker := time.NewTicker(qrPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.WriteJSON(qrMessage{Type: "expired"})
+				return nil
+			case <-ticker.C:
+				tokens, err := svc.CompleteLoginChallenge(ctx, ch.ID)
+				var stepUp *auth.AuthChallenge
+				switch {
+				case errors.Is(err, auth.ErrChallengeNotApproved):
+					continue
+				case errors.As(err, &stepUp):
+					conn.WriteJSON(qrMessage{Type: "challenge_required", Challenge: stepUp})
+					return nil
+				case err != nil:
+					conn.WriteJSON(qrMessage{Type: "error", Error: "login failed"})
+					return nil
+				}
+				conn.WriteJSON(qrMessage{Type: "authenticated", Tokens: tokens})
+				return nil
+			}
+		}
+	})
+}
+
+// qrContent is what the mobile app reads from the QR code.
+func qrContent(baseURL, challengeID string) string {
+	return baseURL + "?c=" + url.QueryEscape(challengeID)
+}
@@ -2,16 +2,19 @@ package ws
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/http/metrics"
 )
 
-// RegisterWebsocket sets up the WebSocket endpoint.
-func RegisterWebsocket(e *echo.Echo) {
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
-	}
+// RegisterWebsocket sets up the WebSocket endpoint. Browsers may only
+// connect from the service's own origin or one of origins.
+func RegisterWebsocket(e *echo.Echo, origins []string) {
+	upgrader := websocket.Upgrader{CheckOrigin: CheckOrigin(origins)}
 
 	e.GET("/ws", func(c echo.Context) error {
 		conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
@@ -19,6 +22,8 @@ func RegisterWebsocket(e *echo.Echo) {
 			return err
 		}
 		defer conn.Close()
+		metrics.WebSocketOpened()
+		defer metrics.WebSocketClosed()
 
 		conn.WriteMessage(websocket.TextMessage, []byte("connected"))
 		for {
@@ -31,3 +36,29 @@ func RegisterWebsocket(e *echo.Echo) {
 		return nil
 	})
 }
+
+// CheckOrigin allows WebSocket upgrades without an Origin header (native
+// apps), from the request's own host, and from the given origins, e.g.
+// "https://app.example.com". Any other browser page is refused, so it can't
+// ride on the user's cookies.
+func CheckOrigin(origins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		for _, o := range origins {
+			if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"simple-go-auth/internal/users/config"
+)
+
+const (
+	queueSize    = 1000
+	retryBackoff = 2 * time.Second
+)
+
+// NewFromConfig builds the configured email and SMS notifiers behind a
+// retrying async Queue. Channels without a provider only log.
+func NewFromConfig(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*Queue, error) {
+	fallback := &LogNotifier{Logger: logger}
+	router := &Router{Email: fallback, SMS: fallback}
+
+	switch cfg.NotifyEmailProvider {
+	case "smtp":
+		router.Email = NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "ses":
+		ses, err := NewSESNotifier(ctx, cfg.AWSRegion, cfg.SESEndpoint, cfg.MailFrom)
+		if err != nil {
+			return nil, err
+		}
+		router.Email = ses
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown NOTIFY_EMAIL_PROVIDER %q", cfg.NotifyEmailProvider)
+	}
+
+	if cfg.SMSGatewayURL != "" {
+		router.SMS = NewSMSGatewayNotifier(cfg.SMSGatewayURL, cfg.SMSGatewayAPIKey, cfg.SMSSender)
+	}
+
+	return NewQueue(router, cfg.NotifyWorkers, queueSize, cfg.NotifyMaxAttempts, retryBackoff, logger), nil
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Delivery channels.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Message is a rendered notification ready for delivery.
+type Message struct {
+	Channel string // ChannelEmail or ChannelSMS
+	To      string // email address or E.164 phone number
+	Subject string // email only
+	Text    string // plain-text body; the SMS body
+	HTML    string // email only, optional
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Router dispatches messages to the notifier for their channel.
+type Router struct {
+	Email Notifier
+	SMS   Notifier
+}
+
+// Send implements Notifier.
+func (r *Router) Send(ctx context.Context, msg Message) error {
+	var n Notifier
+	switch msg.Channel {
+	case ChannelEmail:
+		n = r.Email
+	case ChannelSMS:
+		n = r.SMS
+	}
+	if n == nil {
+		return fmt.Errorf("no notifier configured for channel %q", msg.Channel)
+	}
+	return n.Send(ctx, msg)
+}
+
+// LogNotifier only logs messages (without their bodies); the default when
+// no provider is configured, e.g. in local development.
+type LogNotifier struct {
+	Logger *zap.Logger
+}
+
+// Send implements Notifier.
+func (l *LogNotifier) Send(_ context.Context, msg Message) error {
+	l.Logger.Info("notification not delivered: no provider configured",
+		zap.String("channel", msg.Channel),
+		zap.String("subject", msg.Subject),
+	)
+	return nil
+}
+
+// Compile-time checks.
+var (
+	_ Notifier = (*Router)(nil)
+	_ Notifier = (*LogNotifier)(nil)
+)
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrQueueFull is returned by Queue.Send when the buffer is full.
+var ErrQueueFull = errors.New("notification queue full")
+
+// ErrQueueClosed is returned by Queue.Send after Close.
+var ErrQueueClosed = errors.New("notification queue closed")
+
+// sendTimeout bounds a single delivery attempt.
+const sendTimeout = 30 * time.Second
+
+// Queue delivers messages asynchronously through another Notifier, retrying
+// failures with exponential backoff. It implements Notifier: Send only enqueues.
+type Queue struct {
+	next        Notifier
+	jobs        chan Message
+	maxAttempts int
+	backoff     time.Duration // delay before the first retry, doubled each time
+	logger      *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewQueue starts workers goroutines delivering through next.
+func NewQueue(next Notifier, workers, size, maxAttempts int, backoff time.Duration, logger *zap.Logger) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	q := &Queue{
+		next:        next,
+		jobs:        make(chan Message, size),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		logger:      logger,
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Send enqueues msg for delivery. It never blocks.
+func (q *Queue) Send(_ context.Context, msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for queued ones (and their retries).
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for msg := range q.jobs {
+		q.deliver(msg)
+	}
+}
+
+// deliver tries msg up to maxAttempts times. Recipients are never logged.
+func (q *Queue) deliver(msg Message) {
+	delay := q.backoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := q.next.Send(ctx, msg)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= q.maxAttempts {
+			q.logger.Error("notification delivery failed",
+				zap.String("channel", msg.Channel),
+				zap.String("subject", msg.Subject),
+				zap.Int("attempts", attempt),
+				zap.Error(err),
+			)
+			return
+		}
+		q.logger.Warn("notification delivery failed, retrying",
+			zap.String("channel", msg.Channel),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", delay),
+			zap.Error(err),
+		)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Compile-time check that Queue implements Notifier.
+var _ Notifier = (*Queue)(nil)
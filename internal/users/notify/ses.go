@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sdkconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// SESNotifier sends email through the SES v2 API. Endpoint may point at an
+// SES-compatible service (e.g. LocalStack); empty uses AWS.
+type SESNotifier struct {
+	client *sesv2.Client
+	from   string
+}
+
+// NewSESNotifier creates an SESNotifier for the given region.
+func NewSESNotifier(ctx context.Context, region, endpoint, from string) (*SESNotifier, error) {
+	cfg, err := sdkconfig.LoadDefaultConfig(ctx, sdkconfig.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	client := sesv2.NewFromConfig(cfg, func(o *sesv2.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &SESNotifier{client: client, from: from}, nil
+}
+
+// Send implements Notifier.
+func (s *SESNotifier) Send(ctx context.Context, msg Message) error {
+	body := &types.Body{
+		Text: &types.Content{Data: aws.String(msg.Text), Charset: aws.String("UTF-8")},
+	}
+	if msg.HTML != "" {
+		body.Html = &types.Content{Data: aws.String(msg.HTML), Charset: aws.String("UTF-8")}
+	}
+	_, err := s.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(s.from),
+		Destination:      &types.Destination{ToAddresses: []string{msg.To}},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(msg.Subject), Charset: aws.String("UTF-8")},
+				Body:    body,
+			},
+		},
+	})
+	return err
+}
+
+// Compile-time check that SESNotifier implements Notifier.
+var _ Notifier = (*SESNotifier)(nil)
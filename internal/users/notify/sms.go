@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SMSGatewayNotifier sends SMS through a generic HTTP gateway that accepts
+// POST {"from","to","body"} with a bearer API key and answers 2xx on success.
+type SMSGatewayNotifier struct {
+	URL    string
+	APIKey string
+	Sender string
+	Client *http.Client
+}
+
+// NewSMSGatewayNotifier creates an SMSGatewayNotifier with a 10s HTTP timeout.
+func NewSMSGatewayNotifier(url, apiKey, sender string) *SMSGatewayNotifier {
+	return &SMSGatewayNotifier{
+		URL:    url,
+		APIKey: apiKey,
+		Sender: sender,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send implements Notifier.
+func (s *SMSGatewayNotifier) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"from": s.Sender,
+		"to":   msg.To,
+		"body": msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sms gateway returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Compile-time check that SMSGatewayNotifier implements Notifier.
+var _ Notifier = (*SMSGatewayNotifier)(nil)
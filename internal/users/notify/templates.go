@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// DefaultLocale is used when no template exists for the requested locale.
+const DefaultLocale = "en"
+
+// Templates renders localized notifications from templates/<locale>/:
+//
+//	<name>.subject.tmpl  email subject (text/template)
+//	<name>.txt.tmpl      email plain-text body (text/template)
+//	<name>.html.tmpl     email HTML body (html/template, optional)
+//	<name>.sms.tmpl      SMS body (text/template)
+type Templates struct {
+	text map[string]*texttemplate.Template // keyed by "<locale>/<file>"
+	html map[string]*htmltemplate.Template
+}
+
+// NewTemplates parses the built-in templates.
+func NewTemplates() (*Templates, error) {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplates(sub)
+}
+
+// ParseTemplates parses every template under root (laid out as <locale>/<file>).
+func ParseTemplates(root fs.FS) (*Templates, error) {
+	t := &Templates{
+		text: map[string]*texttemplate.Template{},
+		html: map[string]*htmltemplate.Template{},
+	}
+	err := fs.WalkDir(root, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".tmpl") {
+			return err
+		}
+		src, err := fs.ReadFile(root, p)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(p, ".html.tmpl") {
+			tpl, err := htmltemplate.New(p).Parse(string(src))
+			if err != nil {
+				return fmt.Errorf("parse %s: %w", p, err)
+			}
+			t.html[p] = tpl
+			return nil
+		}
+		tpl, err := texttemplate.New(p).Parse(string(src))
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", p, err)
+		}
+		t.text[p] = tpl
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Email renders the email template name for locale.
+func (t *Templates) Email(name, locale, to string, data any) (Message, error) {
+	loc, ok := t.resolve(locale, name+".subject.tmpl")
+	if !ok {
+		return Message{}, fmt.Errorf("no email template %q", name)
+	}
+	subject, err := t.execText(loc, name+".subject.tmpl", data)
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := t.execText(loc, name+".txt.tmpl", data)
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{
+		Channel: ChannelEmail,
+		To:      to,
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+	}
+	if tpl, ok := t.html[path.Join(loc, name+".html.tmpl")]; ok {
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+// SMS renders the SMS template name for locale.
+func (t *Templates) SMS(name, locale, to string, data any) (Message, error) {
+	loc, ok := t.resolve(locale, name+".sms.tmpl")
+	if !ok {
+		return Message{}, fmt.Errorf("no sms template %q", name)
+	}
+	text, err := t.execText(loc, name+".sms.tmpl", data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Channel: ChannelSMS, To: to, Text: strings.TrimSpace(text)}, nil
+}
+
+// resolve picks the most specific locale that has file: "pt-BR", then "pt",
+// then DefaultLocale.
+func (t *Templates) resolve(locale, file string) (string, bool) {
+	locale = strings.ReplaceAll(strings.ToLower(locale), "_", "-")
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, DefaultLocale)
+	for _, loc := range candidates {
+		if loc == "" {
+			continue
+		}
+		if _, ok := t.text[path.Join(loc, file)]; ok {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+func (t *Templates) execText(locale, file string, data any) (string, error) {
+	tpl, ok := t.text[path.Join(locale, file)]
+	if !ok {
+		return "", fmt.Errorf("missing template %s/%s", locale, file)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
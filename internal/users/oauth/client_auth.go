@@ -0,0 +1,161 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm/clause"
+
+	"simple-go-auth/internal/platform"
+)
+
+// AssertionType is the client_assertion_type of private_key_jwt (RFC 7523).
+const AssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// maxAssertionLifetime bounds how far in the future a client assertion may
+// expire, and so how long its jti is remembered.
+const maxAssertionLifetime = 10 * time.Minute
+
+// AuthenticateClient authenticates the client of a token endpoint request
+// by HTTP Basic, client_secret in the form, or a private_key_jwt
+// assertion; public clients just send client_id. The form must already be
+// parsed.
+func (s *Server) AuthenticateClient(ctx context.Context, r *http.Request) (*Client, error) {
+	// 1) private_key_jwt
+	if r.PostForm.Get("client_assertion_type") != "" {
+		if r.PostForm.Get("client_assertion_type") != AssertionType {
+			return nil, ErrInvalidClient.WithDescription("unsupported client_assertion_type")
+		}
+		return s.verifyAssertion(ctx, r.PostForm.Get("client_assertion"))
+	}
+
+	// 2) client_secret_basic, with form-encoded credentials (RFC 6749 2.3.1)
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		var err error
+		if id, err = url.QueryUnescape(id); err != nil {
+			return nil, ErrInvalidClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return nil, ErrInvalidClient
+		}
+	} else {
+		// 3) client_secret_post
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id == "" {
+		return nil, ErrInvalidClient.WithDescription("client authentication required")
+	}
+	c, err := s.activeClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4) Public clients only identify themselves
+	if c.Public() {
+		if secret != "" {
+			return nil, ErrInvalidClient.WithDescription("public clients have no secret")
+		}
+		return c, nil
+	}
+	if c.AuthMethod == AuthPrivateKeyJWT || !c.verifySecret(secret) {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// activeClient returns client id unless it is unknown or disabled.
+func (s *Server) activeClient(ctx context.Context, id string) (*Client, error) {
+	c, err := s.Client(ctx, id)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.DisabledAt != nil {
+		return nil, ErrInvalidClient.WithDescription("client disabled")
+	}
+	return c, nil
+}
+
+// verifyAssertion checks a client assertion signed with one of the client's
+// registered keys: iss and sub are the client ID, aud names this server,
+// and its jti is used once.
+func (s *Server) verifyAssertion(ctx context.Context, assertion string) (*Client, error) {
+	var client *Client
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(assertion, claims, func(t *jwt.Token) (any, error) {
+		iss, _ := claims.GetIssuer()
+		sub, _ := claims.GetSubject()
+		if iss == "" || iss != sub {
+			return nil, errors.New("iss and sub must be the client ID")
+		}
+		c, err := s.activeClient(ctx, iss)
+		if err != nil {
+			return nil, err
+		}
+		if c.AuthMethod != AuthPrivateKeyJWT {
+			return nil, errors.New("client does not use private_key_jwt")
+		}
+		keys, err := platform.ParseJWKS([]byte(c.JWKS))
+		if err != nil {
+			return nil, err
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keys[kid]
+		if !ok && kid == "" && len(keys) == 1 {
+			for _, k := range keys {
+				key, ok = k, true
+			}
+		}
+		if !ok {
+			return nil, errors.New("unknown key")
+		}
+		client = c
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			return nil, err
+		}
+		return nil, ErrInvalidClient.WithDescription("invalid client assertion")
+	}
+
+	aud, _ := claims.GetAudience()
+	if !slices.Contains(aud, s.TokenURL()) && !slices.Contains(aud, s.Issuer()) {
+		return nil, ErrInvalidClient.WithDescription("client assertion audience must be the token endpoint")
+	}
+	exp, _ := claims.GetExpirationTime()
+	if time.Until(exp.Time) > maxAssertionLifetime {
+		return nil, ErrInvalidClient.WithDescription("client assertion expires too late")
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, ErrInvalidClient.WithDescription("client assertion needs a jti")
+	}
+
+	// Remember the jti until the assertion expires; a second use is a replay
+	db := s.DB.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&UsedAssertion{}).Error; err != nil {
+		return nil, err
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UsedAssertion{ID: client.ID + ":" + jti, ExpiresAt: exp.Time})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidClient.WithDescription("client assertion already used")
+	}
+	return client, nil
+}
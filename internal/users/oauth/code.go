@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/token"
+)
+
+// PKCEMethod is the only code_challenge_method accepted; "plain" offers no
+// protection once the request leaks.
+const PKCEMethod = "S256"
+
+// IssueCode stores ac under a new authorization code, valid for ttl, and
+// returns the code.
+func (s *Server) IssueCode(ctx context.Context, ac *AuthorizationCode, ttl time.Duration) (string, error) {
+	code := token.RandomString(32)
+	ac.Hash = token.Hash(code)
+	ac.ExpiresAt = time.Now().Add(ttl)
+	db := s.DB.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&AuthorizationCode{}).Error; err != nil {
+		return "", err
+	}
+	if err := db.Create(ac).Error; err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemCode returns the authorization behind code, at most once. It must
+// have been issued to client for redirectURI, and verifier must match its
+// PKCE challenge (RFC 7636 4.6).
+func (s *Server) RedeemCode(ctx context.Context, code string, client *Client, redirectURI, verifier string) (*AuthorizationCode, error) {
+	// 1) Find the live code
+	var ac AuthorizationCode
+	err := s.DB.WithContext(ctx).Where("hash = ?", token.Hash(code)).First(&ac).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidGrant.WithDescription("unknown authorization code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ac.UsedAt != nil || time.Now().After(ac.ExpiresAt) {
+		return nil, ErrInvalidGrant.WithDescription("authorization code expired or already used")
+	}
+
+	// 2) Bound to the client, redirect URI and PKCE verifier
+	if ac.ClientID != client.ID || ac.RedirectURI != redirectURI {
+		return nil, ErrInvalidGrant.WithDescription("authorization code was issued for another client or redirect_uri")
+	}
+	if !VerifyPKCE(ac.CodeChallenge, verifier) {
+		return nil, ErrInvalidGrant.WithDescription("code_verifier does not match the code_challenge")
+	}
+
+	// 3) Use it up; a concurrent redemption loses
+	now := time.Now()
+	res := s.DB.WithContext(ctx).Model(&AuthorizationCode{}).
+		Where("hash = ? AND used_at IS NULL", ac.Hash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidGrant.WithDescription("authorization code expired or already used")
+	}
+	ac.UsedAt = &now
+	return &ac, nil
+}
+
+// VerifyPKCE reports whether verifier hashes to the S256 challenge.
+func VerifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConsentView is a consent as its user sees it.
+type ConsentView struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Consented reports whether subject has granted client every one of scopes.
+func (s *Server) Consented(ctx context.Context, subject, clientID string, scopes []string) (bool, error) {
+	c, err := s.consent(ctx, subject, clientID)
+	if err != nil || c == nil {
+		return false, err
+	}
+	granted := strings.Fields(c.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// GrantConsent adds scopes to those subject has granted client.
+func (s *Server) GrantConsent(ctx context.Context, subject, clientID string, scopes []string) error {
+	c, err := s.consent(ctx, subject, clientID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		c = &Consent{Subject: subject, ClientID: clientID}
+	}
+	granted := strings.Fields(c.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	c.Scopes = strings.Join(granted, " ")
+	return s.DB.WithContext(ctx).Save(c).Error
+}
+
+// Consents lists the clients subject has granted access.
+func (s *Server) Consents(ctx context.Context, subject string) ([]ConsentView, error) {
+	var consents []Consent
+	if err := s.DB.WithContext(ctx).Where("subject = ?", subject).Order("created_at").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ConsentView, 0, len(consents))
+	for _, c := range consents {
+		v := ConsentView{ClientID: c.ClientID, Scopes: strings.Fields(c.Scopes), GrantedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt}
+		if client, err := s.Client(ctx, c.ClientID); err == nil {
+			v.ClientName = client.Name
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// RevokeConsent withdraws subject's consent for client; the client must
+// ask again next time.
+func (s *Server) RevokeConsent(ctx context.Context, subject, clientID string) error {
+	res := s.DB.WithContext(ctx).Where("subject = ? AND client_id = ?", subject, clientID).Delete(&Consent{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrConsentNotFound
+	}
+	return nil
+}
+
+func (s *Server) consent(ctx context.Context, subject, clientID string) (*Consent, error) {
+	var c Consent
+	err := s.DB.WithContext(ctx).Where("subject = ? AND client_id = ?", subject, clientID).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
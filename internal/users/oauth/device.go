@@ -0,0 +1,181 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/token"
+)
+
+// userCodeAlphabet has no vowels, so codes don't spell words, and no
+// letters easily mistaken for each other (RFC 8628 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// userCodeLength gives 20^8 ≈ 2^34 codes, enough while they live minutes.
+const userCodeLength = 8
+
+// slowDownStep is added to a device's polling interval each time it polls
+// too fast (RFC 8628 3.5).
+const slowDownStep = 5
+
+// IssueDeviceCode stores dc under a new device code and user code, valid
+// for ttl. The user code is returned formatted as XXXX-XXXX.
+func (s *Server) IssueDeviceCode(ctx context.Context, dc *DeviceCode, ttl time.Duration) (deviceCode, userCode string, err error) {
+	deviceCode = token.RandomString(32)
+	if userCode, err = newUserCode(); err != nil {
+		return "", "", err
+	}
+	dc.Hash = token.Hash(deviceCode)
+	dc.UserCodeHash = token.Hash(NormalizeUserCode(userCode))
+	dc.ExpiresAt = time.Now().Add(ttl)
+	db := s.DB.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&DeviceCode{}).Error; err != nil {
+		return "", "", err
+	}
+	if err := db.Create(dc).Error; err != nil {
+		return "", "", err
+	}
+	return deviceCode, userCode, nil
+}
+
+// PendingDeviceCode returns the live, undecided authorization of userCode,
+// as typed by the user.
+func (s *Server) PendingDeviceCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	var dc DeviceCode
+	err := s.pendingDeviceCodes(ctx, userCode).First(&dc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dc, nil
+}
+
+// ApproveDeviceCode lets the device of userCode have the tokens of the
+// user signed in as approval's Subject, Username, AuthTime, ACR and AMR.
+func (s *Server) ApproveDeviceCode(ctx context.Context, userCode string, approval *DeviceCode) error {
+	return s.decideDeviceCode(ctx, userCode, map[string]any{
+		"subject":     approval.Subject,
+		"username":    approval.Username,
+		"auth_time":   approval.AuthTime,
+		"acr":         approval.ACR,
+		"amr":         approval.AMR,
+		"approved_at": time.Now(),
+	})
+}
+
+// DenyDeviceCode refuses the device of userCode; its next poll gets
+// access_denied.
+func (s *Server) DenyDeviceCode(ctx context.Context, userCode string) error {
+	return s.decideDeviceCode(ctx, userCode, map[string]any{"denied_at": time.Now()})
+}
+
+// decideDeviceCode records a decision once; a code decided concurrently or
+// expired in the meantime is not found.
+func (s *Server) decideDeviceCode(ctx context.Context, userCode string, updates map[string]any) error {
+	res := s.pendingDeviceCodes(ctx, userCode).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserCodeNotFound
+	}
+	return nil
+}
+
+func (s *Server) pendingDeviceCodes(ctx context.Context, userCode string) *gorm.DB {
+	return s.DB.WithContext(ctx).Model(&DeviceCode{}).
+		Where("user_code_hash = ? AND expires_at > ?", token.Hash(NormalizeUserCode(userCode)), time.Now()).
+		Where("approved_at IS NULL AND denied_at IS NULL")
+}
+
+// PollDeviceCode answers a device polling with deviceCode: the approved
+// authorization, at most once, or the RFC 8628 error telling it to keep
+// polling, slow down or give up.
+func (s *Server) PollDeviceCode(ctx context.Context, deviceCode string, client *Client) (*DeviceCode, error) {
+	// 1) Find the device's code
+	var dc DeviceCode
+	err := s.DB.WithContext(ctx).Where("hash = ?", token.Hash(deviceCode)).First(&dc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && dc.ClientID != client.ID {
+		return nil, ErrInvalidGrant.WithDescription("unknown device code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case dc.UsedAt != nil:
+		return nil, ErrInvalidGrant.WithDescription("device code already used")
+	case now.After(dc.ExpiresAt):
+		return nil, ErrExpiredToken
+	}
+
+	// 2) Polling faster than the interval slows the device down for good
+	updates := map[string]any{"last_polled_at": now}
+	tooFast := dc.LastPolledAt != nil && now.Sub(*dc.LastPolledAt) < time.Duration(dc.Interval)*time.Second
+	if tooFast {
+		updates["interval"] = dc.Interval + slowDownStep
+	}
+	if err := s.DB.WithContext(ctx).Model(&dc).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	switch {
+	case tooFast:
+		return nil, ErrSlowDown
+	case dc.DeniedAt != nil:
+		return nil, ErrDeviceAccessDenied.WithDescription("the user denied access")
+	case dc.ApprovedAt == nil:
+		return nil, ErrAuthorizationPending
+	}
+
+	// 3) Use it up; a concurrent poll loses
+	res := s.DB.WithContext(ctx).Model(&DeviceCode{}).
+		Where("hash = ? AND used_at IS NULL", dc.Hash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidGrant.WithDescription("device code already used")
+	}
+	dc.UsedAt = &now
+	return &dc, nil
+}
+
+// NormalizeUserCode drops the separators and case users type a user code
+// with.
+func NormalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, code)
+}
+
+// newUserCode returns a random user code formatted as XXXX-XXXX.
+func newUserCode() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
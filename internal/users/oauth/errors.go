@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"net/http"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// Error is an OAuth error response (RFC 6749 section 5.2). The token and
+// related endpoints render it as {"error", "error_description"} rather
+// than problem+json, as OAuth clients expect.
+type Error struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// Is matches errors with the same code, so WithDescription copies match.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDescription returns a copy with error_description set.
+func (e *Error) WithDescription(d string) *Error {
+	cp := *e
+	cp.Description = d
+	return &cp
+}
+
+// OAuth errors of the token, introspection and revocation endpoints.
+var (
+	ErrInvalidRequest       = &Error{Status: http.StatusBadRequest, Code: "invalid_request"}
+	ErrInvalidClient        = &Error{Status: http.StatusUnauthorized, Code: "invalid_client"}
+	ErrInvalidGrant         = &Error{Status: http.StatusBadRequest, Code: "invalid_grant"}
+	ErrUnauthorizedClient   = &Error{Status: http.StatusBadRequest, Code: "unauthorized_client"}
+	ErrUnsupportedGrantType = &Error{Status: http.StatusBadRequest, Code: "unsupported_grant_type"}
+	ErrInvalidScope         = &Error{Status: http.StatusBadRequest, Code: "invalid_scope"}
+	ErrUnsupportedTokenType = &Error{Status: http.StatusBadRequest, Code: "unsupported_token_type"}
+)
+
+// Errors of the authorization endpoint, returned to the client's redirect
+// URI (RFC 6749 4.1.2.1, OpenID Connect Core 3.1.2.6).
+var (
+	ErrAccessDenied            = &Error{Status: http.StatusForbidden, Code: "access_denied"}
+	ErrUnsupportedResponseType = &Error{Status: http.StatusBadRequest, Code: "unsupported_response_type"}
+	ErrLoginRequired           = &Error{Status: http.StatusUnauthorized, Code: "login_required"}
+	ErrConsentRequired         = &Error{Status: http.StatusForbidden, Code: "consent_required"}
+)
+
+// Errors of device code token requests (RFC 8628 3.5). The device keeps
+// polling on the first two.
+var (
+	ErrAuthorizationPending = &Error{Status: http.StatusBadRequest, Code: "authorization_pending"}
+	ErrSlowDown             = &Error{Status: http.StatusBadRequest, Code: "slow_down"}
+	ErrExpiredToken         = &Error{Status: http.StatusBadRequest, Code: "expired_token"}
+	ErrDeviceAccessDenied   = &Error{Status: http.StatusBadRequest, Code: "access_denied"}
+)
+
+// Errors of dynamic client registration (RFC 7591 3.2.2, RFC 6750 3.1).
+var (
+	ErrInvalidToken          = &Error{Status: http.StatusUnauthorized, Code: "invalid_token"}
+	ErrInvalidClientMetadata = &Error{Status: http.StatusBadRequest, Code: "invalid_client_metadata"}
+	ErrInvalidRedirectURI    = &Error{Status: http.StatusBadRequest, Code: "invalid_redirect_uri"}
+)
+
+// Client registry and consent errors of the JSON APIs; they render as
+// problem+json.
+var (
+	ErrClientNotFound      = problem.New(http.StatusNotFound, "oauth_client_not_found", "OAuth client not found")
+	ErrInvalidClientConfig = problem.New(http.StatusBadRequest, "invalid_oauth_client", "Invalid OAuth client configuration")
+	ErrUnsafeRedirectURI   = problem.New(http.StatusBadRequest, "unsafe_redirect_uri", "Redirect URI not allowed")
+	ErrConsentNotFound     = problem.New(http.StatusNotFound, "consent_not_found", "Consent not found")
+	ErrUserCodeNotFound    = problem.New(http.StatusNotFound, "user_code_not_found", "Code not found or expired")
+)
@@ -0,0 +1,109 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// RegisterRoutes mounts the public OAuth endpoints on e.
+func (s *Server) RegisterRoutes(e *echo.Echo) {
+	e.POST("/oauth/token", s.Token)
+	e.POST("/oauth/introspect", s.Introspect)
+	e.POST("/oauth/revoke", s.Revoke)
+	e.POST("/oauth/register", s.Register)
+	e.GET("/oauth/register/:client_id", s.ReadRegistration)
+	e.PUT("/oauth/register/:client_id", s.UpdateRegistration)
+	e.DELETE("/oauth/register/:client_id", s.DeleteRegistration)
+}
+
+// RegisterAdminRoutes mounts the client registry on g, which should
+// already authenticate the caller; read and write guard the routes. actor
+// names the caller for the audit trail.
+func (s *Server) RegisterAdminRoutes(g *echo.Group, read, write echo.MiddlewareFunc, actor func(echo.Context) string) {
+	h := &adminHandler{s: s, actor: actor}
+	g.POST("/clients", h.create, write)
+	g.GET("/clients", h.list, read)
+	g.GET("/clients/:id", h.get, read)
+	g.PUT("/clients/:id", h.update, write)
+	g.DELETE("/clients/:id", h.delete, write)
+	g.POST("/clients/:id/rotate-secret", h.rotateSecret, write)
+	g.POST("/clients/:id/disable", h.disable, write)
+	g.POST("/clients/:id/enable", h.enable, write)
+}
+
+type adminHandler struct {
+	s     *Server
+	actor func(echo.Context) string
+}
+
+func (h *adminHandler) create(c echo.Context) error {
+	var spec ClientSpec
+	if err := c.Bind(&spec); err != nil {
+		return problem.ErrInvalidBody
+	}
+	view, err := h.s.CreateClient(c.Request().Context(), h.actor(c), spec)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, view)
+}
+
+func (h *adminHandler) list(c echo.Context) error {
+	clients, err := h.s.Clients(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"clients": clients})
+}
+
+func (h *adminHandler) get(c echo.Context) error {
+	client, err := h.s.Client(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, client.View())
+}
+
+func (h *adminHandler) update(c echo.Context) error {
+	var spec ClientSpec
+	if err := c.Bind(&spec); err != nil {
+		return problem.ErrInvalidBody
+	}
+	view, err := h.s.UpdateClient(c.Request().Context(), h.actor(c), c.Param("id"), spec)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+func (h *adminHandler) delete(c echo.Context) error {
+	if err := h.s.DeleteClient(c.Request().Context(), h.actor(c), c.Param("id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *adminHandler) rotateSecret(c echo.Context) error {
+	view, err := h.s.RotateClientSecret(c.Request().Context(), h.actor(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+func (h *adminHandler) disable(c echo.Context) error {
+	if err := h.s.SetClientDisabled(c.Request().Context(), h.actor(c), c.Param("id"), true); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *adminHandler) enable(c echo.Context) error {
+	if err := h.s.SetClientDisabled(c.Request().Context(), h.actor(c), c.Param("id"), false); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,248 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/token"
+)
+
+// Token type hints of introspection and revocation requests (RFC 7009 2.1).
+const (
+	HintAccessToken  = "access_token"
+	HintRefreshToken = "refresh_token"
+)
+
+// Introspection is an introspection response (RFC 7662 2.2). Inactive
+// tokens carry nothing but Active.
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+}
+
+// UserTokens introspects the tokens of users, which are access tokens,
+// refresh tokens and API keys, and revokes their refresh tokens. Unknown,
+// expired and revoked tokens are inactive rather than errors, and revoking
+// them succeeds.
+type UserTokens interface {
+	IntrospectToken(ctx context.Context, tok, hint string) (*Introspection, error)
+	RevokeToken(ctx context.Context, tok, hint string) error
+}
+
+// Introspect is the introspection endpoint: POST /oauth/introspect, for
+// confidential clients allowed to introspect, such as resource servers.
+func (s *Server) Introspect(c echo.Context) error {
+	r := c.Request()
+	client, tok, err := s.tokenRequest(r)
+	if err != nil {
+		return WriteError(c, err)
+	}
+	if !client.Introspect {
+		return WriteError(c, ErrUnauthorizedClient.WithDescription("client is not allowed to introspect tokens"))
+	}
+	info, err := s.introspect(r.Context(), tok, r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		return err
+	}
+	noStore(c)
+	return c.JSON(http.StatusOK, info)
+}
+
+// Revoke is the revocation endpoint: POST /oauth/revoke, for confidential
+// clients and the tokens issued to them. Unknown tokens are not an error
+// (RFC 7009 2.2).
+func (s *Server) Revoke(c echo.Context) error {
+	r := c.Request()
+	client, tok, err := s.tokenRequest(r)
+	if err != nil {
+		return WriteError(c, err)
+	}
+	if err := s.revoke(r.Context(), client, tok, r.PostForm.Get("token_type_hint")); err != nil {
+		return WriteError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// tokenRequest authenticates the confidential client of an introspection
+// or revocation request and returns the token it is about. Public clients
+// only identify themselves, so they can't use either endpoint.
+func (s *Server) tokenRequest(r *http.Request) (*Client, string, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, "", ErrInvalidRequest.WithDescription("malformed form body")
+	}
+	client, err := s.AuthenticateClient(r.Context(), r)
+	if err != nil {
+		return nil, "", err
+	}
+	if client.Public() {
+		return nil, "", ErrInvalidClient.WithDescription("public clients can't introspect or revoke tokens")
+	}
+	tok := r.PostForm.Get("token")
+	if tok == "" {
+		return nil, "", ErrInvalidRequest.WithDescription("token is required")
+	}
+	return client, tok, nil
+}
+
+func (s *Server) introspect(ctx context.Context, tok, hint string) (*Introspection, error) {
+	key := token.Hash(tok)
+	if info, ok := s.introspections.get(key); ok {
+		return info, nil
+	}
+	info, err := s.lookup(ctx, tok, hint)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.IntrospectionCacheTTL
+	if info.Active && info.Exp > 0 {
+		ttl = min(ttl, time.Until(time.Unix(info.Exp, 0)))
+	}
+	s.introspections.put(key, info, ttl)
+	return info, nil
+}
+
+// lookup describes tok, bypassing the cache.
+func (s *Server) lookup(ctx context.Context, tok, hint string) (*Introspection, error) {
+	// Client tokens are the server's own; everything else is a user's
+	info, ok := s.introspectClientToken(tok)
+	if !ok {
+		info = &Introspection{}
+		if s.Users != nil {
+			var err error
+			if info, err = s.Users.IntrospectToken(ctx, tok, hint); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !info.Active {
+		info = &Introspection{}
+	}
+	return info, nil
+}
+
+// introspectClientToken describes a client_credentials token; ok is false
+// for any other token.
+func (s *Server) introspectClientToken(tok string) (*Introspection, bool) {
+	claims, err := s.Tokens.Verify(tok)
+	if err != nil || claims["token_use"] != TokenUseClient {
+		return nil, false
+	}
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return v
+	}
+	info := &Introspection{
+		Active:    true,
+		Scope:     str("scope"),
+		ClientID:  str("client_id"),
+		TokenType: "Bearer",
+		Sub:       str("sub"),
+		Iss:       str("iss"),
+	}
+	info.Aud, _ = claims.GetAudience()
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		info.Exp = exp.Unix()
+	}
+	if iat, _ := claims.GetIssuedAt(); iat != nil {
+		info.Iat = iat.Unix()
+	}
+	return info, true
+}
+
+func (s *Server) revoke(ctx context.Context, client *Client, tok, hint string) error {
+	// 1) Only the client a token was issued to may revoke it (RFC 7009 2.1),
+	//    so tokens issued to no client, such as API keys and a user's own
+	//    session, can't be revoked here; unknown and expired tokens need
+	//    nothing done but forgetting them
+	info, err := s.lookup(ctx, tok, hint)
+	if err != nil {
+		return err
+	}
+	if !info.Active {
+		s.introspections.delete(token.Hash(tok))
+		return nil
+	}
+	if info.ClientID != client.ID {
+		return ErrUnauthorizedClient.WithDescription("the token was not issued to this client")
+	}
+
+	// 2) Client tokens are self-contained and live until they expire
+	if _, ok := s.introspectClientToken(tok); ok {
+		return ErrUnsupportedTokenType.WithDescription("client access tokens can't be revoked")
+	}
+	if s.Users != nil {
+		if err := s.Users.RevokeToken(ctx, tok, hint); err != nil {
+			return err
+		}
+	}
+	s.introspections.delete(token.Hash(tok))
+	return nil
+}
+
+// introspectionCache remembers introspection results by token hash for a
+// short while, so gateways can introspect on every request.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedIntrospection
+}
+
+type cachedIntrospection struct {
+	info    *Introspection
+	expires time.Time
+}
+
+// maxCachedIntrospections bounds the cache; when full, expired entries are
+// dropped, and everything if none has expired.
+const maxCachedIntrospections = 10000
+
+func (c *introspectionCache) get(key string) (*Introspection, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.info, true
+}
+
+func (c *introspectionCache) put(key string, info *Introspection, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedIntrospection)
+	}
+	if len(c.entries) >= maxCachedIntrospections {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedIntrospections {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = cachedIntrospection{info: info, expires: now.Add(ttl)}
+}
+
+func (c *introspectionCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
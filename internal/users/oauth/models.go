@@ -0,0 +1,140 @@
+// Package oauth is the service's OAuth 2.0 authorization server.
+package oauth
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Client authentication methods at the token endpoint (RFC 7591 names).
+const (
+	AuthSecretBasic   = "client_secret_basic"
+	AuthSecretPost    = "client_secret_post"
+	AuthPrivateKeyJWT = "private_key_jwt"
+	// AuthNone marks public clients, which can't keep a secret and rely on
+	// PKCE instead.
+	AuthNone = "none"
+)
+
+// Grant types.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// Client is a registered OAuth client.
+type Client struct {
+	ID                    string     `gorm:"primaryKey;size:64" json:"client_id"`
+	Name                  string     `gorm:"not null" json:"name"`
+	AuthMethod            string     `gorm:"not null" json:"token_endpoint_auth_method"`
+	SecretHash            string     `gorm:"default:''" json:"-"`           // SHA-256 of the secret, hex; client_secret_* only
+	JWKS                  string     `gorm:"type:text;default:''" json:"-"` // public keys for private_key_jwt
+	GrantTypes            string     `gorm:"not null" json:"-"`             // space-separated
+	Scopes                string     `gorm:"type:text;default:''" json:"-"` // space-separated scopes the client may request
+	Audiences             string     `gorm:"type:text;default:''" json:"-"` // space-separated; all of them when none is requested
+	RedirectURIs          string     `gorm:"type:text;default:''" json:"-"` // space-separated, matched exactly
+	LogoURI               string     `gorm:"type:text;default:''" json:"logo_uri,omitempty"`
+	RegistrationTokenHash string     `gorm:"default:''" json:"-"`                         // SHA-256 of the RFC 7592 registration access token; dynamic clients only
+	AccessTokenTTL        int        `gorm:"default:0" json:"access_token_ttl,omitempty"` // seconds; 0 uses Server.AccessTokenTTL
+	Introspect            bool       `gorm:"default:false" json:"introspect"`             // may call the introspection endpoint
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	DisabledAt            *time.Time `json:"disabled_at,omitempty"`
+}
+
+// TableName keeps the table name stable.
+func (Client) TableName() string { return "oauth_clients" }
+
+// AllowsGrant reports whether the client may use grant.
+func (c *Client) AllowsGrant(grant string) bool {
+	return slices.Contains(strings.Fields(c.GrantTypes), grant)
+}
+
+// AllowsRedirect reports whether uri is one of the client's redirect URIs.
+func (c *Client) AllowsRedirect(uri string) bool {
+	return slices.Contains(strings.Fields(c.RedirectURIs), uri)
+}
+
+// Public reports whether the client has no credentials of its own.
+func (c *Client) Public() bool { return c.AuthMethod == AuthNone }
+
+// UsedAssertion remembers the jti of a private_key_jwt client assertion
+// until it expires, so it can't be replayed.
+type UsedAssertion struct {
+	ID        string    `gorm:"primaryKey;size:320"` // client ID and jti
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// TableName keeps the table name stable.
+func (UsedAssertion) TableName() string { return "oauth_used_assertions" }
+
+// AuthorizationCode is an authorization the user gave a client at the
+// authorization endpoint, until the client redeems it once at the token
+// endpoint. Only the hash of the code is stored.
+type AuthorizationCode struct {
+	Hash          string     `gorm:"primaryKey;size:64"`
+	ClientID      string     `gorm:"size:64;not null"`
+	RedirectURI   string     `gorm:"type:text;not null"`
+	Subject       string     `gorm:"not null"`
+	Username      string     `gorm:"not null"`
+	Scope         string     `gorm:"type:text;default:''"` // space-separated, as granted
+	Nonce         string     `gorm:"type:text;default:''"`
+	CodeChallenge string     `gorm:"not null"` // PKCE, S256
+	AuthTime      time.Time  `gorm:"not null"`
+	ACR           string     `gorm:"default:''"`
+	AMR           string     `gorm:"default:''"` // space-separated
+	ExpiresAt     time.Time  `gorm:"index;not null"`
+	UsedAt        *time.Time // set on redemption; a second use fails
+}
+
+// TableName keeps the table name stable.
+func (AuthorizationCode) TableName() string { return "oauth_authorization_codes" }
+
+// Consent records the scopes a user has granted a client, so they are not
+// asked again.
+type Consent struct {
+	ID        uint   `gorm:"primaryKey"`
+	Subject   string `gorm:"uniqueIndex:idx_oauth_consent_subject_client;size:255;not null"`
+	ClientID  string `gorm:"uniqueIndex:idx_oauth_consent_subject_client;size:64;not null"`
+	Scopes    string `gorm:"type:text;not null"` // space-separated
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName keeps the table name stable.
+func (Consent) TableName() string { return "oauth_consents" }
+
+// DeviceCode is a pending device authorization (RFC 8628): the device
+// polls with the device code while the user approves the user code in a
+// browser. Only hashes of both codes are stored.
+type DeviceCode struct {
+	Hash         string     `gorm:"primaryKey;size:64"`
+	UserCodeHash string     `gorm:"uniqueIndex;size:64;not null"`
+	ClientID     string     `gorm:"size:64;not null"`
+	Scope        string     `gorm:"type:text;default:''"` // space-separated, as requested
+	Interval     int        `gorm:"not null"`             // seconds between polls; grows on slow_down
+	ExpiresAt    time.Time  `gorm:"index;not null"`
+	LastPolledAt *time.Time // for slow_down
+	// Set on approval, from the user's session
+	Subject    string `gorm:"default:''"`
+	Username   string `gorm:"default:''"`
+	AuthTime   *time.Time
+	ACR        string `gorm:"default:''"`
+	AMR        string `gorm:"default:''"` // space-separated
+	ApprovedAt *time.Time
+	DeniedAt   *time.Time
+	UsedAt     *time.Time // set when the device gets its tokens
+}
+
+// TableName keeps the table name stable.
+func (DeviceCode) TableName() string { return "oauth_device_codes" }
+
+// Migrate creates the OAuth tables.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Client{}, &UsedAssertion{}, &AuthorizationCode{}, &Consent{}, &DeviceCode{})
+}
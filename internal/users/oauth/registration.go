@@ -0,0 +1,271 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/token"
+)
+
+// registrationActor names dynamic registration in the audit trail; later
+// changes are made by the client itself.
+const registrationActor = "dynamic_registration"
+
+// ClientMetadata is the client metadata of dynamic registration
+// (RFC 7591 2).
+type ClientMetadata struct {
+	ClientName    string          `json:"client_name"`
+	RedirectURIs  []string        `json:"redirect_uris,omitempty"`
+	GrantTypes    []string        `json:"grant_types,omitempty"`
+	ResponseTypes []string        `json:"response_types,omitempty"`
+	AuthMethod    string          `json:"token_endpoint_auth_method,omitempty"`
+	LogoURI       string          `json:"logo_uri,omitempty"`
+	Scope         string          `json:"scope,omitempty"` // space-separated
+	JWKS          json.RawMessage `json:"jwks,omitempty"`
+}
+
+// ClientInformation is a registration response (RFC 7591 3.2.1, RFC 7592
+// 3). The registration access token is only returned on registration.
+type ClientInformation struct {
+	ClientMetadata
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   *int64 `json:"client_secret_expires_at,omitempty"` // 0: never; set with a secret
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+}
+
+// spec turns metadata into a client specification; its scopes must be in
+// allowed.
+func (m ClientMetadata) spec(allowed []string) (ClientSpec, error) {
+	for _, rt := range m.ResponseTypes {
+		if rt != "code" {
+			return ClientSpec{}, ErrInvalidClientConfig.WithDetail("unsupported response type " + rt)
+		}
+	}
+	scopes := strings.Fields(m.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return ClientSpec{}, ErrInvalidClientConfig.WithDetail("scope " + scope + " can't be registered")
+		}
+	}
+	if len(m.GrantTypes) == 0 {
+		// RFC 7591 2: authorization_code when omitted
+		m.GrantTypes = []string{GrantAuthorizationCode}
+	}
+	// Device clients get the user's own tokens, so only admins create them
+	if slices.Contains(m.GrantTypes, GrantDeviceCode) {
+		return ClientSpec{}, ErrInvalidClientConfig.WithDetail("grant type " + GrantDeviceCode + " can't be registered")
+	}
+	return ClientSpec{
+		Name:         m.ClientName,
+		AuthMethod:   m.AuthMethod,
+		JWKS:         m.JWKS,
+		GrantTypes:   m.GrantTypes,
+		Scopes:       scopes,
+		RedirectURIs: m.RedirectURIs,
+		LogoURI:      m.LogoURI,
+	}, nil
+}
+
+// information returns the registration response of c.
+func (s *Server) information(c *Client) *ClientInformation {
+	info := &ClientInformation{
+		ClientMetadata: ClientMetadata{
+			ClientName:   c.Name,
+			RedirectURIs: strings.Fields(c.RedirectURIs),
+			GrantTypes:   strings.Fields(c.GrantTypes),
+			AuthMethod:   c.AuthMethod,
+			LogoURI:      c.LogoURI,
+			Scope:        c.Scopes,
+		},
+		ClientID:              c.ID,
+		ClientIDIssuedAt:      c.CreatedAt.Unix(),
+		RegistrationClientURI: s.RegistrationURL() + "/" + c.ID,
+	}
+	if c.AllowsGrant(GrantAuthorizationCode) {
+		info.ResponseTypes = []string{"code"}
+	}
+	if c.JWKS != "" {
+		info.JWKS = json.RawMessage(c.JWKS)
+	}
+	return info
+}
+
+// RegistrationURL is the client registration endpoint.
+func (s *Server) RegistrationURL() string {
+	return strings.TrimSuffix(s.Issuer(), "/") + "/oauth/register"
+}
+
+// RegisterClient registers a client on behalf of the holder of the initial
+// access token. Its credentials and registration access token are returned
+// once; only their hashes are stored.
+func (s *Server) RegisterClient(ctx context.Context, m ClientMetadata) (*ClientInformation, error) {
+	spec, err := m.spec(s.RegistrationScopes)
+	if err != nil {
+		return nil, err
+	}
+	view, err := s.CreateClient(ctx, registrationActor, spec)
+	if err != nil {
+		return nil, err
+	}
+	rat := token.RandomString(32)
+	if err := s.DB.WithContext(ctx).Model(view.Client).Update("registration_token_hash", token.Hash(rat)).Error; err != nil {
+		return nil, err
+	}
+	info := s.information(view.Client)
+	info.RegistrationAccessToken = rat
+	setSecret(info, view.ClientSecret)
+	return info, nil
+}
+
+// updateRegistration replaces the metadata of a dynamically registered
+// client.
+func (s *Server) updateRegistration(ctx context.Context, c *Client, m ClientMetadata) (*ClientInformation, error) {
+	spec, err := m.spec(s.RegistrationScopes)
+	if err != nil {
+		return nil, err
+	}
+	// The rest of the configuration is the server's to set
+	spec.Audiences = strings.Fields(c.Audiences)
+	spec.AccessTokenTTL = c.AccessTokenTTL
+	spec.Introspect = c.Introspect
+	view, err := s.UpdateClient(ctx, c.ID, c.ID, spec)
+	if err != nil {
+		return nil, err
+	}
+	info := s.information(view.Client)
+	setSecret(info, view.ClientSecret)
+	return info, nil
+}
+
+// registeredClient returns the client a registration access token manages.
+func (s *Server) registeredClient(ctx context.Context, id, rat string) (*Client, error) {
+	c, err := s.Client(ctx, id)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rat == "" || c.RegistrationTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(c.RegistrationTokenHash), []byte(token.Hash(rat))) != 1 {
+		return nil, ErrInvalidToken
+	}
+	return c, nil
+}
+
+func setSecret(info *ClientInformation, secret string) {
+	if secret != "" {
+		never := int64(0)
+		info.ClientSecret, info.ClientSecretExpiresAt = secret, &never
+	}
+}
+
+// Register is the client registration endpoint (RFC 7591 3).
+func (s *Server) Register(c echo.Context) error {
+	if s.RegistrationToken == "" {
+		return WriteError(c, ErrAccessDenied.WithDescription("dynamic client registration is disabled"))
+	}
+	tok := bearer(c)
+	if tok == "" || subtle.ConstantTimeCompare([]byte(tok), []byte(s.RegistrationToken)) != 1 {
+		return WriteError(c, ErrInvalidToken)
+	}
+	var m ClientMetadata
+	if err := json.NewDecoder(c.Request().Body).Decode(&m); err != nil {
+		return WriteError(c, ErrInvalidClientMetadata.WithDescription("malformed JSON body"))
+	}
+	info, err := s.RegisterClient(c.Request().Context(), m)
+	if err != nil {
+		return WriteError(c, registrationError(err))
+	}
+	noStore(c)
+	return c.JSON(http.StatusCreated, info)
+}
+
+// ReadRegistration returns a client's registration (RFC 7592 2.1).
+func (s *Server) ReadRegistration(c echo.Context) error {
+	client, err := s.registeredClient(c.Request().Context(), c.Param("client_id"), bearer(c))
+	if err != nil {
+		return WriteError(c, err)
+	}
+	noStore(c)
+	return c.JSON(http.StatusOK, s.information(client))
+}
+
+// UpdateRegistration replaces a client's metadata (RFC 7592 2.2). The
+// request repeats the client_id and may repeat its secret, but can't change
+// either.
+func (s *Server) UpdateRegistration(c echo.Context) error {
+	ctx := c.Request().Context()
+	client, err := s.registeredClient(ctx, c.Param("client_id"), bearer(c))
+	if err != nil {
+		return WriteError(c, err)
+	}
+	var body struct {
+		ClientMetadata
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return WriteError(c, ErrInvalidClientMetadata.WithDescription("malformed JSON body"))
+	}
+	if body.ClientID != client.ID {
+		return WriteError(c, ErrInvalidRequest.WithDescription("client_id must match the registration"))
+	}
+	if body.ClientSecret != "" && !client.verifySecret(body.ClientSecret) {
+		return WriteError(c, ErrInvalidRequest.WithDescription("client_secret can't be changed"))
+	}
+	info, err := s.updateRegistration(ctx, client, body.ClientMetadata)
+	if err != nil {
+		return WriteError(c, registrationError(err))
+	}
+	noStore(c)
+	return c.JSON(http.StatusOK, info)
+}
+
+// DeleteRegistration deprovisions a client (RFC 7592 2.3).
+func (s *Server) DeleteRegistration(c echo.Context) error {
+	ctx := c.Request().Context()
+	client, err := s.registeredClient(ctx, c.Param("client_id"), bearer(c))
+	if err != nil {
+		return WriteError(c, err)
+	}
+	if err := s.DeleteClient(ctx, client.ID, client.ID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// registrationError turns client configuration problems into RFC 7591 3.2.2
+// errors.
+func registrationError(err error) error {
+	p, ok := problem.From(err)
+	switch {
+	case !ok || p.Status >= http.StatusInternalServerError:
+		return err
+	case errors.Is(p, ErrUnsafeRedirectURI):
+		return ErrInvalidRedirectURI.WithDescription(p.Detail)
+	case errors.Is(p, ErrInvalidClientConfig):
+		return ErrInvalidClientMetadata.WithDescription(p.Detail)
+	}
+	return err
+}
+
+// bearer returns the request's bearer token, or "".
+func bearer(c echo.Context) string {
+	h := c.Request().Header.Get(echo.HeaderAuthorization)
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
@@ -0,0 +1,376 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/platform"
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/token"
+)
+
+// Server issues OAuth tokens signed with the service's native token key, so
+// other services verify them through /.well-known/jwks.json.
+type Server struct {
+	DB     *gorm.DB
+	Tokens *token.Signer
+	// AccessTokenTTL is the lifetime of access tokens of clients that set
+	// none. Default 1h.
+	AccessTokenTTL time.Duration
+	// Audit records changes to the client registry; optional.
+	Audit audit.Recorder
+	// Users introspects and revokes user tokens; without it only client
+	// tokens are active.
+	Users UserTokens
+	// IntrospectionCacheTTL is how long introspection results are reused,
+	// never past the token's expiry. Default 10s; 0 disables the cache.
+	IntrospectionCacheTTL time.Duration
+	// RegistrationToken is the initial access token of dynamic client
+	// registration (RFC 7591 3); empty disables POST /oauth/register.
+	RegistrationToken string
+	// RegistrationScopes are the scopes dynamically registered clients may
+	// ask for; other scopes are refused. Clients created through the admin
+	// API are not limited.
+	RegistrationScopes []string
+	// AllowInsecureRedirects permits plain http redirect URIs on any host,
+	// for development.
+	AllowInsecureRedirects bool
+
+	introspections introspectionCache
+	grants         map[string]GrantHandler
+}
+
+// GrantHandler answers a token request of one grant type for an
+// authenticated client that may use it. The form is already parsed.
+type GrantHandler func(ctx context.Context, r *http.Request, client *Client) (*TokenResponse, error)
+
+// NewServer returns a Server on db issuing tokens with tokens. It supports
+// the client_credentials grant; RegisterGrant adds others.
+func NewServer(db *gorm.DB, tokens *token.Signer) *Server {
+	s := &Server{DB: db, Tokens: tokens, AccessTokenTTL: time.Hour, IntrospectionCacheTTL: 10 * time.Second}
+	s.RegisterGrant(GrantClientCredentials, s.clientCredentials)
+	return s
+}
+
+// RegisterGrant makes the token endpoint answer grant with h, and lets
+// clients register for it.
+func (s *Server) RegisterGrant(grant string, h GrantHandler) {
+	if s.grants == nil {
+		s.grants = make(map[string]GrantHandler)
+	}
+	s.grants[grant] = h
+}
+
+// Issuer is the "iss" of the tokens the server issues.
+func (s *Server) Issuer() string { return s.Tokens.Issuer() }
+
+// TokenURL is the token endpoint, the audience of client assertions.
+func (s *Server) TokenURL() string { return strings.TrimSuffix(s.Issuer(), "/") + "/oauth/token" }
+
+// ClientSpec describes a client to register.
+type ClientSpec struct {
+	Name           string          `json:"name"`
+	AuthMethod     string          `json:"token_endpoint_auth_method"` // default client_secret_basic
+	JWKS           json.RawMessage `json:"jwks,omitempty"`             // {"keys": [...]} for private_key_jwt
+	GrantTypes     []string        `json:"grant_types"`                // default client_credentials
+	Scopes         []string        `json:"scopes"`
+	Audiences      []string        `json:"audiences"`
+	AccessTokenTTL int             `json:"access_token_ttl"`        // seconds; 0 for the server default
+	RedirectURIs   []string        `json:"redirect_uris,omitempty"` // authorization_code only
+	LogoURI        string          `json:"logo_uri,omitempty"`
+	Introspect     bool            `json:"introspect"` // may introspect any token, for resource servers
+}
+
+// ClientView is a client as the admin API shows it.
+type ClientView struct {
+	*Client
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	Audiences    []string `json:"audiences"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"` // only on creation and rotation
+}
+
+// View returns c for the admin API.
+func (c *Client) View() ClientView {
+	return ClientView{
+		Client:       c,
+		GrantTypes:   strings.Fields(c.GrantTypes),
+		Scopes:       strings.Fields(c.Scopes),
+		Audiences:    strings.Fields(c.Audiences),
+		RedirectURIs: strings.Fields(c.RedirectURIs),
+	}
+}
+
+// CreateClient registers a client. For client_secret_* methods the secret
+// is returned once, in ClientSecret; only its hash is stored.
+func (s *Server) CreateClient(ctx context.Context, actor string, spec ClientSpec) (_ *ClientView, err error) {
+	subject := spec.Name
+	defer func() { s.audit(ctx, actor, "admin.oauth_client.created", subject, err) }()
+
+	c := &Client{ID: token.RandomString(12)}
+	secret, err := s.applySpec(c, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.WithContext(ctx).Create(c).Error; err != nil {
+		return nil, err
+	}
+	subject = c.ID
+	view := c.View()
+	view.ClientSecret = secret
+	return &view, nil
+}
+
+// UpdateClient replaces the configuration of client id with spec. The
+// secret is kept unless the client switches to a client_secret_* method,
+// when a new one is returned in ClientSecret.
+func (s *Server) UpdateClient(ctx context.Context, actor, id string, spec ClientSpec) (_ *ClientView, err error) {
+	defer func() { s.audit(ctx, actor, "admin.oauth_client.updated", id, err) }()
+
+	c, err := s.Client(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.applySpec(c, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.WithContext(ctx).Save(c).Error; err != nil {
+		return nil, err
+	}
+	view := c.View()
+	view.ClientSecret = secret
+	return &view, nil
+}
+
+// DeleteClient removes client id with its consents and unredeemed codes.
+// Tokens already issued stay valid until they expire.
+func (s *Server) DeleteClient(ctx context.Context, actor, id string) (err error) {
+	defer func() { s.audit(ctx, actor, "admin.oauth_client.deleted", id, err) }()
+
+	if _, err := s.Client(ctx, id); err != nil {
+		return err
+	}
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", id).Delete(&Consent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", id).Delete(&AuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&Client{}).Error
+	})
+}
+
+// applySpec validates spec and sets it on c, generating a secret when c
+// needs one it doesn't have yet.
+func (s *Server) applySpec(c *Client, spec ClientSpec) (string, error) {
+	// 1) Validate
+	if strings.TrimSpace(spec.Name) == "" {
+		return "", ErrInvalidClientConfig.WithDetail("name is required")
+	}
+	if spec.AuthMethod == "" {
+		spec.AuthMethod = AuthSecretBasic
+	}
+	if len(spec.GrantTypes) == 0 {
+		spec.GrantTypes = []string{GrantClientCredentials}
+	}
+	if spec.AccessTokenTTL < 0 {
+		return "", ErrInvalidClientConfig.WithDetail("access_token_ttl must not be negative")
+	}
+	for _, g := range spec.GrantTypes {
+		if _, ok := s.grants[g]; !ok {
+			return "", ErrInvalidClientConfig.WithDetail("unsupported grant type " + g)
+		}
+	}
+	if slices.Contains(spec.GrantTypes, GrantAuthorizationCode) && len(spec.RedirectURIs) == 0 {
+		return "", ErrInvalidClientConfig.WithDetail("authorization_code needs redirect_uris")
+	}
+	for _, uri := range spec.RedirectURIs {
+		if err := s.validateRedirectURI(uri); err != nil {
+			return "", ErrUnsafeRedirectURI.WithDetail(err.Error())
+		}
+	}
+	if spec.LogoURI != "" {
+		if u, err := url.Parse(spec.LogoURI); err != nil || u.Scheme != "https" || u.Host == "" {
+			return "", ErrInvalidClientConfig.WithDetail("logo_uri must be an https URL")
+		}
+	}
+
+	// 2) Credentials
+	var secret, jwks string
+	secretHash := c.SecretHash
+	switch spec.AuthMethod {
+	case AuthSecretBasic, AuthSecretPost:
+		if secretHash == "" {
+			secret = token.RandomString(32)
+			secretHash = token.Hash(secret)
+		}
+	case AuthPrivateKeyJWT:
+		keys, err := platform.ParseJWKS(spec.JWKS)
+		if err != nil || len(keys) == 0 {
+			return "", ErrInvalidClientConfig.WithDetail("private_key_jwt needs jwks with at least one RSA or P-256 signing key")
+		}
+		jwks, secretHash = string(spec.JWKS), ""
+	case AuthNone:
+		if slices.Contains(spec.GrantTypes, GrantClientCredentials) {
+			return "", ErrInvalidClientConfig.WithDetail("public clients can't use client_credentials")
+		}
+		if spec.Introspect {
+			return "", ErrInvalidClientConfig.WithDetail("public clients can't introspect tokens")
+		}
+		secretHash = ""
+	default:
+		return "", ErrInvalidClientConfig.WithDetail("unsupported token_endpoint_auth_method " + spec.AuthMethod)
+	}
+
+	c.Name = strings.TrimSpace(spec.Name)
+	c.AuthMethod = spec.AuthMethod
+	c.SecretHash = secretHash
+	c.JWKS = jwks
+	c.GrantTypes = strings.Join(spec.GrantTypes, " ")
+	c.Scopes = strings.Join(spec.Scopes, " ")
+	c.Audiences = strings.Join(spec.Audiences, " ")
+	c.RedirectURIs = strings.Join(spec.RedirectURIs, " ")
+	c.LogoURI = spec.LogoURI
+	c.AccessTokenTTL = spec.AccessTokenTTL
+	c.Introspect = spec.Introspect
+	return secret, nil
+}
+
+// validateRedirectURI accepts absolute URLs without a fragment
+// (RFC 6749 3.1.2), matched exactly so wildcards are refused. Unless
+// AllowInsecureRedirects is set, http is only for loopback hosts (RFC 8252
+// 7.3); private-use schemes of native apps need no host.
+func (s *Server) validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return fmt.Errorf("redirect URI %q must be absolute", uri)
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return fmt.Errorf("redirect URI %q must not have a fragment", uri)
+	}
+	if strings.Contains(uri, "*") {
+		return fmt.Errorf("redirect URI %q must not have wildcards", uri)
+	}
+	if u.User != nil {
+		return fmt.Errorf("redirect URI %q must not have credentials", uri)
+	}
+	switch scheme := strings.ToLower(u.Scheme); {
+	case scheme == "http" && !s.AllowInsecureRedirects && !loopback(u.Hostname()):
+		return fmt.Errorf("redirect URI %q must use https", uri)
+	case slices.Contains([]string{"javascript", "data", "file", "vbscript"}, scheme):
+		return fmt.Errorf("redirect URI %q has a forbidden scheme", uri)
+	}
+	return nil
+}
+
+// loopback reports whether host is a loopback address or localhost.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Clients lists every registered client.
+func (s *Server) Clients(ctx context.Context) ([]ClientView, error) {
+	var clients []Client
+	if err := s.DB.WithContext(ctx).Order("created_at").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ClientView, 0, len(clients))
+	for i := range clients {
+		out = append(out, clients[i].View())
+	}
+	return out, nil
+}
+
+// Client returns client id, including disabled ones.
+func (s *Server) Client(ctx context.Context, id string) (*Client, error) {
+	var c Client
+	if err := s.DB.WithContext(ctx).Where("id = ?", id).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// RotateClientSecret replaces the secret of a client_secret_* client; the
+// old one stops working at once.
+func (s *Server) RotateClientSecret(ctx context.Context, actor, id string) (_ *ClientView, err error) {
+	defer func() { s.audit(ctx, actor, "admin.oauth_client.secret_rotated", id, err) }()
+
+	c, err := s.Client(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.AuthMethod == AuthPrivateKeyJWT {
+		return nil, ErrInvalidClientConfig.WithDetail("private_key_jwt clients have no secret")
+	}
+	secret := token.RandomString(32)
+	if err := s.DB.WithContext(ctx).Model(c).Update("secret_hash", token.Hash(secret)).Error; err != nil {
+		return nil, err
+	}
+	view := c.View()
+	view.ClientSecret = secret
+	return &view, nil
+}
+
+// SetClientDisabled disables or re-enables a client. Tokens already issued
+// stay valid until they expire.
+func (s *Server) SetClientDisabled(ctx context.Context, actor, id string, disabled bool) (err error) {
+	action := "admin.oauth_client.enabled"
+	if disabled {
+		action = "admin.oauth_client.disabled"
+	}
+	defer func() { s.audit(ctx, actor, action, id, err) }()
+
+	c, err := s.Client(ctx, id)
+	if err != nil {
+		return err
+	}
+	var at *time.Time
+	if disabled {
+		now := time.Now()
+		at = &now
+	}
+	return s.DB.WithContext(ctx).Model(c).Update("disabled_at", at).Error
+}
+
+// verifySecret compares secret with the stored hash in constant time.
+func (c *Client) verifySecret(secret string) bool {
+	return c.SecretHash != "" && subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(token.Hash(secret))) == 1
+}
+
+func (s *Server) audit(ctx context.Context, actor, action, subject string, err error) {
+	if s.Audit == nil {
+		return
+	}
+	e := audit.Event{Time: time.Now(), Action: action, Actor: actor, Subject: subject, Outcome: audit.OutcomeSuccess}
+	if err != nil {
+		e.Outcome = audit.OutcomeFailure
+		reason := "error"
+		if p, ok := problem.From(err); ok {
+			reason = p.Code
+		}
+		e.Details = map[string]any{"reason": reason}
+	}
+	_ = s.Audit.Record(ctx, e)
+}
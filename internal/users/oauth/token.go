@@ -0,0 +1,150 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// TokenUseClient marks access tokens issued to clients for themselves, as
+// opposed to the "access" tokens of users.
+const TokenUseClient = "client"
+
+// TokenUseDelegated marks access tokens a user granted a client through the
+// authorization code or device grants. Their aud and client_id are the
+// client and their scope is what the user consented to; the service's own
+// routes don't accept them.
+const TokenUseDelegated = "delegated"
+
+// TokenResponse is a successful token endpoint response.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Token is the token endpoint: POST /oauth/token, form-encoded.
+func (s *Server) Token(c echo.Context) error {
+	r := c.Request()
+	if err := r.ParseForm(); err != nil {
+		return WriteError(c, ErrInvalidRequest.WithDescription("malformed form body"))
+	}
+	resp, err := s.grant(r.Context(), r)
+	if err != nil {
+		return WriteError(c, err)
+	}
+	noStore(c)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// grant authenticates the client and runs the handler of the grant type.
+func (s *Server) grant(ctx context.Context, r *http.Request) (*TokenResponse, error) {
+	grant := r.PostForm.Get("grant_type")
+	if grant == "" {
+		return nil, ErrInvalidRequest.WithDescription("grant_type is required")
+	}
+	h, ok := s.grants[grant]
+	if !ok {
+		return nil, ErrUnsupportedGrantType.WithDescription(grant)
+	}
+	client, err := s.AuthenticateClient(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(grant) {
+		return nil, ErrUnauthorizedClient.WithDescription(grant + " not allowed for this client")
+	}
+	return h(ctx, r, client)
+}
+
+// clientCredentials issues a token to the client itself (RFC 6749 4.4).
+func (s *Server) clientCredentials(ctx context.Context, r *http.Request, client *Client) (*TokenResponse, error) {
+	// 1) Narrow scopes and audiences to what was asked for
+	scopes, err := narrow(r.PostForm.Get("scope"), client.Scopes)
+	if err != nil {
+		return nil, ErrInvalidScope.WithDescription(err.Error())
+	}
+	audiences, err := narrow(strings.Join(r.PostForm["audience"], " "), client.Audiences)
+	if err != nil {
+		return nil, ErrInvalidRequest.WithDescription("audience " + err.Error())
+	}
+
+	// 2) Sign
+	ttl := s.ClientTokenTTL(client)
+	claims := jwt.MapClaims{
+		"sub":       client.ID,
+		"client_id": client.ID,
+		"token_use": TokenUseClient,
+	}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	if len(audiences) > 0 {
+		claims["aud"] = audiences
+	}
+	access, err := s.Tokens.Sign(claims, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// ClientTokenTTL is the lifetime of access tokens issued to client.
+func (s *Server) ClientTokenTTL(client *Client) time.Duration {
+	if client.AccessTokenTTL > 0 {
+		return time.Duration(client.AccessTokenTTL) * time.Second
+	}
+	return s.AccessTokenTTL
+}
+
+// narrow returns the requested space-separated values, which must all be
+// allowed, or every allowed value when none is requested.
+func narrow(requested, allowed string) ([]string, error) {
+	allow := strings.Fields(allowed)
+	want := strings.Fields(requested)
+	if len(want) == 0 {
+		return allow, nil
+	}
+	for _, w := range want {
+		if !slices.Contains(allow, w) {
+			return nil, fmt.Errorf("%s is not allowed for this client", w)
+		}
+	}
+	return want, nil
+}
+
+// WriteError renders OAuth errors as RFC 6749 JSON and anything else
+// through the service's error handler.
+func WriteError(c echo.Context, err error) error {
+	e, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+	noStore(c)
+	switch {
+	case e.Code == ErrInvalidToken.Code:
+		c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	case e.Status == http.StatusUnauthorized:
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	return c.JSON(e.Status, e)
+}
+
+func noStore(c echo.Context) {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+}
@@ -0,0 +1,329 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/oauth"
+	"simple-go-auth/internal/users/problem"
+)
+
+// prompt values (OpenID Connect Core 3.1.2.1).
+const (
+	promptNone          = "none"
+	promptLogin         = "login"
+	promptConsent       = "consent"
+	promptSelectAccount = "select_account" // one account per browser: same as login
+)
+
+// authorizeRequest is a validated authorization request.
+type authorizeRequest struct {
+	ClientID      string   `json:"client_id"`
+	RedirectURI   string   `json:"redirect_uri"`
+	Scopes        []string `json:"scope"`
+	State         string   `json:"state,omitempty"`
+	Nonce         string   `json:"nonce,omitempty"`
+	CodeChallenge string   `json:"code_challenge"`
+	Prompt        []string `json:"prompt,omitempty"`
+	MaxAge        int      `json:"max_age"` // seconds; -1 when not set
+	ACRValues     []string `json:"acr_values,omitempty"`
+	// Subject is the user a consent ticket was shown to.
+	Subject string `json:"subject,omitempty"`
+	// UserCode is set instead of RedirectURI when the user approves a
+	// device; the device, not the browser, gets the tokens.
+	UserCode string `json:"user_code,omitempty"`
+}
+
+func (r *authorizeRequest) prompts(prompt string) bool { return slices.Contains(r.Prompt, prompt) }
+
+// Authorize is the authorization endpoint: GET /authorize with the
+// authorization code flow and PKCE.
+func (p *Provider) Authorize(c echo.Context) error {
+	q := c.QueryParams()
+
+	// 1) Until the client and redirect URI check out, errors can't go back to it
+	client, redirectURI, err := p.authorizeClient(c, q)
+	if err != nil {
+		return p.renderError(c, err)
+	}
+	req, err := p.parseAuthorize(q, client, redirectURI)
+	if err != nil {
+		return p.redirectError(c, redirectURI, q.Get("state"), err)
+	}
+
+	// 2) Sign the user in unless the session will do
+	sess := p.session(c)
+	if p.needsLogin(req, sess) {
+		if req.prompts(promptNone) {
+			return p.redirectError(c, req.RedirectURI, req.State, oauth.ErrLoginRequired)
+		}
+		return p.renderLogin(c, req, http.StatusOK, "")
+	}
+	return p.proceed(c, req, client, sess)
+}
+
+// authorizeClient returns the client of an authorization request and the
+// redirect URI, which must be registered for it.
+func (p *Provider) authorizeClient(c echo.Context, q url.Values) (*oauth.Client, string, error) {
+	client, err := p.client(c, q.Get("client_id"))
+	if err != nil {
+		return nil, "", err
+	}
+	redirectURI := q.Get("redirect_uri")
+	if uris := strings.Fields(client.RedirectURIs); redirectURI == "" && len(uris) == 1 {
+		redirectURI = uris[0]
+	}
+	if !client.AllowsRedirect(redirectURI) {
+		return nil, "", oauth.ErrInvalidRequest.WithDescription("redirect_uri is not registered for this client")
+	}
+	return client, redirectURI, nil
+}
+
+// client returns client id unless it is unknown or disabled.
+func (p *Provider) client(c echo.Context, id string) (*oauth.Client, error) {
+	client, err := p.OAuth.Client(c.Request().Context(), id)
+	if errors.Is(err, oauth.ErrClientNotFound) || err == nil && client.DisabledAt != nil {
+		return nil, oauth.ErrInvalidClient.WithDescription("unknown client")
+	}
+	return client, err
+}
+
+// parseAuthorize validates the rest of an authorization request.
+func (p *Provider) parseAuthorize(q url.Values, client *oauth.Client, redirectURI string) (*authorizeRequest, error) {
+	req := &authorizeRequest{
+		ClientID:      client.ID,
+		RedirectURI:   redirectURI,
+		Scopes:        strings.Fields(q.Get("scope")),
+		State:         q.Get("state"),
+		Nonce:         q.Get("nonce"),
+		CodeChallenge: q.Get("code_challenge"),
+		Prompt:        strings.Fields(q.Get("prompt")),
+		MaxAge:        -1,
+		ACRValues:     strings.Fields(q.Get("acr_values")),
+	}
+	if rt := q.Get("response_type"); rt != "code" {
+		return nil, oauth.ErrUnsupportedResponseType.WithDescription("only response_type=code is supported")
+	}
+	if !client.AllowsGrant(oauth.GrantAuthorizationCode) {
+		return nil, oauth.ErrUnauthorizedClient.WithDescription("authorization_code not allowed for this client")
+	}
+	if !slices.Contains(req.Scopes, ScopeOpenID) {
+		return nil, oauth.ErrInvalidScope.WithDescription("scope must include openid")
+	}
+	if err := checkScopes(client, req.Scopes); err != nil {
+		return nil, err
+	}
+	if req.CodeChallenge == "" || q.Get("code_challenge_method") != oauth.PKCEMethod {
+		return nil, oauth.ErrInvalidRequest.WithDescription("PKCE with code_challenge_method=S256 is required")
+	}
+	for _, prompt := range req.Prompt {
+		if !slices.Contains([]string{promptNone, promptLogin, promptConsent, promptSelectAccount}, prompt) {
+			return nil, oauth.ErrInvalidRequest.WithDescription("unsupported prompt " + prompt)
+		}
+	}
+	if req.prompts(promptNone) && len(req.Prompt) > 1 {
+		return nil, oauth.ErrInvalidRequest.WithDescription("prompt=none can't be combined")
+	}
+	if v := q.Get("max_age"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, oauth.ErrInvalidRequest.WithDescription("max_age must be a non-negative integer")
+		}
+		req.MaxAge = n
+	}
+	return req, nil
+}
+
+// checkScopes allows the standard scopes and the client's own.
+func checkScopes(client *oauth.Client, scopes []string) error {
+	allowed := strings.Fields(client.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(standardScopes, scope) && !slices.Contains(allowed, scope) {
+			return oauth.ErrInvalidScope.WithDescription(scope + " is not allowed for this client")
+		}
+	}
+	return nil
+}
+
+// needsLogin reports whether the user must sign in (again) for req.
+func (p *Provider) needsLogin(req *authorizeRequest, sess *session) bool {
+	switch {
+	case sess == nil:
+		return true
+	case req.prompts(promptLogin), req.prompts(promptSelectAccount):
+		return true
+	case req.MaxAge >= 0 && time.Since(sess.AuthTime) > time.Duration(req.MaxAge)*time.Second:
+		return true
+	}
+	// A weaker sign-in than asked for is stepped up where the user can interact
+	return len(req.ACRValues) > 0 && !slices.Contains(req.ACRValues, sess.ACR) && !req.prompts(promptNone)
+}
+
+// Login signs the user in from the login page: POST /authorize/login with
+// username and password, or the answer to a second-factor challenge.
+func (p *Provider) Login(c echo.Context) error {
+	req, client, err := p.resume(c)
+	if err != nil {
+		return p.renderError(c, err)
+	}
+
+	// 1) The auth service's sign-in, including its challenges
+	ctx := auth.RequestContext(c)
+	username := c.FormValue("username")
+	sess := &session{ACR: ACRPassword, AMR: []string{"pwd"}}
+	var tokens *auth.AuthTokens
+	if challenge := c.FormValue("challenge"); challenge != "" {
+		tokens, err = p.Users.RespondToChallenge(ctx, username, challenge, c.FormValue("session"), c.FormValue("code"))
+		sess.ACR, sess.AMR = ACRMFA, []string{"pwd", "mfa", challengeAMR(challenge)}
+	} else {
+		tokens, err = p.Users.SignIn(ctx, username, c.FormValue("password"))
+	}
+	var ch *auth.AuthChallenge
+	if errors.As(err, &ch) {
+		return p.renderChallenge(c, req, username, ch)
+	}
+	if err != nil {
+		if pr, ok := problem.From(problem.FromCognito(err)); ok && pr.Status >= 500 {
+			return err
+		}
+		return p.renderLogin(c, req, http.StatusUnauthorized, "Sign-in failed. Check your username and password and try again.")
+	}
+
+	// 2) Keep who signed in, not the tokens: the code is redeemed for fresh ones
+	principal, err := p.Users.Authenticate(ctx, tokens.AccessToken)
+	if err != nil {
+		return err
+	}
+	if err := p.Users.RevokeToken(ctx, tokens.RefreshToken, oauth.HintRefreshToken); err != nil {
+		return err
+	}
+	sess.Subject, sess.Username, sess.AuthTime = principal.Subject, principal.Username, time.Now()
+	if err := p.startSession(c, sess); err != nil {
+		return err
+	}
+	return p.proceed(c, req, client, sess)
+}
+
+// Consent records the user's decision on the consent page: POST
+// /authorize/consent with decision=allow or deny.
+func (p *Provider) Consent(c echo.Context) error {
+	req, client, err := p.resume(c)
+	if err != nil {
+		return p.renderError(c, err)
+	}
+	// The page was shown to the user still signed in
+	sess := p.session(c)
+	if sess == nil || req.Subject == "" || sess.Subject != req.Subject {
+		return p.renderError(c, oauth.ErrInvalidRequest.WithDescription("the session changed; start again from the application"))
+	}
+	allow := c.FormValue("decision") == "allow"
+	if req.UserCode != "" {
+		return p.decideDevice(c, req, client, sess, allow)
+	}
+	if !allow {
+		return p.redirectError(c, req.RedirectURI, req.State, oauth.ErrAccessDenied.WithDescription("the user denied access"))
+	}
+	if err := p.OAuth.GrantConsent(c.Request().Context(), sess.Subject, client.ID, req.Scopes); err != nil {
+		return err
+	}
+	return p.issueCode(c, req, sess)
+}
+
+// resume reopens the authorization request of a login or consent form.
+func (p *Provider) resume(c echo.Context) (*authorizeRequest, *oauth.Client, error) {
+	req, err := p.openTicket(c.FormValue("request"))
+	if err != nil {
+		return nil, nil, oauth.ErrInvalidRequest.WithDescription("the sign-in page expired; start again from the application")
+	}
+	if req.UserCode != "" {
+		client, err := p.client(c, req.ClientID)
+		return req, client, err
+	}
+	client, _, err := p.authorizeClient(c, url.Values{"client_id": {req.ClientID}, "redirect_uri": {req.RedirectURI}})
+	return req, client, err
+}
+
+// proceed asks for consent unless the user already gave it, then issues
+// the code. Devices are always confirmed, so a user can't be tricked into
+// approving a code they didn't get from their own device.
+func (p *Provider) proceed(c echo.Context, req *authorizeRequest, client *oauth.Client, sess *session) error {
+	consented, err := p.OAuth.Consented(c.Request().Context(), sess.Subject, client.ID, req.Scopes)
+	if err != nil {
+		return err
+	}
+	if !consented || req.prompts(promptConsent) || req.UserCode != "" {
+		if req.prompts(promptNone) {
+			return p.redirectError(c, req.RedirectURI, req.State, oauth.ErrConsentRequired)
+		}
+		req.Subject = sess.Subject
+		return p.renderConsent(c, req, client)
+	}
+	return p.issueCode(c, req, sess)
+}
+
+// issueCode sends the user back to the client with an authorization code.
+func (p *Provider) issueCode(c echo.Context, req *authorizeRequest, sess *session) error {
+	code, err := p.OAuth.IssueCode(c.Request().Context(), &oauth.AuthorizationCode{
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Subject:       sess.Subject,
+		Username:      sess.Username,
+		Scope:         strings.Join(req.Scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      sess.AuthTime,
+		ACR:           sess.ACR,
+		AMR:           strings.Join(sess.AMR, " "),
+	}, p.CodeTTL)
+	if err != nil {
+		return err
+	}
+	return p.redirect(c, req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+}
+
+// redirectError returns err to the client's redirect URI.
+func (p *Provider) redirectError(c echo.Context, redirectURI, state string, err error) error {
+	var oe *oauth.Error
+	if !errors.As(err, &oe) {
+		return err
+	}
+	params := url.Values{"error": {oe.Code}, "state": {state}}
+	if oe.Description != "" {
+		params.Set("error_description", oe.Description)
+	}
+	return p.redirect(c, redirectURI, params)
+}
+
+// redirect sends the browser to redirectURI with params and the issuer
+// (RFC 9207) added to its query.
+func (p *Provider) redirect(c echo.Context, redirectURI string, params url.Values) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	for k, v := range params {
+		if v[0] != "" {
+			q[k] = v
+		}
+	}
+	q.Set("iss", p.OAuth.Issuer())
+	u.RawQuery = q.Encode()
+	return c.Redirect(http.StatusFound, u.String())
+}
+
+// challengeAMR names a second factor in the "amr" claim (RFC 8176).
+func challengeAMR(challenge string) string {
+	if challenge == auth.ChallengeSMSMFA {
+		return "sms"
+	}
+	return "otp"
+}
@@ -0,0 +1,188 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/oauth"
+)
+
+// DeviceAuthorizationResponse answers a device authorization request
+// (RFC 8628 3.2).
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceAuthorization is the device authorization endpoint: POST
+// /oauth/device_authorization, form-encoded, for clients allowed the
+// device_code grant.
+func (p *Provider) DeviceAuthorization(c echo.Context) error {
+	r := c.Request()
+	if err := r.ParseForm(); err != nil {
+		return oauth.WriteError(c, oauth.ErrInvalidRequest.WithDescription("malformed form body"))
+	}
+	client, err := p.OAuth.AuthenticateClient(r.Context(), r)
+	if err != nil {
+		return oauth.WriteError(c, err)
+	}
+	if !client.AllowsGrant(oauth.GrantDeviceCode) {
+		return oauth.WriteError(c, oauth.ErrUnauthorizedClient.WithDescription("device_code not allowed for this client"))
+	}
+	scopes := strings.Fields(r.PostForm.Get("scope"))
+	if err := checkScopes(client, scopes); err != nil {
+		return oauth.WriteError(c, err)
+	}
+
+	interval := int(p.DevicePollInterval.Seconds())
+	deviceCode, userCode, err := p.OAuth.IssueDeviceCode(r.Context(), &oauth.DeviceCode{
+		ClientID: client.ID,
+		Scope:    strings.Join(scopes, " "),
+		Interval: interval,
+	}, p.DeviceCodeTTL)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         p.url("/device"),
+		VerificationURIComplete: p.url("/device?" + url.Values{"user_code": {userCode}}.Encode()),
+		ExpiresIn:               int(p.DeviceCodeTTL.Seconds()),
+		Interval:                interval,
+	})
+}
+
+// deviceCode gives a polling device the user's tokens once they approve
+// it (RFC 8628 3.4).
+func (p *Provider) deviceCode(ctx context.Context, r *http.Request, client *oauth.Client) (*oauth.TokenResponse, error) {
+	code := r.PostForm.Get("device_code")
+	if code == "" {
+		return nil, oauth.ErrInvalidRequest.WithDescription("device_code is required")
+	}
+	dc, err := p.OAuth.PollDeviceCode(ctx, code, client)
+	if err != nil {
+		return nil, err
+	}
+	a := &authorization{Subject: dc.Subject, Username: dc.Username, Scope: dc.Scope, ACR: dc.ACR, AMR: dc.AMR}
+	if dc.AuthTime != nil {
+		a.AuthTime = *dc.AuthTime
+	}
+	return p.deviceTokens(ctx, client, a)
+}
+
+// deviceTokens returns the user's own tokens for a device, the AuthTokens of
+// any sign-in: devices such as the internal CLI act as the user on the
+// service itself, and refresh through /refresh. The refresh token comes only
+// with offline_access, an ID token only with openid.
+func (p *Provider) deviceTokens(ctx context.Context, client *oauth.Client, a *authorization) (*oauth.TokenResponse, error) {
+	// 1) Tokens through the auth service, as for any sign-in
+	tokens, err := p.Users.IssueTokens(ctx, a.Username)
+	if err != nil {
+		return nil, grantError(err)
+	}
+	scopes := strings.Fields(a.Scope)
+	resp := &oauth.TokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(tokens.ExpiresIn),
+		Scope:       a.Scope,
+	}
+	if slices.Contains(scopes, ScopeOfflineAccess) {
+		resp.RefreshToken = tokens.RefreshToken
+	} else if err := p.Users.RevokeToken(ctx, tokens.RefreshToken, oauth.HintRefreshToken); err != nil {
+		return nil, err
+	}
+
+	// 2) The ID token
+	if slices.Contains(scopes, ScopeOpenID) {
+		if resp.IDToken, err = p.idToken(ctx, client, a, resp.AccessToken); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Device is the verification page: GET /device, optionally with the
+// user_code the device showed.
+func (p *Provider) Device(c echo.Context) error {
+	return render(c, http.StatusOK, "device", page{Title: "Connect a device", UserCode: c.QueryParam("user_code")})
+}
+
+// VerifyDevice takes the user code from the verification page: POST
+// /device. The user signs in unless already signed in, then confirms the
+// device.
+func (p *Provider) VerifyDevice(c echo.Context) error {
+	userCode := c.FormValue("user_code")
+	dc, err := p.OAuth.PendingDeviceCode(c.Request().Context(), userCode)
+	if errors.Is(err, oauth.ErrUserCodeNotFound) {
+		return render(c, http.StatusBadRequest, "device", page{
+			Title:    "Connect a device",
+			Message:  "That code is wrong or has expired. Check your device and try again.",
+			UserCode: userCode,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	client, err := p.client(c, dc.ClientID)
+	if err != nil {
+		return p.renderError(c, err)
+	}
+
+	req := &authorizeRequest{
+		ClientID: client.ID,
+		Scopes:   strings.Fields(dc.Scope),
+		MaxAge:   -1,
+		UserCode: userCode,
+	}
+	sess := p.session(c)
+	if sess == nil {
+		return p.renderLogin(c, req, http.StatusOK, "")
+	}
+	return p.proceed(c, req, client, sess)
+}
+
+// decideDevice records the user's decision on a device's consent page.
+func (p *Provider) decideDevice(c echo.Context, req *authorizeRequest, client *oauth.Client, sess *session, allow bool) error {
+	ctx := c.Request().Context()
+	var err error
+	if allow {
+		authTime := sess.AuthTime
+		err = p.OAuth.ApproveDeviceCode(ctx, req.UserCode, &oauth.DeviceCode{
+			Subject:  sess.Subject,
+			Username: sess.Username,
+			AuthTime: &authTime,
+			ACR:      sess.ACR,
+			AMR:      strings.Join(sess.AMR, " "),
+		})
+		if err == nil {
+			err = p.OAuth.GrantConsent(ctx, sess.Subject, client.ID, req.Scopes)
+		}
+	} else {
+		err = p.OAuth.DenyDeviceCode(ctx, req.UserCode)
+	}
+	switch {
+	case errors.Is(err, oauth.ErrUserCodeNotFound):
+		return render(c, http.StatusBadRequest, "error", page{Title: "Connect a device", Message: "That code has expired. Start again on your device."})
+	case err != nil:
+		return err
+	case !allow:
+		return render(c, http.StatusOK, "done", page{Title: "Device not connected", Message: "You denied access. You can close this window."})
+	}
+	return render(c, http.StatusOK, "done", page{
+		Title:   "Device connected",
+		Message: client.Name + " is signed in. You can return to your device.",
+	})
+}
@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/oauth"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var pages = template.Must(template.ParseFS(embedded, "templates/*.html"))
+
+// scopeDescriptions say on the consent page what a scope gives access to.
+var scopeDescriptions = map[string]string{
+	ScopeOpenID:        "Know who you are",
+	ScopeProfile:       "See your username",
+	ScopeEmail:         "See your email address",
+	ScopePhone:         "See your phone number",
+	ScopeOfflineAccess: "Stay signed in when you're not using it",
+}
+
+type page struct {
+	Title       string
+	Message     string
+	ClientName  string
+	Request     string
+	Username    string
+	Challenge   string
+	Session     string
+	Destination string
+	Scopes      []string
+	UserCode    string
+}
+
+func (p *Provider) renderLogin(c echo.Context, req *authorizeRequest, status int, message string) error {
+	return p.renderTicket(c, req, status, "login", page{Title: "Sign in", Message: message})
+}
+
+func (p *Provider) renderChallenge(c echo.Context, req *authorizeRequest, username string, ch *auth.AuthChallenge) error {
+	return p.renderTicket(c, req, http.StatusOK, "challenge", page{
+		Title:       "Verify it's you",
+		Username:    username,
+		Challenge:   ch.Name,
+		Session:     ch.Session,
+		Destination: ch.Destination,
+	})
+}
+
+func (p *Provider) renderConsent(c echo.Context, req *authorizeRequest, client *oauth.Client) error {
+	data := page{Title: "Allow access", ClientName: client.Name, UserCode: req.UserCode}
+	for _, scope := range req.Scopes {
+		if d, ok := scopeDescriptions[scope]; ok {
+			data.Scopes = append(data.Scopes, d)
+		} else {
+			data.Scopes = append(data.Scopes, scope)
+		}
+	}
+	return p.renderTicket(c, req, http.StatusOK, "consent", data)
+}
+
+// renderTicket renders a page that posts req back in its ticket.
+func (p *Provider) renderTicket(c echo.Context, req *authorizeRequest, status int, name string, data page) error {
+	ticket, err := p.ticket(req)
+	if err != nil {
+		return err
+	}
+	data.Request = ticket
+	if data.ClientName == "" {
+		if client, err := p.OAuth.Client(c.Request().Context(), req.ClientID); err == nil {
+			data.ClientName = client.Name
+		}
+	}
+	return render(c, status, name, data)
+}
+
+// renderError shows an error the client can't be told about, because the
+// request didn't identify it or its redirect URI.
+func (p *Provider) renderError(c echo.Context, err error) error {
+	var oe *oauth.Error
+	if !errors.As(err, &oe) {
+		return err
+	}
+	message := oe.Description
+	if message == "" {
+		message = oe.Code
+	}
+	return render(c, http.StatusBadRequest, "error", page{Title: "Sign-in error", Message: message})
+}
+
+func render(c echo.Context, status int, name string, data page) error {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	h := c.Response().Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	return c.HTMLBlob(status, buf.Bytes())
+}
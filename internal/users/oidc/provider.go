@@ -0,0 +1,139 @@
+// Package oidc is the service's OpenID Connect provider. Users sign in at
+// /authorize through the auth service's own sign-in path, and clients
+// redeem the code at the OAuth token endpoint for the service's tokens plus
+// an ID token. Devices without a browser get the same tokens through the
+// device authorization grant, approved at /device.
+package oidc
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/oauth"
+)
+
+// Scopes with a meaning of their own; any client may ask for them.
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopePhone         = "phone"
+	ScopeOfflineAccess = "offline_access" // a refresh token comes with the code
+)
+
+var standardScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone, ScopeOfflineAccess}
+
+// Authentication context classes, in the "acr" claim and acr_values.
+const (
+	ACRPassword = "urn:simple-go-auth:acr:password"
+	ACRMFA      = "urn:simple-go-auth:acr:mfa" // password and a second factor
+)
+
+// Provider serves the OpenID Connect endpoints on top of an OAuth server.
+type Provider struct {
+	OAuth *oauth.Server
+	Users *auth.AuthServiceImpl
+	// SessionTTL is how long a browser stays signed in at the provider.
+	// Default 12h.
+	SessionTTL time.Duration
+	// CodeTTL is how long authorization codes can be redeemed. Default 1m.
+	CodeTTL time.Duration
+	// IDTokenTTL is the lifetime of ID tokens. Default 1h.
+	IDTokenTTL time.Duration
+	// DeviceCodeTTL is how long a device waits for the user to approve it.
+	// Default 10m.
+	DeviceCodeTTL time.Duration
+	// DevicePollInterval is how often devices may poll the token endpoint
+	// unless told to slow down. Default 5s.
+	DevicePollInterval time.Duration
+}
+
+// NewProvider returns a Provider and adds the authorization_code,
+// refresh_token and device_code grants to server's token endpoint.
+func NewProvider(server *oauth.Server, users *auth.AuthServiceImpl) *Provider {
+	p := &Provider{
+		OAuth:              server,
+		Users:              users,
+		SessionTTL:         12 * time.Hour,
+		CodeTTL:            time.Minute,
+		IDTokenTTL:         time.Hour,
+		DeviceCodeTTL:      10 * time.Minute,
+		DevicePollInterval: 5 * time.Second,
+	}
+	server.RegisterGrant(oauth.GrantAuthorizationCode, p.authorizationCode)
+	server.RegisterGrant(oauth.GrantRefreshToken, p.refreshToken)
+	server.RegisterGrant(oauth.GrantDeviceCode, p.deviceCode)
+	return p
+}
+
+// RegisterRoutes mounts the provider on e; authMw guards the consent
+// management routes of signed-in users.
+func (p *Provider) RegisterRoutes(e *echo.Echo, authMw echo.MiddlewareFunc) {
+	e.GET("/.well-known/openid-configuration", p.Discovery)
+	e.GET("/authorize", p.Authorize)
+	e.POST("/authorize/login", p.Login)
+	e.POST("/authorize/consent", p.Consent)
+	e.POST("/oauth/device_authorization", p.DeviceAuthorization)
+	e.GET("/device", p.Device)
+	e.POST("/device", p.VerifyDevice)
+	e.GET("/userinfo", p.UserInfo)
+	e.POST("/userinfo", p.UserInfo)
+	e.GET("/oauth/consents", p.listConsents, authMw)
+	e.DELETE("/oauth/consents/:client_id", p.revokeConsent, authMw)
+}
+
+func (p *Provider) url(path string) string {
+	return strings.TrimSuffix(p.OAuth.Issuer(), "/") + path
+}
+
+// Discovery serves the provider metadata (OpenID Connect Discovery 1.0).
+func (p *Provider) Discovery(c echo.Context) error {
+	meta := map[string]any{
+		"issuer":                                p.OAuth.Issuer(),
+		"authorization_endpoint":                p.url("/authorize"),
+		"token_endpoint":                        p.OAuth.TokenURL(),
+		"userinfo_endpoint":                     p.url("/userinfo"),
+		"jwks_uri":                              p.url("/.well-known/jwks.json"),
+		"introspection_endpoint":                p.url("/oauth/introspect"),
+		"revocation_endpoint":                   p.url("/oauth/revoke"),
+		"device_authorization_endpoint":         p.url("/oauth/device_authorization"),
+		"scopes_supported":                      standardScopes,
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken, oauth.GrantClientCredentials, oauth.GrantDeviceCode},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"token_endpoint_auth_methods_supported": []string{oauth.AuthSecretBasic, oauth.AuthSecretPost, oauth.AuthPrivateKeyJWT, oauth.AuthNone},
+		"code_challenge_methods_supported":      []string{oauth.PKCEMethod},
+		"prompt_values_supported":               []string{promptNone, promptLogin, promptConsent, promptSelectAccount},
+		"acr_values_supported":                  []string{ACRPassword, ACRMFA},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "acr", "amr", "azp",
+			"preferred_username", "updated_at", "email", "email_verified", "phone_number",
+		},
+		"authorization_response_iss_parameter_supported": true,
+	}
+	if p.OAuth.RegistrationToken != "" {
+		meta["registration_endpoint"] = p.OAuth.RegistrationURL()
+	}
+	return c.JSON(http.StatusOK, meta)
+}
+
+func (p *Provider) listConsents(c echo.Context) error {
+	consents, err := p.OAuth.Consents(c.Request().Context(), auth.PrincipalFromContext(c).Subject)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"consents": consents})
+}
+
+func (p *Provider) revokeConsent(c echo.Context) error {
+	if err := p.OAuth.RevokeConsent(c.Request().Context(), auth.PrincipalFromContext(c).Subject, c.Param("client_id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
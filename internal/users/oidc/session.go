@@ -0,0 +1,122 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// sessionCookie holds the browser's sign-in at the provider, a token signed
+// like the service's own so no server-side state is needed.
+const sessionCookie = "sga_oidc_session"
+
+// ticketTTL bounds how long the login and consent pages stay valid.
+const ticketTTL = 10 * time.Minute
+
+// session is a user signed in at the provider.
+type session struct {
+	Subject  string
+	Username string
+	AuthTime time.Time
+	ACR      string
+	AMR      []string
+}
+
+// session returns the browser's session, or nil.
+func (p *Provider) session(c echo.Context) *session {
+	cookie, err := c.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	claims, err := p.verify(cookie.Value, "session")
+	if err != nil {
+		return nil
+	}
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return v
+	}
+	s := &session{Subject: str("sub"), Username: str("username"), ACR: str("acr")}
+	if at, ok := claims["auth_time"].(float64); ok {
+		s.AuthTime = time.Unix(int64(at), 0)
+	}
+	if amr, ok := claims["amr"].([]any); ok {
+		for _, m := range amr {
+			if v, ok := m.(string); ok {
+				s.AMR = append(s.AMR, v)
+			}
+		}
+	}
+	return s
+}
+
+// startSession signs s into the session cookie.
+func (p *Provider) startSession(c echo.Context, s *session) error {
+	value, err := p.sign("session", jwt.MapClaims{
+		"sub":       s.Subject,
+		"username":  s.Username,
+		"auth_time": s.AuthTime.Unix(),
+		"acr":       s.ACR,
+		"amr":       s.AMR,
+	}, p.SessionTTL)
+	if err != nil {
+		return err
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(p.SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// ticket carries a validated authorization request through the login and
+// consent pages, signed so it can't be altered on the way.
+func (p *Provider) ticket(req *authorizeRequest) (string, error) {
+	return p.sign("authorize", jwt.MapClaims{"req": req}, ticketTTL)
+}
+
+// openTicket returns the authorization request in a ticket.
+func (p *Provider) openTicket(ticket string) (*authorizeRequest, error) {
+	claims, err := p.verify(ticket, "authorize")
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(claims["req"])
+	if err != nil {
+		return nil, err
+	}
+	var req authorizeRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// sign issues a provider-internal token of kind; its audience keeps it from
+// being accepted anywhere else.
+func (p *Provider) sign(kind string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	claims["token_use"] = kind
+	claims["aud"] = p.url("/" + kind)
+	return p.OAuth.Tokens.Sign(claims, ttl)
+}
+
+func (p *Provider) verify(raw, kind string) (jwt.MapClaims, error) {
+	claims, err := p.OAuth.Tokens.Verify(raw)
+	if err != nil {
+		return nil, err
+	}
+	aud, _ := claims.GetAudience()
+	if claims["token_use"] != kind || !slices.Contains(aud, p.url("/"+kind)) {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
+	return claims, nil
+}
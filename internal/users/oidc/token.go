@@ -0,0 +1,187 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/oauth"
+	"simple-go-auth/internal/users/problem"
+)
+
+// authorization is what a user let a client have, through a code or a
+// device.
+type authorization struct {
+	Subject  string
+	Username string
+	Scope    string // space-separated
+	Nonce    string
+	AuthTime time.Time
+	ACR      string
+	AMR      string // space-separated
+}
+
+// authorizationCode redeems a code from /authorize for the user's tokens
+// and, with the openid scope, an ID token (RFC 6749 4.1.3).
+func (p *Provider) authorizationCode(ctx context.Context, r *http.Request, client *oauth.Client) (*oauth.TokenResponse, error) {
+	code := r.PostForm.Get("code")
+	if code == "" {
+		return nil, oauth.ErrInvalidRequest.WithDescription("code is required")
+	}
+	ac, err := p.OAuth.RedeemCode(ctx, code, client, r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	if err != nil {
+		return nil, err
+	}
+	return p.issueTokens(ctx, client, &authorization{
+		Subject:  ac.Subject,
+		Username: ac.Username,
+		Scope:    ac.Scope,
+		Nonce:    ac.Nonce,
+		AuthTime: ac.AuthTime,
+		ACR:      ac.ACR,
+		AMR:      ac.AMR,
+	})
+}
+
+// issueTokens returns the user's tokens for client under a: an access
+// token bound to the client, a refresh token only with offline_access, an
+// ID token only with openid.
+func (p *Provider) issueTokens(ctx context.Context, client *oauth.Client, a *authorization) (*oauth.TokenResponse, error) {
+	// 1) A sign-in through the auth service, whose refresh token only the
+	//    client can use; its access token is the service's own and stays here
+	tokens, err := p.Users.IssueClientTokens(ctx, a.Username, client.ID, a.Scope)
+	if err != nil {
+		return nil, grantError(err)
+	}
+	scopes := strings.Fields(a.Scope)
+	resp, err := p.accessToken(client, a.Subject, a.Username, a.Scope)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(scopes, ScopeOfflineAccess) && client.AllowsGrant(oauth.GrantRefreshToken) {
+		resp.RefreshToken = tokens.RefreshToken
+	} else if err := p.Users.RevokeToken(ctx, tokens.RefreshToken, oauth.HintRefreshToken); err != nil {
+		return nil, err
+	}
+
+	// 2) The ID token
+	if slices.Contains(scopes, ScopeOpenID) {
+		if resp.IDToken, err = p.idToken(ctx, client, a, resp.AccessToken); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// refreshToken rotates a refresh token issued to client with
+// offline_access; other refresh tokens are invalid_grant.
+func (p *Provider) refreshToken(ctx context.Context, r *http.Request, client *oauth.Client) (*oauth.TokenResponse, error) {
+	rt := r.PostForm.Get("refresh_token")
+	if rt == "" {
+		return nil, oauth.ErrInvalidRequest.WithDescription("refresh_token is required")
+	}
+	tokens, scope, err := p.Users.RefreshClientTokens(ctx, rt, client.ID)
+	if err != nil {
+		return nil, grantError(err)
+	}
+	// The rotated access token names the user for the client's own
+	user, err := p.Users.Authenticate(ctx, tokens.AccessToken)
+	if err != nil {
+		return nil, grantError(err)
+	}
+	resp, err := p.accessToken(client, user.Subject, user.Username, scope)
+	if err != nil {
+		return nil, err
+	}
+	resp.RefreshToken = tokens.RefreshToken
+	return resp, nil
+}
+
+// accessToken signs the access token a user granted client: aud and
+// client_id are the client and scope is what was granted. Its token_use
+// keeps it off the service's own routes.
+func (p *Provider) accessToken(client *oauth.Client, subject, username, scope string) (*oauth.TokenResponse, error) {
+	ttl := p.OAuth.ClientTokenTTL(client)
+	access, err := p.OAuth.Tokens.Sign(jwt.MapClaims{
+		"sub":       subject,
+		"username":  username,
+		"aud":       client.ID,
+		"client_id": client.ID,
+		"scope":     scope,
+		"token_use": oauth.TokenUseDelegated,
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth.TokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// idToken signs the ID token of a for client (OpenID Connect Core 2).
+func (p *Provider) idToken(ctx context.Context, client *oauth.Client, a *authorization, accessToken string) (string, error) {
+	claims, err := p.userClaims(ctx, a.Username, strings.Fields(a.Scope))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(accessToken))
+	claims["sub"] = a.Subject
+	claims["aud"] = client.ID
+	claims["azp"] = client.ID
+	claims["auth_time"] = a.AuthTime.Unix()
+	claims["at_hash"] = base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+	claims["token_use"] = "id"
+	if a.Nonce != "" {
+		claims["nonce"] = a.Nonce
+	}
+	if a.ACR != "" {
+		claims["acr"] = a.ACR
+		claims["amr"] = strings.Fields(a.AMR)
+	}
+	return p.OAuth.Tokens.Sign(claims, p.IDTokenTTL)
+}
+
+// userClaims returns the standard claims of username that scopes release.
+func (p *Provider) userClaims(ctx context.Context, username string, scopes []string) (jwt.MapClaims, error) {
+	var user db.User
+	if err := p.Users.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	if slices.Contains(scopes, ScopeProfile) {
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.ConfirmedAt != nil
+	}
+	if slices.Contains(scopes, ScopePhone) && user.Phone != "" {
+		claims["phone_number"] = user.Phone
+	}
+	return claims, nil
+}
+
+// grantError turns the auth service refusing a user or refresh token into
+// invalid_grant; failures stay server errors.
+func grantError(err error) error {
+	if pr, ok := problem.From(problem.FromCognito(err)); ok && pr.Status < http.StatusInternalServerError {
+		return oauth.ErrInvalidGrant.WithDescription(pr.Title)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return oauth.ErrInvalidGrant.WithDescription("the user no longer exists")
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/oauth"
+)
+
+// UserInfo is the UserInfo endpoint (OpenID Connect Core 5.3): the claims
+// of the user behind a bearer access token. Tokens a user granted a client
+// release the claims of their scopes; the service's own access tokens
+// don't carry scopes, so every standard claim is returned.
+func (p *Provider) UserInfo(c echo.Context) error {
+	tok, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if !ok && c.Request().Method == http.MethodPost {
+		tok = c.FormValue("access_token")
+	}
+	ctx := auth.RequestContext(c)
+	subject, username, scopes, ok := p.userInfoCaller(ctx, tok)
+	if !ok {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	claims, err := p.userClaims(ctx, username, scopes)
+	if err != nil {
+		return err
+	}
+	claims["sub"] = subject
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, claims)
+}
+
+// userInfoCaller resolves the user behind tok and the scopes it releases.
+// Only users have user info; API keys and client tokens don't qualify, nor
+// client-bound tokens without openid or of a disabled user.
+func (p *Provider) userInfoCaller(ctx context.Context, tok string) (subject, username string, scopes []string, ok bool) {
+	if tok == "" {
+		return "", "", nil, false
+	}
+	if claims, err := p.OAuth.Tokens.Verify(tok); err == nil && claims["token_use"] == oauth.TokenUseDelegated {
+		subject, _ = claims["sub"].(string)
+		username, _ = claims["username"].(string)
+		scope, _ := claims["scope"].(string)
+		scopes = strings.Fields(scope)
+		var enabled int64
+		err := p.Users.DB.WithContext(ctx).Model(&db.User{}).
+			Where("username = ? AND disabled_at IS NULL", username).Count(&enabled).Error
+		return subject, username, scopes, err == nil && enabled > 0 && slices.Contains(scopes, ScopeOpenID)
+	}
+	principal, err := p.Users.Authenticate(ctx, tok)
+	if err != nil || principal.APIKey != "" || principal.ClientID != "" {
+		return "", "", nil, false
+	}
+	return principal.Subject, principal.Username, standardScopes, true
+}
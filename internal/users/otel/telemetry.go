@@ -2,22 +2,13 @@ package otel
 
 import (
 	"context"
-	"log"
 
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"simple-go-auth/internal/platform"
+	"simple-go-auth/internal/users/config"
 )
 
-// InitTracer sets up a stdout exporter + tracer provider.
-func InitTracer() func() {
-	exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
-	if err != nil {
-		log.Fatal(err)
-	}
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-	)
-	otel.SetTracerProvider(tp)
-	return func() { _ = tp.Shutdown(context.Background()) }
+// InitTracer sets up tracing for the users service; see platform.InitTelemetry.
+// The returned func flushes pending spans and must be called on shutdown.
+func InitTracer(ctx context.Context, cfg *config.Config) (func(), error) {
+	return platform.InitTelemetry(ctx, cfg.Telemetry)
 }
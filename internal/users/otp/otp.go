@@ -0,0 +1,65 @@
+// Package otp generates and checks short numeric one-time codes.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"time"
+)
+
+// Policy controls how codes are generated and how long they can be used.
+type Policy struct {
+	Digits         int           // default 6
+	TTL            time.Duration // default 5m
+	MaxAttempts    int           // wrong guesses before a code is burned; default 5
+	ResendCooldown time.Duration // minimum gap between codes; default 30s
+	Secret         string        // HMAC key for stored hashes
+}
+
+// WithDefaults fills zero fields with the defaults above.
+func (p Policy) WithDefaults() Policy {
+	if p.Digits == 0 {
+		p.Digits = 6
+	}
+	if p.TTL == 0 {
+		p.TTL = 5 * time.Minute
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 5
+	}
+	if p.ResendCooldown == 0 {
+		p.ResendCooldown = 30 * time.Second
+	}
+	return p
+}
+
+// Generate returns a uniformly random code of p.Digits digits, zero-padded.
+func (p Policy) Generate() (string, error) {
+	digits := p.WithDefaults().Digits
+	buf := make([]byte, digits)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		buf[i] = byte('0' + n.Int64())
+	}
+	return string(buf), nil
+}
+
+// Hash returns the keyed hash stored in place of a code. A plain hash of a
+// six-digit code falls to a lookup table, so the secret must not live in the
+// database.
+func (p Policy) Hash(code string) string {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Equal reports in constant time whether code matches a stored hash.
+func (p Policy) Equal(hash, code string) bool {
+	return hmac.Equal([]byte(hash), []byte(p.Hash(code)))
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sdkconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SNSSink publishes to an SNS topic. On a FIFO topic the aggregate is the
+// message group and the event ID the deduplication ID.
+type SNSSink struct {
+	client   *sns.Client
+	topicARN string
+}
+
+// NewSNSSink creates an SNSSink for topicARN.
+func NewSNSSink(ctx context.Context, region, topicARN string) (*SNSSink, error) {
+	cfg, err := sdkconfig.LoadDefaultConfig(ctx, sdkconfig.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &SNSSink{client: sns.NewFromConfig(cfg), topicARN: topicARN}, nil
+}
+
+// Publish implements Sink.
+func (s *SNSSink) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	in := &sns.PublishInput{
+		TopicArn: aws.String(s.topicARN),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(msg.Type)},
+		},
+	}
+	if strings.HasSuffix(s.topicARN, ".fifo") {
+		in.MessageGroupId = aws.String(msg.AggregateID)
+		in.MessageDeduplicationId = aws.String(msg.ID)
+	}
+	_, err = s.client.Publish(ctx, in)
+	return err
+}
+
+// SQSSink sends to an SQS queue. On a FIFO queue the aggregate is the
+// message group and the event ID the deduplication ID.
+type SQSSink struct {
+	client   *sqs.Client
+	queueURL string
+}
+
+// NewSQSSink creates an SQSSink for queueURL.
+func NewSQSSink(ctx context.Context, region, queueURL string) (*SQSSink, error) {
+	cfg, err := sdkconfig.LoadDefaultConfig(ctx, sdkconfig.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &SQSSink{client: sqs.NewFromConfig(cfg), queueURL: queueURL}, nil
+}
+
+// Publish implements Sink.
+func (s *SQSSink) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	in := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.queueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(msg.Type)},
+		},
+	}
+	if strings.HasSuffix(s.queueURL, ".fifo") {
+		in.MessageGroupId = aws.String(msg.AggregateID)
+		in.MessageDeduplicationId = aws.String(msg.ID)
+	}
+	_, err = s.client.SendMessage(ctx, in)
+	return err
+}
@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+
+	"simple-go-auth/internal/users/config"
+)
+
+// NewSinkFromConfig builds the sink named by OUTBOX_SINK. It returns nil for
+// "none": events then stay in the outbox until a relay is configured.
+func NewSinkFromConfig(ctx context.Context, cfg *config.Config) (Sink, error) {
+	switch cfg.OutboxSink {
+	case "sns":
+		return NewSNSSink(ctx, cfg.AWSRegion, cfg.OutboxSNSTopicARN)
+	case "sqs":
+		return NewSQSSink(ctx, cfg.AWSRegion, cfg.OutboxSQSQueueURL)
+	case "webhook":
+		if cfg.OutboxWebhookSecret == "" {
+			return nil, fmt.Errorf("OUTBOX_WEBHOOK_SECRET is required for the webhook sink")
+		}
+		return NewWebhookSink(cfg.OutboxWebhookURL, cfg.OutboxWebhookSecret), nil
+	case "file":
+		return NewFileSink(cfg.OutboxFile)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown OUTBOX_SINK %q", cfg.OutboxSink)
+	}
+}
@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// ChannelSink hands messages to a Go channel; for tests and in-process consumers.
+type ChannelSink struct {
+	C chan Message
+}
+
+// NewChannelSink creates a ChannelSink with a buffer of size.
+func NewChannelSink(size int) *ChannelSink {
+	return &ChannelSink{C: make(chan Message, size)}
+}
+
+// Publish implements Sink. It blocks until the message is taken or ctx ends.
+func (s *ChannelSink) Publish(ctx context.Context, msg Message) error {
+	select {
+	case s.C <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileSink appends messages to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens (or creates) path for appending.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Publish implements Sink.
+func (s *FileSink) Publish(_ context.Context, msg Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// Compile-time checks.
+var (
+	_ Sink = (*ChannelSink)(nil)
+	_ Sink = (*FileSink)(nil)
+	_ Sink = (*WebhookSink)(nil)
+	_ Sink = (*SNSSink)(nil)
+	_ Sink = (*SQSSink)(nil)
+)
+
+// MultiSink publishes each message to every sink and fails if any does; the
+// relay then retries all of them, so each sink must tolerate duplicates.
+type MultiSink []Sink
+
+var _ Sink = MultiSink(nil)
+
+// Publish implements Sink.
+func (m MultiSink) Publish(ctx context.Context, msg Message) error {
+	var first error
+	for _, s := range m {
+		if err := s.Publish(ctx, msg); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,90 @@
+// Package outbox publishes domain events reliably: events are written to the
+// outbox table in the same transaction as the change they describe, and a
+// Relay delivers them to a Sink at least once, in order per aggregate.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// User lifecycle event types.
+const (
+	UserCreated   = "user.created"
+	UserConfirmed = "user.confirmed"
+	UserChanged   = "user.changed"
+	UserDeleted   = "user.deleted"
+)
+
+// Event is a row of the outbox table.
+type Event struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement"` // publish order
+	EventID       string     `gorm:"size:36;uniqueIndex;not null"`
+	AggregateID   string     `gorm:"index;not null"` // events of one aggregate are delivered in order
+	Type          string     `gorm:"not null"`
+	Payload       string     `gorm:"type:text;not null"`
+	OccurredAt    time.Time  `gorm:"not null"`
+	PublishedAt   *time.Time `gorm:"index"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time
+	LastError     string     `gorm:"type:text;default:''"`
+	LeaseID       string     `gorm:"size:36;index;default:''"` // relay pass publishing the event
+	LeasedUntil   *time.Time `gorm:"index"`
+}
+
+// TableName keeps the table name stable.
+func (Event) TableName() string { return "outbox_events" }
+
+// Migrate creates the outbox table.
+func Migrate(gormDB *gorm.DB) error {
+	return gormDB.AutoMigrate(&Event{})
+}
+
+// Enqueue adds an event to the outbox using tx, which should be the
+// transaction making the change the event describes.
+func Enqueue(tx *gorm.DB, aggregateID, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	return tx.Create(&Event{
+		EventID:       uuid.New().String(),
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       string(payload),
+		OccurredAt:    now,
+		NextAttemptAt: now,
+	}).Error
+}
+
+// Message is an event as delivered to sinks.
+type Message struct {
+	ID          string          `json:"id"` // idempotency key; the same on every redelivery
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	Sequence    uint64          `json:"sequence"` // increases with each event; compare within one aggregate
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+func (e *Event) message() Message {
+	return Message{
+		ID:          e.EventID,
+		Type:        e.Type,
+		AggregateID: e.AggregateID,
+		Sequence:    e.ID,
+		OccurredAt:  e.OccurredAt,
+		Data:        json.RawMessage(e.Payload),
+	}
+}
+
+// Sink publishes messages. Publish may be called again with a message it
+// already accepted, so consumers must deduplicate on Message.ID.
+type Sink interface {
+	Publish(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,216 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Relay defaults.
+const (
+	DefaultBatchSize  = 100
+	DefaultInterval   = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+	DefaultLeaseTTL   = 5 * time.Minute
+)
+
+// publishTimeout bounds a single Publish call.
+const publishTimeout = 30 * time.Second
+
+// relayLock is the Postgres advisory lock key held while claiming a batch;
+// claims are serialized across replicas, publishing is not.
+const relayLock = 0x6f7574626f78 // "outbox"
+
+// Relay moves events from the outbox table to a Sink.
+type Relay struct {
+	DB         *gorm.DB
+	Sink       Sink
+	Logger     *zap.Logger
+	BatchSize  int
+	Interval   time.Duration // poll interval; also the first retry delay
+	MaxBackoff time.Duration
+	LeaseTTL   time.Duration // how long a pass owns its batch; DefaultLeaseTTL when unset
+}
+
+// NewRelay returns a Relay with the default batch size, interval and backoff.
+func NewRelay(gormDB *gorm.DB, sink Sink, logger *zap.Logger) *Relay {
+	return &Relay{
+		DB:         gormDB,
+		Sink:       sink,
+		Logger:     logger,
+		BatchSize:  DefaultBatchSize,
+		Interval:   DefaultInterval,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// Run publishes pending events until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval())
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := r.PublishPending(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				r.Logger.Error("outbox relay failed", zap.Error(err))
+			}
+			// A full batch means there may be more waiting
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending makes one pass over the outbox and returns how many events
+// were published. It leases a batch in a short transaction, publishes with
+// no transaction open, and records each outcome as it goes. An event is held
+// back while an earlier event of the same aggregate is unpublished, so a
+// failing event delays only its own aggregate.
+func (r *Relay) PublishPending(ctx context.Context) (published int, err error) {
+	// 1) Claim a batch
+	lease := uuid.New().String()
+	until := time.Now().Add(r.leaseTTL())
+	batch, err := r.claim(ctx, lease, until)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+	leased := r.DB.WithContext(context.WithoutCancel(ctx)).Model(&Event{}).Where("lease_id = ?", lease)
+	defer func() {
+		// Hand back whatever this pass didn't publish
+		if rerr := leased.Session(&gorm.Session{}).Where("published_at IS NULL").
+			Updates(map[string]any{"lease_id": "", "leased_until": nil}).Error; err == nil {
+			err = rerr
+		}
+	}()
+
+	// 2) Publish in order; a failure blocks the rest of its aggregate
+	now := time.Now()
+	blocked := map[string]bool{}
+	for i := range batch {
+		e := &batch[i]
+		if blocked[e.AggregateID] {
+			continue
+		}
+		if e.NextAttemptAt.After(now) {
+			blocked[e.AggregateID] = true
+			continue
+		}
+		// Stop before the lease can run out mid-publish
+		if time.Now().Add(publishTimeout).After(until) || ctx.Err() != nil {
+			break
+		}
+		pctx, cancel := context.WithTimeout(ctx, publishTimeout)
+		perr := r.Sink.Publish(pctx, e.message())
+		cancel()
+
+		// 3) Record the outcome
+		if perr != nil {
+			blocked[e.AggregateID] = true
+			r.Logger.Warn("outbox publish failed",
+				zap.String("event_id", e.EventID),
+				zap.String("type", e.Type),
+				zap.Int("attempt", e.Attempts+1),
+				zap.Error(perr),
+			)
+			if err := leased.Session(&gorm.Session{}).Where("id = ?", e.ID).Updates(map[string]any{
+				"attempts":        e.Attempts + 1,
+				"next_attempt_at": time.Now().Add(r.backoff(e.Attempts + 1)),
+				"last_error":      perr.Error(),
+			}).Error; err != nil {
+				return published, err
+			}
+			continue
+		}
+		// If this update is lost the event is published again: at least once.
+		if err := leased.Session(&gorm.Session{}).Where("id = ?", e.ID).Updates(map[string]any{
+			"published_at": time.Now(), "lease_id": "", "leased_until": nil,
+		}).Error; err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// claim leases the oldest unpublished events to lease until the given time.
+// Events queued behind another pass's lease on their aggregate are left for
+// a later pass, so each aggregate is published by one pass at a time.
+func (r *Relay) claim(ctx context.Context, lease string, until time.Time) ([]Event, error) {
+	var batch []Event
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			var locked bool
+			if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", relayLock).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if !locked {
+				return nil
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Where("published_at IS NULL AND (leased_until IS NULL OR leased_until <= ?)", now).
+			Where(`NOT EXISTS (SELECT 1 FROM outbox_events AS held
+				WHERE held.aggregate_id = outbox_events.aggregate_id AND held.id < outbox_events.id
+				AND held.published_at IS NULL AND held.leased_until > ?)`, now).
+			Order("id ASC").
+			Limit(r.batchSize()).
+			Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]uint64, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+		}
+		return tx.Model(&Event{}).Where("id IN ?", ids).
+			Updates(map[string]any{"lease_id": lease, "leased_until": until}).Error
+	})
+	return batch, err
+}
+
+// backoff is Interval doubled per failed attempt, capped at MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.interval()
+	limit := r.MaxBackoff
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+	for i := 1; i < attempts && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+func (r *Relay) batchSize() int {
+	if r.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return r.BatchSize
+}
+
+func (r *Relay) leaseTTL() time.Duration {
+	if r.LeaseTTL <= 0 {
+		return DefaultLeaseTTL
+	}
+	return r.LeaseTTL
+}
+
+func (r *Relay) interval() time.Duration {
+	if r.Interval <= 0 {
+		return DefaultInterval
+	}
+	return r.Interval
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook request headers.
+const (
+	HeaderSignature      = "X-Webhook-Signature" // "t=<unix>,v1=<hex hmac>"
+	HeaderTimestamp      = "X-Webhook-Timestamp" // unix seconds, also covered by the signature
+	HeaderID             = "X-Webhook-Id"        // delivery ID; receivers reject ones already seen
+	HeaderIdempotencyKey = "Idempotency-Key"
+)
+
+// ErrInvalidSignature is returned by VerifySignature.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the HeaderSignature value for body sent at ts:
+// HMAC-SHA256(secret, "<unix ts>.<body>").
+func Sign(secret string, ts time.Time, body []byte) string {
+	return SignAll([]string{secret}, ts, body)
+}
+
+// SignAll is Sign with one v1 signature per secret, so receivers still
+// holding a rotated-out secret keep verifying during the grace period.
+func SignAll(secrets []string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	header := "t=" + t
+	for _, secret := range secrets {
+		header += ",v1=" + hex.EncodeToString(signatureMAC(secret, t, body))
+	}
+	return header
+}
+
+// VerifySignature checks a HeaderSignature value against body and rejects
+// timestamps more than tolerance away from now, which bounds replays.
+func VerifySignature(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			if b, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, b)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrInvalidSignature
+	}
+	want := signatureMAC(secret, t, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func signatureMAC(secret, t string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,52 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+)
+
+// Cognito-derived problems.
+var (
+	ErrUserExists            = New(http.StatusConflict, "user_exists", "A user with this username already exists")
+	ErrInvalidCode           = New(http.StatusBadRequest, "invalid_code", "The verification code is invalid")
+	ErrExpiredCode           = New(http.StatusBadRequest, "expired_code", "The verification code has expired")
+	ErrNotAuthorized         = New(http.StatusUnauthorized, "not_authorized", "The credentials or token are not valid")
+	ErrUserNotConfirmed      = New(http.StatusForbidden, "user_not_confirmed", "The user has not confirmed their account")
+	ErrUserNotFound          = New(http.StatusNotFound, "user_not_found", "The user was not found")
+	ErrInvalidPassword       = New(http.StatusBadRequest, "invalid_password", "The password does not meet the password policy")
+	ErrInvalidParameter      = New(http.StatusBadRequest, "invalid_parameter", "A request parameter is invalid")
+	ErrPasswordResetRequired = New(http.StatusForbidden, "password_reset_required", "The password must be reset")
+)
+
+// cognitoErrors maps Cognito exception names to problems.
+var cognitoErrors = map[string]*Problem{
+	"UsernameExistsException":        ErrUserExists,
+	"AliasExistsException":           ErrUserExists,
+	"CodeMismatchException":          ErrInvalidCode,
+	"ExpiredCodeException":           ErrExpiredCode,
+	"NotAuthorizedException":         ErrNotAuthorized,
+	"TooManyRequestsException":       ErrTooManyRequests,
+	"LimitExceededException":         ErrTooManyRequests,
+	"TooManyFailedAttemptsException": ErrTooManyRequests,
+	"UserNotConfirmedException":      ErrUserNotConfirmed,
+	"UserNotFoundException":          ErrUserNotFound,
+	"InvalidPasswordException":       ErrInvalidPassword,
+	"InvalidParameterException":      ErrInvalidParameter,
+	"PasswordResetRequiredException": ErrPasswordResetRequired,
+}
+
+// FromCognito translates a Cognito API error into a problem. Unknown Cognito
+// errors become a 502; anything that is not a Cognito error is returned as is.
+// The raw Cognito message is kept as the cause only, never as the detail.
+func FromCognito(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if p, ok := cognitoErrors[apiErr.ErrorCode()]; ok {
+		return p.Wrap(err)
+	}
+	return ErrUpstream.Wrap(err)
+}
@@ -0,0 +1,81 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// HTTPErrorHandler renders every error returned by a handler or middleware as
+// application/problem+json. Problems are rendered as they are, Cognito errors
+// are mapped with FromCognito, echo.HTTPErrors keep their status, and anything
+// else becomes an opaque 500 that is logged server-side.
+func HTTPErrorHandler(logger *zap.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		p := resolve(err)
+		out := *p
+		out.Instance = c.Request().URL.Path
+		if id, ok := c.Get("request_id").(string); ok {
+			out.RequestID = id
+		}
+
+		if out.Status >= http.StatusInternalServerError {
+			logger.Error("request failed",
+				zap.String("request_id", out.RequestID),
+				zap.String("code", out.Code),
+				zap.Error(err),
+			)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, ContentType)
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(out.Status)
+		} else {
+			err = c.JSON(out.Status, out)
+		}
+		if err != nil {
+			logger.Error("failed to write problem response", zap.Error(err))
+		}
+	}
+}
+
+// resolve turns any error into the problem to render.
+func resolve(err error) *Problem {
+	if p, ok := From(FromCognito(err)); ok {
+		return p
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return fromHTTPError(he)
+	}
+	return ErrInternal
+}
+
+// fromHTTPError converts Echo's own errors (404 routes, body limit, rate limiter, ...).
+func fromHTTPError(he *echo.HTTPError) *Problem {
+	switch he.Code {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrTooManyRequests
+	}
+	if he.Code >= http.StatusInternalServerError {
+		return ErrInternal
+	}
+	title := http.StatusText(he.Code)
+	code := strings.ToLower(strings.ReplaceAll(title, " ", "_"))
+	return New(he.Code, code, title)
+}
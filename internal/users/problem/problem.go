@@ -0,0 +1,92 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+)
+
+// TypeBase prefixes the problem "type" URI; the stable code follows it.
+const TypeBase = "urn:simple-go-auth:problem:"
+
+// ContentType is the RFC 7807 media type.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem-details error. Code is a stable,
+// machine-readable identifier clients can switch on; Title never changes
+// for a given Code, Detail may.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+
+	cause error
+}
+
+// New defines a problem; typically used for package-level sentinel errors.
+func New(status int, code, title string) *Problem {
+	return &Problem{
+		Type:   TypeBase + code,
+		Title:  title,
+		Status: status,
+		Code:   code,
+	}
+}
+
+// Error implements error.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Code + ": " + p.Detail
+	}
+	return p.Code + ": " + p.Title
+}
+
+// Unwrap exposes the underlying cause, if any.
+func (p *Problem) Unwrap() error { return p.cause }
+
+// Is matches any problem with the same code, so errors.Is works on copies
+// made by WithDetail and Wrap.
+func (p *Problem) Is(target error) bool {
+	t, ok := target.(*Problem)
+	return ok && t.Code == p.Code
+}
+
+// WithDetail returns a copy with a human-readable, client-safe detail.
+func (p *Problem) WithDetail(detail string) *Problem {
+	cp := *p
+	cp.Detail = detail
+	return &cp
+}
+
+// Wrap returns a copy that keeps err as its cause for logging and errors.As.
+// The cause is never rendered to the client.
+func (p *Problem) Wrap(err error) *Problem {
+	cp := *p
+	cp.cause = err
+	return &cp
+}
+
+// From returns err as a *Problem if it is (or wraps) one.
+func From(err error) (*Problem, bool) {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p, true
+	}
+	return nil, false
+}
+
+// Generic problems shared across handlers.
+var (
+	ErrBadRequest      = New(http.StatusBadRequest, "bad_request", "The request is invalid")
+	ErrInvalidBody     = New(http.StatusBadRequest, "invalid_request_body", "The request body could not be parsed")
+	ErrUnauthorized    = New(http.StatusUnauthorized, "unauthorized", "Authentication is required")
+	ErrForbidden       = New(http.StatusForbidden, "forbidden", "You are not allowed to perform this action")
+	ErrNotFound        = New(http.StatusNotFound, "not_found", "The resource was not found")
+	ErrConflict        = New(http.StatusConflict, "conflict", "The request conflicts with the current state")
+	ErrTooManyRequests = New(http.StatusTooManyRequests, "too_many_requests", "Too many requests")
+	ErrInternal        = New(http.StatusInternalServerError, "internal_error", "An internal error occurred")
+	ErrUpstream        = New(http.StatusBadGateway, "identity_provider_error", "The identity provider failed to process the request")
+)
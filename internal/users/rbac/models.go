@@ -0,0 +1,36 @@
+// Package rbac stores roles, their permissions and who holds them.
+package rbac
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Role is a named set of permissions. In Cognito each role is a user pool
+// group of the same name.
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"-"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"default:''" json:"description,omitempty"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// Permission is a "resource:action" string such as "users:write".
+type Permission struct {
+	ID   uint   `gorm:"primaryKey" json:"-"`
+	Name string `gorm:"uniqueIndex;not null" json:"name"`
+}
+
+// UserRole grants a role to a user.
+type UserRole struct {
+	UserID    uint `gorm:"primaryKey;autoIncrement:false"`
+	RoleID    uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time
+}
+
+// Migrate creates the RBAC tables.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Permission{}, &Role{}, &UserRole{})
+}
@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Permissions checked by the users service.
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermRolesWrite     = "roles:write"
+	PermWebhooksRead   = "webhooks:read"
+	PermWebhooksWrite  = "webhooks:write"
+	PermAuditRead      = "audit:read"
+	PermConfigRead     = "config:read"
+	PermRelationsRead  = "relations:read"  // check, expand and list objects
+	PermRelationsWrite = "relations:write" // write relation tuples
+	PermClientsRead    = "clients:read"    // OAuth client registry
+	PermClientsWrite   = "clients:write"
+)
+
+// Built-in roles.
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleService = "relations-service"
+)
+
+// RoleSpec is a role as seeded from configuration.
+type RoleSpec struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// DefaultRoles are seeded when no roles file is configured.
+func DefaultRoles() []RoleSpec {
+	return []RoleSpec{
+		{
+			Name:        RoleAdmin,
+			Description: "Full access to the admin API",
+			Permissions: []string{
+				PermUsersRead, PermUsersWrite, PermRolesWrite,
+				PermWebhooksRead, PermWebhooksWrite, PermAuditRead, PermConfigRead,
+				PermRelationsRead, PermRelationsWrite, PermClientsRead, PermClientsWrite,
+			},
+		},
+		{
+			Name:        RoleSupport,
+			Description: "Look up users and the audit log",
+			Permissions: []string{PermUsersRead, PermAuditRead},
+		},
+		{
+			Name:        RoleService,
+			Description: "Product services using the relationship API",
+			Permissions: []string{PermRelationsRead, PermRelationsWrite},
+		},
+	}
+}
+
+// LoadRoles reads a JSON array of RoleSpec from path; an empty path returns
+// DefaultRoles.
+func LoadRoles(path string) ([]RoleSpec, error) {
+	if path == "" {
+		return DefaultRoles(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []RoleSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("rbac roles %s: %w", path, err)
+	}
+	return specs, nil
+}
@@ -0,0 +1,190 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRoleNotFound is returned for role names that were never seeded.
+var ErrRoleNotFound = errors.New("role not found")
+
+// Store reads and changes roles. Role permissions only change through Seed,
+// so they are cached in memory.
+type Store struct {
+	DB *gorm.DB
+
+	mu    sync.RWMutex
+	perms map[string][]string // role name -> permissions; nil until loaded
+}
+
+// NewStore returns a Store on db.
+func NewStore(db *gorm.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Seed creates or updates the given roles and sets their permissions to
+// exactly the listed ones. Roles not listed are left alone.
+func (s *Store) Seed(ctx context.Context, specs []RoleSpec) error {
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, spec := range specs {
+			perms := make([]Permission, 0, len(spec.Permissions))
+			for _, name := range spec.Permissions {
+				p := Permission{Name: name}
+				if err := tx.Where(Permission{Name: name}).FirstOrCreate(&p).Error; err != nil {
+					return err
+				}
+				perms = append(perms, p)
+			}
+			role := Role{Name: spec.Name}
+			if err := tx.Where(Role{Name: spec.Name}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&role).Update("description", spec.Description).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&role).Association("Permissions").Replace(perms); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.mu.Lock()
+	s.perms = nil
+	s.mu.Unlock()
+	return err
+}
+
+// Roles lists every role with its permissions.
+func (s *Store) Roles(ctx context.Context) ([]Role, error) {
+	var roles []Role
+	err := s.DB.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// Permissions returns the union of the permissions of roles, sorted.
+func (s *Store) Permissions(ctx context.Context, roles []string) ([]string, error) {
+	table, err := s.permissionTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, r := range roles {
+		for _, p := range table[r] {
+			if !slices.Contains(out, p) {
+				out = append(out, p)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *Store) permissionTable(ctx context.Context) (map[string][]string, error) {
+	s.mu.RLock()
+	table := s.perms
+	s.mu.RUnlock()
+	if table != nil {
+		return table, nil
+	}
+
+	roles, err := s.Roles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	table = make(map[string][]string, len(roles))
+	for _, r := range roles {
+		names := []string{}
+		for _, p := range r.Permissions {
+			names = append(names, p.Name)
+		}
+		table[r.Name] = names
+	}
+	s.mu.Lock()
+	s.perms = table
+	s.mu.Unlock()
+	return table, nil
+}
+
+// Exists reports whether role was seeded.
+func (s *Store) Exists(ctx context.Context, role string) (bool, error) {
+	table, err := s.permissionTable(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := table[role]
+	return ok, nil
+}
+
+// UserRoles returns the names of the roles held by userID, sorted.
+func (s *Store) UserRoles(ctx context.Context, userID uint) ([]string, error) {
+	var names []string
+	err := s.DB.WithContext(ctx).
+		Model(&Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// Assign grants role to userID; granting a held role is a no-op.
+func (s *Store) Assign(ctx context.Context, userID uint, role string) error {
+	r, err := s.role(ctx, role)
+	if err != nil {
+		return err
+	}
+	return s.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserRole{UserID: userID, RoleID: r.ID}).Error
+}
+
+// Unassign takes role away from userID.
+func (s *Store) Unassign(ctx context.Context, userID uint, role string) error {
+	r, err := s.role(ctx, role)
+	if err != nil {
+		return err
+	}
+	return s.DB.WithContext(ctx).
+		Where("user_id = ? AND role_id = ?", userID, r.ID).
+		Delete(&UserRole{}).Error
+}
+
+// SetUserRoles makes userID hold exactly roles. Unknown role names are
+// ignored, so foreign Cognito groups don't fail a sync.
+func (s *Store) SetUserRoles(ctx context.Context, userID uint, roles []string) error {
+	var ids []uint
+	if len(roles) > 0 {
+		if err := s.DB.WithContext(ctx).Model(&Role{}).Where("name IN ?", roles).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+	}
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := tx.Create(&UserRole{UserID: userID, RoleID: id}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) role(ctx context.Context, name string) (*Role, error) {
+	var r Role
+	err := s.DB.WithContext(ctx).Where("name = ?", name).First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,276 @@
+package rebac
+
+import (
+	"context"
+	"sync"
+)
+
+// checkCache holds check results of one revision. Results never change for
+// a revision, so the cache is only reset when the revision moves on.
+type checkCache struct {
+	mu      sync.Mutex
+	rev     uint64
+	results map[string]bool
+}
+
+func (c *checkCache) get(rev uint64, key string) (allowed, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rev != rev {
+		return false, false
+	}
+	allowed, ok = c.results[key]
+	return allowed, ok
+}
+
+func (c *checkCache) put(rev uint64, key string, allowed bool, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rev < c.rev || size <= 0 {
+		return
+	}
+	if c.results == nil || rev > c.rev || len(c.results) >= size {
+		c.rev, c.results = rev, make(map[string]bool)
+	}
+	c.results[key] = allowed
+}
+
+// Check reports whether subject has relation to object, at a snapshot at
+// least as fresh as z. It returns the zookie of the snapshot used.
+func (s *Store) Check(ctx context.Context, object Object, relation string, subject Subject, z Zookie) (bool, Zookie, error) {
+	if s.Config.rewrite(object.Namespace, relation) == nil {
+		return false, "", ErrUnknownRelation.WithDetail(object.Namespace + "#" + relation)
+	}
+	rev, err := s.snapshot(ctx, z)
+	if err != nil {
+		return false, "", err
+	}
+	allowed, err := s.check(ctx, rev, object, relation, subject, 0)
+	return allowed, encodeZookie(rev), err
+}
+
+func (s *Store) check(ctx context.Context, rev uint64, object Object, relation string, subject Subject, depth int) (bool, error) {
+	if depth > s.MaxDepth {
+		return false, ErrDepthExceeded
+	}
+	// A userset contains itself
+	if subject.Relation == relation && subject.Object() == object {
+		return true, nil
+	}
+	key := object.String() + "#" + relation + "@" + subject.String()
+	if allowed, ok := s.cache.get(rev, key); ok {
+		return allowed, nil
+	}
+	rw := s.Config.rewrite(object.Namespace, relation)
+	if rw == nil {
+		return false, nil
+	}
+	allowed, err := s.eval(ctx, rev, object, relation, rw, subject, depth)
+	if err != nil {
+		return false, err
+	}
+	s.cache.put(rev, key, allowed, s.CacheSize)
+	return allowed, nil
+}
+
+func (s *Store) eval(ctx context.Context, rev uint64, object Object, relation string, rw *Rewrite, subject Subject, depth int) (bool, error) {
+	switch rw.Op {
+	case OpThis:
+		tuples, err := s.read(ctx, rev, object, relation)
+		if err != nil {
+			return false, err
+		}
+		// 1) Direct tuples first; they need no further queries
+		for _, t := range tuples {
+			if t.Subject == subject {
+				return true, nil
+			}
+		}
+		// 2) Then the usersets the relation is granted to
+		for _, t := range tuples {
+			if t.Subject.Relation == "" {
+				continue
+			}
+			if ok, err := s.check(ctx, rev, t.Subject.Object(), t.Subject.Relation, subject, depth+1); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case OpComputed:
+		return s.check(ctx, rev, object, rw.Relation, subject, depth+1)
+
+	case OpTupleToUserset:
+		tuples, err := s.read(ctx, rev, object, rw.Tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, t := range tuples {
+			if ok, err := s.check(ctx, rev, t.Subject.Object(), rw.Relation, subject, depth+1); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case OpUnion:
+		for _, c := range rw.Children {
+			if ok, err := s.eval(ctx, rev, object, relation, c, subject, depth); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case OpIntersection:
+		for _, c := range rw.Children {
+			if ok, err := s.eval(ctx, rev, object, relation, c, subject, depth); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case OpExclusion:
+		ok, err := s.eval(ctx, rev, object, relation, rw.Children[0], subject, depth)
+		if !ok || err != nil {
+			return false, err
+		}
+		excluded, err := s.eval(ctx, rev, object, relation, rw.Children[1], subject, depth)
+		return !excluded, err
+	}
+	return false, nil
+}
+
+// Tree is the userset of a relation as Expand returns it. Leaves list the
+// subjects stored for a relation; usersets among them are not expanded.
+type Tree struct {
+	Op       string   `json:"op"` // a Rewrite operator
+	Object   string   `json:"object,omitempty"`
+	Relation string   `json:"relation,omitempty"`
+	Subjects []string `json:"subjects,omitempty"` // OpThis
+	Children []*Tree  `json:"children,omitempty"`
+}
+
+// Expand returns the tree of usersets that make up object#relation.
+func (s *Store) Expand(ctx context.Context, object Object, relation string, z Zookie) (*Tree, Zookie, error) {
+	rw := s.Config.rewrite(object.Namespace, relation)
+	if rw == nil {
+		return nil, "", ErrUnknownRelation.WithDetail(object.Namespace + "#" + relation)
+	}
+	rev, err := s.snapshot(ctx, z)
+	if err != nil {
+		return nil, "", err
+	}
+	tree, err := s.expand(ctx, rev, object, relation, rw, 0)
+	return tree, encodeZookie(rev), err
+}
+
+func (s *Store) expand(ctx context.Context, rev uint64, object Object, relation string, rw *Rewrite, depth int) (*Tree, error) {
+	if depth > s.MaxDepth {
+		return nil, ErrDepthExceeded
+	}
+	node := &Tree{Op: rw.Op, Object: object.String(), Relation: relation}
+	switch rw.Op {
+	case OpThis:
+		tuples, err := s.read(ctx, rev, object, relation)
+		if err != nil {
+			return nil, err
+		}
+		node.Subjects = []string{}
+		for _, t := range tuples {
+			node.Subjects = append(node.Subjects, t.Subject.String())
+		}
+
+	case OpComputed:
+		child, err := s.expandRelation(ctx, rev, object, rw.Relation, depth)
+		if err != nil || child == nil {
+			return child, err
+		}
+		node.Children = []*Tree{child}
+
+	case OpTupleToUserset:
+		tuples, err := s.read(ctx, rev, object, rw.Tupleset)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tuples {
+			child, err := s.expandRelation(ctx, rev, t.Subject.Object(), rw.Relation, depth)
+			if err != nil {
+				return nil, err
+			}
+			if child != nil {
+				node.Children = append(node.Children, child)
+			}
+		}
+
+	default:
+		for _, c := range rw.Children {
+			child, err := s.expand(ctx, rev, object, relation, c, depth)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+	}
+	return node, nil
+}
+
+// expandRelation expands object#relation, or returns nil when the
+// namespace does not define it.
+func (s *Store) expandRelation(ctx context.Context, rev uint64, object Object, relation string, depth int) (*Tree, error) {
+	rw := s.Config.rewrite(object.Namespace, relation)
+	if rw == nil {
+		return nil, nil
+	}
+	return s.expand(ctx, rev, object, relation, rw, depth+1)
+}
+
+// ObjectPage is one page of ListObjects.
+type ObjectPage struct {
+	Objects   []string `json:"objects"`
+	NextAfter string   `json:"next_after,omitempty"` // pass as after for the next page
+	Zookie    Zookie   `json:"zookie"`
+}
+
+// ListObjects returns the objects of namespace that subject has relation
+// to, ordered by ID, starting after the ID after. It checks each object
+// with tuples in the namespace, so pages cost up to one check per object.
+func (s *Store) ListObjects(ctx context.Context, namespace, relation string, subject Subject, after string, limit int, z Zookie) (*ObjectPage, error) {
+	if s.Config.rewrite(namespace, relation) == nil {
+		return nil, ErrUnknownRelation.WithDetail(namespace + "#" + relation)
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	rev, err := s.snapshot(ctx, z)
+	if err != nil {
+		return nil, err
+	}
+	page := &ObjectPage{Objects: []string{}, Zookie: encodeZookie(rev)}
+	const batch = 500
+	for {
+		var ids []string
+		if err := live(s.DB.WithContext(ctx).Model(&RelationTuple{}), rev).
+			Where("namespace = ? AND object_id > ?", namespace, after).
+			Distinct("object_id").Order("object_id").Limit(batch).
+			Pluck("object_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			after = id
+			ok, err := s.check(ctx, rev, Object{Namespace: namespace, ID: id}, relation, subject, 0)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			page.Objects = append(page.Objects, namespace+":"+id)
+			if len(page.Objects) == limit {
+				page.NextAfter = id
+				return page, nil
+			}
+		}
+		if len(ids) < batch {
+			return page, nil
+		}
+	}
+}
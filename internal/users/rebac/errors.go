@@ -0,0 +1,15 @@
+package rebac
+
+import (
+	"net/http"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// Relationship errors; they render as application/problem+json.
+var (
+	ErrInvalidTuple    = problem.New(http.StatusBadRequest, "invalid_relation_tuple", "Invalid relation tuple")
+	ErrUnknownRelation = problem.New(http.StatusBadRequest, "unknown_relation", "The namespace configuration does not define this relation")
+	ErrInvalidZookie   = problem.New(http.StatusBadRequest, "invalid_zookie", "Invalid consistency token")
+	ErrDepthExceeded   = problem.New(http.StatusUnprocessableEntity, "check_depth_exceeded", "The relation graph is too deep to evaluate")
+)
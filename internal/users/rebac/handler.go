@@ -0,0 +1,135 @@
+package rebac
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// Handler serves the relationship API to product services.
+type Handler struct {
+	Store *Store
+}
+
+// RegisterRoutes mounts the API on g, which should already authenticate
+// the caller; read guards the queries and write guards tuple changes.
+func (h *Handler) RegisterRoutes(g *echo.Group, read, write echo.MiddlewareFunc) {
+	g.POST("/relation-tuples", h.Write, write)
+	g.POST("/check", h.Check, read)
+	g.POST("/expand", h.Expand, read)
+	g.POST("/list-objects", h.ListObjects, read)
+}
+
+// Write applies {"writes": [...], "deletes": [...]} of tuples such as
+// "document:readme#viewer@user:alice" and returns the new zookie.
+func (h *Handler) Write(c echo.Context) error {
+	var req struct {
+		Writes  []string `json:"writes"`
+		Deletes []string `json:"deletes"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	writes, err := parseTuples(req.Writes)
+	if err != nil {
+		return err
+	}
+	deletes, err := parseTuples(req.Deletes)
+	if err != nil {
+		return err
+	}
+	if len(writes)+len(deletes) == 0 {
+		return ErrInvalidTuple.WithDetail("no tuples given")
+	}
+	z, err := h.Store.Write(c.Request().Context(), writes, deletes)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"zookie": z})
+}
+
+// Check answers {"object", "relation", "subject", "zookie"}.
+func (h *Handler) Check(c echo.Context) error {
+	var req struct {
+		Object   string `json:"object"`
+		Relation string `json:"relation"`
+		Subject  string `json:"subject"`
+		Zookie   Zookie `json:"zookie"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	object, err := ParseObject(req.Object)
+	if err != nil {
+		return err
+	}
+	subject, err := ParseSubject(req.Subject)
+	if err != nil {
+		return err
+	}
+	allowed, z, err := h.Store.Check(c.Request().Context(), object, req.Relation, subject, req.Zookie)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"allowed": allowed, "zookie": z})
+}
+
+// Expand answers {"object", "relation", "zookie"} with the userset tree.
+func (h *Handler) Expand(c echo.Context) error {
+	var req struct {
+		Object   string `json:"object"`
+		Relation string `json:"relation"`
+		Zookie   Zookie `json:"zookie"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	object, err := ParseObject(req.Object)
+	if err != nil {
+		return err
+	}
+	tree, z, err := h.Store.Expand(c.Request().Context(), object, req.Relation, req.Zookie)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"tree": tree, "zookie": z})
+}
+
+// ListObjects answers {"namespace", "relation", "subject", "after",
+// "limit", "zookie"}.
+func (h *Handler) ListObjects(c echo.Context) error {
+	var req struct {
+		Namespace string `json:"namespace"`
+		Relation  string `json:"relation"`
+		Subject   string `json:"subject"`
+		After     string `json:"after"`
+		Limit     int    `json:"limit"`
+		Zookie    Zookie `json:"zookie"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+	subject, err := ParseSubject(req.Subject)
+	if err != nil {
+		return err
+	}
+	page, err := h.Store.ListObjects(c.Request().Context(), req.Namespace, req.Relation, subject, req.After, req.Limit, req.Zookie)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+func parseTuples(in []string) ([]Tuple, error) {
+	out := make([]Tuple, 0, len(in))
+	for _, s := range in {
+		t, err := ParseTuple(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package rebac
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RelationTuple is a stored tuple. Tuples are never updated in place: a
+// write creates a row at a revision and a delete marks it deleted at a
+// later one, so every revision is a consistent snapshot.
+type RelationTuple struct {
+	ID               uint64  `gorm:"primaryKey;autoIncrement"`
+	Namespace        string  `gorm:"size:64;not null;index:idx_tuple_object,priority:1"`
+	ObjectID         string  `gorm:"size:255;not null;index:idx_tuple_object,priority:2"`
+	Relation         string  `gorm:"size:64;not null;index:idx_tuple_object,priority:3"`
+	SubjectNamespace string  `gorm:"size:64;not null;index:idx_tuple_subject,priority:1"`
+	SubjectID        string  `gorm:"size:255;not null;index:idx_tuple_subject,priority:2"`
+	SubjectRelation  string  `gorm:"size:64;not null;default:''"` // empty for a single subject
+	CreatedRev       uint64  `gorm:"not null"`
+	DeletedRev       *uint64 `gorm:"index"` // nil while the tuple exists
+}
+
+// TableName keeps the table name stable.
+func (RelationTuple) TableName() string { return "relation_tuples" }
+
+func (t *RelationTuple) tuple() Tuple {
+	return Tuple{
+		Object:   Object{Namespace: t.Namespace, ID: t.ObjectID},
+		Relation: t.Relation,
+		Subject:  Subject{Namespace: t.SubjectNamespace, ID: t.SubjectID, Relation: t.SubjectRelation},
+	}
+}
+
+// Revision is the single-row counter of tuple revisions. Writers increment
+// it first, so they hold its row lock until commit and revisions become
+// visible in order.
+type Revision struct {
+	ID    uint   `gorm:"primaryKey;autoIncrement:false"`
+	Value uint64 `gorm:"not null;default:0"`
+}
+
+// TableName keeps the table name stable.
+func (Revision) TableName() string { return "relation_revision" }
+
+// Migrate creates the relationship tables.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&RelationTuple{}, &Revision{}); err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Revision{ID: 1}).Error
+}
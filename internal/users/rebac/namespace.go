@@ -0,0 +1,315 @@
+package rebac
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Rewrite operators of a relation.
+const (
+	OpThis           = "this"             // tuples stored for the relation itself
+	OpComputed       = "computed_userset" // another relation of the same object
+	OpTupleToUserset = "tuple_to_userset" // a relation of the objects a tupleset relation points to
+	OpUnion          = "union"
+	OpIntersection   = "intersection"
+	OpExclusion      = "exclusion"
+)
+
+// Rewrite says how a relation is computed.
+type Rewrite struct {
+	Op       string
+	Relation string     // OpComputed and OpTupleToUserset: the relation evaluated
+	Tupleset string     // OpTupleToUserset: the relation whose subjects are followed
+	Children []*Rewrite // OpUnion, OpIntersection, OpExclusion (base, subtracted)
+}
+
+// Namespace is an object type and its relations.
+type Namespace struct {
+	Name      string
+	Relations map[string]*Rewrite
+}
+
+// Config is the parsed namespace configuration.
+type Config struct {
+	Namespaces map[string]*Namespace
+}
+
+// rewrite returns the rewrite of namespace#relation, or nil.
+func (c *Config) rewrite(namespace, relation string) *Rewrite {
+	ns := c.Namespaces[namespace]
+	if ns == nil {
+		return nil
+	}
+	return ns.Relations[relation]
+}
+
+// LoadConfig parses the namespace configuration in path.
+func LoadConfig(path string) (*Config, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig parses namespace definitions such as
+//
+//	namespace user {}
+//
+//	namespace document {
+//	  relation parent
+//	  relation owner
+//	  relation editor: this | owner
+//	  relation viewer: this | editor | parent->viewer
+//	  relation commenter: viewer - banned
+//	  relation banned
+//	}
+//
+// A relation without an expression stores tuples directly ("this"). In
+// expressions "|" is union, "&" intersection and "-" exclusion, in order
+// of increasing precedence; parentheses group. "parent->viewer" is the
+// viewer relation of every object in this object's parent relation.
+// Comments start with "#" or "//".
+func ParseConfig(src string) (*Config, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	cfg := &Config{Namespaces: map[string]*Namespace{}}
+	for !p.done() {
+		ns, err := p.namespace()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Namespaces[ns.Name] != nil {
+			return nil, fmt.Errorf("namespace %s defined twice", ns.Name)
+		}
+		cfg.Namespaces[ns.Name] = ns
+	}
+	for _, ns := range cfg.Namespaces {
+		for name, rw := range ns.Relations {
+			if err := validate(ns, rw); err != nil {
+				return nil, fmt.Errorf("%s#%s: %w", ns.Name, name, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// validate checks that rw only refers to relations of ns. The relation at
+// the far end of a tupleset depends on the tuples, so it is not checked.
+func validate(ns *Namespace, rw *Rewrite) error {
+	switch rw.Op {
+	case OpComputed:
+		if ns.Relations[rw.Relation] == nil {
+			return fmt.Errorf("unknown relation %s", rw.Relation)
+		}
+	case OpTupleToUserset:
+		if ns.Relations[rw.Tupleset] == nil {
+			return fmt.Errorf("unknown relation %s", rw.Tupleset)
+		}
+	}
+	for _, c := range rw.Children {
+		if err := validate(ns, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type token struct {
+	text string
+	line int
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "->"):
+			toks = append(toks, token{"->", line})
+			i += 2
+		case strings.ContainsRune("{}:|&-()", rune(c)):
+			toks = append(toks, token{string(c), line})
+			i++
+		case isIdent(rune(c)):
+			j := i
+			for j < len(src) && isIdent(rune(src[j])) {
+				j++
+			}
+			toks = append(toks, token{src[i:j], line})
+			i = j
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", line, c)
+		}
+	}
+	return toks, nil
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.toks) }
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.toks[p.pos].text
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line := 0
+	if n := len(p.toks); n > 0 {
+		line = p.toks[min(p.pos, n-1)].line
+	}
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(text string) error {
+	if p.peek() != text {
+		return p.errorf("expected %q, found %q", text, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t == "" || !isIdent(rune(t[0])) {
+		return "", p.errorf("expected a name, found %q", t)
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *parser) namespace() (*Namespace, error) {
+	if err := p.expect("namespace"); err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	ns := &Namespace{Name: name, Relations: map[string]*Rewrite{}}
+	for p.peek() != "}" {
+		if err := p.expect("relation"); err != nil {
+			return nil, err
+		}
+		rel, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if ns.Relations[rel] != nil {
+			return nil, p.errorf("relation %s#%s defined twice", name, rel)
+		}
+		rw := &Rewrite{Op: OpThis}
+		if p.peek() == ":" {
+			p.pos++
+			if rw, err = p.union(); err != nil {
+				return nil, err
+			}
+		}
+		ns.Relations[rel] = rw
+	}
+	return ns, p.expect("}")
+}
+
+// union, intersection and exclusion parse binary expressions, loosest first.
+func (p *parser) union() (*Rewrite, error) {
+	return p.binary("|", OpUnion, p.intersection)
+}
+
+func (p *parser) intersection() (*Rewrite, error) {
+	return p.binary("&", OpIntersection, p.exclusion)
+}
+
+func (p *parser) exclusion() (*Rewrite, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "-" {
+		p.pos++
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Rewrite{Op: OpExclusion, Children: []*Rewrite{left, right}}
+	}
+	return left, nil
+}
+
+func (p *parser) binary(sym, op string, next func() (*Rewrite, error)) (*Rewrite, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Rewrite{first}
+	for p.peek() == sym {
+		p.pos++
+		c, err := next()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, c)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Rewrite{Op: op, Children: children}, nil
+}
+
+func (p *parser) primary() (*Rewrite, error) {
+	if p.peek() == "(" {
+		p.pos++
+		rw, err := p.union()
+		if err != nil {
+			return nil, err
+		}
+		return rw, p.expect(")")
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if name == "this" {
+		return &Rewrite{Op: OpThis}, nil
+	}
+	if p.peek() == "->" {
+		p.pos++
+		rel, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		return &Rewrite{Op: OpTupleToUserset, Tupleset: name, Relation: rel}, nil
+	}
+	return &Rewrite{Op: OpComputed, Relation: name}, nil
+}
@@ -0,0 +1,198 @@
+package rebac
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Zookie is an opaque consistency token naming the revision a write
+// produced or a check was evaluated at. Passing it back guarantees the
+// evaluation sees at least that revision.
+type Zookie string
+
+func encodeZookie(rev uint64) Zookie {
+	b := binary.BigEndian.AppendUint64(nil, rev)
+	return Zookie(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (z Zookie) revision() (uint64, error) {
+	if z == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(string(z))
+	if err != nil || len(b) != 8 {
+		return 0, ErrInvalidZookie
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Store keeps relation tuples and answers Check, Expand and ListObjects.
+type Store struct {
+	DB     *gorm.DB
+	Config *Config
+	// Staleness is how old a snapshot may be when the caller passes no
+	// zookie. Default 1s.
+	Staleness time.Duration
+	// CacheSize bounds the check results cached for the current revision.
+	// Default 10000; negative disables the cache.
+	CacheSize int
+	// MaxDepth bounds the nesting of usersets a check follows. Default 25.
+	MaxDepth int
+
+	mu       sync.Mutex
+	latest   uint64
+	loadedAt time.Time
+	cache    checkCache
+}
+
+// NewStore returns a Store on db for the namespaces in cfg.
+func NewStore(db *gorm.DB, cfg *Config) *Store {
+	return &Store{DB: db, Config: cfg, Staleness: time.Second, CacheSize: 10000, MaxDepth: 25}
+}
+
+// Write applies writes and deletes atomically and returns the zookie of
+// the new revision. Writing an existing tuple or deleting a missing one is
+// not an error.
+func (s *Store) Write(ctx context.Context, writes, deletes []Tuple) (Zookie, error) {
+	for _, t := range append(append([]Tuple{}, writes...), deletes...) {
+		if err := s.validate(t); err != nil {
+			return "", err
+		}
+	}
+	var rev uint64
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rev, err = nextRevision(tx); err != nil {
+			return err
+		}
+		for _, t := range deletes {
+			if err := live(tx.Model(&RelationTuple{}), rev).
+				Where("namespace = ? AND object_id = ? AND relation = ?", t.Object.Namespace, t.Object.ID, t.Relation).
+				Where("subject_namespace = ? AND subject_id = ? AND subject_relation = ?", t.Subject.Namespace, t.Subject.ID, t.Subject.Relation).
+				Update("deleted_rev", rev).Error; err != nil {
+				return err
+			}
+		}
+		for _, t := range writes {
+			row := RelationTuple{
+				Namespace: t.Object.Namespace, ObjectID: t.Object.ID, Relation: t.Relation,
+				SubjectNamespace: t.Subject.Namespace, SubjectID: t.Subject.ID, SubjectRelation: t.Subject.Relation,
+				CreatedRev: rev,
+			}
+			var n int64
+			if err := live(tx.Model(&RelationTuple{}), rev).Where(&row, "Namespace", "ObjectID", "Relation",
+				"SubjectNamespace", "SubjectID", "SubjectRelation").Count(&n).Error; err != nil {
+				return err
+			}
+			if n == 0 {
+				if err := tx.Create(&row).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	s.advance(rev)
+	return encodeZookie(rev), nil
+}
+
+// DeleteSubject deletes every tuple naming subject, with any relation, in
+// the caller's transaction. Used when the subject itself is deleted.
+func (s *Store) DeleteSubject(tx *gorm.DB, subject Object) error {
+	rev, err := nextRevision(tx)
+	if err != nil {
+		return err
+	}
+	return live(tx.Model(&RelationTuple{}), rev).
+		Where("subject_namespace = ? AND subject_id = ?", subject.Namespace, subject.ID).
+		Update("deleted_rev", rev).Error
+}
+
+func (s *Store) validate(t Tuple) error {
+	if s.Config.rewrite(t.Object.Namespace, t.Relation) == nil {
+		return ErrUnknownRelation.WithDetail(t.Object.Namespace + "#" + t.Relation)
+	}
+	if s.Config.Namespaces[t.Subject.Namespace] == nil {
+		return ErrUnknownRelation.WithDetail("unknown namespace " + t.Subject.Namespace)
+	}
+	if t.Subject.Relation != "" && s.Config.rewrite(t.Subject.Namespace, t.Subject.Relation) == nil {
+		return ErrUnknownRelation.WithDetail(t.Subject.Namespace + "#" + t.Subject.Relation)
+	}
+	return nil
+}
+
+// nextRevision increments the revision counter in tx and returns the new
+// value.
+func nextRevision(tx *gorm.DB) (uint64, error) {
+	if err := tx.Model(&Revision{}).Where("id = 1").Update("value", gorm.Expr("value + 1")).Error; err != nil {
+		return 0, err
+	}
+	var r Revision
+	err := tx.Where("id = 1").First(&r).Error
+	return r.Value, err
+}
+
+// live restricts q to the tuples existing at rev.
+func live(q *gorm.DB, rev uint64) *gorm.DB {
+	return q.Where("created_rev <= ? AND (deleted_rev IS NULL OR deleted_rev > ?)", rev, rev)
+}
+
+// snapshot returns the revision to evaluate at: the latest one known, no
+// older than Staleness and no older than z.
+func (s *Store) snapshot(ctx context.Context, z Zookie) (uint64, error) {
+	want, err := z.revision()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	latest, fresh := s.latest, time.Since(s.loadedAt) <= s.Staleness
+	s.mu.Unlock()
+	if fresh && latest >= want {
+		return latest, nil
+	}
+
+	var r Revision
+	if err := s.DB.WithContext(ctx).Where("id = 1").First(&r).Error; err != nil {
+		return 0, err
+	}
+	if r.Value < want {
+		return 0, ErrInvalidZookie.WithDetail("the zookie is from the future")
+	}
+	s.mu.Lock()
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	s.advance(r.Value)
+	return r.Value, nil
+}
+
+// advance records rev as written; the cache only holds the latest revision.
+func (s *Store) advance(rev uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rev > s.latest {
+		s.latest = rev
+	}
+}
+
+// read returns the tuples of object#relation at rev.
+func (s *Store) read(ctx context.Context, rev uint64, object Object, relation string) ([]Tuple, error) {
+	var rows []RelationTuple
+	if err := live(s.DB.WithContext(ctx), rev).
+		Where("namespace = ? AND object_id = ? AND relation = ?", object.Namespace, object.ID, relation).
+		Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Tuple, len(rows))
+	for i := range rows {
+		out[i] = rows[i].tuple()
+	}
+	return out, nil
+}
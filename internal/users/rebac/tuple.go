@@ -0,0 +1,87 @@
+package rebac
+
+import (
+	"regexp"
+	"strings"
+)
+
+// UserNamespace is the namespace of the service's own users; their IDs are
+// usernames.
+const UserNamespace = "user"
+
+// Object is "namespace:id", e.g. "document:readme".
+type Object struct {
+	Namespace string
+	ID        string
+}
+
+func (o Object) String() string { return o.Namespace + ":" + o.ID }
+
+// Subject is a single object ("user:alice") or, with a relation, the set
+// of subjects having that relation to the object ("group:eng#member").
+type Subject struct {
+	Namespace string
+	ID        string
+	Relation  string
+}
+
+func (s Subject) String() string {
+	if s.Relation == "" {
+		return s.Namespace + ":" + s.ID
+	}
+	return s.Namespace + ":" + s.ID + "#" + s.Relation
+}
+
+// Object returns the object part of s.
+func (s Subject) Object() Object { return Object{Namespace: s.Namespace, ID: s.ID} }
+
+// Tuple states that Subject has Relation to Object:
+// "document:readme#editor@group:eng#member".
+type Tuple struct {
+	Object   Object
+	Relation string
+	Subject  Subject
+}
+
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ParseObject parses "namespace:id". IDs cannot contain "#" or "@".
+func ParseObject(s string) (Object, error) {
+	ns, id, ok := strings.Cut(s, ":")
+	if !ok || !nameRe.MatchString(ns) || id == "" || strings.ContainsAny(id, "#@") {
+		return Object{}, ErrInvalidTuple.WithDetail("invalid object " + s)
+	}
+	return Object{Namespace: ns, ID: id}, nil
+}
+
+// ParseSubject parses "namespace:id" or "namespace:id#relation".
+func ParseSubject(s string) (Subject, error) {
+	obj, rel, hasRel := strings.Cut(s, "#")
+	o, err := ParseObject(obj)
+	if err != nil || hasRel && !nameRe.MatchString(rel) {
+		return Subject{}, ErrInvalidTuple.WithDetail("invalid subject " + s)
+	}
+	return Subject{Namespace: o.Namespace, ID: o.ID, Relation: rel}, nil
+}
+
+// ParseTuple parses "namespace:id#relation@subject".
+func ParseTuple(s string) (Tuple, error) {
+	left, subject, ok := strings.Cut(s, "@")
+	obj, rel, ok2 := strings.Cut(left, "#")
+	if !ok || !ok2 || !nameRe.MatchString(rel) {
+		return Tuple{}, ErrInvalidTuple.WithDetail("invalid tuple " + s)
+	}
+	o, err := ParseObject(obj)
+	if err != nil {
+		return Tuple{}, err
+	}
+	sub, err := ParseSubject(subject)
+	if err != nil {
+		return Tuple{}, err
+	}
+	return Tuple{Object: o, Relation: rel, Subject: sub}, nil
+}
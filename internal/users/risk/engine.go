@@ -0,0 +1,41 @@
+package risk
+
+// Engine bundles the rules with the lookups used to collect Signals.
+type Engine struct {
+	Rules      Rules
+	Geo        GeoIP            // optional; impossible travel needs it
+	Reputation *ReputationLists // optional
+}
+
+// NewEngine loads the rules file, GeoIP database and reputation lists; empty
+// paths disable the corresponding signals.
+func NewEngine(rulesFile, geoIPPath string, reputationFiles []string) (*Engine, error) {
+	rules, err := LoadRules(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{Rules: rules}
+	if geoIPPath != "" {
+		geo, err := OpenMaxMind(geoIPPath)
+		if err != nil {
+			return nil, err
+		}
+		e.Geo = geo
+	}
+	if len(reputationFiles) > 0 {
+		rep, err := LoadReputationFiles(reputationFiles...)
+		if err != nil {
+			return nil, err
+		}
+		e.Reputation = rep
+	}
+	return e, nil
+}
+
+// Locate looks ip up when a GeoIP database is loaded.
+func (e *Engine) Locate(ip string) (Location, bool) {
+	if e.Geo == nil || ip == "" {
+		return Location{}, false
+	}
+	return e.Geo.Lookup(ip)
+}
@@ -0,0 +1,80 @@
+package risk
+
+import (
+	"math"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is where an IP address is.
+type Location struct {
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+// GeoIP resolves IP addresses to locations.
+type GeoIP interface {
+	Lookup(ip string) (Location, bool)
+}
+
+// MaxMindDB reads a MaxMind-format (GeoIP2/GeoLite2 City) database.
+type MaxMindDB struct {
+	reader *maxminddb.Reader
+}
+
+var _ GeoIP = (*MaxMindDB)(nil)
+
+// OpenMaxMind loads the .mmdb file at path into memory.
+func OpenMaxMind(path string) (*MaxMindDB, error) {
+	r, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindDB{reader: r}, nil
+}
+
+// Close releases the database.
+func (m *MaxMindDB) Close() error { return m.reader.Close() }
+
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// Lookup returns the location of ip; false when unknown or without coordinates.
+func (m *MaxMindDB) Lookup(ip string) (Location, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Location{}, false
+	}
+	var rec cityRecord
+	if err := m.reader.Lookup(addr, &rec); err != nil {
+		return Location{}, false
+	}
+	if rec.Location.Latitude == 0 && rec.Location.Longitude == 0 {
+		return Location{}, false
+	}
+	return Location{
+		Country:   rec.Country.ISOCode,
+		Latitude:  rec.Location.Latitude,
+		Longitude: rec.Location.Longitude,
+	}, true
+}
+
+// DistanceKm is the great-circle distance between a and b.
+func DistanceKm(a, b Location) float64 {
+	const earthRadiusKm = 6371.0
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(b.Latitude - a.Latitude)
+	dLon := rad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(a.Latitude))*math.Cos(rad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,89 @@
+package risk
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReputationLists holds named lists of bad IP addresses and networks.
+type ReputationLists struct {
+	lists map[string][]*net.IPNet
+}
+
+// NewReputationLists returns empty lists; add entries with Add.
+func NewReputationLists() *ReputationLists {
+	return &ReputationLists{lists: map[string][]*net.IPNet{}}
+}
+
+// LoadReputationFiles reads one list per file, named after the file. Each
+// line holds an IP or CIDR; blank lines and "#" comments are skipped.
+func LoadReputationFiles(paths ...string) (*ReputationLists, error) {
+	r := NewReputationLists()
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		sc := bufio.NewScanner(f)
+		for n := 1; sc.Scan(); n++ {
+			line, _, _ := strings.Cut(sc.Text(), "#")
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if err := r.Add(name, line); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: %w", p, n, err)
+			}
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add puts an IP or CIDR on list name.
+func (r *ReputationLists) Add(name, entry string) error {
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return fmt.Errorf("invalid IP %q", entry)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		r.lists[name] = append(r.lists[name], &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		return nil
+	}
+	_, n, err := net.ParseCIDR(entry)
+	if err != nil {
+		return err
+	}
+	r.lists[name] = append(r.lists[name], n)
+	return nil
+}
+
+// Match returns the name of a list containing ip, or "".
+func (r *ReputationLists) Match(ip string) string {
+	addr := net.ParseIP(ip)
+	if r == nil || addr == nil {
+		return ""
+	}
+	for name, nets := range r.lists {
+		for _, n := range nets {
+			if n.Contains(addr) {
+				return name
+			}
+		}
+	}
+	return ""
+}
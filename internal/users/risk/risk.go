@@ -0,0 +1,173 @@
+// Package risk scores sign-in attempts and decides whether to allow them,
+// ask for a second factor or block them.
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Decision is the outcome of an assessment.
+type Decision string
+
+const (
+	Allow  Decision = "allow"
+	StepUp Decision = "step_up"
+	Block  Decision = "block"
+)
+
+// Reasons reported in an Assessment.
+const (
+	ReasonNewDevice        = "new_device"
+	ReasonImpossibleTravel = "impossible_travel"
+	ReasonIPReputation     = "ip_reputation"
+	ReasonFailedVelocity   = "failed_attempt_velocity"
+	ReasonUnusualHour      = "unusual_hour"
+)
+
+// Signals are the facts known about one sign-in attempt.
+type Signals struct {
+	NewDevice      bool
+	ListedIn       string  // reputation list containing the IP; empty if none
+	RecentFailures int     // failed attempts for the user or IP within Rules.VelocityWindow
+	TravelSpeedKmh float64 // implied speed from the previous sign-in; 0 if unknown
+	UnusualHour    bool
+}
+
+// Assessment is a scored Signals.
+type Assessment struct {
+	Score    int      `json:"score"`
+	Decision Decision `json:"decision"`
+	Reasons  []string `json:"reasons"`
+}
+
+// Rules weigh each signal and set the decision thresholds. Scores add up.
+type Rules struct {
+	NewDeviceWeight        int      `json:"new_device_weight"`
+	ImpossibleTravelWeight int      `json:"impossible_travel_weight"`
+	IPReputationWeight     int      `json:"ip_reputation_weight"`
+	FailedVelocityWeight   int      `json:"failed_velocity_weight"`
+	UnusualHourWeight      int      `json:"unusual_hour_weight"`
+	MaxTravelSpeedKmh      float64  `json:"max_travel_speed_kmh"` // faster than this is impossible travel
+	VelocityWindow         Duration `json:"velocity_window"`
+	VelocityThreshold      int      `json:"velocity_threshold"` // failures in the window that count
+	UsualHourMinHistory    int      `json:"usual_hour_min_history"`
+	UsualHourTolerance     int      `json:"usual_hour_tolerance"` // hours either side of a past sign-in
+	StepUpAt               int      `json:"step_up_at"`
+	BlockAt                int      `json:"block_at"`
+	HistoryWindow          Duration `json:"history_window"` // how far back sign-ins are considered
+}
+
+// DefaultRules are used for anything a rules file leaves out.
+func DefaultRules() Rules {
+	return Rules{
+		NewDeviceWeight:        20,
+		ImpossibleTravelWeight: 50,
+		IPReputationWeight:     60,
+		FailedVelocityWeight:   30,
+		UnusualHourWeight:      15,
+		MaxTravelSpeedKmh:      900,
+		VelocityWindow:         Duration(15 * time.Minute),
+		VelocityThreshold:      5,
+		UsualHourMinHistory:    10,
+		UsualHourTolerance:     1,
+		StepUpAt:               40,
+		BlockAt:                80,
+		HistoryWindow:          Duration(90 * 24 * time.Hour),
+	}
+}
+
+// LoadRules reads a JSON rules file over DefaultRules. An empty path returns the defaults.
+func LoadRules(path string) (Rules, error) {
+	r := DefaultRules()
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("risk rules %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Evaluate scores s. It is pure, so rules can be tested with fixed inputs.
+func (r Rules) Evaluate(s Signals) Assessment {
+	a := Assessment{Reasons: []string{}}
+	add := func(weight int, reason string) {
+		a.Score += weight
+		a.Reasons = append(a.Reasons, reason)
+	}
+	if s.NewDevice {
+		add(r.NewDeviceWeight, ReasonNewDevice)
+	}
+	if r.MaxTravelSpeedKmh > 0 && s.TravelSpeedKmh > r.MaxTravelSpeedKmh {
+		add(r.ImpossibleTravelWeight, ReasonImpossibleTravel)
+	}
+	if s.ListedIn != "" {
+		add(r.IPReputationWeight, ReasonIPReputation)
+	}
+	if r.VelocityThreshold > 0 && s.RecentFailures >= r.VelocityThreshold {
+		add(r.FailedVelocityWeight, ReasonFailedVelocity)
+	}
+	if s.UnusualHour {
+		add(r.UnusualHourWeight, ReasonUnusualHour)
+	}
+
+	switch {
+	case r.BlockAt > 0 && a.Score >= r.BlockAt:
+		a.Decision = Block
+	case r.StepUpAt > 0 && a.Score >= r.StepUpAt:
+		a.Decision = StepUp
+	default:
+		a.Decision = Allow
+	}
+	return a
+}
+
+// UnusualHour reports whether hour (0-23) is more than tolerance hours away
+// from every hour in past. Too little history is never unusual.
+func (r Rules) UnusualHour(hour int, past []int) bool {
+	if len(past) < r.UsualHourMinHistory {
+		return false
+	}
+	for _, h := range past {
+		d := hour - h
+		if d < 0 {
+			d = -d
+		}
+		if d > 12 {
+			d = 24 - d
+		}
+		if d <= r.UsualHourTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// Duration is a time.Duration that reads "15m"-style strings from JSON.
+type Duration time.Duration
+
+// UnmarshalJSON accepts a Go duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes d as a Go duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,149 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signer issues and verifies the service's own RS256 JWTs ("native tokens").
+type Signer struct {
+	key    *rsa.PrivateKey
+	kid    string
+	issuer string
+}
+
+// NewSigner loads a PEM-encoded RSA private key (PKCS#1 or PKCS#8). With an
+// empty keyPEM an ephemeral key is generated: fine for development, but tokens
+// then don't survive restarts and replicas can't verify each other's tokens.
+func NewSigner(keyPEM, issuer string) (*Signer, error) {
+	var key *rsa.PrivateKey
+	if keyPEM == "" {
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	} else {
+		k, err := parseRSAKey([]byte(keyPEM))
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	}
+
+	// kid is derived from the public key so every replica agrees on it.
+	der := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	sum := sha256.Sum256(der)
+	return &Signer{
+		key:    key,
+		kid:    base64.RawURLEncoding.EncodeToString(sum[:8]),
+		issuer: issuer,
+	}, nil
+}
+
+func parseRSAKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("token signing key: no PEM block found")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("token signing key: %w", err)
+	}
+	k, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("token signing key: not an RSA key")
+	}
+	return k, nil
+}
+
+// Issuer is the "iss" of every token this signer issues.
+func (s *Signer) Issuer() string { return s.issuer }
+
+// KeyID is the "kid" header of every token this signer issues.
+func (s *Signer) KeyID() string { return s.kid }
+
+// Sign issues a token with claims plus iss, iat, exp (now+ttl) and a random jti
+// unless the caller set them.
+func (s *Signer) Sign(claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	out := jwt.MapClaims{
+		"iss": s.issuer,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"jti": RandomString(16),
+	}
+	for k, v := range claims {
+		out[k] = v
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, out)
+	t.Header["kid"] = s.kid
+	return t.SignedString(s.key)
+}
+
+// Verify checks signature, issuer and expiry and returns the claims.
+func (s *Signer) Verify(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims,
+		func(t *jwt.Token) (any, error) { return &s.key.PublicKey, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS returns the public key set verifiers need.
+func (s *Signer) JWKS() map[string][]JWK {
+	pub := s.key.PublicKey
+	return map[string][]JWK{"keys": {{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: s.kid,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}}
+}
+
+// RandomString returns n random bytes, base64url-encoded.
+func RandomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Hash returns the hex SHA-256 of a high-entropy secret, for storing
+// single-use tokens without keeping the tokens themselves.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return fmt.Sprintf("%x", sum)
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errRedirect is returned for endpoints that answer with a redirect, which
+// would otherwise let a tenant point deliveries at another host.
+var errRedirect = errors.New("webhook endpoints must not redirect")
+
+// sharedAddressSpace is 100.64.0.0/10 (RFC 6598), used by some cloud
+// providers for metadata services.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newClient returns the delivery client: it never follows redirects and,
+// unless d.AllowPrivateNetworks is set, refuses to connect to loopback,
+// private, link-local (including the 169.254.169.254 metadata service) and
+// other non-public addresses. The check runs on the resolved address, so
+// DNS names pointing inward are refused too.
+func newClient(d *Dispatcher) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if d.AllowPrivateNetworks {
+				return nil
+			}
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(ap.Addr()) {
+				return fmt.Errorf("webhook endpoint address %s is not public", ap.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // the proxy would dial the endpoint, bypassing Control
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errRedirect
+		},
+	}
+}
+
+// publicAddr reports whether addr is a routable public unicast address.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
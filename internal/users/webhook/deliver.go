@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"simple-go-auth/internal/users/http/metrics"
+	"simple-go-auth/internal/users/outbox"
+)
+
+// HeaderAttempt carries the 1-based attempt number of a delivery.
+const HeaderAttempt = "X-Webhook-Attempt"
+
+// EventTest is the event type sent by TestFire.
+const EventTest = "webhook.test"
+
+// claimLease is how long a worker owns a claimed delivery before another
+// worker may pick it up again.
+const claimLease = time.Minute
+
+// body is what endpoints receive.
+type body struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Result is the outcome of one HTTP attempt.
+type Result struct {
+	StatusCode int           `json:"status_code,omitempty"`
+	Duration   time.Duration `json:"duration_ns"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Run delivers due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			d.Logger.Error("webhook delivery pass failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue attempts every pending delivery whose retry time has come and
+// returns how many succeeded. Deliveries to paused endpoints stay pending
+// until the endpoint is resumed.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	var due []Delivery
+	now := time.Now()
+	active := d.DB.Model(&Endpoint{}).Select("id").Where("active = ?", true)
+	if err := d.DB.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+		Where("endpoint_id IN (?)", active).
+		Order("id ASC").
+		Limit(d.batchSize()).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range due {
+		dl := &due[i]
+		// 1) Claim it, so parallel workers don't both send
+		res := d.DB.WithContext(ctx).Model(&Delivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", dl.ID, StatusPending, dl.NextAttemptAt).
+			Update("next_attempt_at", now.Add(claimLease))
+		if res.Error != nil {
+			return delivered, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		// 2) Send and record the outcome
+		ok, err := d.attempt(ctx, dl)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attempt sends dl once and updates it and its endpoint.
+func (d *Dispatcher) attempt(ctx context.Context, dl *Delivery) (bool, error) {
+	var ep Endpoint
+	if err := d.DB.WithContext(ctx).Where("id = ?", dl.EndpointID).First(&ep).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, d.DB.WithContext(ctx).Delete(dl).Error
+		}
+		return false, err
+	}
+	if !ep.Active {
+		// Paused after it was picked; give the claim back
+		return false, d.DB.WithContext(ctx).Model(dl).Update("next_attempt_at", dl.NextAttemptAt).Error
+	}
+
+	dl.Attempts++
+	res := d.send(ctx, &ep, dl.EventID, dl.EventType, dl.CreatedAt, json.RawMessage(dl.Payload), dl.Attempts)
+	now := time.Now()
+
+	if res.Error == "" {
+		if err := d.DB.WithContext(ctx).Model(dl).Updates(map[string]any{
+			"status":           StatusDelivered,
+			"attempts":         dl.Attempts,
+			"delivered_at":     now,
+			"last_status_code": res.StatusCode,
+			"last_error":       "",
+		}).Error; err != nil {
+			return false, err
+		}
+		return true, d.DB.WithContext(ctx).Model(&ep).Updates(map[string]any{
+			"consecutive_failures": 0,
+			"last_success_at":      now,
+		}).Error
+	}
+
+	updates := map[string]any{
+		"attempts":         dl.Attempts,
+		"last_status_code": res.StatusCode,
+		"last_error":       res.Error,
+	}
+	if dl.Attempts >= d.maxAttempts() {
+		updates["status"] = StatusDead
+		updates["dead_at"] = now
+		metrics.RecordWebhookDeadLetter(ep.ID)
+		d.Logger.Warn("webhook delivery dead-lettered",
+			zap.String("endpoint_id", ep.ID),
+			zap.Uint64("delivery_id", dl.ID),
+			zap.String("event_type", dl.EventType),
+			zap.Int("attempts", dl.Attempts),
+			zap.String("error", res.Error),
+		)
+	} else {
+		updates["next_attempt_at"] = now.Add(d.backoff(dl.Attempts))
+	}
+	if err := d.DB.WithContext(ctx).Model(dl).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	return false, d.DB.WithContext(ctx).Model(&ep).Updates(map[string]any{
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"last_failure_at":      now,
+	}).Error
+}
+
+// send POSTs one signed event to ep.
+func (d *Dispatcher) send(ctx context.Context, ep *Endpoint, eventID, eventType string, created time.Time, data json.RawMessage, attempt int) Result {
+	payload, err := json.Marshal(body{ID: eventID, Type: eventType, CreatedAt: created.UTC(), Data: data})
+	if err != nil {
+		return Result{Error: err.Error()}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(payload))
+	if err != nil {
+		return Result{Error: err.Error()}
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(outbox.HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(outbox.HeaderID, eventID)
+	req.Header.Set(outbox.HeaderIdempotencyKey, eventID)
+	req.Header.Set(outbox.HeaderSignature, outbox.SignAll(ep.secrets(now), now, payload))
+	req.Header.Set(HeaderAttempt, strconv.Itoa(attempt))
+
+	start := time.Now()
+	resp, err := d.Client.Do(req)
+	res := Result{Duration: time.Since(start)}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		res.StatusCode = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			res.Error = fmt.Sprintf("endpoint returned %s", resp.Status)
+		}
+	}
+	var mErr error
+	if res.Error != "" {
+		mErr = errors.New(res.Error)
+	}
+	metrics.ObserveWebhook(ctx, ep.ID, res.Duration, mErr)
+	return res
+}
+
+// TestFire sends a webhook.test event to an endpoint right away, outside the
+// queue, and reports the result.
+func (d *Dispatcher) TestFire(ctx context.Context, id string) (*Result, error) {
+	ep, err := d.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(map[string]string{"endpoint_id": ep.ID})
+	res := d.send(ctx, ep, uuid.New().String(), EventTest, time.Now(), data, 1)
+	return &res, nil
+}
+
+// backoff is BaseBackoff doubled per attempt, capped at MaxBackoff, with
+// equal jitter: somewhere between half and all of it.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	base, limit := d.BaseBackoff, d.MaxBackoff
+	if base <= 0 {
+		base = DefaultBaseBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	delay = min(delay, limit)
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func (d *Dispatcher) maxAttempts() int {
+	if d.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	return d.MaxAttempts
+}
+
+func (d *Dispatcher) batchSize() int {
+	if d.BatchSize <= 0 {
+		return 50
+	}
+	return d.BatchSize
+}
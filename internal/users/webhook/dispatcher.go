@@ -0,0 +1,253 @@
+// Package webhook delivers auth events to endpoints registered by tenants.
+// Deliveries are signed like outbox webhooks, retried with exponential
+// backoff and jitter, and dead-lettered after MaxAttempts.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/outbox"
+	"simple-go-auth/internal/users/token"
+)
+
+// Dispatcher defaults.
+const (
+	DefaultMaxAttempts = 8
+	DefaultBaseBackoff = 10 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultSecretGrace = 24 * time.Hour
+)
+
+// Dispatcher stores endpoints and deliveries and runs the delivery worker.
+type Dispatcher struct {
+	DB     *gorm.DB
+	Client *http.Client
+	Logger *zap.Logger
+
+	MaxAttempts int           // attempts before a delivery is dead-lettered
+	BaseBackoff time.Duration // first retry delay, doubled per attempt
+	MaxBackoff  time.Duration
+	SecretGrace time.Duration // how long a rotated-out secret still signs
+	Interval    time.Duration // worker poll interval
+	BatchSize   int
+	// AllowInsecureURLs permits plain http endpoints, for development.
+	AllowInsecureURLs bool
+	// AllowPrivateNetworks permits endpoints on loopback and private
+	// addresses, for development.
+	AllowPrivateNetworks bool
+	// Tenant owns events that carry no tenant of their own, such as user
+	// lifecycle events. Empty delivers such events to nobody.
+	Tenant string
+}
+
+// NewDispatcher returns a Dispatcher with the defaults and a client with a
+// 10s timeout that only reaches public addresses and never follows redirects.
+func NewDispatcher(gormDB *gorm.DB, logger *zap.Logger) *Dispatcher {
+	d := &Dispatcher{
+		DB:          gormDB,
+		Logger:      logger,
+		MaxAttempts: DefaultMaxAttempts,
+		BaseBackoff: DefaultBaseBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		SecretGrace: DefaultSecretGrace,
+		Interval:    time.Second,
+		BatchSize:   50,
+	}
+	d.Client = newClient(d)
+	return d
+}
+
+// Register adds an endpoint for tenant and returns it with its signing
+// secret, which is only shown here and on rotation.
+func (d *Dispatcher) Register(ctx context.Context, tenant, rawURL string, events []string) (*Endpoint, error) {
+	if err := d.checkURL(rawURL); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNoEvents
+	}
+	for _, e := range events {
+		if e == "" || strings.ContainsAny(e, " \t\n") {
+			return nil, ErrNoEvents.WithDetail("event types must be non-empty and contain no spaces")
+		}
+	}
+	ep := &Endpoint{
+		ID:     uuid.New().String(),
+		Tenant: tenant,
+		URL:    rawURL,
+		Events: strings.Join(events, " "),
+		Secret: newSecret(),
+		Active: true,
+	}
+	if err := d.DB.WithContext(ctx).Create(ep).Error; err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// List returns the endpoints of tenant, or all endpoints when tenant is empty.
+func (d *Dispatcher) List(ctx context.Context, tenant string) ([]Endpoint, error) {
+	q := d.DB.WithContext(ctx).Order("created_at")
+	if tenant != "" {
+		q = q.Where("tenant = ?", tenant)
+	}
+	var eps []Endpoint
+	return eps, q.Find(&eps).Error
+}
+
+// Get returns one endpoint.
+func (d *Dispatcher) Get(ctx context.Context, id string) (*Endpoint, error) {
+	var ep Endpoint
+	if err := d.DB.WithContext(ctx).Where("id = ?", id).First(&ep).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEndpointNotFound
+		}
+		return nil, err
+	}
+	return &ep, nil
+}
+
+// SetActive pauses or resumes deliveries to an endpoint. Paused endpoints
+// receive no new deliveries, and those already queued wait for the resume.
+func (d *Dispatcher) SetActive(ctx context.Context, id string, active bool) error {
+	res := d.DB.WithContext(ctx).Model(&Endpoint{}).Where("id = ?", id).Update("active", active)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+// Delete removes an endpoint and its deliveries.
+func (d *Dispatcher) Delete(ctx context.Context, id string) error {
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ?", id).Delete(&Endpoint{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrEndpointNotFound
+		}
+		return tx.Where("endpoint_id = ?", id).Delete(&Delivery{}).Error
+	})
+}
+
+// RotateSecret replaces the signing secret. Deliveries are signed with both
+// secrets for SecretGrace so receivers can switch over.
+func (d *Dispatcher) RotateSecret(ctx context.Context, id string) (string, error) {
+	ep, err := d.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	secret := newSecret()
+	until := time.Now().Add(d.SecretGrace)
+	res := d.DB.WithContext(ctx).Model(&Endpoint{}).
+		Where("id = ? AND secret = ?", id, ep.Secret). // lost race with another rotation
+		Updates(map[string]any{
+			"secret":                     secret,
+			"previous_secret":            ep.Secret,
+			"previous_secret_expires_at": until,
+		})
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", ErrEndpointNotFound
+	}
+	return secret, nil
+}
+
+// Enqueue queues eventType for every active endpoint of tenant subscribed
+// to it. eventID is the idempotency key: enqueuing it again is a no-op.
+func (d *Dispatcher) Enqueue(ctx context.Context, tenant, eventID, eventType string, data any) error {
+	if tenant == "" {
+		return nil
+	}
+	var eps []Endpoint
+	if err := d.DB.WithContext(ctx).Where("tenant = ? AND active = ?", tenant, true).Find(&eps).Error; err != nil {
+		return err
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var deliveries []Delivery
+	for i := range eps {
+		if !eps[i].Subscribed(eventType) {
+			continue
+		}
+		deliveries = append(deliveries, Delivery{
+			EndpointID:    eps[i].ID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        StatusPending,
+			NextAttemptAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&deliveries).Error
+}
+
+// Record implements audit.Recorder: every audit event is an auth event
+// tenants can subscribe to by action. Only endpoints of the event's tenant,
+// or of d.Tenant when it has none, receive it.
+func (d *Dispatcher) Record(ctx context.Context, e audit.Event) error {
+	tenant := e.Tenant
+	if tenant == "" {
+		tenant = d.Tenant
+	}
+	return d.Enqueue(ctx, tenant, uuid.New().String(), e.Action, e)
+}
+
+// Publish implements outbox.Sink for the user lifecycle events, which
+// belong to d.Tenant.
+func (d *Dispatcher) Publish(ctx context.Context, msg outbox.Message) error {
+	return d.Enqueue(ctx, d.Tenant, msg.ID, msg.Type, msg.Data)
+}
+
+// Compile-time checks.
+var (
+	_ audit.Recorder = (*Dispatcher)(nil)
+	_ outbox.Sink    = (*Dispatcher)(nil)
+)
+
+func (d *Dispatcher) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	if u.Scheme != "https" && !(d.AllowInsecureURLs && u.Scheme == "http") {
+		return ErrInvalidURL
+	}
+	// Names are checked when dialing; literal addresses can be refused now.
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !d.AllowPrivateNetworks && !publicAddr(addr) {
+		return ErrInvalidURL.WithDetail("the webhook URL must not point at a private address")
+	}
+	return nil
+}
+
+// newSecret returns a "whsec_"-prefixed random signing secret.
+func newSecret() string {
+	return "whsec_" + token.RandomString(32)
+}
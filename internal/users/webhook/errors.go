@@ -0,0 +1,15 @@
+package webhook
+
+import (
+	"net/http"
+
+	"simple-go-auth/internal/users/problem"
+)
+
+// Webhook errors; they render as application/problem+json.
+var (
+	ErrEndpointNotFound = problem.New(http.StatusNotFound, "webhook_endpoint_not_found", "Webhook endpoint not found")
+	ErrDeliveryNotFound = problem.New(http.StatusNotFound, "webhook_delivery_not_found", "Webhook delivery not found")
+	ErrInvalidURL       = problem.New(http.StatusBadRequest, "invalid_webhook_url", "The webhook URL must be an absolute https URL")
+	ErrNoEvents         = problem.New(http.StatusBadRequest, "invalid_webhook_events", "At least one event type is required")
+)
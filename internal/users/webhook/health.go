@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// healthWindow is the period Health counts deliveries over.
+const healthWindow = 24 * time.Hour
+
+// Health summarizes recent deliveries to one endpoint.
+type Health struct {
+	EndpointID          string     `json:"endpoint_id"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	Pending             int64      `json:"pending"`
+	Delivered           int64      `json:"delivered_24h"`
+	Dead                int64      `json:"dead_24h"`
+	SuccessRate         float64    `json:"success_rate_24h"` // delivered / (delivered + dead); 1 with no traffic
+}
+
+// Health reports on an endpoint.
+func (d *Dispatcher) Health(ctx context.Context, id string) (*Health, error) {
+	ep, err := d.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	h := &Health{
+		EndpointID:          ep.ID,
+		Active:              ep.Active,
+		ConsecutiveFailures: ep.ConsecutiveFailures,
+		LastSuccessAt:       ep.LastSuccessAt,
+		LastFailureAt:       ep.LastFailureAt,
+		SuccessRate:         1,
+	}
+	since := time.Now().Add(-healthWindow)
+	q := func() *gorm.DB { return d.DB.WithContext(ctx).Model(&Delivery{}).Where("endpoint_id = ?", id) }
+	if err := q().Where("status = ?", StatusPending).Count(&h.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := q().Where("status = ? AND delivered_at >= ?", StatusDelivered, since).Count(&h.Delivered).Error; err != nil {
+		return nil, err
+	}
+	if err := q().Where("status = ? AND dead_at >= ?", StatusDead, since).Count(&h.Dead).Error; err != nil {
+		return nil, err
+	}
+	if total := h.Delivered + h.Dead; total > 0 {
+		h.SuccessRate = float64(h.Delivered) / float64(total)
+	}
+	return h, nil
+}
+
+// Deliveries lists an endpoint's deliveries, newest first, optionally by
+// status; StatusDead lists its dead-letter queue.
+func (d *Dispatcher) Deliveries(ctx context.Context, endpointID, status string, limit int) ([]Delivery, error) {
+	if _, err := d.Get(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	q := d.DB.WithContext(ctx).Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var out []Delivery
+	return out, q.Order("id DESC").Limit(limit).Find(&out).Error
+}
+
+// Replay queues a delivery again with fresh attempts, whatever its status.
+// The event ID is unchanged so receivers can still deduplicate.
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID uint64) error {
+	res := d.DB.WithContext(ctx).Model(&Delivery{}).
+		Where("id = ?", deliveryID).
+		Updates(replayed())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// ReplayDead requeues an endpoint's whole dead-letter queue and returns how
+// many deliveries were requeued.
+func (d *Dispatcher) ReplayDead(ctx context.Context, endpointID string) (int64, error) {
+	if _, err := d.Get(ctx, endpointID); err != nil {
+		return 0, err
+	}
+	res := d.DB.WithContext(ctx).Model(&Delivery{}).
+		Where("endpoint_id = ? AND status = ?", endpointID, StatusDead).
+		Updates(replayed())
+	return res.RowsAffected, res.Error
+}
+
+func replayed() map[string]any {
+	return map[string]any{
+		"status":          StatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"dead_at":         nil,
+		"delivered_at":    nil,
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Delivery statuses. Dead deliveries form the dead-letter queue.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Endpoint is a tenant's registered webhook URL.
+type Endpoint struct {
+	ID                      string `gorm:"primaryKey;size:36"`
+	Tenant                  string `gorm:"index;not null"`
+	URL                     string `gorm:"not null"`
+	Events                  string `gorm:"type:text;not null"` // space-separated event types or prefixes ("signin."); "*" for all
+	Secret                  string `gorm:"not null"`
+	PreviousSecret          string `gorm:"default:''"` // still signed with until PreviousSecretExpiresAt
+	PreviousSecretExpiresAt *time.Time
+	Active                  bool `gorm:"not null;default:true"`
+	ConsecutiveFailures     int  `gorm:"not null;default:0"`
+	LastSuccessAt           *time.Time
+	LastFailureAt           *time.Time
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+}
+
+// TableName keeps the table name stable.
+func (Endpoint) TableName() string { return "webhook_endpoints" }
+
+// EventTypes returns the subscribed event types.
+func (e *Endpoint) EventTypes() []string { return strings.Fields(e.Events) }
+
+// Subscribed reports whether e wants events of eventType.
+func (e *Endpoint) Subscribed(eventType string) bool {
+	for _, t := range e.EventTypes() {
+		if t == "*" || t == eventType || (strings.HasSuffix(t, ".") && strings.HasPrefix(eventType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// secrets returns the secrets deliveries are signed with at now.
+func (e *Endpoint) secrets(now time.Time) []string {
+	s := []string{e.Secret}
+	if e.PreviousSecret != "" && e.PreviousSecretExpiresAt != nil && now.Before(*e.PreviousSecretExpiresAt) {
+		s = append(s, e.PreviousSecret)
+	}
+	return s
+}
+
+// Delivery is one event queued for one endpoint.
+type Delivery struct {
+	ID             uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	EndpointID     string     `gorm:"size:36;not null;uniqueIndex:idx_delivery_endpoint_event" json:"endpoint_id"`
+	EventID        string     `gorm:"size:64;not null;uniqueIndex:idx_delivery_endpoint_event" json:"event_id"` // X-Webhook-Id; the same on retries and replays
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"-"`
+	Status         string     `gorm:"index;not null" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError      string     `gorm:"type:text;default:''" json:"last_error,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	DeadAt         *time.Time `json:"dead_at,omitempty"`
+}
+
+// TableName keeps the table name stable.
+func (Delivery) TableName() string { return "webhook_deliveries" }
+
+// Migrate creates the webhook tables.
+func Migrate(gormDB *gorm.DB) error {
+	return gormDB.AutoMigrate(&Endpoint{}, &Delivery{})
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	accountauth "simple-go-auth/internal/account/auth"
+	"simple-go-auth/internal/users/token"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksServer publishes RSA and EC keys the way Cognito does.
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    []map[string]string
+	fetches int
+	*httptest.Server
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) addRSA(kid string, key *rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, map[string]string{
+		"kty": "RSA", "use": "sig", "kid": kid,
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+}
+
+func (s *jwksServer) addEC(kid string, key *ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, map[string]string{
+		"kty": "EC", "use": "sig", "kid": kid, "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	})
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+const cognitoIssuer = "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_pool"
+
+func TestAccountValidatorUserTokens(t *testing.T) {
+	srv := newJWKSServer(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srv.addRSA("rsa-1", &rsaKey.PublicKey)
+	srv.addEC("ec-1", &ecKey.PublicKey)
+
+	v := &accountauth.Validator{
+		Keys:      accountauth.NewJWKS(srv.URL),
+		Issuers:   []string{cognitoIssuer},
+		Audiences: []string{"app-client"},
+	}
+	ctx := context.Background()
+
+	// Cognito access token: audience comes from client_id
+	p, err := v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "sub-1", "client_id": "app-client", "token_use": "access",
+		"username": "alice", "scope": "openid profile", "cognito:groups": []string{"admins"},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "sub-1", p.Subject)
+	require.Equal(t, "alice", p.Username)
+	require.Equal(t, []string{"admins"}, p.Groups)
+	require.True(t, p.HasScope("profile"))
+	require.False(t, p.Service)
+
+	// ID token signed with ES256
+	p, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodES256, "ec-1", ecKey, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "sub-2", "aud": "app-client", "cognito:username": "bob",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "bob", p.Username)
+
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+		"iss": "https://evil.example.com", "sub": "x", "client_id": "app-client",
+	}))
+	require.ErrorIs(t, err, accountauth.ErrIssuerNotAllowed)
+
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "x", "client_id": "other-client",
+	}))
+	require.ErrorIs(t, err, accountauth.ErrAudienceNotAllowed)
+
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "x", "client_id": "app-client", "exp": time.Now().Add(-time.Hour).Unix(),
+	}))
+	require.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	// HS256 is refused without a service secret
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodHS256, "", []byte("guess"), jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "x", "client_id": "app-client",
+	}))
+	require.Error(t, err)
+}
+
+func TestAccountValidatorRequiresAudience(t *testing.T) {
+	srv := newJWKSServer(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv.addRSA("rsa-1", &key.PublicKey)
+	ctx := context.Background()
+
+	// Without an allowlist, tokens addressed to any client are refused
+	v := &accountauth.Validator{Keys: accountauth.NewJWKS(srv.URL), Issuers: []string{cognitoIssuer}}
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", key, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "x", "client_id": "app-client", "token_use": "access",
+	}))
+	require.ErrorIs(t, err, accountauth.ErrAudienceNotAllowed)
+
+	// A user's grant to a third-party client is not a user token here
+	v.Audiences = []string{"app-client"}
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", key, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "x", "aud": "third-party", "client_id": "third-party", "token_use": "delegated",
+	}))
+	require.ErrorIs(t, err, accountauth.ErrAudienceNotAllowed)
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "rsa-1", key, jwt.MapClaims{
+		"iss": cognitoIssuer, "sub": "x", "token_use": "delegated",
+	}))
+	require.ErrorIs(t, err, accountauth.ErrAudienceNotAllowed)
+}
+
+func TestAccountValidatorKeyRotation(t *testing.T) {
+	srv := newJWKSServer(t)
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv.addRSA("old", &oldKey.PublicKey)
+
+	keys := accountauth.NewJWKS(srv.URL)
+	keys.MinRefreshInterval = 0
+	v := &accountauth.Validator{Keys: keys, Issuers: []string{cognitoIssuer}}
+	ctx := context.Background()
+
+	_, err := v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "old", oldKey, jwt.MapClaims{"iss": cognitoIssuer, "sub": "a"}))
+	require.NoError(t, err)
+
+	// A new kid triggers a refetch
+	srv.addRSA("new", &newKey.PublicKey)
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "new", newKey, jwt.MapClaims{"iss": cognitoIssuer, "sub": "a"}))
+	require.NoError(t, err)
+	require.Equal(t, 2, srv.fetches)
+
+	_, err = v.Validate(ctx, signTestToken(t, jwt.SigningMethodRS256, "missing", newKey, jwt.MapClaims{"iss": cognitoIssuer, "sub": "a"}))
+	require.ErrorIs(t, err, accountauth.ErrUnknownKey)
+}
+
+func TestAccountValidatorAcceptsNativeTokens(t *testing.T) {
+	signer, err := token.NewSigner("", "simple-go-auth")
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(signer.JWKS())
+	}))
+	defer srv.Close()
+
+	raw, err := signer.Sign(jwt.MapClaims{"sub": "sub-1", "username": "alice"}, time.Minute)
+	require.NoError(t, err)
+	v := &accountauth.Validator{Keys: accountauth.NewJWKS(srv.URL), Issuers: []string{"simple-go-auth"}}
+	p, err := v.Validate(context.Background(), raw)
+	require.NoError(t, err)
+	require.Equal(t, "alice", p.Username)
+}
+
+func TestAccountMiddleware(t *testing.T) {
+	v := &accountauth.Validator{ServiceSecret: []byte("internal-secret")}
+	e := echo.New()
+	e.Use(accountauth.NewMiddleware(v, "/healthz"))
+	e.GET("/healthz", func(c echo.Context) error { return c.String(http.StatusOK, "OK") })
+	e.GET("/me", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, accountauth.PrincipalFromContext(c))
+	})
+
+	do := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("/healthz", "").Code)
+	rec := do("/me", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	require.Equal(t, http.StatusUnauthorized, do("/me", "not-a-jwt").Code)
+
+	rec = do("/me", signTestToken(t, jwt.SigningMethodHS256, "", []byte("internal-secret"), jwt.MapClaims{"sub": "billing-service"}))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var p accountauth.Principal
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	require.Equal(t, "billing-service", p.Subject)
+	require.True(t, p.Service)
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/rbac"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminListUsersFilters(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	for _, u := range []struct{ name, email string }{
+		{"bob", "bob@acme.io"}, {"carol", "carol@acme.io"}, {"dave", "dave@example.com"}, {"erin_x", "erin@ACME.io"},
+	} {
+		require.NoError(t, svc.SignUp(ctx, u.name, "Secret123", u.email))
+	}
+	require.NoError(t, svc.SetUserDisabled(ctx, "alice", "carol", true))
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "dave").Update("confirmed_at", nil).Error)
+
+	usernames := func(f auth.UserFilter) []string {
+		page, err := svc.ListUsers(ctx, f)
+		require.NoError(t, err)
+		var out []string
+		for _, u := range page.Users {
+			out = append(out, u.Username)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"erin_x", "carol", "bob"}, usernames(auth.UserFilter{EmailDomain: "acme.io"}))
+	require.Equal(t, []string{"carol"}, usernames(auth.UserFilter{Status: auth.UserDisabled}))
+	require.Equal(t, []string{"dave"}, usernames(auth.UserFilter{Status: auth.UserUnconfirmed}))
+	require.Equal(t, []string{"erin_x", "bob", "alice"}, usernames(auth.UserFilter{Status: auth.UserActive}))
+	require.Equal(t, []string{"erin_x"}, usernames(auth.UserFilter{Query: "_"})) // wildcards are literal
+	require.Empty(t, usernames(auth.UserFilter{CreatedAfter: time.Now().Add(time.Hour)}))
+
+	// Keyset pagination, newest first
+	page, err := svc.ListUsers(ctx, auth.UserFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Users, 2)
+	require.NotZero(t, page.NextBefore)
+	require.Equal(t, []string{"carol", "bob", "alice"}, usernames(auth.UserFilter{Before: page.NextBefore}))
+
+	_, err = svc.ListUsers(ctx, auth.UserFilter{Status: "sleeping"})
+	require.ErrorIs(t, err, auth.ErrInvalidUserFilter)
+}
+
+func TestAdminUserActionsAreEnforcedAndAudited(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	rec := &memoryAudit{}
+	svc.Audit = rec
+	ctx := context.Background()
+	require.NoError(t, svc.SignUp(ctx, "bob", "Secret123", "bob@example.com"))
+	tokens, err := svc.SignIn(ctx, "bob", "Secret123")
+	require.NoError(t, err)
+
+	// Disable: sessions end and no sign-in method works
+	require.NoError(t, svc.SetUserDisabled(ctx, "alice", "bob", true))
+	_, err = svc.Authenticate(ctx, tokens.AccessToken)
+	require.ErrorIs(t, err, auth.ErrUserDisabled)
+	_, err = svc.RefreshTokens(ctx, tokens.RefreshToken)
+	require.Error(t, err)
+	_, err = svc.SignIn(ctx, "bob", "Secret123")
+	require.ErrorIs(t, err, auth.ErrUserDisabled)
+	_, err = svc.IssueTokens(ctx, "bob")
+	require.ErrorIs(t, err, auth.ErrUserDisabled)
+	u, err := svc.GetUser(ctx, "bob")
+	require.NoError(t, err)
+	require.Equal(t, auth.UserDisabled, u.Status)
+
+	require.NoError(t, svc.SetUserDisabled(ctx, "alice", "bob", false))
+	tokens, err = svc.SignIn(ctx, "bob", "Secret123")
+	require.NoError(t, err)
+
+	// Global sign-out revokes refresh tokens
+	require.NoError(t, svc.GlobalSignOut(ctx, "alice", "bob"))
+	_, err = svc.RefreshTokens(ctx, tokens.RefreshToken)
+	require.Error(t, err)
+
+	// Forced reset blocks the password, not other sign-in methods
+	require.NoError(t, svc.ForcePasswordReset(ctx, "alice", "bob"))
+	_, err = svc.SignIn(ctx, "bob", "Secret123")
+	require.ErrorIs(t, err, auth.ErrPasswordResetRequired)
+	_, err = svc.SignIn(ctx, "bob", "wrong")
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	require.ErrorIs(t, svc.GlobalSignOut(ctx, "alice", "nobody"), auth.ErrUserNotFound)
+	require.NoError(t, svc.DeleteUser(ctx, "alice", "bob"))
+	_, err = svc.GetUser(ctx, "bob")
+	require.ErrorIs(t, err, auth.ErrUserNotFound)
+
+	var admin []string
+	for _, e := range rec.events {
+		if e.Actor == "alice" {
+			admin = append(admin, e.Action+":"+e.Outcome)
+		}
+	}
+	require.Equal(t, []string{
+		"admin.user.disabled:success",
+		"admin.user.enabled:success",
+		"admin.user.signed_out:success",
+		"admin.user.password_reset:success",
+		"admin.user.signed_out:failure",
+		"user.deleted:success",
+	}, admin)
+}
+
+func TestAdminUsersAPI(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	require.NoError(t, svc.SignUp(ctx, "bob", "Secret123", "bob@example.com"))
+
+	e := newAuthRouter(svc)
+	grantRole(t, svc, "alice", rbac.RoleAdmin)
+	aliceTokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	bobTokens, err := svc.SignIn(ctx, "bob", "Secret123")
+	require.NoError(t, err)
+
+	call := func(token, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusForbidden, call(bobTokens.AccessToken, http.MethodGet, "/admin/users").Code)
+
+	rec := call(aliceTokens.AccessToken, http.MethodGet, "/admin/users?email_domain=example.com&limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page auth.UserPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Users, 1)
+	require.Equal(t, "bob", page.Users[0].Username)
+	require.NotContains(t, rec.Body.String(), "password\":\"$2")
+
+	require.Equal(t, http.StatusBadRequest, call(aliceTokens.AccessToken, http.MethodGet, "/admin/users?created_after=yesterday").Code)
+	require.Equal(t, http.StatusNotFound, call(aliceTokens.AccessToken, http.MethodGet, "/admin/users/nobody").Code)
+	require.Equal(t, http.StatusConflict, call(aliceTokens.AccessToken, http.MethodPost, "/admin/users/alice/disable").Code)
+	require.Equal(t, http.StatusNoContent, call(aliceTokens.AccessToken, http.MethodPost, "/admin/users/bob/disable").Code)
+
+	rec = call(aliceTokens.AccessToken, http.MethodGet, "/admin/users/bob")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"status":"disabled"`)
+
+	require.Equal(t, http.StatusNoContent, call(aliceTokens.AccessToken, http.MethodDelete, "/admin/users/bob").Code)
+	require.Equal(t, http.StatusNotFound, call(aliceTokens.AccessToken, http.MethodDelete, "/admin/users/bob").Code)
+}
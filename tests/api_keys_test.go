@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/rbac"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuthenticatesWithinScopes(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	grantRole(t, svc, "alice", rbac.RoleAdmin)
+	tokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	session, err := svc.Authenticate(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+
+	_, err = svc.CreateAPIKey(ctx, session, auth.APIKeyRequest{Name: "ci", Scopes: []string{"payments:refund"}})
+	require.ErrorIs(t, err, auth.ErrInvalidAPIKeyRequest)
+	_, err = svc.CreateAPIKey(ctx, session, auth.APIKeyRequest{Name: "ci", ExpiresAt: time.Now().Add(10 * 365 * 24 * time.Hour)})
+	require.ErrorIs(t, err, auth.ErrInvalidAPIKeyRequest)
+
+	key, err := svc.CreateAPIKey(ctx, session, auth.APIKeyRequest{Name: "ci", Scopes: []string{rbac.PermUsersRead}})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key.Key, auth.APIKeyPrefix+key.ID+"_"))
+
+	// Stored as a hash only
+	var row db.APIKey
+	require.NoError(t, svc.DB.Where("key_id = ?", key.ID).First(&row).Error)
+	require.NotContains(t, row.Hash, key.Key)
+	require.NotEqual(t, key.Key, row.Hash)
+
+	p, err := svc.Authenticate(ctx, key.Key)
+	require.NoError(t, err)
+	require.Equal(t, "alice", p.Username)
+	require.Equal(t, session.Subject, p.Subject)
+	require.Equal(t, key.ID, p.APIKey)
+	require.Equal(t, []string{rbac.PermUsersRead}, p.Permissions)
+
+	// Keys can't mint keys
+	_, err = svc.CreateAPIKey(ctx, p, auth.APIKeyRequest{Name: "again"})
+	require.ErrorIs(t, err, auth.ErrAPIKeyNotAllowed)
+
+	_, err = svc.Authenticate(ctx, key.Key+"x")
+	require.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+
+	keys, err := svc.ListAPIKeys(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].LastUsedAt)
+	require.Empty(t, keys[0].Key)
+
+	require.NoError(t, svc.RevokeAPIKey(ctx, "alice", "alice", key.ID))
+	_, err = svc.Authenticate(ctx, key.Key)
+	require.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+	require.ErrorIs(t, svc.RevokeAPIKey(ctx, "alice", "alice", key.ID), auth.ErrAPIKeyNotFound)
+}
+
+func TestAPIKeyAndBearerTokenShareMiddleware(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	grantRole(t, svc, "alice", rbac.RoleSupport)
+
+	e := newAuthRouter(svc)
+
+	do := func(method, path, header, value, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	rec := do(http.MethodPost, "/api-keys", "Authorization", "Bearer "+tokens.AccessToken, `{"name":"cli","scopes":["users:read"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var key auth.APIKey
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &key))
+
+	// Either header reaches a permission-guarded route as alice
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/users", "Authorization", "Bearer "+key.Key, "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/users", auth.HeaderAPIKey, key.Key, "").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/users/alice/sign-out", auth.HeaderAPIKey, key.Key, "").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api-keys", auth.HeaderAPIKey, key.Key, `{"name":"x"}`).Code)
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api-keys", auth.HeaderAPIKey, "sga_0000000000000000_nope", "").Code)
+
+	// Keys can't act on the owner's session, MFA or devices
+	for _, r := range []struct{ method, path string }{
+		{http.MethodPost, "/logout"},
+		{http.MethodPost, "/mfa/setup"},
+		{http.MethodPost, "/mfa/verify"},
+		{http.MethodPost, "/mfa/recovery-codes"},
+		{http.MethodDelete, "/devices/some-device"},
+		{http.MethodPost, "/qr-login/some-login/approve"},
+	} {
+		rec := do(r.method, r.path, auth.HeaderAPIKey, key.Key, `{}`)
+		require.Equal(t, http.StatusForbidden, rec.Code, r.path)
+		require.Contains(t, rec.Body.String(), "session_required", r.path)
+	}
+
+	// Disabled owners lose their keys too
+	require.NoError(t, svc.SignUp(ctx, "root", "Secret123", "root@example.com"))
+	require.NoError(t, svc.SetUserDisabled(ctx, "root", "alice", true))
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api-keys", auth.HeaderAPIKey, key.Key, "").Code)
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newAuditStore(t *testing.T, gormDB *gorm.DB) *audit.Store {
+	t.Helper()
+	store, err := audit.NewStore(gormDB, "audit-test-key")
+	require.NoError(t, err)
+	return store
+}
+
+func TestAuditStoreChainsAndDetectsTampering(t *testing.T) {
+	gormDB := newTestDB(t)
+	store := newAuditStore(t, gormDB)
+	ctx := context.Background()
+
+	for _, action := range []string{"signup", "signin.succeeded", "signout"} {
+		require.NoError(t, store.Record(ctx, audit.Event{
+			Action: action, Actor: "alice", Subject: "alice", Outcome: audit.OutcomeSuccess,
+			Details: map[string]any{"method": "password"},
+		}))
+	}
+
+	res, err := store.Verify(ctx)
+	require.NoError(t, err)
+	require.True(t, res.OK)
+	require.EqualValues(t, 3, res.Checked)
+
+	// The table refuses changes
+	require.Error(t, gormDB.Exec("UPDATE audit_entries SET actor = 'mallory' WHERE seq = 2").Error)
+	require.Error(t, gormDB.Exec("DELETE FROM audit_entries").Error)
+
+	// Entries only verify under the key that sealed them
+	other, err := audit.NewStore(gormDB, "another-key")
+	require.NoError(t, err)
+	res, err = other.Verify(ctx)
+	require.NoError(t, err)
+	require.False(t, res.OK)
+	require.EqualValues(t, 1, res.BrokenAt)
+	require.NoError(t, gormDB.Exec("DROP TRIGGER audit_entries_no_update").Error)
+	require.NoError(t, gormDB.Exec("DROP TRIGGER audit_entries_no_delete").Error)
+
+	// Rewriting history breaks the chain at the edited entry
+	require.NoError(t, gormDB.Exec("UPDATE audit_entries SET actor = 'mallory' WHERE seq = 2").Error)
+	res, err = store.Verify(ctx)
+	require.NoError(t, err)
+	require.False(t, res.OK)
+	require.EqualValues(t, 2, res.BrokenAt)
+
+	// So does dropping an entry
+	require.NoError(t, gormDB.Exec("UPDATE audit_entries SET actor = 'alice' WHERE seq = 2").Error)
+	require.NoError(t, gormDB.Exec("DELETE FROM audit_entries WHERE seq = 2").Error)
+	res, err = store.Verify(ctx)
+	require.NoError(t, err)
+	require.False(t, res.OK)
+	require.EqualValues(t, 2, res.BrokenAt)
+}
+
+func TestAuditQueryPaginatesNewestFirst(t *testing.T) {
+	store := newAuditStore(t, newTestDB(t))
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		outcome := audit.OutcomeSuccess
+		if i%2 == 1 {
+			outcome = audit.OutcomeFailure
+		}
+		require.NoError(t, store.Record(ctx, audit.Event{Action: "signin.attempt", Actor: "alice", Outcome: outcome}))
+	}
+	require.NoError(t, store.Record(ctx, audit.Event{Action: "signup", Actor: "bob", Outcome: audit.OutcomeSuccess}))
+
+	page, err := store.Query(ctx, audit.Filter{Actor: "alice", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	require.EqualValues(t, 5, page.Entries[0].Seq)
+	require.EqualValues(t, 4, page.NextBefore)
+
+	page, err = store.Query(ctx, audit.Filter{Actor: "alice", Limit: 2, Before: page.NextBefore})
+	require.NoError(t, err)
+	require.EqualValues(t, []uint64{3, 2}, []uint64{page.Entries[0].Seq, page.Entries[1].Seq})
+
+	page, err = store.Query(ctx, audit.Filter{Actor: "alice", Limit: 2, Before: page.NextBefore})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+	require.Zero(t, page.NextBefore)
+
+	page, err = store.Query(ctx, audit.Filter{Action: "signin.", Outcome: audit.OutcomeFailure})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+
+	page, err = store.Query(ctx, audit.Filter{From: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Empty(t, page.Entries)
+}
+
+func TestAuditExportJSONLAndCEF(t *testing.T) {
+	store := newAuditStore(t, newTestDB(t))
+	ctx := context.Background()
+	require.NoError(t, store.Record(ctx, audit.Event{
+		Action: "signin.failed", Actor: "ali|ce", IP: "203.0.113.7", Outcome: audit.OutcomeFailure,
+		Details: map[string]any{"reason": "a=b"},
+	}))
+	require.NoError(t, store.Record(ctx, audit.Event{Action: "signout", Actor: "bob", Outcome: audit.OutcomeSuccess}))
+
+	var jsonl bytes.Buffer
+	require.NoError(t, store.Export(ctx, &jsonl, audit.FormatJSONL, audit.Filter{}))
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	require.Len(t, lines, 2)
+	var first audit.Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.EqualValues(t, 1, first.Seq)
+	require.Equal(t, "ali|ce", first.Actor)
+
+	var cef bytes.Buffer
+	require.NoError(t, store.Export(ctx, &cef, audit.FormatCEF, audit.Filter{Actor: "ali|ce"}))
+	line := strings.TrimSpace(cef.String())
+	require.True(t, strings.HasPrefix(line, "CEF:0|simple-go-auth|users|1.0|signin.failed|signin.failed|5|"))
+	require.Contains(t, line, `suser=ali|ce`)
+	require.Contains(t, line, `src=203.0.113.7`)
+	require.Contains(t, line, `cs1={"reason":"a\=b"}`)
+
+	require.Error(t, store.Export(ctx, &cef, "xml", audit.Filter{}))
+}
+
+func TestAuditStoreRequiresKey(t *testing.T) {
+	_, err := audit.NewStore(newTestDB(t), "")
+	require.ErrorIs(t, err, audit.ErrNoHashKey)
+}
+
+func TestSignInEventsAreAudited(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	store := newAuditStore(t, svc.DB)
+	svc.Audit = store
+	ctx := context.Background()
+
+	_, err := svc.SignIn(ctx, "alice", "wrong")
+	require.Error(t, err)
+	tokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	require.NoError(t, svc.SignOut(ctx, tokens.AccessToken))
+
+	page, err := store.Query(ctx, audit.Filter{Actor: "alice"})
+	require.NoError(t, err)
+	var got []string
+	for i := len(page.Entries) - 1; i >= 0; i-- {
+		got = append(got, page.Entries[i].Action+":"+page.Entries[i].Outcome)
+	}
+	require.Equal(t, []string{"signin.failed:failure", "signin.succeeded:success", "signout:success"}, got)
+
+	res, err := store.Verify(ctx)
+	require.NoError(t, err)
+	require.True(t, res.OK)
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"simple-go-auth/internal/users/aws"
+
+	"github.com/stretchr/testify/require"
+)
+
+// cognitoError is returned by a fakeCognito handler to answer with an AWS
+// error of that type, e.g. "NotAuthorizedException".
+type cognitoError string
+
+func (e cognitoError) Error() string { return string(e) }
+
+// fakeCognito serves the Cognito JSON API from handle, keyed by operation
+// name (InitiateAuth, RespondToAuthChallenge, ...), and counts the calls.
+type fakeCognito struct {
+	mu     sync.Mutex
+	handle map[string]func(in map[string]any) (any, error)
+	calls  map[string]int
+}
+
+func (f *fakeCognito) count(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// newFakeCognito starts f and returns a CognitoClient talking to it.
+func newFakeCognito(t *testing.T, f *fakeCognito) *aws.CognitoClient {
+	t.Helper()
+	f.calls = map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get("X-Amz-Target")
+		op := target[strings.LastIndex(target, ".")+1:]
+		var in map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&in)
+
+		f.mu.Lock()
+		f.calls[op]++
+		handle := f.handle[op]
+		f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if handle == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "InvalidParameterException", "message": op + " not faked"})
+			return
+		}
+		out, err := handle(in)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": err.Error(), "message": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	client, err := aws.NewCognitoClient("ap-southeast-2", "pool", "client")
+	require.NoError(t, err)
+	return client
+}
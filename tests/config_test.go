@@ -2,14 +2,18 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
+	"simple-go-auth/internal/users/auth"
 	"simple-go-auth/internal/users/config"
 	"simple-go-auth/internal/users/http"
+	"simple-go-auth/internal/users/rbac"
 
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -38,7 +42,7 @@ func TestLoadConfig(t *testing.T) {
 	assert.NoError(t, err)
 
 	// 3. Assert the loaded values
-	assert.Equal(t, "1234", cfg.Port)
+	assert.Equal(t, "1234", cfg.Server.Port)
 	assert.Equal(t, "foo", cfg.AWSRegion)
 	assert.Equal(t, 99, cfg.AccessTokenExpiry)
 	assert.Equal(t, "bar", cfg.DBHost)
@@ -55,14 +59,49 @@ func TestConfigEndpoint(t *testing.T) {
 	cfg, err := config.LoadConfig()
 	require.NoError(t, err)
 
-	// Mount router
-	router := http.SetupRouter(nil, nil, zap.NewNop(), cfg)
+	// Mount router; /config needs the config:read permission
+	authMw := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth.SetPrincipal(c, &auth.Principal{Username: "ops", Permissions: []string{rbac.PermConfigRead}})
+			return next(c)
+		}
+	}
+	router := http.SetupRouter(nil, authMw, zap.NewNop(), cfg)
 	req := httptest.NewRequest("GET", "/config", nil)
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
 
-	// Decode and compare
+	// Decode and compare; secrets are never served
 	var got config.Config
 	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
-	require.Equal(t, cfg, &got)
+	want := *cfg
+	for _, secret := range configSecrets(&want) {
+		*secret = ""
+	}
+	require.Equal(t, &want, &got)
+}
+
+// configSecrets returns the secret fields of cfg.
+func configSecrets(cfg *config.Config) []*string {
+	return []*string{
+		&cfg.DBPassword, &cfg.JWTSecret, &cfg.RecaptchaSecretKey, &cfg.CustomAuthSecret,
+		&cfg.SMTPPassword, &cfg.SMSGatewayAPIKey,
+		&cfg.TokenSigningKey, &cfg.MagicLinkSecret,
+		&cfg.OTPSecret,
+		&cfg.DeviceCookieSecret,
+		&cfg.OutboxWebhookSecret,
+		&cfg.OAuthRegistrationToken,
+		&cfg.ChallengeSessionSecret,
+		&cfg.AuditHashKey,
+	}
+}
+
+func TestConfigJSONOmitsSecrets(t *testing.T) {
+	var cfg config.Config
+	for i, secret := range configSecrets(&cfg) {
+		*secret = fmt.Sprintf("secret-value-%d", i)
+	}
+	raw, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "secret-value-")
 }
@@ -0,0 +1,26 @@
+package tests
+
+import (
+	"testing"
+
+	"simple-go-auth/internal/users/db"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns a migrated in-memory SQLite database for service tests
+// that don't need a real Postgres.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gormDB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(gormDB))
+
+	sqlDB, err := gormDB.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // one connection == one in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	return gormDB
+}
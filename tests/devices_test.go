@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/otp"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedDeviceSkipsMFA(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	svc.DeviceSecret = "device-secret"
+	svc.DeviceTrustTTL = time.Hour
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").Update("mfa_method", "email").Error)
+
+	laptop := auth.ClientInfo{IP: "198.51.100.4", UserAgent: "Firefox/128"}
+	ctx := auth.WithClientInfo(context.Background(), laptop)
+
+	// 1) First sign-in needs the emailed code; then the device is remembered
+	_, err := svc.SignIn(ctx, "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	_, err = svc.RespondToChallenge(ctx, "alice", ch.Name, ch.Session, sentCode(t, n.messages()[0]))
+	require.NoError(t, err)
+	cookie, err := svc.RememberDevice(ctx, "alice")
+	require.NoError(t, err)
+
+	// 2) With the cookie the challenge is skipped
+	laptop.DeviceToken = cookie
+	tokens, err := svc.SignIn(auth.WithClientInfo(context.Background(), laptop), "alice", "Secret123")
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+
+	// 3) A copied cookie doesn't help another device
+	stolen := auth.ClientInfo{UserAgent: "curl/8", DeviceToken: cookie}
+	_, err = svc.SignIn(auth.WithClientInfo(context.Background(), stolen), "alice", "Secret123")
+	require.ErrorAs(t, err, &ch)
+
+	// 4) Forgetting the device brings MFA back
+	alice := &auth.Principal{Username: "alice"}
+	devices, err := svc.ListDevices(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, devices, 1) // only successful sign-ins are tracked
+	trusted := devices[0]
+	require.True(t, trusted.Trusted)
+	require.Equal(t, "Firefox/128", trusted.UserAgent)
+
+	require.NoError(t, svc.ForgetDevice(ctx, alice, trusted.ID))
+	require.ErrorIs(t, svc.ForgetDevice(ctx, alice, trusted.ID), auth.ErrDeviceNotFound)
+	_, err = svc.SignIn(auth.WithClientInfo(context.Background(), laptop), "alice", "Secret123")
+	require.ErrorAs(t, err, &ch)
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/notify"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captureNotifier records every message instead of delivering it.
+type captureNotifier struct {
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (c *captureNotifier) Send(_ context.Context, msg notify.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+func (c *captureNotifier) messages() []notify.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]notify.Message(nil), c.sent...)
+}
+
+// newLocalAuthService returns a local-provider service on SQLite with alice
+// (password "Secret123") signed up.
+func newLocalAuthService(t *testing.T) (*auth.AuthServiceImpl, *captureNotifier) {
+	t.Helper()
+	signer, err := token.NewSigner("", "test-issuer")
+	require.NoError(t, err)
+	templates, err := notify.NewTemplates()
+	require.NoError(t, err)
+	n := &captureNotifier{}
+
+	svc := auth.NewAuthServiceImpl(nil, newTestDB(t))
+	svc.Provider = auth.ProviderLocal
+	svc.RBAC = rbac.NewStore(svc.DB)
+	require.NoError(t, svc.RBAC.Seed(context.Background(), rbac.DefaultRoles()))
+	svc.Tokens = signer
+	svc.Notifier = n
+	svc.Templates = templates
+	svc.MagicLinkSecret = "magic-secret"
+	svc.MagicLinkURL = "https://app.example.com/signin/magic/verify"
+	svc.MagicLinkTTL = 15 * time.Minute
+	require.NoError(t, svc.SignUp(context.Background(), "alice", "Secret123", "alice@example.com"))
+	return svc, n
+}
+
+var linkTokenRe = regexp.MustCompile(`https://app\.example\.com/signin/magic/verify\?token=\S+`)
+
+func magicLinkToken(t *testing.T, msg notify.Message) string {
+	t.Helper()
+	raw := linkTokenRe.FindString(msg.Text)
+	require.NotEmpty(t, raw)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func TestMagicLinkSignIn(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.RequestMagicLink(ctx, "alice@example.com", "browser-nonce", "en"))
+	msgs := n.messages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, "alice@example.com", msgs[0].To)
+	link := magicLinkToken(t, msgs[0])
+
+	// Another browser can't use the link
+	_, err := svc.VerifyMagicLink(ctx, link, "other-nonce")
+	require.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+
+	tokens, err := svc.VerifyMagicLink(ctx, link, "browser-nonce")
+	require.NoError(t, err)
+	p, err := svc.Authenticate(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, "alice", p.Username)
+
+	// Single use
+	_, err = svc.VerifyMagicLink(ctx, link, "browser-nonce")
+	require.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+}
+
+func TestMagicLinkAsksForSecondFactor(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	ctx := context.Background()
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").Update("mfa_method", "email").Error)
+
+	require.NoError(t, svc.RequestMagicLink(ctx, "alice@example.com", "nonce", "en"))
+	_, err := svc.VerifyMagicLink(ctx, magicLinkToken(t, n.messages()[0]), "nonce")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	require.Equal(t, auth.ChallengeEmailOTP, ch.Name)
+	require.Equal(t, "alice", ch.Username)
+
+	tokens, err := svc.RespondToChallenge(ctx, ch.Username, ch.Name, ch.Session, sentCode(t, n.messages()[1]))
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestMagicLinkUnknownEmailIsSilent(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+
+	require.NoError(t, svc.RequestMagicLink(context.Background(), "nobody@example.com", "nonce", "en"))
+	require.Empty(t, n.messages())
+}
+
+// failingNotifier rejects every message with err.
+type failingNotifier struct{ err error }
+
+func (f failingNotifier) Send(context.Context, notify.Message) error { return f.err }
+
+func TestMagicLinkDeliveryFailureLooksLikeUnknownEmail(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	svc.Notifier = failingNotifier{err: notify.ErrQueueFull}
+	e := newAuthRouter(svc)
+
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/signin/magic", strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code, email)
+	}
+}
+
+func TestMagicLinkRejectsTamperingAndExpiry(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.RequestMagicLink(ctx, "alice@example.com", "nonce", "en"))
+	link := magicLinkToken(t, n.messages()[0])
+
+	_, err := svc.VerifyMagicLink(ctx, link+"x", "nonce")
+	require.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+
+	svc.MagicLinkTTL = -time.Second
+	require.NoError(t, svc.RequestMagicLink(ctx, "alice@example.com", "nonce", "en"))
+	expired := magicLinkToken(t, n.messages()[1])
+	_, err = svc.VerifyMagicLink(ctx, expired, "nonce")
+	require.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+}
+
+func TestLocalProviderSignInAndRefresh(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+
+	_, err := svc.SignIn(ctx, "alice", "wrong")
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	tokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	rotated, err := svc.RefreshTokens(ctx, tokens.RefreshToken)
+	require.NoError(t, err)
+	require.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	// Replaying the rotated-out token revokes the whole family
+	_, err = svc.RefreshTokens(ctx, tokens.RefreshToken)
+	require.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+	_, err = svc.RefreshTokens(ctx, rotated.RefreshToken)
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/http/metrics"
+
+	"github.com/aws/smithy-go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestMetricsOpenMetricsExemplars(t *testing.T) {
+	// The collectors are process-wide and other tests record to them too,
+	// so counts are compared against a scrape taken first.
+	const (
+		cognitoAuthErrors = `cognito_request_errors_total{code="NotAuthorizedException",operation="InitiateAuth"}`
+		cognitoUnknown    = `cognito_request_errors_total{code="unknown",operation="InitiateAuth"}`
+		signInFailures    = `auth_signin_total{reason="invalid_credentials",result="failure"}`
+		activeSockets     = "websocket_active_connections"
+	)
+	before := scrapeMetrics(t)
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	defer tp.Shutdown(context.Background())
+	ctx, span := tp.Tracer("test").Start(context.Background(), "signin")
+	traceID := span.SpanContext().TraceID().String()
+
+	metrics.ObserveCognito(ctx, "InitiateAuth", 20*time.Millisecond,
+		&smithy.GenericAPIError{Code: "NotAuthorizedException"})
+	metrics.ObserveCognito(ctx, "InitiateAuth", 10*time.Millisecond, errors.New("network down"))
+	metrics.RecordSignIn("invalid_credentials")
+	metrics.WebSocketOpened()
+	defer metrics.WebSocketClosed()
+	span.End()
+
+	rec := scrapeMetrics(t)
+	require.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "application/openmetrics-text"))
+	for _, series := range []string{cognitoAuthErrors, cognitoUnknown, signInFailures, activeSockets} {
+		require.Equal(t, 1.0, metricSample(rec.Body.String(), series)-metricSample(before.Body.String(), series), series)
+	}
+	require.Contains(t, rec.Body.String(), `trace_id="`+traceID+`"`)
+}
+
+// scrapeMetrics fetches /metrics in the OpenMetrics format.
+func scrapeMetrics(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	metrics.MetricsHandler(e)
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec
+}
+
+// metricSample returns the value of series in an exposition body, or 0 when
+// it hasn't been recorded yet.
+func metricSample(body, series string) float64 {
+	for _, line := range strings.Split(body, "\n") {
+		rest, ok := strings.CutPrefix(line, series+" ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.Fields(rest)[0], 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	return 0
+}
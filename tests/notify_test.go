@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/notify"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTemplatesLocalizeWithFallback(t *testing.T) {
+	tpl, err := notify.NewTemplates()
+	require.NoError(t, err)
+	data := map[string]any{"Username": "<b>alice</b>", "Code": "123456", "ExpiresIn": "10 minutes"}
+
+	de, err := tpl.Email("verification", "de-AT", "alice@example.com", data)
+	require.NoError(t, err)
+	require.Equal(t, "Ihr Bestätigungscode", de.Subject)
+	require.Contains(t, de.Text, "123456")
+	require.Contains(t, de.HTML, "&lt;b&gt;alice&lt;/b&gt;") // html/template escapes
+
+	fr, err := tpl.SMS("verification", "fr", "+61400000000", data)
+	require.NoError(t, err)
+	require.Equal(t, notify.ChannelSMS, fr.Channel)
+	require.Equal(t, "Your verification code is 123456. It expires in 10 minutes.", fr.Text)
+
+	_, err = tpl.Email("does_not_exist", "en", "x@example.com", data)
+	require.Error(t, err)
+}
+
+func TestQueueDeliversThroughSMTPSink(t *testing.T) {
+	sink := newSMTPSink(t)
+	host, port := sink.Addr()
+
+	tpl, err := notify.NewTemplates()
+	require.NoError(t, err)
+	msg, err := tpl.Email("new_device", "en", "alice@example.com", map[string]any{
+		"Username": "alice", "Device": "Firefox on Linux", "IP": "203.0.113.7", "Time": "now",
+	})
+	require.NoError(t, err)
+
+	smtpNotifier := notify.NewSMTPNotifier(host, port, "", "", "no-reply@example.com")
+	q := notify.NewQueue(&notify.Router{Email: smtpNotifier}, 1, 10, 3, time.Millisecond, zap.NewNop())
+	require.NoError(t, q.Send(context.Background(), msg))
+
+	select {
+	case <-sink.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message reached the SMTP sink")
+	}
+	q.Close()
+
+	got := sink.Messages()
+	require.Len(t, got, 1)
+	require.Equal(t, "no-reply@example.com", got[0].From)
+	require.Equal(t, []string{"alice@example.com"}, got[0].To)
+	require.Contains(t, got[0].Data, "Subject: "+mime.QEncoding.Encode("utf-8", "New sign-in to your account"))
+	require.Contains(t, got[0].Data, "Content-Type: text/plain; charset=utf-8")
+	require.Contains(t, got[0].Data, "Content-Type: text/html; charset=utf-8")
+	require.Contains(t, got[0].Data, "Firefox on Linux")
+}
+
+type flakyNotifier struct {
+	failures int32
+	calls    atomic.Int32
+}
+
+func (f *flakyNotifier) Send(context.Context, notify.Message) error {
+	if f.calls.Add(1) <= f.failures {
+		return errors.New("temporary failure")
+	}
+	return nil
+}
+
+func TestQueueRetriesThenGivesUp(t *testing.T) {
+	recovering := &flakyNotifier{failures: 2}
+	q := notify.NewQueue(recovering, 1, 10, 3, time.Millisecond, zap.NewNop())
+	require.NoError(t, q.Send(context.Background(), notify.Message{Channel: notify.ChannelEmail}))
+	q.Close()
+	require.EqualValues(t, 3, recovering.calls.Load())
+
+	broken := &flakyNotifier{failures: 100}
+	q = notify.NewQueue(broken, 1, 10, 3, time.Millisecond, zap.NewNop())
+	require.NoError(t, q.Send(context.Background(), notify.Message{Channel: notify.ChannelEmail}))
+	q.Close()
+	require.EqualValues(t, 3, broken.calls.Load())
+
+	require.ErrorIs(t, q.Send(context.Background(), notify.Message{}), notify.ErrQueueClosed)
+}
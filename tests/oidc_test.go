@@ -0,0 +1,428 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/oauth"
+	"simple-go-auth/internal/users/oidc"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/token"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const rpRedirectURI = "https://wiki.example.com/callback"
+
+// relyingParty drives the provider like a browser plus the app behind it.
+type relyingParty struct {
+	t        *testing.T
+	base     string
+	http     *http.Client
+	clientID string
+	verifier string
+}
+
+// newOIDCProvider serves a provider over TLS, so the Secure session cookie
+// works, and registers a public client for the relying party.
+func newOIDCProvider(t *testing.T) (*oidc.Provider, *auth.AuthServiceImpl, *captureNotifier, *relyingParty) {
+	t.Helper()
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(zap.NewNop())
+	srv := httptest.NewTLSServer(e)
+	t.Cleanup(srv.Close)
+
+	svc, n := newLocalAuthService(t)
+	signer, err := token.NewSigner("", srv.URL)
+	require.NoError(t, err)
+	svc.Tokens = signer
+	server := oauth.NewServer(svc.DB, signer)
+	server.Users = svc
+	p := oidc.NewProvider(server, svc)
+	server.RegisterRoutes(e)
+	p.RegisterRoutes(e, auth.NewMiddleware(svc))
+
+	client, err := server.CreateClient(context.Background(), "root", oauth.ClientSpec{
+		Name:         "Wiki",
+		AuthMethod:   oauth.AuthNone,
+		GrantTypes:   []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		RedirectURIs: []string{rpRedirectURI},
+	})
+	require.NoError(t, err)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	hc := srv.Client()
+	hc.Jar = jar
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return p, svc, n, &relyingParty{t: t, base: srv.URL, http: hc, clientID: client.ID, verifier: token.RandomString(32)}
+}
+
+// authorize starts a sign-in with extra overriding the defaults.
+func (rp *relyingParty) authorize(extra url.Values) (*http.Response, string) {
+	sum := sha256.Sum256([]byte(rp.verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.clientID},
+		"redirect_uri":          {rpRedirectURI},
+		"scope":                 {"openid email offline_access"},
+		"state":                 {"state-1"},
+		"nonce":                 {"nonce-1"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	for k, v := range extra {
+		q[k] = v
+	}
+	return rp.do(http.MethodGet, "/authorize?"+q.Encode(), nil)
+}
+
+func (rp *relyingParty) post(path string, form url.Values) (*http.Response, string) {
+	return rp.do(http.MethodPost, path, form)
+}
+
+func (rp *relyingParty) do(method, path string, form url.Values) (*http.Response, string) {
+	rp.t.Helper()
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, rp.base+path, body)
+	require.NoError(rp.t, err)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := rp.http.Do(req)
+	require.NoError(rp.t, err)
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(rp.t, err)
+	return resp, string(raw)
+}
+
+var ticketRe = regexp.MustCompile(`name="request" value="([^"]+)"`)
+
+func pageTicket(t *testing.T, page string) string {
+	t.Helper()
+	m := ticketRe.FindStringSubmatch(page)
+	require.NotNil(t, m, page)
+	return m[1]
+}
+
+// callback returns the query the provider redirected back with.
+func (rp *relyingParty) callback(resp *http.Response) url.Values {
+	rp.t.Helper()
+	require.Equal(rp.t, http.StatusFound, resp.StatusCode)
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(rp.t, err)
+	require.Equal(rp.t, rpRedirectURI, loc.Scheme+"://"+loc.Host+loc.Path)
+	require.Equal(rp.t, rp.base, loc.Query().Get("iss"))
+	return loc.Query()
+}
+
+func (rp *relyingParty) exchange(code string) (int, map[string]any) {
+	resp, body := rp.post("/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {rp.clientID},
+		"code":          {code},
+		"redirect_uri":  {rpRedirectURI},
+		"code_verifier": {rp.verifier},
+	})
+	var out map[string]any
+	require.NoError(rp.t, json.Unmarshal([]byte(body), &out))
+	return resp.StatusCode, out
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	p, svc, _, rp := newOIDCProvider(t)
+	ctx := context.Background()
+
+	// Discovery points at this issuer
+	_, body := rp.do(http.MethodGet, "/.well-known/openid-configuration", nil)
+	var meta map[string]any
+	require.NoError(t, json.Unmarshal([]byte(body), &meta))
+	require.Equal(t, rp.base, meta["issuer"])
+	require.Equal(t, rp.base+"/authorize", meta["authorization_endpoint"])
+	require.Equal(t, rp.base+"/oauth/token", meta["token_endpoint"])
+
+	// 1) Login page; a wrong password shows it again
+	resp, page := rp.authorize(nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, "Wiki")
+	resp, page = rp.post("/authorize/login", url.Values{"request": {pageTicket(t, page)}, "username": {"alice"}, "password": {"wrong"}})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// 2) Sign in, consent, and back to the app with a code
+	resp, page = rp.post("/authorize/login", url.Values{"request": {pageTicket(t, page)}, "username": {"alice"}, "password": {"Secret123"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, "See your email address")
+	resp, _ = rp.post("/authorize/consent", url.Values{"request": {pageTicket(t, page)}, "decision": {"allow"}})
+	cb := rp.callback(resp)
+	require.Equal(t, "state-1", cb.Get("state"))
+
+	// 3) The code buys tokens once
+	status, tokens := rp.exchange(cb.Get("code"))
+	require.Equal(t, http.StatusOK, status, tokens)
+	require.NotEmpty(t, tokens["refresh_token"])
+	claims, err := p.OAuth.Tokens.Verify(tokens["id_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, rp.clientID, claims["aud"])
+	require.Equal(t, "nonce-1", claims["nonce"])
+	require.Equal(t, "alice@example.com", claims["email"])
+	require.Equal(t, oidc.ACRPassword, claims["acr"])
+	require.NotNil(t, claims["auth_time"])
+	require.NotEmpty(t, claims["at_hash"])
+
+	status, replay := rp.exchange(cb.Get("code"))
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid_grant", replay["error"])
+
+	// The access token is bound to the client and its scopes, and isn't
+	// accepted by the service's own routes
+	access, err := p.OAuth.Tokens.Verify(tokens["access_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, rp.clientID, access["aud"])
+	require.Equal(t, "openid email offline_access", access["scope"])
+	_, err = svc.Authenticate(ctx, tokens["access_token"].(string))
+	require.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	// 4) UserInfo agrees on the subject
+	req, err := http.NewRequest(http.MethodGet, rp.base+"/userinfo", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+	uiResp, err := rp.http.Do(req)
+	require.NoError(t, err)
+	defer uiResp.Body.Close()
+	var info map[string]any
+	require.NoError(t, json.NewDecoder(uiResp.Body).Decode(&info))
+	require.Equal(t, claims["sub"], info["sub"])
+	require.Equal(t, "alice@example.com", info["email"])
+	require.NotContains(t, info, "preferred_username") // profile wasn't granted
+
+	// 5) Refresh, only by the client the token was issued to
+	refresh := func(clientID, rt string) (*http.Response, map[string]any) {
+		resp, body := rp.post("/oauth/token", url.Values{
+			"grant_type": {"refresh_token"}, "client_id": {clientID}, "refresh_token": {rt},
+		})
+		var out map[string]any
+		require.NoError(t, json.Unmarshal([]byte(body), &out))
+		return resp, out
+	}
+	other, err := p.OAuth.CreateClient(ctx, "root", oauth.ClientSpec{
+		Name: "Other", AuthMethod: oauth.AuthNone, RedirectURIs: []string{rpRedirectURI},
+		GrantTypes: []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+	})
+	require.NoError(t, err)
+	resp, out := refresh(other.ID, tokens["refresh_token"].(string))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "invalid_grant", out["error"])
+	_, err = svc.RefreshTokens(ctx, tokens["refresh_token"].(string))
+	require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+	session, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	resp, out = refresh(rp.clientID, session.RefreshToken)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "invalid_grant", out["error"])
+
+	resp, out = refresh(rp.clientID, tokens["refresh_token"].(string))
+	require.Equal(t, http.StatusOK, resp.StatusCode, out)
+	access, err = p.OAuth.Tokens.Verify(out["access_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, rp.clientID, access["aud"])
+	require.Equal(t, "openid email offline_access", access["scope"])
+	resp, out = refresh(rp.clientID, out["refresh_token"].(string))
+	require.Equal(t, http.StatusOK, resp.StatusCode, out)
+
+	// 6) The session and stored consent make the next sign-in silent...
+	resp, _ = rp.authorize(url.Values{"prompt": {"none"}, "nonce": {"nonce-2"}})
+	cb = rp.callback(resp)
+	require.NotEmpty(t, cb.Get("code"))
+	_, tokens = rp.exchange(cb.Get("code"))
+	again, err := p.OAuth.Tokens.Verify(tokens["id_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, "nonce-2", again["nonce"])
+	require.Equal(t, claims["auth_time"], again["auth_time"])
+
+	// ...unless the session is too old or consent is asked for again
+	resp, _ = rp.authorize(url.Values{"prompt": {"none"}, "max_age": {"0"}})
+	require.Equal(t, "login_required", rp.callback(resp).Get("error"))
+	resp, page = rp.authorize(url.Values{"max_age": {"0"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, `name="password"`)
+	resp, page = rp.authorize(url.Values{"prompt": {"consent"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = rp.post("/authorize/consent", url.Values{"request": {pageTicket(t, page)}, "decision": {"deny"}})
+	require.Equal(t, "access_denied", rp.callback(resp).Get("error"))
+}
+
+func TestOIDCRejectsBadRequests(t *testing.T) {
+	_, _, _, rp := newOIDCProvider(t)
+
+	// An unregistered redirect URI is never redirected to
+	resp, _ := rp.authorize(url.Values{"redirect_uri": {"https://evil.example.com/cb"}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Location"))
+
+	// Other errors go back to the client
+	resp, _ = rp.authorize(url.Values{"code_challenge_method": {"plain"}})
+	require.Equal(t, "invalid_request", rp.callback(resp).Get("error"))
+	resp, _ = rp.authorize(url.Values{"scope": {"email"}})
+	require.Equal(t, "invalid_scope", rp.callback(resp).Get("error"))
+	resp, _ = rp.authorize(url.Values{"prompt": {"none"}})
+	require.Equal(t, "login_required", rp.callback(resp).Get("error"))
+
+	// A code is bound to the PKCE verifier
+	resp, page := rp.authorize(nil)
+	resp, page = rp.post("/authorize/login", url.Values{"request": {pageTicket(t, page)}, "username": {"alice"}, "password": {"Secret123"}})
+	resp, _ = rp.post("/authorize/consent", url.Values{"request": {pageTicket(t, page)}, "decision": {"allow"}})
+	code := rp.callback(resp).Get("code")
+	rp.verifier = token.RandomString(32)
+	status, out := rp.exchange(code)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid_grant", out["error"])
+}
+
+func TestOIDCSecondFactorRaisesACR(t *testing.T) {
+	p, svc, n, rp := newOIDCProvider(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").Update("mfa_method", "email").Error)
+
+	// The password leads to the code page; the emailed code completes sign-in
+	resp, page := rp.authorize(url.Values{"acr_values": {oidc.ACRMFA}})
+	resp, page = rp.post("/authorize/login", url.Values{"request": {pageTicket(t, page)}, "username": {"alice"}, "password": {"Secret123"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, `name="code"`)
+	form := url.Values{"request": {pageTicket(t, page)}, "code": {sentCode(t, n.messages()[0])}}
+	for _, field := range []string{"username", "challenge", "session"} {
+		m := regexp.MustCompile(`name="` + field + `" value="([^"]+)"`).FindStringSubmatch(page)
+		require.NotNil(t, m)
+		form.Set(field, m[1])
+	}
+	resp, page = rp.post("/authorize/login", form)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = rp.post("/authorize/consent", url.Values{"request": {pageTicket(t, page)}, "decision": {"allow"}})
+	_, tokens := rp.exchange(rp.callback(resp).Get("code"))
+	claims, err := p.OAuth.Tokens.Verify(tokens["id_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, oidc.ACRMFA, claims["acr"])
+	require.Contains(t, claims["amr"], "mfa")
+}
+
+func TestOIDCDeviceAuthorizationGrant(t *testing.T) {
+	p, svc, _, rp := newOIDCProvider(t)
+	cli, err := p.OAuth.CreateClient(context.Background(), "root", oauth.ClientSpec{
+		Name:       "Internal CLI",
+		AuthMethod: oauth.AuthNone,
+		GrantTypes: []string{oauth.GrantDeviceCode, oauth.GrantRefreshToken},
+	})
+	require.NoError(t, err)
+	start := func() map[string]any {
+		resp, body := rp.post("/oauth/device_authorization", url.Values{"client_id": {cli.ID}, "scope": {"openid offline_access"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		var out map[string]any
+		require.NoError(t, json.Unmarshal([]byte(body), &out))
+		return out
+	}
+	poll := func(deviceCode string) (int, map[string]any) {
+		// Waiting out the interval is the device's job; rewind instead
+		require.NoError(t, svc.DB.Model(&oauth.DeviceCode{}).Where("1 = 1").Update("last_polled_at", time.Now().Add(-time.Minute)).Error)
+		resp, body := rp.post("/oauth/token", url.Values{
+			"grant_type": {oauth.GrantDeviceCode}, "client_id": {cli.ID}, "device_code": {deviceCode},
+		})
+		var out map[string]any
+		require.NoError(t, json.Unmarshal([]byte(body), &out))
+		return resp.StatusCode, out
+	}
+
+	// 1) The device gets its codes and polls
+	started := start()
+	require.Equal(t, rp.base+"/device", started["verification_uri"])
+	require.EqualValues(t, 5, started["interval"])
+	deviceCode, userCode := started["device_code"].(string), started["user_code"].(string)
+	require.Regexp(t, `^[A-Z]{4}-[A-Z]{4}$`, userCode)
+	status, out := poll(deviceCode)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "authorization_pending", out["error"])
+
+	// Polling faster than the interval slows the device down
+	resp, body := rp.post("/oauth/token", url.Values{"grant_type": {oauth.GrantDeviceCode}, "client_id": {cli.ID}, "device_code": {deviceCode}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, body, "slow_down")
+	var dc oauth.DeviceCode
+	require.NoError(t, svc.DB.First(&dc).Error)
+	require.Equal(t, 10, dc.Interval)
+
+	// 2) The user enters the code, signs in and approves
+	resp, page := rp.do(http.MethodGet, "/device?user_code="+userCode, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, userCode)
+	resp, _ = rp.post("/device", url.Values{"user_code": {"BBBB-BBBB"}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, page = rp.post("/device", url.Values{"user_code": {strings.ToLower(userCode)}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, `name="password"`)
+	resp, page = rp.post("/authorize/login", url.Values{"request": {pageTicket(t, page)}, "username": {"alice"}, "password": {"Secret123"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, "Internal CLI")
+	resp, page = rp.post("/authorize/consent", url.Values{"request": {pageTicket(t, page)}, "decision": {"allow"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, "Device connected")
+
+	// 3) The next poll gets the user's own tokens, once; the service
+	// accepts them and refreshes them like any sign-in
+	status, tokens := poll(deviceCode)
+	require.Equal(t, http.StatusOK, status, tokens)
+	require.NotEmpty(t, tokens["refresh_token"])
+	principal, err := svc.Authenticate(context.Background(), tokens["access_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, "alice", principal.Username)
+	_, err = svc.RefreshTokens(context.Background(), tokens["refresh_token"].(string))
+	require.NoError(t, err)
+	claims, err := p.OAuth.Tokens.Verify(tokens["id_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, cli.ID, claims["aud"])
+	require.Equal(t, oidc.ACRPassword, claims["acr"])
+	status, out = poll(deviceCode)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid_grant", out["error"])
+
+	// 4) Signed in already, the user goes straight to the confirmation; a
+	// denial ends the device's polling
+	denied := start()
+	resp, page = rp.post("/device", url.Values{"user_code": {denied["user_code"].(string)}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, page, denied["user_code"].(string))
+	resp, _ = rp.post("/authorize/consent", url.Values{"request": {pageTicket(t, page)}, "decision": {"deny"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, out = poll(denied["device_code"].(string))
+	require.Equal(t, "access_denied", out["error"])
+
+	// 5) Expired codes can't be approved or redeemed
+	expired := start()
+	require.NoError(t, svc.DB.Model(&oauth.DeviceCode{}).Where("expires_at > ?", time.Now()).Update("expires_at", time.Now().Add(-time.Second)).Error)
+	resp, _ = rp.post("/device", url.Values{"user_code": {expired["user_code"].(string)}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_, out = poll(expired["device_code"].(string))
+	require.Equal(t, "expired_token", out["error"])
+
+	// Only clients registered for the grant may start it
+	resp, body = rp.post("/oauth/device_authorization", url.Values{"client_id": {rp.clientID}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, body, "unauthorized_client")
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/notify"
+	"simple-go-auth/internal/users/otp"
+
+	"github.com/stretchr/testify/require"
+)
+
+var codeRe = regexp.MustCompile(`\b\d{6}\b`)
+
+func sentCode(t *testing.T, msg notify.Message) string {
+	t.Helper()
+	code := codeRe.FindString(msg.Text)
+	require.NotEmpty(t, code)
+	return code
+}
+
+func TestOTPPolicyGenerate(t *testing.T) {
+	p := otp.Policy{Digits: 8, Secret: "k"}
+	code, err := p.Generate()
+	require.NoError(t, err)
+	require.Regexp(t, `^\d{8}$`, code)
+	require.True(t, p.Equal(p.Hash(code), code))
+	require.False(t, otp.Policy{Secret: "other"}.Equal(p.Hash(code), code))
+}
+
+func TestEmailLoginCode(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret", ResendCooldown: time.Minute}
+	ctx := context.Background()
+
+	require.NoError(t, svc.RequestLoginCode(ctx, "email", "alice@example.com", "en"))
+	// Within the cooldown nothing new is sent
+	require.NoError(t, svc.RequestLoginCode(ctx, "email", "alice@example.com", "en"))
+	require.Len(t, n.messages(), 1)
+	code := sentCode(t, n.messages()[0])
+
+	tokens, err := svc.VerifyLoginCode(ctx, "email", "alice@example.com", code)
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+
+	// Single use
+	_, err = svc.VerifyLoginCode(ctx, "email", "alice@example.com", code)
+	require.ErrorIs(t, err, auth.ErrInvalidOTP)
+
+	// Unknown destinations look the same to the caller
+	require.NoError(t, svc.RequestLoginCode(ctx, "email", "nobody@example.com", "en"))
+	require.Len(t, n.messages(), 1)
+}
+
+func TestLoginCodeAsksForSecondFactor(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	ctx := context.Background()
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").Update("mfa_method", "email").Error)
+
+	require.NoError(t, svc.RequestLoginCode(ctx, "email", "alice@example.com", "en"))
+	_, err := svc.VerifyLoginCode(ctx, "email", "alice@example.com", sentCode(t, n.messages()[0]))
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	require.Equal(t, "alice", ch.Username)
+
+	tokens, err := svc.RespondToChallenge(ctx, ch.Username, ch.Name, ch.Session, sentCode(t, n.messages()[1]))
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestLoginCodeAttemptLimit(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret", MaxAttempts: 2}
+	ctx := context.Background()
+
+	require.NoError(t, svc.RequestLoginCode(ctx, "email", "alice@example.com", "en"))
+	code := sentCode(t, n.messages()[0])
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.VerifyLoginCode(ctx, "email", "alice@example.com", wrong)
+		require.ErrorIs(t, err, auth.ErrInvalidOTP)
+	}
+	_, err := svc.VerifyLoginCode(ctx, "email", "alice@example.com", code)
+	require.ErrorIs(t, err, auth.ErrOTPAttemptsExceeded)
+}
+
+func TestLocalSMSMFAChallenge(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	ctx := context.Background()
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").
+		Updates(map[string]any{"phone": "+61400001234", "mfa_method": "sms"}).Error)
+
+	_, err := svc.SignIn(ctx, "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	require.ErrorIs(t, err, auth.ErrChallengeRequired)
+	require.Equal(t, auth.ChallengeSMSMFA, ch.Name)
+	require.Equal(t, "********1234", ch.Destination)
+
+	msgs := n.messages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, notify.ChannelSMS, msgs[0].Channel)
+	require.Equal(t, "+61400001234", msgs[0].To)
+
+	tokens, err := svc.RespondToChallenge(ctx, "alice", ch.Name, ch.Session, sentCode(t, msgs[0]))
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.RefreshToken)
+}
+
+func TestSMSMFASetupSavesPhoneOnlyOnceConfirmed(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	ctx := context.Background()
+	alice := &auth.Principal{Username: "alice"}
+	phone := func() string {
+		var user db.User
+		require.NoError(t, svc.DB.Where("username = ?", "alice").First(&user).Error)
+		return user.Phone
+	}
+
+	// The number stays pending while the code is outstanding
+	setup, err := svc.BeginMFASetup(ctx, alice, "sms", "+61400005678")
+	require.NoError(t, err)
+	require.Empty(t, phone())
+
+	// Asking again for another number sends a new code there
+	setup, err = svc.BeginMFASetup(ctx, alice, "sms", "+61400001234")
+	require.NoError(t, err)
+	msgs := n.messages()
+	require.Len(t, msgs, 2)
+	require.Equal(t, "+61400001234", msgs[1].To)
+
+	// A wrong code saves nothing; the right one saves the number it went to
+	_, err = svc.CompleteMFASetup(ctx, alice, setup.Session, "000000")
+	require.ErrorIs(t, err, auth.ErrInvalidOTP)
+	require.Empty(t, phone())
+	_, err = svc.CompleteMFASetup(ctx, alice, setup.Session, sentCode(t, msgs[1]))
+	require.NoError(t, err)
+	require.Equal(t, "+61400001234", phone())
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/outbox"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestUserLifecycleEventsAreRelayedInOrder(t *testing.T) {
+	svc, notifier := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	ctx := context.Background()
+	require.NoError(t, svc.SignUp(ctx, "bob", "Secret123", "bob@example.com"))
+	alice := &auth.Principal{Username: "alice"}
+	setup, err := svc.BeginMFASetup(ctx, alice, "sms", "+61400000000")
+	require.NoError(t, err)
+	_, err = svc.CompleteMFASetup(ctx, alice, setup.Session, sentCode(t, notifier.messages()[0]))
+	require.NoError(t, err)
+	require.NoError(t, svc.DeleteUser(ctx, "admin", "alice"))
+
+	sink := outbox.NewChannelSink(10)
+	relay := outbox.NewRelay(svc.DB, sink, zap.NewNop())
+	n, err := relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	var got []string
+	var last uint64
+	for i := 0; i < n; i++ {
+		msg := <-sink.C
+		require.Greater(t, msg.Sequence, last)
+		last = msg.Sequence
+		require.NotEmpty(t, msg.ID)
+		var data auth.UserEvent
+		require.NoError(t, json.Unmarshal(msg.Data, &data))
+		got = append(got, data.Username+":"+msg.Type)
+	}
+	require.Equal(t, []string{
+		"alice:" + outbox.UserCreated,
+		"bob:" + outbox.UserCreated,
+		"alice:" + outbox.UserChanged,
+		"alice:" + outbox.UserDeleted,
+	}, got)
+
+	// Published events are not sent again
+	n, err = relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestOutboxEventRolledBackWithChange(t *testing.T) {
+	gormDB := newTestDB(t)
+	err := gormDB.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, outbox.Enqueue(tx, "1", outbox.UserChanged, map[string]string{"k": "v"}))
+		return errors.New("change failed")
+	})
+	require.Error(t, err)
+	var count int64
+	require.NoError(t, gormDB.Model(&outbox.Event{}).Count(&count).Error)
+	require.Zero(t, count)
+}
+
+// failingSink rejects messages of one aggregate until healed.
+type failingSink struct {
+	mu        sync.Mutex
+	aggregate string
+	healed    bool
+	got       []string
+}
+
+func (f *failingSink) Publish(_ context.Context, msg outbox.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if msg.AggregateID == f.aggregate && !f.healed {
+		return errors.New("unavailable")
+	}
+	f.got = append(f.got, msg.AggregateID+":"+msg.Type)
+	return nil
+}
+
+func TestRelayHoldsBackAggregateAfterFailure(t *testing.T) {
+	gormDB := newTestDB(t)
+	ctx := context.Background()
+	for _, e := range [][2]string{
+		{"1", outbox.UserCreated}, {"2", outbox.UserCreated}, {"1", outbox.UserChanged}, {"2", outbox.UserDeleted},
+	} {
+		require.NoError(t, outbox.Enqueue(gormDB, e[0], e[1], nil))
+	}
+
+	sink := &failingSink{aggregate: "1"}
+	relay := outbox.NewRelay(gormDB, sink, zap.NewNop())
+	n, err := relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"2:" + outbox.UserCreated, "2:" + outbox.UserDeleted}, sink.got)
+
+	var failed outbox.Event
+	require.NoError(t, gormDB.Order("id").First(&failed).Error)
+	require.Equal(t, 1, failed.Attempts)
+	require.Equal(t, "unavailable", failed.LastError)
+	require.True(t, failed.NextAttemptAt.After(time.Now()))
+
+	// Still backing off: nothing for aggregate 1
+	sink.healed = true
+	n, err = relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	require.NoError(t, gormDB.Model(&outbox.Event{}).Where("id = ?", failed.ID).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	n, err = relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"1:" + outbox.UserCreated, "1:" + outbox.UserChanged}, sink.got[2:])
+}
+
+// dbSink reads the outbox while publishing; with the single test connection
+// that only works when the relay holds no transaction open.
+type dbSink struct {
+	db  *gorm.DB
+	got []string
+}
+
+func (d *dbSink) Publish(ctx context.Context, msg outbox.Message) error {
+	var pending int64
+	if err := d.db.WithContext(ctx).Model(&outbox.Event{}).Where("published_at IS NULL").Count(&pending).Error; err != nil {
+		return err
+	}
+	d.got = append(d.got, msg.AggregateID+":"+msg.Type)
+	return nil
+}
+
+func TestRelaySkipsAggregatesLeasedElsewhere(t *testing.T) {
+	gormDB := newTestDB(t)
+	ctx := context.Background()
+	for _, e := range [][2]string{
+		{"1", outbox.UserCreated}, {"2", outbox.UserCreated}, {"1", outbox.UserChanged},
+	} {
+		require.NoError(t, outbox.Enqueue(gormDB, e[0], e[1], nil))
+	}
+	// Another pass is publishing aggregate 1
+	var first outbox.Event
+	require.NoError(t, gormDB.Order("id").First(&first).Error)
+	require.NoError(t, gormDB.Model(&first).Updates(map[string]any{
+		"lease_id": "other-pass", "leased_until": time.Now().Add(time.Minute),
+	}).Error)
+
+	sink := &dbSink{db: gormDB}
+	relay := outbox.NewRelay(gormDB, sink, zap.NewNop())
+	n, err := relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"2:" + outbox.UserCreated}, sink.got)
+
+	// Its own leases are handed back; the other pass keeps its own
+	var leased []string
+	require.NoError(t, gormDB.Model(&outbox.Event{}).Where("lease_id <> ''").Pluck("lease_id", &leased).Error)
+	require.Equal(t, []string{"other-pass"}, leased)
+
+	// Once that lease expires the aggregate is picked up in order
+	require.NoError(t, gormDB.Model(&first).Update("leased_until", time.Now().Add(-time.Second)).Error)
+	n, err = relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"1:" + outbox.UserCreated, "1:" + outbox.UserChanged}, sink.got[1:])
+}
+
+func TestWebhookSinkSignsRequests(t *testing.T) {
+	var gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := outbox.VerifySignature("hook-secret", r.Header.Get(outbox.HeaderSignature), body, 5*time.Minute, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		gotKey = r.Header.Get(outbox.HeaderIdempotencyKey)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	msg := outbox.Message{ID: "evt-1", Type: outbox.UserCreated, AggregateID: "1", Data: json.RawMessage(`{}`)}
+	require.NoError(t, outbox.NewWebhookSink(srv.URL, "hook-secret").Publish(context.Background(), msg))
+	require.Equal(t, "evt-1", gotKey)
+	require.Error(t, outbox.NewWebhookSink(srv.URL, "wrong-secret").Publish(context.Background(), msg))
+
+	// Old signatures are rejected
+	body := []byte(`{}`)
+	old := outbox.Sign("hook-secret", time.Now().Add(-time.Hour), body)
+	require.ErrorIs(t, outbox.VerifySignature("hook-secret", old, body, 5*time.Minute, time.Now()), outbox.ErrInvalidSignature)
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/platform"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPlatformLoadBase(t *testing.T) {
+	t.Setenv("BILLING_PORT", "9090")
+	t.Setenv("BILLING_LOG_LEVEL", "debug")
+	t.Setenv("BILLING_LOG_SAMPLING", "false")
+	t.Setenv("BILLING_OTEL_TRACES_SAMPLER_RATIO", "0")
+	t.Setenv("PORT", "1111") // unprefixed variables belong to another service
+
+	b := platform.LoadBase(platform.NewEnv("BILLING"), "billing", "8080")
+	require.Equal(t, "billing", b.ServiceName)
+	require.Equal(t, "production", b.Env)
+	require.Equal(t, "9090", b.Server.Port)
+	require.Equal(t, 15*time.Second, b.Server.ShutdownTimeout)
+	require.Equal(t, platform.LogConfig{Level: "debug"}, b.Log)
+	require.Zero(t, b.Telemetry.SampleRatio)
+	require.Equal(t, "billing", b.Telemetry.ServiceName)
+
+	b = platform.LoadBase(platform.NewEnv("OTHER"), "other", "8080")
+	require.Equal(t, "8080", b.Server.Port)
+	require.True(t, b.Log.Sampling)
+	require.Equal(t, 1.0, b.Telemetry.SampleRatio)
+}
+
+func TestPlatformLogger(t *testing.T) {
+	logger, err := platform.NewLogger(platform.LogConfig{Level: "warn", Sampling: true})
+	require.NoError(t, err)
+	require.False(t, logger.Core().Enabled(zapcore.InfoLevel))
+	require.True(t, logger.Core().Enabled(zapcore.WarnLevel))
+
+	_, err = platform.NewLogger(platform.LogConfig{Level: "loud"})
+	require.Error(t, err)
+}
+
+func TestPlatformSecrets(t *testing.T) {
+	t.Setenv("SVC_APP_SECRETS", `{"db_dsn":"postgres://x","jwt_secret":"s3cret"}`)
+	secrets, err := platform.NewSecrets("env", "", platform.NewEnv("SVC"))
+	require.NoError(t, err)
+
+	var s struct {
+		DBDSN     string `json:"db_dsn"`
+		JWTSecret string `json:"jwt_secret"`
+	}
+	require.NoError(t, platform.JSONSecret(secrets, "APP_SECRETS", &s))
+	require.Equal(t, "s3cret", s.JWTSecret)
+
+	_, err = secrets.GetSecret("MISSING")
+	require.Error(t, err)
+	_, err = platform.NewSecrets("vault", "", nil)
+	require.Error(t, err)
+
+	t.Setenv("PLATFORM_TEST_SECRET", "plain")
+	val, err := (&platform.EnvSecrets{}).GetSecret("PLATFORM_TEST_SECRET")
+	require.NoError(t, err)
+	require.Equal(t, "plain", val)
+}
+
+func TestPlatformServeDrainsOnShutdown(t *testing.T) {
+	e := platform.NewEcho("test", zap.NewNop())
+	entered, release := make(chan struct{}), make(chan struct{})
+	e.GET("/slow", func(c echo.Context) error {
+		close(entered)
+		<-release
+		return c.String(http.StatusOK, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- platform.Serve(ctx, e, platform.ServerConfig{Port: "0", ShutdownTimeout: 5 * time.Second}, zap.NewNop())
+	}()
+	require.Eventually(t, func() bool { return e.ListenerAddr() != nil }, time.Second, 10*time.Millisecond)
+
+	type result struct {
+		body, requestID string
+		err             error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + e.ListenerAddr().String() + "/slow")
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		resc <- result{body: string(b), requestID: resp.Header.Get("X-Request-ID")}
+	}()
+	<-entered
+
+	// Shutdown waits for the in-flight request
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("server stopped before draining: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	r := <-resc
+	require.NoError(t, r.err)
+	require.Equal(t, "done", r.body)
+	require.NotEmpty(t, r.requestID)
+	require.NoError(t, <-served)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/problem"
+
+	"github.com/aws/smithy-go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func serveProblem(t *testing.T, handlerErr error) (*httptest.ResponseRecorder, problem.Problem) {
+	t.Helper()
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(zap.NewNop())
+	e.GET("/boom", func(c echo.Context) error { return handlerErr })
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	var got problem.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+	return rec, got
+}
+
+func TestProblemMapsCognitoErrors(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		code   string
+	}{
+		"UsernameExistsException":   {http.StatusConflict, "user_exists"},
+		"CodeMismatchException":     {http.StatusBadRequest, "invalid_code"},
+		"NotAuthorizedException":    {http.StatusUnauthorized, "not_authorized"},
+		"TooManyRequestsException":  {http.StatusTooManyRequests, "too_many_requests"},
+		"UserNotConfirmedException": {http.StatusForbidden, "user_not_confirmed"},
+		"SomethingNewException":     {http.StatusBadGateway, "identity_provider_error"},
+	}
+	for cognitoCode, want := range cases {
+		t.Run(cognitoCode, func(t *testing.T) {
+			err := &smithy.GenericAPIError{Code: cognitoCode, Message: "secret detail about alice@example.com"}
+			rec, got := serveProblem(t, err)
+			require.Equal(t, want.status, rec.Code)
+			require.Equal(t, want.status, got.Status)
+			require.Equal(t, want.code, got.Code)
+			require.Equal(t, problem.TypeBase+want.code, got.Type)
+			require.Equal(t, "/boom", got.Instance)
+			require.NotContains(t, rec.Body.String(), "alice@example.com")
+		})
+	}
+}
+
+func TestProblemHidesUnknownErrors(t *testing.T) {
+	rec, got := serveProblem(t, errors.New(`duplicate key value (email)=(bob@example.com)`))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, "internal_error", got.Code)
+	require.NotContains(t, rec.Body.String(), "bob@example.com")
+}
+
+func TestProblemRendersDomainErrors(t *testing.T) {
+	wrapped := auth.ErrInvalidCredentials.Wrap(errors.New("cognito said no"))
+	require.ErrorIs(t, wrapped, auth.ErrInvalidCredentials)
+
+	rec, got := serveProblem(t, wrapped)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, "invalid_credentials", got.Code)
+	require.NotContains(t, rec.Body.String(), "cognito said no")
+
+	rec, got = serveProblem(t, echo.ErrNotFound)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, "not_found", got.Code)
+}
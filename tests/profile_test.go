@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	accountauth "simple-go-auth/internal/account/auth"
+	"simple-go-auth/internal/account/profile"
+
+	"github.com/alicebob/miniredis/v2"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const profileSecret = "account-secret"
+
+func newProfileServer(t *testing.T) (*echo.Echo, *profile.Service, *miniredis.Miniredis) {
+	t.Helper()
+	gormDB := newTestDB(t)
+	require.NoError(t, profile.Migrate(gormDB))
+	mr := miniredis.RunT(t)
+	svc := &profile.Service{
+		DB:     gormDB,
+		Cache:  &profile.Cache{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()}), TTL: time.Minute},
+		Logger: zap.NewNop(),
+	}
+
+	e := echo.New()
+	e.Use(accountauth.NewMiddleware(&accountauth.Validator{ServiceSecret: []byte(profileSecret)}))
+	h := &profile.Handler{Service: svc, Subject: func(c echo.Context) string {
+		return accountauth.PrincipalFromContext(c).Subject
+	}}
+	h.RegisterRoutes(e)
+	return e, svc, mr
+}
+
+func profileRequest(t *testing.T, e *echo.Echo, method, sub, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	tok, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{
+		"sub": sub, "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(profileSecret))
+	require.NoError(t, err)
+	req := httptest.NewRequest(method, "/me", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+tok)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProfileConditionalUpdates(t *testing.T) {
+	e, _, _ := newProfileServer(t)
+
+	rec := profileRequest(t, e, http.MethodGet, "user-1", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	require.Equal(t, http.StatusNotModified, profileRequest(t, e, http.MethodGet, "user-1", "", map[string]string{"If-None-Match": `"1"`}).Code)
+
+	patch := `{"display_name":"Alice","locale":"en-au","timezone":"Australia/Sydney","preferences":{"theme":"dark","beta":true}}`
+	require.Equal(t, http.StatusPreconditionRequired, profileRequest(t, e, http.MethodPatch, "user-1", patch, nil).Code)
+	require.Equal(t, http.StatusPreconditionFailed, profileRequest(t, e, http.MethodPatch, "user-1", patch, map[string]string{"If-Match": `"7"`}).Code)
+
+	rec = profileRequest(t, e, http.MethodPatch, "user-1", patch, map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	// Merge patch: null removes a preference, absent fields are kept
+	rec = profileRequest(t, e, http.MethodPatch, "user-1", `{"preferences":{"beta":null,"lang":"en"}}`, map[string]string{"If-Match": `"2"`})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var p profile.Profile
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	require.Equal(t, "Alice", p.DisplayName)
+	require.Equal(t, "en-AU", p.Locale)
+	require.Equal(t, map[string]any{"theme": "dark", "lang": "en"}, p.Preferences)
+
+	// The first writer wins; a second one holding the old ETag is rejected
+	require.Equal(t, http.StatusPreconditionFailed, profileRequest(t, e, http.MethodPatch, "user-1", `{"display_name":"Mallory"}`, map[string]string{"If-Match": `"2"`}).Code)
+
+	require.Equal(t, http.StatusUnprocessableEntity, profileRequest(t, e, http.MethodPatch, "user-1", `{"timezone":"Mars/Olympus"}`, map[string]string{"If-Match": "*"}).Code)
+	require.Equal(t, http.StatusUnprocessableEntity, profileRequest(t, e, http.MethodPatch, "user-1", `{"avatar_url":"http://x"}`, map[string]string{"If-Match": "*"}).Code)
+
+	// Other subjects see their own profile
+	rec = profileRequest(t, e, http.MethodGet, "user-2", "", nil)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	require.Empty(t, p.DisplayName)
+
+	require.Equal(t, http.StatusPreconditionFailed, profileRequest(t, e, http.MethodDelete, "user-1", "", map[string]string{"If-Match": `"1"`}).Code)
+	require.Equal(t, http.StatusNoContent, profileRequest(t, e, http.MethodDelete, "user-1", "", map[string]string{"If-Match": `"3"`}).Code)
+	rec = profileRequest(t, e, http.MethodGet, "user-1", "", nil)
+	require.Equal(t, `"1"`, rec.Header().Get("ETag"))
+}
+
+func TestProfileReadThroughCache(t *testing.T) {
+	e, svc, mr := newProfileServer(t)
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, "user-1", 0, profile.Patch{"display_name": json.RawMessage(`"Alice"`)})
+	require.NoError(t, err)
+	require.False(t, mr.Exists("profile:user-1")) // writes evict
+
+	require.Equal(t, http.StatusOK, profileRequest(t, e, http.MethodGet, "user-1", "", nil).Code)
+	require.True(t, mr.Exists("profile:user-1"))
+
+	// Reads are served from Redis while the entry lives
+	require.NoError(t, svc.DB.Model(&profile.Profile{}).Where("subject = ?", "user-1").Update("display_name", "Stale").Error)
+	p, err := svc.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "Alice", p.DisplayName)
+
+	// Redis down: reads fall back to the database
+	mr.Close()
+	p, err = svc.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "Stale", p.DisplayName)
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/http/ws"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/risk"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginChallengeLifecycle(t *testing.T) {
+	svc := auth.NewAuthServiceImpl(nil, newTestDB(t))
+	ctx := context.Background()
+
+	ch, err := svc.CreateLoginChallenge(ctx, time.Minute, "203.0.113.7", "desktop-browser")
+	require.NoError(t, err)
+	require.Equal(t, auth.ChallengePending, ch.Status)
+
+	// Not approved yet: the waiting socket keeps polling
+	_, err = svc.CompleteLoginChallenge(ctx, ch.ID)
+	require.ErrorIs(t, err, auth.ErrChallengeNotApproved)
+
+	// Mobile approves once; a second approval is rejected
+	require.NoError(t, svc.ApproveLoginChallenge(ctx, ch.ID, "alice"))
+	require.ErrorIs(t, svc.ApproveLoginChallenge(ctx, ch.ID, "mallory"), auth.ErrChallengeNotFound)
+
+	got, err := svc.GetLoginChallenge(ctx, ch.ID)
+	require.NoError(t, err)
+	require.Equal(t, auth.ChallengeApproved, got.Status)
+	require.Equal(t, "alice", got.Username)
+	require.Equal(t, "203.0.113.7", got.RequestIP)
+}
+
+func TestLoginChallengeExpired(t *testing.T) {
+	svc := auth.NewAuthServiceImpl(nil, newTestDB(t))
+	ctx := context.Background()
+
+	ch, err := svc.CreateLoginChallenge(ctx, -time.Second, "", "")
+	require.NoError(t, err)
+
+	_, err = svc.GetLoginChallenge(ctx, ch.ID)
+	require.ErrorIs(t, err, auth.ErrChallengeNotFound)
+	require.ErrorIs(t, svc.ApproveLoginChallenge(ctx, ch.ID, "alice"), auth.ErrChallengeNotFound)
+}
+
+func TestLoginChallengeCompletion(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	svc.RBAC = nil // one test connection: role reads would wait on the transaction
+	ctx := context.Background()
+
+	// Tokens can't be issued for an unknown user: the challenge stays approved
+	ch, err := svc.CreateLoginChallenge(ctx, time.Minute, "", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.ApproveLoginChallenge(ctx, ch.ID, "ghost"))
+	_, err = svc.CompleteLoginChallenge(ctx, ch.ID)
+	require.Error(t, err)
+	got, err := svc.GetLoginChallenge(ctx, ch.ID)
+	require.NoError(t, err)
+	require.Equal(t, auth.ChallengeApproved, got.Status)
+
+	// A successful completion consumes it exactly once
+	ch, err = svc.CreateLoginChallenge(ctx, time.Minute, "", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.ApproveLoginChallenge(ctx, ch.ID, "alice"))
+	tokens, err := svc.CompleteLoginChallenge(ctx, ch.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+	_, err = svc.CompleteLoginChallenge(ctx, ch.ID)
+	require.ErrorIs(t, err, auth.ErrChallengeNotApproved)
+}
+
+func TestWebSocketCheckOrigin(t *testing.T) {
+	check := ws.CheckOrigin([]string{"https://app.example.com"})
+	for origin, want := range map[string]bool{
+		"":                         true, // native apps send none
+		"https://auth.example.com": true, // same host
+		"https://app.example.com":  true,
+		"https://APP.example.com":  true,
+		"https://evil.example.com": false,
+		"null":                     false,
+	} {
+		r := httptest.NewRequest("GET", "https://auth.example.com/ws/qr-login", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		require.Equal(t, want, check(r), origin)
+	}
+}
+
+func TestLoginChallengeRiskStepUp(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.RBAC = nil
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	reputation := risk.NewReputationLists()
+	require.NoError(t, reputation.Add("proxies", "192.0.2.0/24"))
+	svc.Risk = &risk.Engine{Rules: risk.DefaultRules(), Reputation: reputation}
+	desktop := auth.WithClientInfo(context.Background(), auth.ClientInfo{IP: "192.0.2.7"})
+
+	ch, err := svc.CreateLoginChallenge(desktop, time.Minute, "192.0.2.7", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.ApproveLoginChallenge(desktop, ch.ID, "alice"))
+
+	// A listed network gets a code to answer instead of tokens
+	_, err = svc.CompleteLoginChallenge(desktop, ch.ID)
+	var stepUp *auth.AuthChallenge
+	require.ErrorAs(t, err, &stepUp)
+	require.Equal(t, auth.ChallengeStepUp, stepUp.Name)
+	_, err = svc.CompleteLoginChallenge(desktop, ch.ID)
+	require.ErrorIs(t, err, auth.ErrChallengeNotApproved)
+
+	tokens, err := svc.RespondToChallenge(desktop, stepUp.Username, stepUp.Name, stepUp.Session, sentCode(t, n.messages()[0]))
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+}
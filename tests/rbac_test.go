@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/rbac"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// grantRole gives username role; tokens issued before keep their old roles.
+func grantRole(t *testing.T, svc *auth.AuthServiceImpl, username, role string) {
+	t.Helper()
+	require.NoError(t, svc.AssignRole(context.Background(), "system", username, role))
+}
+
+func TestRBACSeedIsIdempotentAndResolvesPermissions(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	require.NoError(t, svc.RBAC.Seed(ctx, rbac.DefaultRoles()))
+
+	roles, err := svc.RBAC.Roles(ctx)
+	require.NoError(t, err)
+	require.Len(t, roles, len(rbac.DefaultRoles()))
+
+	perms, err := svc.RBAC.Permissions(ctx, []string{rbac.RoleSupport})
+	require.NoError(t, err)
+	require.Equal(t, []string{rbac.PermAuditRead, rbac.PermUsersRead}, perms)
+
+	// Reseeding with a narrower spec replaces the permissions
+	require.NoError(t, svc.RBAC.Seed(ctx, []rbac.RoleSpec{{Name: rbac.RoleSupport, Permissions: []string{rbac.PermUsersRead}}}))
+	perms, err = svc.RBAC.Permissions(ctx, []string{rbac.RoleSupport, "unknown"})
+	require.NoError(t, err)
+	require.Equal(t, []string{rbac.PermUsersRead}, perms)
+}
+
+func TestRolesAreTokenClaimsAndPermissions(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	rec := &memoryAudit{}
+	svc.Audit = rec
+	ctx := context.Background()
+
+	tokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	p, err := svc.Authenticate(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	require.Empty(t, p.Roles)
+	require.False(t, p.HasPermission(rbac.PermUsersRead))
+
+	require.NoError(t, svc.AssignRole(ctx, "root", "alice", rbac.RoleSupport))
+	require.ErrorIs(t, svc.AssignRole(ctx, "root", "alice", "wizard"), auth.ErrRoleNotFound)
+	tokens, err = svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(tokens.AccessToken, claims)
+	require.NoError(t, err)
+	require.Equal(t, []any{rbac.RoleSupport}, claims["roles"])
+
+	p, err = svc.Authenticate(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, []string{rbac.RoleSupport}, p.Roles)
+	require.True(t, p.HasPermission(rbac.PermUsersRead))
+	require.False(t, p.HasPermission(rbac.PermUsersWrite))
+
+	require.NoError(t, svc.RevokeRole(ctx, "root", "alice", rbac.RoleSupport))
+	roles, err := svc.UserRoleNames(ctx, "alice")
+	require.NoError(t, err)
+	require.Empty(t, roles)
+	var admin []string
+	for _, e := range rec.events {
+		if e.Actor == "root" {
+			admin = append(admin, e.Action+":"+e.Outcome)
+		}
+	}
+	require.Equal(t, []string{"admin.role.assigned:success", "admin.role.assigned:failure", "admin.role.revoked:success"}, admin)
+}
+
+func TestRequirePermissionGuardsAdminRoutes(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	require.NoError(t, svc.SignUp(ctx, "bob", "Secret123", "bob@example.com"))
+	require.NoError(t, svc.SignUp(ctx, "carol", "Secret123", "carol@example.com"))
+	grantRole(t, svc, "alice", rbac.RoleAdmin)
+	grantRole(t, svc, "carol", rbac.RoleSupport)
+
+	e := newAuthRouter(svc)
+
+	call := func(username, method, path string) int {
+		tokens, err := svc.SignIn(ctx, username, "Secret123")
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Support reads but cannot change users or roles
+	require.Equal(t, http.StatusForbidden, call("bob", http.MethodGet, "/admin/users"))
+	require.Equal(t, http.StatusOK, call("carol", http.MethodGet, "/admin/users"))
+	require.Equal(t, http.StatusForbidden, call("carol", http.MethodPost, "/admin/users/bob/disable"))
+	require.Equal(t, http.StatusForbidden, call("carol", http.MethodPut, "/admin/users/bob/roles/support"))
+
+	// Admin grants roles; the new role applies to tokens issued afterwards
+	require.Equal(t, http.StatusNoContent, call("alice", http.MethodPut, "/admin/users/bob/roles/support"))
+	require.Equal(t, http.StatusNotFound, call("alice", http.MethodPut, "/admin/users/bob/roles/wizard"))
+	require.Equal(t, http.StatusOK, call("bob", http.MethodGet, "/admin/users/alice/roles"))
+	require.Equal(t, http.StatusConflict, call("alice", http.MethodDelete, "/admin/users/alice/roles/admin"))
+	require.Equal(t, http.StatusNoContent, call("alice", http.MethodDelete, "/admin/users/bob/roles/support"))
+	require.Equal(t, http.StatusForbidden, call("bob", http.MethodGet, "/admin/users"))
+}
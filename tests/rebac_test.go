@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/problem"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/rebac"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testNamespaces = `
+# users of the users service
+namespace user {}
+
+namespace group {
+  relation member: this
+}
+
+namespace folder {
+  relation viewer
+}
+
+namespace document {
+  relation parent
+  relation owner
+  relation banned
+  relation editor: this | owner
+  relation viewer: (this | editor | parent->viewer) - banned
+}
+`
+
+func newTestRelations(t *testing.T) *rebac.Store {
+	t.Helper()
+	cfg, err := rebac.ParseConfig(testNamespaces)
+	require.NoError(t, err)
+	return rebac.NewStore(newTestDB(t), cfg)
+}
+
+func tuples(t *testing.T, in ...string) []rebac.Tuple {
+	t.Helper()
+	out := make([]rebac.Tuple, len(in))
+	for i, s := range in {
+		tp, err := rebac.ParseTuple(s)
+		require.NoError(t, err)
+		out[i] = tp
+	}
+	return out
+}
+
+func TestParseNamespaceConfigRejectsMistakes(t *testing.T) {
+	for _, src := range []string{
+		`namespace doc { relation viewer: editor }`,
+		`namespace doc { relation viewer relation viewer }`,
+		`namespace doc { relation viewer: parent->viewer }`,
+		`namespace doc { relation viewer: ( this }`,
+		`namespace doc { relation viewer: this | }`,
+		`namespace doc {} namespace doc {}`,
+	} {
+		_, err := rebac.ParseConfig(src)
+		require.Error(t, err, src)
+	}
+
+	cfg, err := rebac.ParseConfig(testNamespaces)
+	require.NoError(t, err)
+	viewer := cfg.Namespaces["document"].Relations["viewer"]
+	require.Equal(t, rebac.OpExclusion, viewer.Op)
+	require.Equal(t, rebac.OpUnion, viewer.Children[0].Op)
+	require.Equal(t, rebac.OpTupleToUserset, viewer.Children[0].Children[2].Op)
+}
+
+func TestRelationCheckFollowsRewrites(t *testing.T) {
+	s := newTestRelations(t)
+	ctx := context.Background()
+	_, err := s.Write(ctx, tuples(t,
+		"group:eng#member@user:alice",
+		"document:readme#owner@user:bob",
+		"document:readme#editor@group:eng#member",
+		"document:readme#parent@folder:docs",
+		"folder:docs#viewer@user:carol",
+		"document:readme#banned@user:carol",
+		"document:spec#parent@folder:docs",
+	), nil)
+	require.NoError(t, err)
+
+	check := func(object, relation, subject string) bool {
+		o, err := rebac.ParseObject(object)
+		require.NoError(t, err)
+		sub, err := rebac.ParseSubject(subject)
+		require.NoError(t, err)
+		ok, _, err := s.Check(ctx, o, relation, sub, "")
+		require.NoError(t, err)
+		return ok
+	}
+	require.True(t, check("document:readme", "editor", "user:alice"))  // via group userset
+	require.True(t, check("document:readme", "viewer", "user:bob"))    // owner → editor → viewer
+	require.False(t, check("document:readme", "viewer", "user:carol")) // banned
+	require.True(t, check("document:spec", "viewer", "user:carol"))    // via parent folder
+	require.False(t, check("document:readme", "owner", "user:alice"))
+	require.True(t, check("document:readme", "editor", "group:eng#member"))
+
+	_, err = s.Write(ctx, tuples(t, "document:readme#writer@user:alice"), nil)
+	require.ErrorIs(t, err, rebac.ErrUnknownRelation)
+	_, err = rebac.ParseTuple("document:readme@user:alice")
+	require.ErrorIs(t, err, rebac.ErrInvalidTuple)
+
+	page, err := s.ListObjects(ctx, "document", "viewer", rebac.Subject{Namespace: "user", ID: "carol"}, "", 0, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"document:spec"}, page.Objects)
+	page, err = s.ListObjects(ctx, "document", "viewer", rebac.Subject{Namespace: "user", ID: "alice"}, "", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"document:readme"}, page.Objects)
+	require.Equal(t, "readme", page.NextAfter)
+}
+
+func TestRelationZookieGivesReadAfterWrite(t *testing.T) {
+	writer := newTestRelations(t)
+	reader := rebac.NewStore(writer.DB, writer.Config) // another replica
+	reader.Staleness = time.Hour
+	ctx := context.Background()
+	doc := rebac.Object{Namespace: "document", ID: "readme"}
+	alice := rebac.Subject{Namespace: "user", ID: "alice"}
+
+	ok, _, err := reader.Check(ctx, doc, "viewer", alice, "")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	z, err := writer.Write(ctx, tuples(t, "document:readme#viewer@user:alice"), nil)
+	require.NoError(t, err)
+
+	// Without the zookie the reader may use its cached snapshot
+	ok, _, err = reader.Check(ctx, doc, "viewer", alice, "")
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, at, err := reader.Check(ctx, doc, "viewer", alice, z)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, z, at)
+
+	z, err = writer.Write(ctx, nil, tuples(t, "document:readme#viewer@user:alice"))
+	require.NoError(t, err)
+	ok, _, err = reader.Check(ctx, doc, "viewer", alice, z)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = reader.Check(ctx, doc, "viewer", alice, "not-a-zookie")
+	require.ErrorIs(t, err, rebac.ErrInvalidZookie)
+}
+
+func TestRelationExpandReturnsUsersetTree(t *testing.T) {
+	s := newTestRelations(t)
+	ctx := context.Background()
+	_, err := s.Write(ctx, tuples(t,
+		"document:readme#owner@user:bob",
+		"document:readme#editor@group:eng#member",
+	), nil)
+	require.NoError(t, err)
+
+	tree, _, err := s.Expand(ctx, rebac.Object{Namespace: "document", ID: "readme"}, "editor", "")
+	require.NoError(t, err)
+	require.Equal(t, rebac.OpUnion, tree.Op)
+	require.Equal(t, []string{"group:eng#member"}, tree.Children[0].Subjects)
+	owner := tree.Children[1]
+	require.Equal(t, rebac.OpComputed, owner.Op)
+	require.Equal(t, "owner", owner.Children[0].Relation)
+	require.Equal(t, []string{"user:bob"}, owner.Children[0].Subjects)
+}
+
+func TestRelationsAPIAndUserDeletion(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	ctx := context.Background()
+	cfg, err := rebac.ParseConfig(testNamespaces)
+	require.NoError(t, err)
+	svc.Relations = rebac.NewStore(svc.DB, cfg)
+	require.NoError(t, svc.SignUp(ctx, "bob", "Secret123", "bob@example.com"))
+	grantRole(t, svc, "alice", rbac.RoleService)
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(zap.NewNop())
+	rh := &rebac.Handler{Store: svc.Relations}
+	rh.RegisterRoutes(e.Group("/authz", auth.NewMiddleware(svc)),
+		auth.RequirePermission(rbac.PermRelationsRead), auth.RequirePermission(rbac.PermRelationsWrite))
+
+	call := func(username, path, body string) (int, map[string]any) {
+		tokens, err := svc.SignIn(ctx, username, "Secret123")
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out
+	}
+
+	code, _ := call("bob", "/authz/relation-tuples", `{"writes":["document:readme#owner@user:bob"]}`)
+	require.Equal(t, http.StatusForbidden, code)
+	code, out := call("alice", "/authz/relation-tuples", `{"writes":["document:readme#owner@user:bob"]}`)
+	require.Equal(t, http.StatusOK, code)
+	zookie := out["zookie"].(string)
+
+	code, out = call("alice", "/authz/check",
+		`{"object":"document:readme","relation":"viewer","subject":"user:bob","zookie":"`+zookie+`"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, true, out["allowed"])
+
+	code, _ = call("alice", "/authz/check", `{"object":"document:readme","relation":"reader","subject":"user:bob"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// Deleting the user deletes the tuples naming them
+	require.NoError(t, svc.DeleteUser(ctx, "alice", "bob"))
+	svc.Relations.Staleness = 0
+	ok, _, err := svc.Relations.Check(ctx, rebac.Object{Namespace: "document", ID: "readme"}, "owner",
+		rebac.Subject{Namespace: "user", ID: "bob"}, "")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
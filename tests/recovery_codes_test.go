@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/notify"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/risk"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAudit keeps audit events in memory.
+type memoryAudit struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (m *memoryAudit) Record(_ context.Context, e audit.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memoryAudit) actions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []string
+	for _, e := range m.events {
+		out = append(out, e.Action+":"+e.Outcome)
+	}
+	return out
+}
+
+func TestRecoveryCodeReplacesMFACode(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	rec := &memoryAudit{}
+	svc.Audit = rec
+	ctx := context.Background()
+	alice := &auth.Principal{Username: "alice"}
+
+	// Enable email MFA; the recovery codes come back exactly once
+	setup, err := svc.BeginMFASetup(ctx, alice, "email", "")
+	require.NoError(t, err)
+	codes, err := svc.CompleteMFASetup(ctx, alice, setup.Session, sentCode(t, n.messages()[0]))
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	var stored []db.RecoveryCode
+	require.NoError(t, svc.DB.Find(&stored).Error)
+	require.Len(t, stored, 10)
+	for _, rc := range stored {
+		require.NotContains(t, codes, rc.CodeHash)
+	}
+
+	// Sign in with a recovery code instead of the emailed one
+	_, err = svc.SignIn(ctx, "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	tokens, err := svc.SignInWithRecoveryCode(ctx, "alice", ch.Name, ch.Session, strings.ToUpper(codes[0]))
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+
+	// Each code works once
+	_, err = svc.SignIn(ctx, "alice", "Secret123")
+	require.ErrorAs(t, err, &ch)
+	_, err = svc.SignInWithRecoveryCode(ctx, "alice", ch.Name, ch.Session, codes[0])
+	require.ErrorIs(t, err, auth.ErrInvalidRecoveryCode)
+
+	require.Equal(t, []string{
+		"mfa.enabled:success",
+		"mfa.recovery_codes.generated:success",
+		"signin.challenged:success",
+		"mfa.recovery_code.used:success",
+		"signin.succeeded:success",
+		"signin.challenged:success",
+		"mfa.recovery_code.used:failure",
+		"signin.failed:failure",
+	}, rec.actions())
+
+	last := n.messages()
+	var alerted bool
+	for _, m := range last {
+		alerted = alerted || strings.Contains(m.Text, "recovery code was used")
+	}
+	require.True(t, alerted)
+}
+
+func TestRecoveryCodeNeedsPasswordStep(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	codes, err := svc.GenerateRecoveryCodes(context.Background(), "alice")
+	require.NoError(t, err)
+
+	_, err = svc.SignInWithRecoveryCode(context.Background(), "alice", auth.ChallengeEmailOTP, "made-up-session", codes[0])
+	require.ErrorIs(t, err, auth.ErrInvalidRecoveryCode)
+
+	// Regenerating invalidates the old set
+	fresh, err := svc.GenerateRecoveryCodes(context.Background(), "alice")
+	require.NoError(t, err)
+	require.NotEqual(t, codes, fresh)
+	var count int64
+	svc.DB.Model(&db.RecoveryCode{}).Count(&count)
+	require.EqualValues(t, 10, count)
+}
+
+func TestRegenerateRecoveryCodesNeedsMFAAndPassword(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	ctx := context.Background()
+	alice := &auth.Principal{Username: "alice"}
+
+	_, err := svc.RegenerateRecoveryCodes(ctx, alice, "Secret123")
+	require.ErrorIs(t, err, auth.ErrMFANotEnabled)
+
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").Update("mfa_method", "email").Error)
+	_, err = svc.RegenerateRecoveryCodes(ctx, alice, "")
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = svc.RegenerateRecoveryCodes(ctx, alice, "wrong")
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	codes, err := svc.RegenerateRecoveryCodes(ctx, alice, "Secret123")
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+}
+
+// newCognitoMFAService returns a Cognito-backed service whose pool asks alice
+// for an SMS code and accepts any answer.
+func newCognitoMFAService(t *testing.T) (*auth.AuthServiceImpl, *fakeCognito) {
+	t.Helper()
+	issued := 0
+	f := &fakeCognito{handle: map[string]func(map[string]any) (any, error){
+		"InitiateAuth": func(map[string]any) (any, error) {
+			return map[string]any{"ChallengeName": "SMS_MFA", "Session": "cognito-session"}, nil
+		},
+		"RespondToAuthChallenge": func(map[string]any) (any, error) {
+			issued++
+			return map[string]any{"AuthenticationResult": map[string]any{
+				"AccessToken":  "access",
+				"IdToken":      "id",
+				"RefreshToken": fmt.Sprintf("refresh-%d", issued),
+				"ExpiresIn":    3600,
+				"TokenType":    "Bearer",
+			}}, nil
+		},
+	}}
+	svc := auth.NewAuthServiceImpl(newFakeCognito(t, f), newTestDB(t))
+	svc.SessionSecret = "session-secret"
+	require.NoError(t, svc.DB.Create(&db.User{Username: "alice", Email: "alice@example.com", Password: "-"}).Error)
+	return svc, f
+}
+
+func TestChallengeSessionIsSingleUse(t *testing.T) {
+	svc, f := newCognitoMFAService(t)
+	ctx := context.Background()
+
+	_, err := svc.SignIn(ctx, "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+
+	_, err = svc.RespondToChallenge(ctx, "alice", ch.Name, ch.Session, "123456")
+	require.NoError(t, err)
+
+	// Replaying the answered session is refused before reaching Cognito
+	_, err = svc.RespondToChallenge(ctx, "alice", ch.Name, ch.Session, "123456")
+	require.ErrorIs(t, err, auth.ErrInvalidChallengeSession)
+	require.Equal(t, 1, f.count("RespondToAuthChallenge"))
+}
+
+func TestRecoveryCodeAnswersCognitoStepUp(t *testing.T) {
+	f := &fakeCognito{handle: map[string]func(map[string]any) (any, error){
+		"InitiateAuth": func(map[string]any) (any, error) {
+			return map[string]any{"AuthenticationResult": map[string]any{"AccessToken": "dropped", "RefreshToken": "dropped"}}, nil
+		},
+		"AdminInitiateAuth": func(map[string]any) (any, error) {
+			return map[string]any{"ChallengeName": "CUSTOM_CHALLENGE", "Session": "custom-session"}, nil
+		},
+		"AdminRespondToAuthChallenge": func(map[string]any) (any, error) {
+			return map[string]any{"AuthenticationResult": map[string]any{
+				"AccessToken": "access", "IdToken": "id", "RefreshToken": "refresh", "ExpiresIn": 3600, "TokenType": "Bearer",
+			}}, nil
+		},
+	}}
+	svc := auth.NewAuthServiceImpl(newFakeCognito(t, f), newTestDB(t))
+	svc.CustomAuthSecret = "custom-secret"
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	templates, err := notify.NewTemplates()
+	require.NoError(t, err)
+	svc.Notifier, svc.Templates = &captureNotifier{}, templates
+	reputation := risk.NewReputationLists()
+	require.NoError(t, reputation.Add("tor", "192.0.2.0/24"))
+	svc.Risk = &risk.Engine{Rules: risk.DefaultRules(), Reputation: reputation}
+	require.NoError(t, svc.DB.Create(&db.User{Username: "alice", Email: "alice@example.com", Password: "-"}).Error)
+	ctx := auth.WithClientInfo(context.Background(), auth.ClientInfo{IP: "192.0.2.7"})
+
+	codes, err := svc.GenerateRecoveryCodes(ctx, "alice")
+	require.NoError(t, err)
+	_, err = svc.SignIn(ctx, "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	require.Equal(t, auth.ChallengeStepUp, ch.Name)
+
+	tokens, err := svc.SignInWithRecoveryCode(ctx, "alice", ch.Name, ch.Session, codes[0])
+	require.NoError(t, err)
+	require.Equal(t, "access", tokens.AccessToken)
+
+	// The step-up code was used up with the session
+	_, err = svc.SignInWithRecoveryCode(ctx, "alice", ch.Name, ch.Session, codes[1])
+	require.ErrorIs(t, err, auth.ErrInvalidRecoveryCode)
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/oauth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	gormDB := newTestDB(t)
+	svc := auth.NewAuthServiceImpl(nil, gormDB)
+	ctx := context.Background()
+
+	user := &db.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	require.NoError(t, gormDB.Create(user).Error)
+	expires := time.Now().Add(time.Hour)
+
+	// Tokens from separate sign-ins both have an empty PreviousToken
+	rotated := &db.RefreshToken{UserID: user.ID, Token: "rt-1", ExpiresAt: expires, Revoked: true}
+	current := &db.RefreshToken{UserID: user.ID, Token: "rt-2", ExpiresAt: expires, PreviousToken: "rt-1"}
+	other := &db.RefreshToken{UserID: user.ID, Token: "rt-3", ExpiresAt: expires}
+	require.NoError(t, gormDB.Create(rotated).Error)
+	require.NoError(t, gormDB.Create(current).Error)
+	require.NoError(t, gormDB.Create(other).Error)
+
+	// Unknown tokens are just invalid
+	_, err := svc.RefreshTokens(ctx, "rt-unknown")
+	require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+
+	// A rotated token coming back revokes every live token of the user
+	_, err = svc.RefreshTokens(ctx, "rt-1")
+	require.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+	var live int64
+	require.NoError(t, gormDB.Model(&db.RefreshToken{}).
+		Where("user_id = ? AND revoked = false", user.ID).Count(&live).Error)
+	require.Zero(t, live)
+}
+
+func TestCognitoRefreshTokenOutlivesAccessToken(t *testing.T) {
+	issued := 0
+	f := &fakeCognito{handle: map[string]func(map[string]any) (any, error){
+		// Password sign-in and refresh both come through InitiateAuth
+		"InitiateAuth": func(map[string]any) (any, error) {
+			issued++
+			return map[string]any{"AuthenticationResult": map[string]any{
+				"AccessToken":  "access",
+				"IdToken":      "id",
+				"RefreshToken": fmt.Sprintf("refresh-%d", issued),
+				"ExpiresIn":    3600,
+				"TokenType":    "Bearer",
+			}}, nil
+		},
+	}}
+	svc := auth.NewAuthServiceImpl(newFakeCognito(t, f), newTestDB(t))
+	svc.RefreshTokenTTL = 30 * 24 * time.Hour
+	require.NoError(t, svc.DB.Create(&db.User{Username: "alice", Email: "alice@example.com", Password: "-"}).Error)
+	ctx := context.Background()
+
+	// Refresh tokens report their own lifetime, not the access token's hour
+	lastsLong := func(tok string) {
+		t.Helper()
+		info, err := svc.IntrospectToken(ctx, tok, oauth.HintRefreshToken)
+		require.NoError(t, err)
+		require.True(t, info.Active)
+		require.Greater(t, info.Exp, time.Now().Add(29*24*time.Hour).Unix())
+	}
+	tokens, err := svc.SignIn(ctx, "alice", "Secret123")
+	require.NoError(t, err)
+	lastsLong(tokens.RefreshToken)
+
+	tokens, err = svc.RefreshTokens(ctx, tokens.RefreshToken)
+	require.NoError(t, err)
+	require.Equal(t, "refresh-2", tokens.RefreshToken)
+	lastsLong(tokens.RefreshToken)
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/db"
+	"simple-go-auth/internal/users/otp"
+	"simple-go-auth/internal/users/risk"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskRulesEvaluate(t *testing.T) {
+	rules := risk.DefaultRules()
+	cases := []struct {
+		name    string
+		signals risk.Signals
+		want    risk.Decision
+		reasons []string
+	}{
+		{"clean", risk.Signals{}, risk.Allow, []string{}},
+		{"new device only", risk.Signals{NewDevice: true}, risk.Allow, []string{risk.ReasonNewDevice}},
+		{"new device at night", risk.Signals{NewDevice: true, UnusualHour: true}, risk.Allow,
+			[]string{risk.ReasonNewDevice, risk.ReasonUnusualHour}},
+		{"impossible travel", risk.Signals{TravelSpeedKmh: 5000}, risk.StepUp, []string{risk.ReasonImpossibleTravel}},
+		{"plausible travel", risk.Signals{TravelSpeedKmh: 800}, risk.Allow, []string{}},
+		{"listed ip", risk.Signals{ListedIn: "tor"}, risk.StepUp, []string{risk.ReasonIPReputation}},
+		{"listed ip brute force", risk.Signals{ListedIn: "tor", RecentFailures: 5}, risk.Block,
+			[]string{risk.ReasonIPReputation, risk.ReasonFailedVelocity}},
+		{"few failures", risk.Signals{RecentFailures: 4}, risk.Allow, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := rules.Evaluate(tc.signals)
+			require.Equal(t, tc.want, a.Decision)
+			require.Equal(t, tc.reasons, a.Reasons)
+		})
+	}
+}
+
+func TestRiskRulesFileAndHours(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"block_at": 20, "velocity_window": "1h"}`), 0o600))
+	rules, err := risk.LoadRules(path)
+	require.NoError(t, err)
+	require.Equal(t, 20, rules.BlockAt)
+	require.Equal(t, 40, rules.StepUpAt) // untouched defaults stay
+	require.Equal(t, risk.Block, rules.Evaluate(risk.Signals{NewDevice: true}).Decision)
+
+	usual := []int{8, 9, 9, 10, 17, 18, 8, 9, 23, 22}
+	require.False(t, rules.UnusualHour(0, usual)) // wraps around midnight
+	require.True(t, rules.UnusualHour(3, usual))
+	require.False(t, rules.UnusualHour(3, usual[:5])) // not enough history
+}
+
+func TestReputationListsAndDistance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tor-exits.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# exits\n203.0.113.9\n198.51.100.0/24 # range\n"), 0o600))
+	lists, err := risk.LoadReputationFiles(path)
+	require.NoError(t, err)
+	require.Equal(t, "tor-exits", lists.Match("203.0.113.9"))
+	require.Equal(t, "tor-exits", lists.Match("198.51.100.77"))
+	require.Empty(t, lists.Match("192.0.2.1"))
+
+	sydney := risk.Location{Latitude: -33.87, Longitude: 151.21}
+	london := risk.Location{Latitude: 51.51, Longitude: -0.13}
+	require.InDelta(t, 16990, risk.DistanceKm(sydney, london), 50)
+}
+
+// staticGeo resolves IPs from a fixed table.
+type staticGeo map[string]risk.Location
+
+func (g staticGeo) Lookup(ip string) (risk.Location, bool) {
+	loc, ok := g[ip]
+	return loc, ok
+}
+
+func TestSignInRiskStepUpAndBlock(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	rec := &memoryAudit{}
+	svc.Audit = rec
+
+	reputation := risk.NewReputationLists()
+	require.NoError(t, reputation.Add("botnet", "192.0.2.0/24"))
+	rules := risk.DefaultRules()
+	rules.NewDeviceWeight = 0 // isolate the travel signal
+	svc.Risk = &risk.Engine{
+		Rules: rules,
+		Geo: staticGeo{
+			"203.0.113.1":  {Country: "AU", Latitude: -33.87, Longitude: 151.21},
+			"198.51.100.1": {Country: "GB", Latitude: 51.51, Longitude: -0.13},
+		},
+		Reputation: reputation,
+	}
+	from := func(ip string) context.Context {
+		return auth.WithClientInfo(context.Background(), auth.ClientInfo{IP: ip, UserAgent: "Safari/17"})
+	}
+
+	// 1) Home sign-in is allowed outright
+	_, err := svc.SignIn(from("203.0.113.1"), "alice", "Secret123")
+	require.NoError(t, err)
+
+	// 2) London minutes later: step up with an emailed code
+	_, err = svc.SignIn(from("198.51.100.1"), "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	require.Equal(t, auth.ChallengeStepUp, ch.Name)
+	tokens, err := svc.RespondToChallenge(from("198.51.100.1"), "alice", ch.Name, ch.Session, sentCode(t, n.messages()[0]))
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.AccessToken)
+
+	// 3) A listed network guessing passwords gets blocked
+	for i := 0; i < 5; i++ {
+		_, err = svc.SignIn(from("192.0.2.50"), "alice", "wrong")
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	_, err = svc.SignIn(from("192.0.2.50"), "alice", "Secret123")
+	require.ErrorIs(t, err, auth.ErrSignInBlocked)
+
+	require.Equal(t, "signin.failed", rec.events[len(rec.events)-1].Action)
+	last := rec.events[len(rec.events)-2]
+	require.Equal(t, "signin.risk_assessed", last.Action)
+	require.Equal(t, risk.Block, last.Details["decision"])
+	require.Contains(t, last.Details["reasons"], risk.ReasonIPReputation)
+}
+
+func TestPasswordlessSignInRiskBlock(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	reputation := risk.NewReputationLists()
+	require.NoError(t, reputation.Add("botnet", "192.0.2.0/24"))
+	svc.Risk = &risk.Engine{Rules: risk.DefaultRules(), Reputation: reputation}
+	// Listed network and a new device: 60 + 20 reaches the block threshold
+	ctx := auth.WithClientInfo(context.Background(), auth.ClientInfo{IP: "192.0.2.50", UserAgent: "curl/8"})
+
+	require.NoError(t, svc.RequestMagicLink(ctx, "alice@example.com", "nonce", "en"))
+	_, err := svc.VerifyMagicLink(ctx, magicLinkToken(t, n.messages()[0]), "nonce")
+	require.ErrorIs(t, err, auth.ErrSignInBlocked)
+
+	require.NoError(t, svc.RequestLoginCode(ctx, "email", "alice@example.com", "en"))
+	_, err = svc.VerifyLoginCode(ctx, "email", "alice@example.com", sentCode(t, n.messages()[1]))
+	require.ErrorIs(t, err, auth.ErrSignInBlocked)
+}
+
+func TestSignInSuccessIsRecordedOnceTokensAreIssued(t *testing.T) {
+	svc, n := newLocalAuthService(t)
+	svc.OTP = otp.Policy{Secret: "otp-secret"}
+	svc.Risk = &risk.Engine{Rules: risk.DefaultRules()}
+	require.NoError(t, svc.DB.Model(&db.User{}).Where("username = ?", "alice").Update("mfa_method", "email").Error)
+	ctx := auth.WithClientInfo(context.Background(), auth.ClientInfo{IP: "203.0.113.1"})
+	successes := func() int64 {
+		var count int64
+		require.NoError(t, svc.DB.Model(&db.LoginAttempt{}).
+			Where("username = ? AND success = ?", "alice", true).Count(&count).Error)
+		return count
+	}
+
+	// The password passed, but the sign-in isn't done until the code is in
+	_, err := svc.SignIn(ctx, "alice", "Secret123")
+	var ch *auth.AuthChallenge
+	require.ErrorAs(t, err, &ch)
+	require.Zero(t, successes())
+
+	_, err = svc.RespondToChallenge(ctx, "alice", ch.Name, ch.Session, sentCode(t, n.messages()[0]))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, successes())
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/config"
+	router "simple-go-auth/internal/users/http"
+	"simple-go-auth/internal/users/problem"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newAuthRouter mounts svc's routes, MFA included, on a fresh Echo.
+func newAuthRouter(svc *auth.AuthServiceImpl) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(zap.NewNop())
+	router.RegisterRoutes(e, auth.NewHandler(svc, &config.Config{}), auth.NewMiddleware(svc), &config.Config{MFAEnabled: true})
+	return e
+}
+
+func TestRegisterRoutesMountsConfirm(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	e := newAuthRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/confirm", strings.NewReader(`{"username":"alice","code":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
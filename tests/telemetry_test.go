@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/platform"
+	"simple-go-auth/internal/users/auth"
+	"simple-go-auth/internal/users/config"
+	"simple-go-auth/internal/users/otel"
+
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector is a local OTLP/HTTP collector stand-in that keeps what it receives.
+type fakeCollector struct {
+	mu       sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+}
+
+func (f *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestInitTracerExportsAuthSpansOverOTLP(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	cfg := &config.Config{Base: platform.Base{
+		Telemetry: platform.TelemetryConfig{
+			ServiceName:    "users-test",
+			ServiceVersion: "1.2.3",
+			Env:            "test",
+			Exporter:       "otlp-http",
+			Endpoint:       srv.URL,
+			SampleRatio:    1.0,
+		},
+	}}
+	shutdown, err := otel.InitTracer(context.Background(), cfg)
+	require.NoError(t, err)
+
+	svc := auth.NewAuthServiceImpl(nil, newTestDB(t))
+	_, err = svc.CreateLoginChallenge(context.Background(), time.Minute, "", "")
+	require.NoError(t, err)
+	_, err = svc.GetLoginChallenge(context.Background(), "missing")
+	require.ErrorIs(t, err, auth.ErrChallengeNotFound)
+
+	shutdown() // flushes the batcher
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	require.NotEmpty(t, collector.requests)
+
+	resAttrs := map[string]string{}
+	spans := map[string]string{} // name -> status message
+	for _, req := range collector.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, kv := range rs.Resource.Attributes {
+				resAttrs[kv.Key] = kv.Value.GetStringValue()
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s.Status.GetMessage()
+				}
+			}
+		}
+	}
+	require.Equal(t, "users-test", resAttrs["service.name"])
+	require.Equal(t, "1.2.3", resAttrs["service.version"])
+	require.Equal(t, "test", resAttrs["deployment.environment"])
+	require.Contains(t, spans, "AuthService.CreateLoginChallenge")
+	require.Equal(t, auth.ErrChallengeNotFound.Code, spans["AuthService.GetLoginChallenge"])
+}
+
+func TestInitTracerRejectsUnknownExporter(t *testing.T) {
+	_, err := otel.InitTracer(context.Background(), &config.Config{Base: platform.Base{Telemetry: platform.TelemetryConfig{Exporter: "zipkin"}}})
+	require.Error(t, err)
+}
@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"simple-go-auth/internal/users/audit"
+	"simple-go-auth/internal/users/outbox"
+	"simple-go-auth/internal/users/rbac"
+	"simple-go-auth/internal/users/webhook"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// webhookReceiver records verified deliveries and can be told to fail.
+type webhookReceiver struct {
+	srv     *httptest.Server
+	mu      sync.Mutex
+	secret  string
+	failing atomic.Bool
+	events  []string
+	headers []http.Header
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	r := &webhookReceiver{}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := outbox.VerifySignature(r.secret, req.Header.Get(outbox.HeaderSignature), body, 5*time.Minute, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var b struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(body, &b)
+		r.events = append(r.events, b.Type)
+		r.headers = append(r.headers, req.Header.Clone())
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func newTestDispatcher(t *testing.T) *webhook.Dispatcher {
+	d := webhook.NewDispatcher(newTestDB(t), zap.NewNop())
+	d.AllowInsecureURLs = true
+	d.AllowPrivateNetworks = true // receivers listen on 127.0.0.1
+	d.BaseBackoff = time.Nanosecond
+	return d
+}
+
+func TestWebhookDeliversSubscribedEventsOnce(t *testing.T) {
+	d := newTestDispatcher(t)
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t)
+
+	_, err := webhook.NewDispatcher(d.DB, zap.NewNop()).Register(ctx, "acme", rcv.srv.URL, []string{"*"})
+	require.ErrorIs(t, err, webhook.ErrInvalidURL) // plain http outside development
+
+	ep, err := d.Register(ctx, "acme", rcv.srv.URL, []string{"signin.", outbox.UserCreated})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(ep.Secret, "whsec_"))
+	rcv.secret = ep.Secret
+
+	d.Tenant = "acme"
+	require.NoError(t, d.Record(ctx, audit.Event{Action: "signin.succeeded", Actor: "alice"}))
+	require.NoError(t, d.Record(ctx, audit.Event{Action: "signout", Actor: "alice"}))
+	msg := outbox.Message{ID: "evt-1", Type: outbox.UserCreated, AggregateID: "1", Data: json.RawMessage(`{"id":1}`)}
+	require.NoError(t, d.Publish(ctx, msg))
+	require.NoError(t, d.Publish(ctx, msg)) // redelivered by the relay
+
+	n, err := d.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"signin.succeeded", outbox.UserCreated}, rcv.events)
+	require.Equal(t, "evt-1", rcv.headers[1].Get(outbox.HeaderID))
+	require.Equal(t, "1", rcv.headers[1].Get(webhook.HeaderAttempt))
+
+	n, err = d.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestWebhookRetriesThenDeadLettersAndReplays(t *testing.T) {
+	d := newTestDispatcher(t)
+	d.MaxAttempts = 3
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t)
+	ep, err := d.Register(ctx, "acme", rcv.srv.URL, []string{"*"})
+	require.NoError(t, err)
+	rcv.secret = ep.Secret
+	rcv.failing.Store(true)
+
+	require.NoError(t, d.Record(ctx, audit.Event{Action: "signup", Actor: "bob", Tenant: "acme"}))
+	for i := 0; i < 5; i++ {
+		_, err := d.DeliverDue(ctx)
+		require.NoError(t, err)
+	}
+
+	dead, err := d.Deliveries(ctx, ep.ID, webhook.StatusDead, 0)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	require.Equal(t, 3, dead[0].Attempts)
+	require.Equal(t, http.StatusServiceUnavailable, dead[0].LastStatusCode)
+
+	health, err := d.Health(ctx, ep.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, health.ConsecutiveFailures)
+	require.EqualValues(t, 1, health.Dead)
+	require.Zero(t, health.SuccessRate)
+
+	rcv.failing.Store(false)
+	requeued, err := d.ReplayDead(ctx, ep.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, requeued)
+	n, err := d.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"signup"}, rcv.events)
+
+	health, err = d.Health(ctx, ep.ID)
+	require.NoError(t, err)
+	require.Zero(t, health.ConsecutiveFailures)
+	require.EqualValues(t, 1, health.Delivered)
+}
+
+func TestWebhookPausedEndpointKeepsQueuedDeliveries(t *testing.T) {
+	d := newTestDispatcher(t)
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t)
+	ep, err := d.Register(ctx, "acme", rcv.srv.URL, []string{"*"})
+	require.NoError(t, err)
+	rcv.secret = ep.Secret
+
+	require.NoError(t, d.Record(ctx, audit.Event{Action: "signup", Actor: "bob", Tenant: "acme"}))
+	require.NoError(t, d.SetActive(ctx, ep.ID, false))
+	n, err := d.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Empty(t, rcv.events)
+
+	pending, err := d.Deliveries(ctx, ep.ID, webhook.StatusPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Zero(t, pending[0].Attempts)
+
+	require.NoError(t, d.SetActive(ctx, ep.ID, true))
+	n, err = d.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"signup"}, rcv.events)
+}
+
+func TestWebhookDeliversOnlyToEventTenant(t *testing.T) {
+	d := newTestDispatcher(t)
+	ctx := context.Background()
+	acme, globex := newWebhookReceiver(t), newWebhookReceiver(t)
+	ep, err := d.Register(ctx, "acme", acme.srv.URL, []string{"*"})
+	require.NoError(t, err)
+	acme.secret = ep.Secret
+	ep, err = d.Register(ctx, "globex", globex.srv.URL, []string{"*"})
+	require.NoError(t, err)
+	globex.secret = ep.Secret
+
+	require.NoError(t, d.Record(ctx, audit.Event{Action: "signin.succeeded", Actor: "alice", Tenant: "acme"}))
+	// No tenant and no d.Tenant: nobody receives it
+	require.NoError(t, d.Record(ctx, audit.Event{Action: "signout", Actor: "alice"}))
+	require.NoError(t, d.Publish(ctx, outbox.Message{ID: "evt-1", Type: outbox.UserCreated, Data: json.RawMessage(`{}`)}))
+
+	n, err := d.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"signin.succeeded"}, acme.events)
+	require.Empty(t, globex.events)
+}
+
+func TestWebhookRefusesPrivateAddressesAndRedirects(t *testing.T) {
+	d := newTestDispatcher(t)
+	ctx := context.Background()
+	var hit atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { hit.Store(true) }))
+	t.Cleanup(target.Close)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+
+	ep, err := d.Register(ctx, "acme", redirect.URL, []string{"*"})
+	require.NoError(t, err)
+	res, err := d.TestFire(ctx, ep.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Error)
+	require.False(t, hit.Load(), "redirect was followed")
+
+	d.AllowPrivateNetworks = false
+	for _, u := range []string{"http://127.0.0.1:8080/hook", "http://10.0.0.5/hook", "http://169.254.169.254/latest", "http://[::1]/hook"} {
+		_, err := d.Register(ctx, "acme", u, []string{"*"})
+		require.ErrorIs(t, err, webhook.ErrInvalidURL, u)
+	}
+
+	// Names are checked on the resolved address
+	ep, err = d.Register(ctx, "acme", strings.Replace(target.URL, "127.0.0.1", "localhost", 1), []string{"*"})
+	require.NoError(t, err)
+	res, err = d.TestFire(ctx, ep.ID)
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "not public")
+	require.False(t, hit.Load())
+}
+
+func TestWebhookSecretRotationSignsWithBoth(t *testing.T) {
+	d := newTestDispatcher(t)
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t)
+	ep, err := d.Register(ctx, "acme", rcv.srv.URL, []string{"*"})
+	require.NoError(t, err)
+
+	secret, err := d.RotateSecret(ctx, ep.ID)
+	require.NoError(t, err)
+	require.NotEqual(t, ep.Secret, secret)
+
+	// A receiver still on the old secret keeps working during the grace period
+	rcv.secret = ep.Secret
+	res, err := d.TestFire(ctx, ep.ID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	rcv.secret = secret
+	res, err = d.TestFire(ctx, ep.ID)
+	require.NoError(t, err)
+	require.Empty(t, res.Error)
+	require.Equal(t, []string{webhook.EventTest, webhook.EventTest}, rcv.events)
+}
+
+func TestWebhookAdminAPIRequiresAdmin(t *testing.T) {
+	svc, _ := newLocalAuthService(t)
+	svc.Webhooks = newTestDispatcher(t)
+	require.NoError(t, svc.SignUp(context.Background(), "bob", "Secret123", "bob@example.com"))
+
+	e := newAuthRouter(svc)
+	grantRole(t, svc, "alice", rbac.RoleAdmin)
+
+	call := func(username, body string) *httptest.ResponseRecorder {
+		tokens, err := svc.SignIn(context.Background(), username, "Secret123")
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"tenant":"acme","url":"https://hooks.example.com/auth","events":["signin."]}`
+	require.Equal(t, http.StatusForbidden, call("bob", body).Code)
+
+	rec := call("alice", body)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.NotEmpty(t, out["secret"])
+	require.Equal(t, []any{"signin."}, out["events"])
+
+	require.Equal(t, http.StatusBadRequest, call("alice", `{"tenant":"acme","url":"ftp://x","events":["*"]}`).Code)
+}